@@ -0,0 +1,297 @@
+// Package aiff reads uncompressed AIFF and AIFF-C files.
+//
+// the samples are big-endian signed integers, or "sowt" little-endian and "fl32" float
+// of AIFF-C. the samples are read as U8, I16, I24 or I32 of the next whole bytes.
+package aiff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/toy80/audio/wav"
+)
+
+var (
+	// ErrFormat indicates the file is not AIFF, or it is compressed
+	ErrFormat = errors.New("aiff: bad or unsupported format")
+
+	// ErrCorrupted indicates the chunks are truncated or missing
+	ErrCorrupted = errors.New("aiff: corrupted data")
+)
+
+// sample encodings of the sound data
+const (
+	encBigEndian = iota
+	encLittleEndian
+	encFloat
+)
+
+// Reader of AIFF file
+type Reader struct {
+	r io.Reader
+
+	channels   int
+	frames     int64 // sample frames of COMM chunk
+	sampleSize int   // bits per sample
+	sampleRate float64
+	enc        int
+	outType    wav.Type
+
+	left int64  // bytes of sound data not read
+	in   []byte // raw samples read but not converted
+}
+
+// NewReader reads the COMM chunk of r, and stops at the sound data. if the SSND chunk
+// comes before COMM, r must be an io.ReadSeeker.
+func NewReader(r io.Reader) (*Reader, error) {
+	var head [12]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, ErrFormat
+	}
+	form := string(head[8:])
+	if string(head[:4]) != "FORM" || form != "AIFF" && form != "AIFC" {
+		return nil, ErrFormat
+	}
+	a := &Reader{r: r}
+	var comm bool
+	var ssnd int64 = -1 // position of sound data if it comes before COMM
+	var ssndLen int64
+	for {
+		var ch [8]byte
+		if _, err := io.ReadFull(r, ch[:]); err != nil {
+			return nil, ErrCorrupted
+		}
+		id, size := string(ch[:4]), int64(binary.BigEndian.Uint32(ch[4:]))
+		switch id {
+		case "COMM":
+			b := make([]byte, size+size&1)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return nil, ErrCorrupted
+			}
+			if err := a.parseComm(b[:size], form == "AIFC"); err != nil {
+				return nil, err
+			}
+			comm = true
+			if ssnd >= 0 {
+				rs := r.(io.ReadSeeker)
+				if _, err := rs.Seek(ssnd, io.SeekStart); err != nil {
+					return nil, err
+				}
+				a.left = ssndLen
+				return a, nil
+			}
+		case "SSND":
+			var b [8]byte
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return nil, ErrCorrupted
+			}
+			offset := int64(binary.BigEndian.Uint32(b[:]))
+			if _, err := io.CopyN(io.Discard, r, offset); err != nil {
+				return nil, ErrCorrupted
+			}
+			n := size - 8 - offset
+			if n < 0 {
+				return nil, ErrCorrupted
+			}
+			if comm {
+				a.left = n
+				return a, nil
+			}
+			rs, ok := r.(io.ReadSeeker)
+			if !ok {
+				return nil, errors.New("aiff: sound data before COMM chunk of unseekable file")
+			}
+			pos, err := rs.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+			ssnd, ssndLen = pos, n
+			if _, err = rs.Seek(n+n&1, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size&1); err != nil {
+				return nil, ErrCorrupted
+			}
+		}
+	}
+}
+
+// Open the AIFF file
+func Open(filename string) (*Reader, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	a, err := NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Reader) parseComm(b []byte, aifc bool) error {
+	if len(b) < 18 {
+		return ErrCorrupted
+	}
+	a.channels = int(binary.BigEndian.Uint16(b))
+	a.frames = int64(binary.BigEndian.Uint32(b[2:]))
+	a.sampleSize = int(binary.BigEndian.Uint16(b[6:]))
+	a.sampleRate = extended(b[8:18])
+	a.enc = encBigEndian
+	if aifc {
+		if len(b) < 22 {
+			return ErrCorrupted
+		}
+		switch string(b[18:22]) {
+		case "NONE", "twos":
+		case "sowt":
+			a.enc = encLittleEndian
+		case "fl32", "FL32":
+			a.enc = encFloat
+			if a.sampleSize != 32 {
+				return fmt.Errorf("aiff: unsupported float bits width %d", a.sampleSize)
+			}
+		default:
+			return ErrFormat // compressed
+		}
+	}
+	if a.channels <= 0 || a.sampleRate <= 0 || a.sampleSize <= 0 || a.sampleSize > 32 {
+		return ErrFormat
+	}
+	switch {
+	case a.enc == encFloat:
+		a.outType = wav.F32
+	case a.sampleSize > 24:
+		a.outType = wav.I32
+	case a.sampleSize > 16:
+		a.outType = wav.I24
+	case a.sampleSize > 8:
+		a.outType = wav.I16
+	default:
+		a.outType = wav.U8
+	}
+	return nil
+}
+
+// extended decodes the 80 bits IEEE 754 extended precision number
+func extended(b []byte) float64 {
+	exp := int(binary.BigEndian.Uint16(b) & 0x7fff)
+	mant := binary.BigEndian.Uint64(b[2:])
+	if exp == 0 && mant == 0 {
+		return 0
+	}
+	f := math.Ldexp(float64(mant), exp-16383-63)
+	if b[0]&0x80 != 0 {
+		f = -f
+	}
+	return f
+}
+
+// Close closes the underlying reader if it is an io.Closer
+func (a *Reader) Close() error {
+	if c, ok := a.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// SampleType reports the output sample type
+func (a *Reader) SampleType() wav.Type {
+	return a.outType
+}
+
+// Frequency reports the sample rate, rounded to integer
+func (a *Reader) Frequency() int {
+	return int(math.Round(a.sampleRate))
+}
+
+// NumTracks reports the channels
+func (a *Reader) NumTracks() int {
+	return a.channels
+}
+
+// NumFrames reports the sample frames of COMM chunk
+func (a *Reader) NumFrames() int64 {
+	return a.frames
+}
+
+// Duration of the audio
+func (a *Reader) Duration() time.Duration {
+	return time.Duration(float64(a.frames) / a.sampleRate * float64(time.Second))
+}
+
+// inBytes reports the bytes of a sample in file, it is padded to whole bytes
+func (a *Reader) inBytes() int {
+	return (a.sampleSize + 7) / 8
+}
+
+func (a *Reader) Read(p []byte) (n int, err error) {
+	in, out := a.inBytes(), a.outType.Bits()/8
+	samples := len(p) / out
+	if samples == 0 {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.ErrShortBuffer
+	}
+	if int64(samples*in) > a.left {
+		samples = int(a.left / int64(in))
+	}
+	if samples == 0 {
+		return 0, io.EOF
+	}
+	if cap(a.in) < samples*in {
+		a.in = make([]byte, samples*in)
+	}
+	buf := a.in[:samples*in]
+	m, err := io.ReadFull(a.r, buf)
+	if err == io.ErrUnexpectedEOF {
+		err = nil // the file is truncated, take the whole samples
+	}
+	a.left -= int64(m)
+	samples = m / in
+	for i := 0; i < samples; i++ {
+		a.convert(p[i*out:], buf[i*in:i*in+in])
+	}
+	if samples == 0 && err == nil {
+		err = io.EOF
+	}
+	return samples * out, err
+}
+
+// convert the sample s to dst of output type
+func (a *Reader) convert(dst, s []byte) {
+	if a.enc == encFloat {
+		binary.LittleEndian.PutUint32(dst, binary.BigEndian.Uint32(s))
+		return
+	}
+	// the sample left justified to 32 bits
+	var x uint32
+	if a.enc == encLittleEndian {
+		for i := len(s) - 1; i >= 0; i-- {
+			x = x<<8 | uint32(s[i])
+		}
+	} else {
+		for _, c := range s {
+			x = x<<8 | uint32(c)
+		}
+	}
+	x <<= 32 - 8*uint(len(s))
+	switch a.outType {
+	case wav.U8:
+		dst[0] = byte(x>>24) ^ 0x80
+	case wav.I16:
+		binary.LittleEndian.PutUint16(dst, uint16(x>>16))
+	case wav.I24:
+		dst[0], dst[1], dst[2] = byte(x>>8), byte(x>>16), byte(x>>24)
+	case wav.I32:
+		binary.LittleEndian.PutUint32(dst, x)
+	}
+}
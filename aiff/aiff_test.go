@@ -0,0 +1,93 @@
+package aiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/toy80/audio/wav"
+)
+
+func chunk(id string, data []byte) []byte {
+	b := append([]byte(id), 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(data)))
+	b = append(b, data...)
+	if len(data)&1 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// comm makes the COMM chunk, the sample rate 44100 is encoded as 80 bits extended
+func comm(channels, frames, bits int, compression string) []byte {
+	b := make([]byte, 18)
+	binary.BigEndian.PutUint16(b, uint16(channels))
+	binary.BigEndian.PutUint32(b[2:], uint32(frames))
+	binary.BigEndian.PutUint16(b[6:], uint16(bits))
+	copy(b[8:], []byte{0x40, 0x0e, 0xac, 0x44})
+	if compression != "" {
+		b = append(b, compression...)
+		b = append(b, 0, 0) // empty pascal string
+	}
+	return chunk("COMM", b)
+}
+
+func ssnd(data []byte) []byte {
+	return chunk("SSND", append(make([]byte, 8), data...))
+}
+
+func form(kind string, chunks ...[]byte) []byte {
+	body := append([]byte(kind), bytes.Join(chunks, nil)...)
+	return chunk("FORM", body)
+}
+
+func TestRead16(t *testing.T) {
+	data := []byte{0x12, 0x34, 0xff, 0xfe, 0x80, 0x00, 0x7f, 0xff}
+	file := form("AIFF", comm(2, 2, 16, ""), chunk("NAME", []byte("abc")), ssnd(data))
+	a, err := NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.SampleType() != wav.I16 || a.Frequency() != 44100 || a.NumTracks() != 2 || a.NumFrames() != 2 {
+		t.Fatalf("%v %d %d %d", a.SampleType(), a.Frequency(), a.NumTracks(), a.NumFrames())
+	}
+	pcm, err := io.ReadAll(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x34, 0x12, 0xfe, 0xff, 0x00, 0x80, 0xff, 0x7f}
+	if !bytes.Equal(pcm, want) {
+		t.Fatalf("%x, want %x", pcm, want)
+	}
+}
+
+func TestReadAIFC(t *testing.T) {
+	// 24 bits little-endian, the sound data before COMM
+	data := []byte{0x00, 0x00, 0x40, 0x00, 0x00, 0xc0}
+	file := form("AIFC", ssnd(data), comm(1, 2, 24, "sowt"))
+	a, err := NewReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.SampleType() != wav.I24 {
+		t.Fatal(a.SampleType())
+	}
+	pcm, err := io.ReadAll(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pcm, data) {
+		t.Fatalf("%x, want %x", pcm, data)
+	}
+
+	// not seekable
+	if _, err = NewReader(io.MultiReader(bytes.NewReader(file))); err == nil {
+		t.Fatal("expect error")
+	}
+	// compressed
+	file = form("AIFC", comm(1, 2, 16, "ima4"), ssnd(data))
+	if _, err = NewReader(bytes.NewReader(file)); err != ErrFormat {
+		t.Fatalf("expect ErrFormat, got %v", err)
+	}
+}
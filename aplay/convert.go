@@ -0,0 +1,109 @@
+package aplay
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/toy80/audio/wav"
+)
+
+// int16Reader converts the samples of types other than U8 and I16, they are not
+// supported by OpenAL
+type int16Reader struct {
+	wav.Reader
+	size  int    // bytes per sample of source
+	buf   []byte // the source samples
+	carry int    // bytes of partial sample at the beginning of buf
+}
+
+// int16ReaderAt is int16Reader of an io.ReaderAt source, it can be played in different
+// players at same time
+type int16ReaderAt struct {
+	*int16Reader
+	ra io.ReaderAt
+}
+
+// toInt16 wraps x to 16bits signed, if x is not U8 or I16
+func toInt16(x wav.Reader) wav.Reader {
+	t := x.SampleType()
+	if t == wav.U8 || t == wav.I16 {
+		return x
+	}
+	c := &int16Reader{Reader: x, size: t.Bits() / 8}
+	if ra, ok := x.(io.ReaderAt); ok {
+		return int16ReaderAt{c, ra}
+	}
+	return c
+}
+
+func (c *int16Reader) SampleType() wav.Type {
+	return wav.I16
+}
+
+func (c *int16Reader) grow(n int) []byte {
+	if cap(c.buf) < n {
+		buf := make([]byte, n)
+		copy(buf, c.buf[:c.carry])
+		c.buf = buf
+	}
+	return c.buf[:n]
+}
+
+// Read converts the samples into p, which must hold a sample at least. the source is
+// read again while it gives less than a sample.
+func (c *int16Reader) Read(p []byte) (int, error) {
+	if len(p) < 2 {
+		return 0, io.ErrShortBuffer
+	}
+	buf := c.grow(len(p) / 2 * c.size)
+	for {
+		m, err := c.Reader.Read(buf[c.carry:])
+		m += c.carry
+		n := c.convert(p, buf[:m])
+		c.carry = copy(buf, buf[n/2*c.size:m])
+		if n != 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+func (c int16ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) < 2 {
+		return 0, io.ErrShortBuffer
+	}
+	buf := c.grow(len(p) / 2 * c.size)
+	m, err := c.ra.ReadAt(buf, off/2*int64(c.size))
+	return c.convert(p, buf[:m]), err
+}
+
+// convert the whole samples of src into dst, reports the bytes of dst
+func (c *int16Reader) convert(dst, src []byte) int {
+	t := c.Reader.SampleType()
+	n := len(src) / c.size
+	for i := 0; i < n; i++ {
+		s := src[i*c.size:]
+		var x int16
+		switch t {
+		case wav.I24:
+			x = int16(binary.LittleEndian.Uint16(s[1:]))
+		case wav.I32:
+			x = int16(binary.LittleEndian.Uint16(s[2:]))
+		case wav.F32:
+			x = floatToInt16(float64(math.Float32frombits(binary.LittleEndian.Uint32(s))))
+		case wav.F64:
+			x = floatToInt16(math.Float64frombits(binary.LittleEndian.Uint64(s)))
+		}
+		binary.LittleEndian.PutUint16(dst[2*i:], uint16(x))
+	}
+	return 2 * n
+}
+
+func floatToInt16(f float64) int16 {
+	if f >= 1 {
+		return math.MaxInt16
+	} else if f <= -1 {
+		return -math.MaxInt16
+	}
+	return int16(math.Round(f * math.MaxInt16))
+}
@@ -0,0 +1,104 @@
+package aplay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/toy80/audio/wav"
+)
+
+func TestToInt16(t *testing.T) {
+	want := []int16{0, 16384, -16384, 32767}
+	for _, typ := range []wav.Type{wav.I24, wav.I32, wav.F32, wav.F64} {
+		size := typ.Bits() / 8
+		data := make([]byte, len(want)*size)
+		for i, v := range want {
+			b := data[i*size:]
+			switch typ {
+			case wav.F32:
+				binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v)/math.MaxInt16))
+			case wav.F64:
+				binary.LittleEndian.PutUint64(b, math.Float64bits(float64(v)/math.MaxInt16))
+			default:
+				binary.LittleEndian.PutUint16(b[size-2:], uint16(v))
+			}
+		}
+		block := wav.NewBlock(data, 1, typ, 8000)
+		x := toInt16(block)
+		if x.SampleType() != wav.I16 {
+			t.Fatal(x.SampleType())
+		}
+		// odd sized reads split the source samples
+		var got []byte
+		buf := make([]byte, 3)
+		for {
+			n, err := x.Read(buf)
+			got = append(got, buf[:n]...)
+			if err == io.EOF {
+				break
+			}
+		}
+		at := make([]byte, 4)
+		if _, err := x.(io.ReaderAt).ReadAt(at, 4); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(at, got[4:]) {
+			t.Fatalf("%s: ReadAt %x, want %x", typ, at, got[4:])
+		}
+		for i, v := range want {
+			if x := int16(binary.LittleEndian.Uint16(got[2*i:])); x != v {
+				t.Fatalf("%s: sample %d is %d, want %d", typ, i, x, v)
+			}
+		}
+	}
+}
+
+// byteReader gives a byte by each Read
+type byteReader struct {
+	wav.Reader
+}
+
+func (r byteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return r.Reader.Read(p)
+}
+
+func TestToInt16ShortRead(t *testing.T) {
+	data := make([]byte, 3*4)
+	for i := range data {
+		data[i] = byte(i * 37)
+	}
+	x := toInt16(wav.NewBlock(data, 1, wav.F32, 8000))
+	if n, err := x.Read(make([]byte, 1)); n != 0 || err != io.ErrShortBuffer {
+		t.Fatalf("%d, %v", n, err)
+	}
+	want, err := io.ReadAll(x)
+	if err != nil || len(want) != 2*3 {
+		t.Fatalf("%d bytes, %v", len(want), err)
+	}
+
+	// every Read makes progress, though the source gives less than a sample
+	x = toInt16(byteReader{wav.NewBlock(data, 1, wav.F32, 8000)})
+	var got []byte
+	buf := make([]byte, 2)
+	for {
+		n, err := x.Read(buf)
+		if n == 0 && err == nil {
+			t.Fatal("no progress")
+		}
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%x, want %x", got, want)
+	}
+}
@@ -31,6 +31,7 @@ type Player interface {
 
 	// Play the sound, if sound implement io.ReadAt interface (i.e. MemSound), it can be played
 	// in difference players at same time. otherwise (i.e. SoundFile) the io.Read interface will be used.
+	// a vorbis.Sound can be played in difference players by giving each player a new cursor.
 	// if the player is playing another sound, the new sound will put in pending state.
 	Play(x wav.Reader, gain float32, loop int) error
 
@@ -347,9 +347,10 @@ func (s *source) SetRelative(b bool) {
 
 func (s *source) Play(x wav.Reader, gain float32, loop int) error {
 	t := x.SampleType()
-	if t != wav.I16 && t != wav.U8 {
+	if t.Bits() == 0 {
 		return fmt.Errorf("unsuppored pcm wave type %s", t)
 	}
+	x = toInt16(x)
 	p := pendingPool.Get().(*pending)
 	p.sound = x
 	p.gain = gain
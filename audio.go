@@ -0,0 +1,207 @@
+// Package audio opens the audio files of any registered format, the format is sniffed by
+// the magic bytes at the beginning of file.
+//
+// the formats of this module are registered: wav, aiff, vorbis, opus, flac and mp3.
+// the Ogg streams are told apart by the identification header in the first page.
+// other codecs can be plugged in by RegisterFormat.
+package audio
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/toy80/audio/aiff"
+	"github.com/toy80/audio/flac"
+	"github.com/toy80/audio/mp3"
+	"github.com/toy80/audio/opus"
+	"github.com/toy80/audio/vorbis"
+	"github.com/toy80/audio/wav"
+)
+
+// ErrFormat indicates the format is not registered
+var ErrFormat = errors.New("audio: unknown format")
+
+// bytes of the magic are matched at most, enough for the identification header of Ogg
+const maxMagic = 64
+
+type format struct {
+	name, magic string
+	decode      func(io.Reader) (wav.Reader, error)
+}
+
+var (
+	formatsMu sync.Mutex
+	formats   []format
+)
+
+// RegisterFormat registers an audio format. name is the name of format, like "wav".
+// magic is the magic bytes at the beginning of file, a "?" matches any byte. decode
+// decodes the stream from the beginning, if the result is an io.Closer, it closes the
+// underlying reader. the formats registered later are matched first.
+func RegisterFormat(name, magic string, decode func(io.Reader) (wav.Reader, error)) {
+	if len(magic) > maxMagic {
+		panic("audio: magic of " + name + " is too long")
+	}
+	formatsMu.Lock()
+	formats = append(formats, format{name, magic, decode})
+	formatsMu.Unlock()
+}
+
+func match(magic string, b []byte) bool {
+	if len(magic) > len(b) {
+		return false
+	}
+	for i, c := range []byte(magic) {
+		if c != '?' && c != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sniff(b []byte) (format, bool) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	for i := len(formats) - 1; i >= 0; i-- {
+		if match(formats[i].magic, b) {
+			return formats[i], true
+		}
+	}
+	return format{}, false
+}
+
+// peek reads the beginning of r for sniffing. if r is an io.ReadSeeker, it is moved
+// back, so the decoder can seek. otherwise r is wrapped by a bufio.Reader.
+func peek(r io.Reader) ([]byte, io.Reader, error) {
+	if rs, ok := r.(io.ReadSeeker); ok {
+		if pos, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			b := make([]byte, maxMagic)
+			n, err := io.ReadFull(rs, b)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return nil, nil, err
+			}
+			if _, err = rs.Seek(pos, io.SeekStart); err != nil {
+				return nil, nil, err
+			}
+			return b[:n], r, nil
+		}
+	}
+	br := bufio.NewReader(r)
+	b, err := br.Peek(maxMagic)
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	return b, br, nil
+}
+
+// Format reports the name of the format of r, which is not consumed if it is an
+// io.ReadSeeker. it is "" if the format is not registered.
+func Format(r io.Reader) (string, error) {
+	b, _, err := peek(r)
+	if err != nil {
+		return "", err
+	}
+	f, _ := sniff(b)
+	return f.name, nil
+}
+
+type nopCloser struct {
+	wav.Reader
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// readCloser closes c after the decoder, c may be closed by the decoder already
+type readCloser struct {
+	wav.ReadCloser
+	c io.Closer
+}
+
+func (x readCloser) Close() error {
+	err := x.ReadCloser.Close()
+	if err2 := x.c.Close(); err == nil && !errors.Is(err2, os.ErrClosed) {
+		err = err2
+	}
+	return err
+}
+
+// NewReader decodes r of any registered format. the result closes r if r is an
+// io.Closer.
+func NewReader(r io.Reader) (wav.ReadCloser, error) {
+	b, br, err := peek(r)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := sniff(b)
+	if !ok {
+		return nil, ErrFormat
+	}
+	x, err := f.decode(br)
+	if err != nil {
+		return nil, err
+	}
+	rc, ok := x.(wav.ReadCloser)
+	if !ok {
+		rc = nopCloser{x}
+	}
+	if c, ok := r.(io.Closer); ok && br != r {
+		// the decoder has the bufio.Reader, which does not close r
+		return readCloser{rc, c}, nil
+	}
+	return rc, nil
+}
+
+// Open the audio file of any registered format
+func Open(filename string) (wav.ReadCloser, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	x, err := NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return readCloser{x, f}, nil
+}
+
+// the first page of Ogg stream has one segment, it is the identification header
+const oggFirstPage = "OggS??????????????????????\x01?"
+
+func init() {
+	wavDecode := func(r io.Reader) (wav.Reader, error) {
+		return wav.NewReader(r)
+	}
+	RegisterFormat("wav", "RIFF????WAVE", wavDecode)
+	RegisterFormat("wav", "RF64????WAVE", wavDecode)
+	RegisterFormat("wav", "BW64????WAVE", wavDecode)
+	aiffDecode := func(r io.Reader) (wav.Reader, error) {
+		return aiff.NewReader(r)
+	}
+	RegisterFormat("aiff", "FORM????AIFF", aiffDecode)
+	RegisterFormat("aiff", "FORM????AIFC", aiffDecode)
+	RegisterFormat("vorbis", oggFirstPage+"\x01vorbis", func(r io.Reader) (wav.Reader, error) {
+		return vorbis.New(r, wav.I16)
+	})
+	RegisterFormat("opus", oggFirstPage+"OpusHead", func(r io.Reader) (wav.Reader, error) {
+		return opus.New(r, wav.I16)
+	})
+	flacDecode := func(r io.Reader) (wav.Reader, error) {
+		return flac.NewLossless(r)
+	}
+	RegisterFormat("flac", "fLaC", flacDecode)
+	RegisterFormat("flac", oggFirstPage+"\x7fFLAC", flacDecode)
+	mp3Decode := func(r io.Reader) (wav.Reader, error) {
+		return mp3.New(r, wav.I16)
+	}
+	RegisterFormat("mp3", "ID3", mp3Decode)
+	// the frame sync of MPEG-1, 2 and 2.5 Layer III, with and without CRC
+	for _, sync := range []string{"\xff\xfb", "\xff\xfa", "\xff\xf3", "\xff\xf2", "\xff\xe3", "\xff\xe2"} {
+		RegisterFormat("mp3", sync, mp3Decode)
+	}
+}
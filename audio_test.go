@@ -0,0 +1,148 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toy80/audio/wav"
+)
+
+func oggPage(id string) []byte {
+	b := append([]byte("OggS\x00\x02"), make([]byte, 20)...)
+	b = append(b, 1, byte(len(id)))
+	return append(b, id...)
+}
+
+func TestFormat(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt ")},
+		{"wav", []byte("RF64\xff\xff\xff\xffWAVEds64")},
+		{"aiff", []byte("FORM\x00\x00\x00\x20AIFCFVER")},
+		{"vorbis", oggPage("\x01vorbis\x00\x00\x00\x00")},
+		{"opus", oggPage("OpusHead\x01\x02")},
+		{"flac", oggPage("\x7fFLAC\x01\x00")},
+		{"flac", []byte("fLaC\x00\x00\x00\x22")},
+		{"mp3", []byte("ID3\x04\x00\x00")},
+		{"mp3", []byte{0xff, 0xfb, 0x90, 0x40}},
+		{"", oggPage("\x80theora")},
+		{"", []byte("MThd")},
+		{"", nil},
+	} {
+		name, err := Format(bytes.NewReader(c.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != c.name {
+			t.Fatalf("%q: format %q, want %q", c.data, name, c.name)
+		}
+	}
+}
+
+type closeReader struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestNewReader(t *testing.T) {
+	pcm := []byte{1, 0, 2, 0, 3, 0, 4, 0}
+	var file bytes.Buffer
+	if err := wav.Write(&file, wav.NewBlock(pcm, 2, wav.I16, 22050)); err != nil {
+		t.Fatal(err)
+	}
+	// not seekable
+	rc := &closeReader{Reader: io.MultiReader(bytes.NewReader(file.Bytes()))}
+	x, err := NewReader(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, pcm) || x.Frequency() != 22050 || x.NumTracks() != 2 {
+		t.Fatalf("%x %d %d", b, x.Frequency(), x.NumTracks())
+	}
+	if err = x.Close(); err != nil || !rc.closed {
+		t.Fatalf("closed %v, %v", rc.closed, err)
+	}
+
+	if _, err = NewReader(strings.NewReader("unknown")); err != ErrFormat {
+		t.Fatalf("expect ErrFormat, got %v", err)
+	}
+}
+
+type rawReader struct {
+	io.Reader
+}
+
+func (rawReader) SampleType() wav.Type    { return wav.U8 }
+func (rawReader) Frequency() int          { return 8000 }
+func (rawReader) NumTracks() int          { return 1 }
+func (rawReader) Duration() time.Duration { return 0 }
+
+func TestRegisterFormat(t *testing.T) {
+	RegisterFormat("raw", "RAW?", func(r io.Reader) (wav.Reader, error) {
+		var head [4]byte
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return nil, err
+		}
+		return rawReader{r}, nil
+	})
+	name := filepath.Join(t.TempDir(), "test.raw")
+	if err := os.WriteFile(name, []byte("RAW1\x80\x81"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	x, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "\x80\x81" || x.Frequency() != 8000 {
+		t.Fatalf("%q", b)
+	}
+	if err = x.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenAIFF(t *testing.T) {
+	comm := []byte("COMM\x00\x00\x00\x12\x00\x01\x00\x00\x00\x02\x00\x10\x40\x0e\xac\x44\x00\x00\x00\x00\x00\x00")
+	ssnd := []byte("SSND\x00\x00\x00\x0c\x00\x00\x00\x00\x00\x00\x00\x00\x12\x34\x56\x78")
+	body := append(append([]byte("AIFF"), comm...), ssnd...)
+	file := append([]byte("FORM\x00\x00\x00\x00"), body...)
+	binary.BigEndian.PutUint32(file[4:], uint32(len(body)))
+
+	name := filepath.Join(t.TempDir(), "test.aif")
+	if err := os.WriteFile(name, file, 0666); err != nil {
+		t.Fatal(err)
+	}
+	x, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Close()
+	b, err := io.ReadAll(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, []byte{0x34, 0x12, 0x78, 0x56}) || x.SampleType() != wav.I16 || x.Frequency() != 44100 {
+		t.Fatalf("%x %v %d", b, x.SampleType(), x.Frequency())
+	}
+}
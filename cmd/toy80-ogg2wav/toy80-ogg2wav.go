@@ -13,19 +13,40 @@ import (
 )
 
 var cpuprofile = flag.String("p", "", "write cpu profile to file")
+var workers = flag.Int("j", 1, "number of decoding workers, 0 for all CPUs")
 
 func convert(name string) {
 	fmt.Println("read", name)
 
-	f, err := vorbis.Open(name)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	var wave wav.Reader
+	if *workers == 1 {
+		f, err := vorbis.Open(name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		wave = f
+	} else {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if wave, err = vorbis.DecodeParallel(f, fi.Size(), *workers); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 	}
-	defer f.Close()
 
 	fmt.Println("write", name+".wav")
-	if err = wav.WriteFile(name+".wav", f); err != nil {
+	if err := wav.WriteFile(name+".wav", wave); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -35,7 +56,7 @@ func convert(name string) {
 func usage() {
 	name := filepath.Base(os.Args[0])
 	fmt.Fprintf(os.Stderr, "Usage of %s:\n\n", name)
-	fmt.Fprintf(os.Stderr, "  %s [-p pprof.out] foo.ogg bar.ogg other.ogg ...\n\n", name)
+	fmt.Fprintf(os.Stderr, "  %s [-p pprof.out] [-j workers] foo.ogg bar.ogg other.ogg ...\n\n", name)
 	flag.PrintDefaults()
 	fmt.Fprintf(os.Stderr, "\n")
 }
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/toy80/audio/vorbis/conformance"
+)
+
+var tolerance = flag.Float64("t", conformance.DefaultTolerance, "max absolute error of a sample")
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n\n", name)
+	fmt.Fprintf(os.Stderr, "  %s [-t tolerance] corpus_dir foo.ogg ...\n\n", name)
+	fmt.Fprintf(os.Stderr, "the reference PCM of foo.ogg is foo%s, raw little-endian float32 interleaved.\n\n", conformance.RefExt)
+	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\n")
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	var results []conformance.Result
+	for _, name := range flag.Args() {
+		if fi, err := os.Stat(name); err == nil && fi.IsDir() {
+			x, err := conformance.CheckDir(name, *tolerance)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			results = append(results, x...)
+		} else {
+			ref := strings.TrimSuffix(name, filepath.Ext(name)) + conformance.RefExt
+			results = append(results, conformance.CheckFile(name, ref, *tolerance))
+		}
+	}
+
+	failed := 0
+	channels := make(map[int]int)
+	for i := range results {
+		r := &results[i]
+		fmt.Println(r)
+		if !r.Pass() {
+			failed++
+		}
+		if r.Err == nil {
+			channels[r.Channels]++
+		}
+	}
+
+	// coverage of channel counts, the corpus should have 1 to 8 channels at least
+	var keys []int
+	for k := range channels {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	fmt.Print("channels:")
+	for _, k := range keys {
+		fmt.Printf(" %dch=%d", k, channels[k])
+	}
+	fmt.Println()
+
+	fmt.Printf("%d files, %d passed, %d failed.\n", len(results), len(results)-failed, failed)
+	if failed != 0 {
+		os.Exit(1)
+	}
+}
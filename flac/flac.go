@@ -0,0 +1,436 @@
+// Package flac is a FLAC decoder in pure golang, RFC 9639. it decodes the native .flac
+// files and FLAC in Ogg, all bit depths of 4 to 32 bits, the fixed and variable blocking
+// strategies. the stream can be located by the SEEKTABLE.
+package flac
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/toy80/audio/ogg"
+	"github.com/toy80/audio/wav"
+)
+
+var (
+	// ErrFormat indicates the stream is not FLAC
+	ErrFormat = errors.New("flac: not a valid flac stream")
+
+	// ErrRandomAccess indicates the underlying reader is not seekable
+	ErrRandomAccess = errors.New("flac: not random accessible")
+)
+
+// the frames of block size larger than STREAMINFO are rejected
+var errBlockSize = errors.New("flac: block size exceeds STREAMINFO")
+
+// Flac decoder
+type Flac struct {
+	r      io.Reader
+	br     *bufio.Reader
+	bits   bitReader
+	seeker io.ReadSeeker // nil if not seekable
+	begin  int64         // position of the stream in seeker
+
+	isOgg       bool
+	dmx         ogg.Demuxer
+	inbuf       []byte
+	pkt         bytes.Reader
+	numHeaders  int   // header packets of ogg flac
+	audioOffset int64 // bytes before the first frame of native stream
+
+	info      StreamInfo
+	hasInfo   bool
+	seekTable []SeekPoint
+	vendor    string
+	comments  map[string]string
+
+	outType wav.Type
+	samples [][]int64 // of channels
+	pos     int64     // sample number after the frame decoded last
+	target  int64     // the samples before it are discarded, set by SeekFrame
+	out     []byte
+	outBuf  []byte // the part of out not read yet
+	fault   error
+}
+
+// New flac decoder of native or ogg flac stream. t is the output sample type, the samples
+// are scaled to its range.
+func New(r io.Reader, t wav.Type) (*Flac, error) {
+	if t.Bits() == 0 {
+		return nil, fmt.Errorf("flac: unsupported target PCM format %s", t)
+	}
+	fl, err := newFlac(r)
+	if err != nil {
+		return nil, err
+	}
+	fl.setType(t)
+	return fl, nil
+}
+
+// NewLossless creates flac decoder of the lossless sample type of STREAMINFO, see
+// SampleType
+func NewLossless(r io.Reader) (*Flac, error) {
+	fl, err := newFlac(r)
+	if err != nil {
+		return nil, err
+	}
+	fl.setType(SampleType(fl.info.BitsPerSample))
+	return fl, nil
+}
+
+// newFlac reads the headers of stream, the output type is not set
+func newFlac(r io.Reader) (*Flac, error) {
+	fl := &Flac{r: r}
+	if rs, ok := r.(io.ReadSeeker); ok {
+		if begin, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			fl.seeker, fl.begin = rs, begin
+		}
+	}
+	fl.br = bufio.NewReader(r)
+	magic, err := fl.br.Peek(4)
+	if err != nil {
+		return nil, ErrFormat
+	}
+	switch string(magic) {
+	case "fLaC":
+		fl.br.Discard(4)
+		n, err := fl.readMetadata(fl.br)
+		if err != nil {
+			return nil, err
+		}
+		fl.audioOffset = 4 + n
+		fl.bits.reset(fl.br)
+	case "OggS":
+		fl.isOgg = true
+		fl.inbuf = make([]byte, 16<<10)
+		if err := fl.readOggHeaders(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrFormat
+	}
+	if !fl.hasInfo {
+		return nil, ErrFormat
+	}
+	return fl, nil
+}
+
+// setType sets the output sample type, and allocates the buffers
+func (fl *Flac) setType(t wav.Type) {
+	channels, size := fl.info.Channels, fl.info.MaxBlockSize
+	fl.outType = t
+	fl.samples = make([][]int64, channels)
+	for ch := range fl.samples {
+		fl.samples[ch] = make([]int64, size)
+	}
+	fl.out = make([]byte, channels*size*t.Bits()/8)
+}
+
+// SampleType reports the lossless output type of the bits per sample, 16, 24 or 32bits
+// signed
+func SampleType(bitsPerSample int) wav.Type {
+	switch {
+	case bitsPerSample <= 16:
+		return wav.I16
+	case bitsPerSample <= 24:
+		return wav.I24
+	default:
+		return wav.I32
+	}
+}
+
+// Open the flac file, the output is the lossless sample type, see SampleType
+func Open(filename string) (*Flac, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	fl, err := NewLossless(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return fl, nil
+}
+
+// Close closes the underlying reader if it is an io.Closer
+func (fl *Flac) Close() error {
+	if c, ok := fl.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Info reports the STREAMINFO
+func (fl *Flac) Info() StreamInfo {
+	return fl.info
+}
+
+// SeekTable reports the points of SEEKTABLE, the placeholders are excluded
+func (fl *Flac) SeekTable() []SeekPoint {
+	return append([]SeekPoint(nil), fl.seekTable...)
+}
+
+// Vendor reports the vendor string of VORBIS_COMMENT
+func (fl *Flac) Vendor() string {
+	return fl.vendor
+}
+
+// Comments reports the user comments of VORBIS_COMMENT
+func (fl *Flac) Comments() map[string]string {
+	return fl.comments
+}
+
+// Comment reports the user comment of name
+func (fl *Flac) Comment(name string) string {
+	return fl.comments[name]
+}
+
+// SampleType reports the output sample type
+func (fl *Flac) SampleType() wav.Type {
+	return fl.outType
+}
+
+// Frequency reports the sample rate
+func (fl *Flac) Frequency() int {
+	return fl.info.SampleRate
+}
+
+// NumTracks reports the channels
+func (fl *Flac) NumTracks() int {
+	return fl.info.Channels
+}
+
+// NumFrames reports the samples of each channel, 0 if unknown
+func (fl *Flac) NumFrames() int64 {
+	return fl.info.TotalSamples
+}
+
+// Duration of the stream, 0 if unknown
+func (fl *Flac) Duration() time.Duration {
+	return time.Second * time.Duration(fl.info.TotalSamples) / time.Duration(fl.info.SampleRate)
+}
+
+// Position reports the frame position of the next Read
+func (fl *Flac) Position() int64 {
+	size := int64(fl.outType.Bits() / 8 * fl.info.Channels)
+	if p := fl.pos - int64(len(fl.outBuf))/size; p > fl.target {
+		return p
+	}
+	return fl.target
+}
+
+func (fl *Flac) Read(buf []byte) (n int, err error) {
+	for len(buf) != 0 {
+		if len(fl.outBuf) != 0 {
+			n1 := copy(buf, fl.outBuf)
+			buf = buf[n1:]
+			fl.outBuf = fl.outBuf[n1:]
+			n += n1
+			continue
+		}
+		if fl.fault != nil {
+			return n, fl.fault
+		}
+		if err = fl.decodeFrame(); err != nil {
+			fl.fault = err
+			if n != 0 {
+				err = nil
+			}
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// SeekFrame moves to the frame position, the following Read starts from it. the native
+// stream is located by the nearest point of SEEKTABLE, then decoded forward to the frame.
+func (fl *Flac) SeekFrame(frame int64) error {
+	if fl.seeker == nil {
+		return ErrRandomAccess
+	}
+	if frame < 0 || fl.info.TotalSamples > 0 && frame > fl.info.TotalSamples {
+		return fmt.Errorf("flac: seek to frame %d out of stream", frame)
+	}
+	var offset, sample int64
+	if !fl.isOgg {
+		offset = fl.audioOffset
+		for _, p := range fl.seekTable {
+			if p.Sample <= frame && p.Sample >= sample {
+				offset, sample = fl.audioOffset+p.Offset, p.Sample
+			}
+		}
+	}
+	if _, err := fl.seeker.Seek(fl.begin+offset, io.SeekStart); err != nil {
+		return err
+	}
+	fl.br.Reset(fl.seeker)
+	fl.bits.reset(fl.br)
+	fl.pos, fl.target = sample, frame
+	fl.outBuf, fl.fault = nil, nil
+	if fl.isOgg {
+		fl.dmx = ogg.Demuxer{}
+		for i := 0; i < fl.numHeaders; i++ {
+			if _, err := fl.nextPacket(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readOggHeaders reads the header packets of ogg flac, the first one has the STREAMINFO,
+// and the others are metadata blocks
+func (fl *Flac) readOggHeaders() error {
+	p, err := fl.nextPacket()
+	if err != nil {
+		return err
+	}
+	b := p.Data
+	if len(b) < 13 || string(b[:5]) != "\x7fFLAC" || b[5] != 1 || string(b[9:13]) != "fLaC" {
+		return ErrFormat
+	}
+	fl.numHeaders = 1
+	for b = b[13:]; ; {
+		if len(b) < 4 {
+			return ErrFormat
+		}
+		last := b[0]&0x80 != 0
+		size := int(b[1])<<16 | int(b[2])<<8 | int(b[3])
+		if len(b) < 4+size {
+			return ErrFormat
+		}
+		if err = fl.parseMetadata(int(b[0]&0x7f), b[4:4+size]); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		if p, err = fl.nextPacket(); err != nil {
+			return err
+		}
+		fl.numHeaders++
+		b = p.Data
+	}
+}
+
+// nextPacket reads the next complete packet of ogg flac, io.EOF at the end of stream
+func (fl *Flac) nextPacket() (ogg.Packet, error) {
+	for {
+		if p, ok := fl.dmx.Packet(); ok {
+			return p, nil
+		}
+		if fl.dmx.EndOfStream() {
+			return ogg.Packet{}, io.EOF
+		}
+		n, err := fl.br.Read(fl.inbuf)
+		if n != 0 {
+			if _, err := fl.dmx.Write(fl.inbuf[:n]); err != nil {
+				return ogg.Packet{}, err
+			}
+			continue
+		}
+		if err == io.EOF {
+			return ogg.Packet{}, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return ogg.Packet{}, err
+		}
+	}
+}
+
+// decodeFrame decodes the next frame into outBuf, it may be empty
+func (fl *Flac) decodeFrame() error {
+	if fl.isOgg {
+		p, err := fl.nextPacket()
+		if err != nil {
+			return err
+		}
+		fl.pkt.Reset(p.Data)
+		fl.bits.reset(&fl.pkt)
+	} else if fl.info.TotalSamples > 0 && fl.pos >= fl.info.TotalSamples {
+		return io.EOF // ignore the data after the last frame, i.e. ID3v1 tag
+	}
+	var h frameHeader
+	if err := fl.bits.readFrameHeader(&h); err != nil {
+		return err
+	}
+	if h.blockSize > fl.info.MaxBlockSize {
+		return errBlockSize
+	}
+	if h.channels != fl.info.Channels || h.bps != 0 && h.bps != fl.info.BitsPerSample {
+		return fmt.Errorf("flac: frame of %d channels %d bits in stream of %d channels %d bits",
+			h.channels, h.bps, fl.info.Channels, fl.info.BitsPerSample)
+	}
+	if err := fl.bits.readFrame(&h, fl.info.BitsPerSample, fl.samples); err != nil {
+		return err
+	}
+
+	start := int64(h.number)
+	if !h.variable {
+		start *= int64(fl.info.MaxBlockSize)
+	}
+	fl.pos = start + int64(h.blockSize)
+	begin := 0
+	if fl.target > start {
+		begin = int(minInt64(fl.target-start, int64(h.blockSize)))
+	}
+	fl.output(begin, h.blockSize)
+	return nil
+}
+
+// output converts the samples [begin, end) of channels into outBuf
+func (fl *Flac) output(begin, end int) {
+	channels, bps := fl.info.Channels, fl.info.BitsPerSample
+	size := fl.outType.Bits() / 8
+	b := fl.out[:(end-begin)*channels*size]
+	scale := 1 / float32(math.Ldexp(1, bps-1))
+	k := 0
+	for i := begin; i < end; i++ {
+		for ch := 0; ch < channels; ch++ {
+			x := fl.samples[ch][i]
+			switch fl.outType {
+			case wav.U8:
+				b[k] = byte(rescale(x, bps, 8) + 128)
+			case wav.I16:
+				v := uint16(rescale(x, bps, 16))
+				b[k], b[k+1] = byte(v), byte(v>>8)
+			case wav.I24:
+				v := rescale(x, bps, 24)
+				b[k], b[k+1], b[k+2] = byte(v), byte(v>>8), byte(v>>16)
+			case wav.I32:
+				v := rescale(x, bps, 32)
+				b[k], b[k+1], b[k+2], b[k+3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+			case wav.F32:
+				v := math.Float32bits(float32(x) * scale)
+				b[k], b[k+1], b[k+2], b[k+3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+			case wav.F64:
+				v := math.Float64bits(math.Ldexp(float64(x), 1-bps))
+				for j := 0; j < 8; j++ {
+					b[k+j] = byte(v >> (8 * j))
+				}
+			}
+			k += size
+		}
+	}
+	fl.outBuf = b
+}
+
+// rescale the sample of bits from to bits to
+func rescale(x int64, from, to int) int64 {
+	if from > to {
+		return x >> uint(from-to)
+	}
+	return x << uint(to-from)
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,578 @@
+package flac
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/toy80/audio/ogg"
+	"github.com/toy80/audio/wav"
+)
+
+// bitWriter writes bits msb first, for the test encoder
+type bitWriter struct {
+	buf []byte
+	n   uint // bits in the last byte
+}
+
+func (w *bitWriter) write(x uint64, n uint) {
+	for i := int(n) - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if x>>uint(i)&1 != 0 {
+			w.buf[len(w.buf)-1] |= 0x80 >> (w.n % 8)
+		}
+		w.n++
+	}
+}
+
+func (w *bitWriter) writeSigned(x int64, n uint) {
+	w.write(uint64(x)&(1<<n-1), n)
+}
+
+func (w *bitWriter) align() {
+	for w.n%8 != 0 {
+		w.write(0, 1)
+	}
+}
+
+// subframe kinds of the test encoder
+const (
+	encConstant = iota
+	encVerbatim
+	encFixed
+	encLPC
+	encEscape
+)
+
+// encodeSubframe writes x of bps bits in the kind of subframe
+func encodeSubframe(w *bitWriter, x []int64, bps uint, kind, order int) {
+	w.write(0, 1)
+	constant := true
+	for _, v := range x {
+		constant = constant && v == x[0]
+	}
+	if kind == encConstant && !constant {
+		kind = encFixed
+	}
+	switch kind {
+	case encConstant:
+		w.write(0, 6)
+		w.write(0, 1)
+		w.writeSigned(x[0], bps)
+		return
+	case encVerbatim:
+		var all int64
+		for _, v := range x {
+			all |= v
+		}
+		wasted := uint(0)
+		for all != 0 && all&1 == 0 {
+			all >>= 1
+			wasted++
+		}
+		w.write(1, 6)
+		if wasted == 0 {
+			w.write(0, 1)
+		} else {
+			w.write(1, 1)
+			w.write(1, wasted) // unary of wasted-1
+		}
+		for _, v := range x {
+			w.writeSigned(v>>wasted, bps-wasted)
+		}
+		return
+	}
+
+	res := make([]int64, len(x))
+	copy(res, x)
+	if kind == encLPC {
+		// order 2 predictor of 2*x[i-1] - x[i-2], the coefficients are scaled by 2 and shifted back
+		coefs := []int64{4, -2}
+		w.write(uint64(32+len(coefs)-1), 6)
+		w.write(0, 1)
+		for _, v := range x[:2] {
+			w.writeSigned(v, bps)
+		}
+		w.write(4-1, 4) // precision
+		w.writeSigned(1, 5)
+		for _, c := range coefs {
+			w.writeSigned(c, 4)
+		}
+		for i := 2; i < len(x); i++ {
+			res[i] = x[i] - (coefs[0]*x[i-1]+coefs[1]*x[i-2])>>1
+		}
+		order = 2
+	} else {
+		w.write(uint64(8+order), 6)
+		w.write(0, 1)
+		for _, v := range x[:order] {
+			w.writeSigned(v, bps)
+		}
+		for k := 0; k < order; k++ {
+			// the residual of order k+1 is the difference of order k
+			for i := len(res) - 1; i > k; i-- {
+				res[i] -= res[i-1]
+			}
+		}
+	}
+
+	// rice of 4 partitions, the last partition is escaped for kind encEscape
+	const partOrder = 2
+	w.write(0, 2)
+	w.write(partOrder, 4)
+	size := len(x) >> partOrder
+	for p := 0; p < 1<<partOrder; p++ {
+		begin, end := p*size, (p+1)*size
+		if p == 0 {
+			begin = order
+		}
+		part := res[begin:end]
+		if kind == encEscape && p == 1<<partOrder-1 {
+			n := uint(1)
+			for _, v := range part {
+				for v < -1<<(n-1) || v >= 1<<(n-1) {
+					n++
+				}
+			}
+			w.write(15, 4)
+			w.write(uint64(n), 5)
+			for _, v := range part {
+				w.writeSigned(v, n)
+			}
+			continue
+		}
+		var sum uint64
+		for _, v := range part {
+			sum += uint64(v<<1 ^ v>>63)
+		}
+		k := uint(0)
+		for len(part) > 0 && k < 14 && uint64(len(part))<<(k+1) < sum {
+			k++
+		}
+		w.write(uint64(k), 4)
+		for _, v := range part {
+			u := uint64(v<<1 ^ v>>63)
+			for q := u >> k; q > 0; q-- {
+				w.write(0, 1)
+			}
+			w.write(1, 1)
+			w.write(u, k)
+		}
+	}
+}
+
+// encodeFrame writes a frame of the channels, stereo is the channel assignment
+func encodeFrame(x [][]int64, bps uint, number uint64, variable bool, stereo int, kinds []int) []byte {
+	var w bitWriter
+	n := len(x[0])
+	w.write(0x7ffc, 15)
+	if variable {
+		w.write(1, 1)
+	} else {
+		w.write(0, 1)
+	}
+	w.write(7, 4) // 16 bits block size at the end
+	w.write(0, 4) // sample rate of STREAMINFO
+	if stereo != 0 {
+		w.write(uint64(stereo), 4)
+	} else {
+		w.write(uint64(len(x)-1), 4)
+	}
+	w.write(0, 3) // sample size of STREAMINFO
+	w.write(0, 1)
+	// utf-8 like number
+	switch {
+	case number < 0x80:
+		w.write(number, 8)
+	case number < 0x800:
+		w.write(0xc0|number>>6, 8)
+		w.write(0x80|number&0x3f, 8)
+	default:
+		w.write(0xe0|number>>12, 8)
+		w.write(0x80|number>>6&0x3f, 8)
+		w.write(0x80|number&0x3f, 8)
+	}
+	w.write(uint64(n-1), 16)
+	w.write(uint64(crc8(w.buf)), 8)
+
+	chs := x
+	side := -1
+	if stereo != 0 {
+		l, r := x[0], x[1]
+		a, b := make([]int64, n), make([]int64, n)
+		for i := range a {
+			switch stereo {
+			case 8:
+				a[i], b[i] = l[i], l[i]-r[i]
+			case 9:
+				a[i], b[i] = l[i]-r[i], r[i]
+			case 10:
+				a[i], b[i] = (l[i]+r[i])>>1, l[i]-r[i]
+			}
+		}
+		chs = [][]int64{a, b}
+		side = 1
+		if stereo == 9 {
+			side = 0
+		}
+	}
+	for ch, v := range chs {
+		b := bps
+		if ch == side {
+			b++
+		}
+		kind := kinds[ch%len(kinds)]
+		encodeSubframe(&w, v, b, kind, (int(number)+ch)%5)
+	}
+	w.align()
+	crc := crc16(w.buf)
+	w.write(uint64(crc), 16)
+	return w.buf
+}
+
+func crc8(b []byte) (c uint8) {
+	for _, x := range b {
+		c = crc8Table[c^x]
+	}
+	return
+}
+
+func crc16(b []byte) (c uint16) {
+	for _, x := range b {
+		c = c<<8 ^ crc16Table[byte(c>>8)^x]
+	}
+	return
+}
+
+func metadataBlock(typ int, last bool, data []byte) []byte {
+	b := []byte{byte(typ), byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data))}
+	if last {
+		b[0] |= 0x80
+	}
+	return append(b, data...)
+}
+
+// testStream is the samples and frames of the test encoder
+type testStream struct {
+	bps      uint
+	rate     int
+	samples  [][]int64
+	frames   [][]byte
+	starts   []int64 // the first sample of frames
+	info     []byte  // STREAMINFO
+	comments []byte  // VORBIS_COMMENT
+}
+
+func makeTestStream(channels int, bps uint, total int, variable bool) *testStream {
+	rnd := rand.New(rand.NewSource(int64(bps)))
+	s := &testStream{bps: bps, rate: 44100}
+	amp := float64(int64(1)<<(bps-1)) * 0.7
+	for ch := 0; ch < channels; ch++ {
+		x := make([]int64, total)
+		for i := range x {
+			if i < 1000 {
+				continue // silence for constant subframe
+			}
+			v := amp*math.Sin(float64(i*(ch+1))*0.01) + rnd.Float64()*amp*0.05
+			x[i] = int64(v)
+			if ch == 0 {
+				x[i] &^= 3 // wasted bits
+			}
+		}
+		s.samples = append(s.samples, x)
+	}
+
+	blockSize := 1000
+	kinds := [][]int{{encConstant}, {encVerbatim, encFixed}, {encFixed, encLPC}, {encEscape, encFixed}}
+	for start, k := 0, 0; start < total; k++ {
+		n := blockSize
+		if variable {
+			n = 700 + 300*(k%3)
+		}
+		if start+n > total {
+			n = total - start
+		}
+		x := make([][]int64, channels)
+		for ch := range x {
+			x[ch] = s.samples[ch][start : start+n]
+		}
+		stereo := 0
+		if channels == 2 {
+			stereo = [4]int{0, 8, 9, 10}[k%4]
+		}
+		number := uint64(k)
+		if variable {
+			number = uint64(start)
+		}
+		s.frames = append(s.frames, encodeFrame(x, bps, number, variable, stereo, kinds[k%len(kinds)]))
+		s.starts = append(s.starts, int64(start))
+		start += n
+	}
+
+	var info [34]byte
+	maxBlock := blockSize
+	if variable {
+		maxBlock = 1300
+	}
+	binary.BigEndian.PutUint16(info[0:], uint16(blockSize))
+	binary.BigEndian.PutUint16(info[2:], uint16(maxBlock))
+	binary.BigEndian.PutUint64(info[10:], uint64(s.rate)<<44|uint64(channels-1)<<41|uint64(bps-1)<<36|uint64(total))
+	s.info = info[:]
+	s.comments = []byte("\x04\x00\x00\x00test\x02\x00\x00\x00\x0b\x00\x00\x00ARTIST=some\x0b\x00\x00\x00ARTIST=body")
+	return s
+}
+
+// native writes the native flac stream, a seek point for every 3 frames
+func (s *testStream) native() []byte {
+	var table []byte
+	var offset int64
+	for i, f := range s.frames {
+		if i%3 == 0 {
+			var p [18]byte
+			binary.BigEndian.PutUint64(p[0:], uint64(s.starts[i]))
+			binary.BigEndian.PutUint64(p[8:], uint64(offset))
+			binary.BigEndian.PutUint16(p[16:], uint16(len(s.samples[0])))
+			table = append(table, p[:]...)
+		}
+		offset += int64(len(f))
+	}
+	placeholder := bytes.Repeat([]byte{0xff}, 8)
+	table = append(table, append(placeholder, make([]byte, 10)...)...)
+
+	b := []byte("fLaC")
+	b = append(b, metadataBlock(blockStreamInfo, false, s.info)...)
+	b = append(b, metadataBlock(blockSeekTable, false, table)...)
+	b = append(b, metadataBlock(blockVorbisComment, false, s.comments)...)
+	b = append(b, metadataBlock(blockPadding, true, make([]byte, 100))...)
+	for _, f := range s.frames {
+		b = append(b, f...)
+	}
+	return b
+}
+
+// ogg writes the ogg flac stream, each frame is a packet
+func (s *testStream) ogg(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w := ogg.NewWriter(&buf, 7)
+	head := append([]byte("\x7fFLAC\x01\x00\x00\x01fLaC"), metadataBlock(blockStreamInfo, false, s.info)...)
+	for i, p := range [][]byte{head, metadataBlock(blockVorbisComment, true, s.comments)} {
+		if err := w.WritePacket(p, 0); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			w.Flush()
+		}
+	}
+	w.Flush()
+	for i, f := range s.frames {
+		end := int64(len(s.samples[0]))
+		if i+1 < len(s.frames) {
+			end = s.starts[i+1]
+		}
+		if err := w.WritePacket(f, end); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// check decodes all frames as float32, and compares with the samples from begin
+func (s *testStream) check(t *testing.T, fl *Flac, begin int) {
+	t.Helper()
+	got, err := io.ReadAll(fl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channels := len(s.samples)
+	total := len(s.samples[0])
+	if len(got) != 4*channels*(total-begin) {
+		t.Fatalf("%d frames decoded, want %d", len(got)/4/channels, total-begin)
+	}
+	scale := math.Ldexp(1, int(s.bps)-1)
+	for i := begin; i < total; i++ {
+		for ch := 0; ch < channels; ch++ {
+			k := 4 * ((i-begin)*channels + ch)
+			x := math.Float32frombits(binary.LittleEndian.Uint32(got[k:]))
+			if want := float32(float64(s.samples[ch][i]) / scale); x != want {
+				t.Fatalf("sample %d of channel %d: %v, want %v", i, ch, x, want)
+			}
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, c := range []struct {
+		channels int
+		bps      uint
+		variable bool
+	}{{2, 16, false}, {2, 24, true}, {1, 8, false}, {3, 12, true}, {2, 20, false}} {
+		s := makeTestStream(c.channels, c.bps, 10500, c.variable)
+		for _, data := range [][]byte{s.native(), s.ogg(t)} {
+			fl, err := New(bytes.NewReader(data), wav.F32)
+			if err != nil {
+				t.Fatalf("%+v: %v", c, err)
+			}
+			info := fl.Info()
+			if info.Channels != c.channels || info.BitsPerSample != int(c.bps) || fl.NumFrames() != 10500 || fl.Frequency() != 44100 {
+				t.Fatalf("%+v: info %+v", c, info)
+			}
+			if fl.Vendor() != "test" || fl.Comment("ARTIST") != "some|body" {
+				t.Fatalf("%+v: vendor %q, comments %v", c, fl.Vendor(), fl.Comments())
+			}
+			s.check(t, fl, 0)
+		}
+	}
+}
+
+// TestLibFLAC decodes the streams of libFLAC, and checks the MD5 of STREAMINFO, see
+// testdata/README.md
+func TestLibFLAC(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.flac")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no .flac file in testdata")
+	}
+	variable := false
+	for _, name := range files {
+		fl, err := Open(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := io.ReadAll(fl)
+		fl.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		info := fl.Info()
+		if typ := SampleType(info.BitsPerSample); fl.SampleType() != typ {
+			t.Fatalf("%s: decoded to %s, want %s", name, fl.SampleType(), typ)
+		}
+		if int64(len(got)) != info.TotalSamples*int64(info.Channels*fl.SampleType().Bits()/8) {
+			t.Fatalf("%s: %d bytes decoded", name, len(got))
+		}
+
+		// the MD5 is of the samples of the bytes of bps, little endian
+		size, width := fl.SampleType().Bits()/8, (info.BitsPerSample+7)/8
+		h := md5.New()
+		var b [4]byte
+		for i := 0; i < len(got); i += size {
+			var x uint32
+			for j := 0; j < size; j++ {
+				x |= uint32(got[i+j]) << (8 * uint(j+4-size))
+			}
+			x = uint32(int32(x) >> uint(32-info.BitsPerSample))
+			binary.LittleEndian.PutUint32(b[:], x)
+			h.Write(b[:width])
+		}
+		if sum := h.Sum(nil); !bytes.Equal(sum, info.MD5[:]) {
+			t.Fatalf("%s: MD5 %x, want %x", name, sum, info.MD5)
+		}
+		variable = variable || info.MinBlockSize != info.MaxBlockSize
+	}
+	if !variable {
+		t.Fatal("the corpus should have a stream of variable blocking")
+	}
+}
+
+func TestInt16(t *testing.T) {
+	s := makeTestStream(2, 16, 3000, false)
+	fl, err := New(bytes.NewReader(s.native()), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(fl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(got)/2; i++ {
+		if x := int16(binary.LittleEndian.Uint16(got[2*i:])); int64(x) != s.samples[i%2][i/2] {
+			t.Fatalf("sample %d: %d, want %d", i, x, s.samples[i%2][i/2])
+		}
+	}
+}
+
+func TestWideTypes(t *testing.T) {
+	s := makeTestStream(2, 20, 3000, false)
+	for _, typ := range []wav.Type{wav.I24, wav.I32, wav.F64} {
+		fl, err := New(bytes.NewReader(s.native()), typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(fl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := typ.Bits() / 8
+		for i := 0; i < len(got)/size; i++ {
+			b := got[i*size:]
+			var x int64
+			switch typ {
+			case wav.I24:
+				x = int64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)) >> 12
+			case wav.I32:
+				x = int64(int32(binary.LittleEndian.Uint32(b))) >> 12
+			case wav.F64:
+				x = int64(math.Ldexp(math.Float64frombits(binary.LittleEndian.Uint64(b)), 19))
+			}
+			if want := s.samples[i%2][i/2]; x != want {
+				t.Fatalf("%s: sample %d is %d, want %d", typ, i, x, want)
+			}
+		}
+	}
+	if typ := SampleType(20); typ != wav.I24 {
+		t.Fatalf("20 bits stream is decoded to %s", typ)
+	}
+}
+
+func TestSeekFrame(t *testing.T) {
+	s := makeTestStream(2, 16, 10500, false)
+	for _, data := range [][]byte{s.native(), s.ogg(t)} {
+		fl, err := New(bytes.NewReader(data), wav.F32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fl.SeekTable()) == 0 && data[0] == 'f' {
+			t.Fatal("seek table not found")
+		}
+		for _, frame := range []int64{7777, 0, 3000, 10499} {
+			if err = fl.SeekFrame(frame); err != nil {
+				t.Fatal(err)
+			}
+			if fl.Position() != frame {
+				t.Fatalf("position %d, want %d", fl.Position(), frame)
+			}
+			s.check(t, fl, int(frame))
+		}
+		if err = fl.SeekFrame(10501); err == nil {
+			t.Fatal("expect error of seeking out of stream")
+		}
+	}
+}
+
+func TestCorrupted(t *testing.T) {
+	s := makeTestStream(2, 16, 3000, false)
+	data := s.native()
+	data[len(data)-100] ^= 0x10
+	fl, err := New(bytes.NewReader(data), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.ReadAll(fl); err != errCRC {
+		t.Fatalf("expect checksum error, got %v", err)
+	}
+	if _, err = New(bytes.NewReader([]byte("RIFF0000WAVE")), wav.I16); err != ErrFormat {
+		t.Fatalf("expect ErrFormat, got %v", err)
+	}
+}
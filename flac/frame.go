@@ -0,0 +1,422 @@
+package flac
+
+import (
+	"errors"
+	"io"
+	"math/bits"
+)
+
+var (
+	errSync     = errors.New("flac: frame sync not found")
+	errReserved = errors.New("flac: reserved value in frame")
+	errCRC      = errors.New("flac: frame checksum mismatch")
+)
+
+var crc8Table, crc16Table = func() (t8 [256]uint8, t16 [256]uint16) {
+	for i := range t8 {
+		c8, c16 := uint8(i), uint16(i)<<8
+		for j := 0; j < 8; j++ {
+			if c8&0x80 != 0 {
+				c8 = c8<<1 ^ 0x07
+			} else {
+				c8 <<= 1
+			}
+			if c16&0x8000 != 0 {
+				c16 = c16<<1 ^ 0x8005
+			} else {
+				c16 <<= 1
+			}
+		}
+		t8[i], t16[i] = c8, c16
+	}
+	return
+}()
+
+// bitReader reads the bits of frame msb first, and computes the checksums of the bytes
+// read. the bytes are read on demand, so at byte boundary the checksums cover exactly
+// the bits consumed.
+type bitReader struct {
+	r     io.ByteReader
+	cache uint64 // the bits not read yet, msb aligned
+	n     uint   // bits in cache
+	crc8  uint8
+	crc16 uint16
+	err   error
+}
+
+func (br *bitReader) reset(r io.ByteReader) {
+	*br = bitReader{r: r}
+}
+
+// fill reads bytes into cache until it has n bits, n <= 57
+func (br *bitReader) fill(n uint) bool {
+	for br.n < n {
+		b, err := br.r.ReadByte()
+		if err != nil {
+			if br.err == nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				br.err = err
+			}
+			return false
+		}
+		br.crc8 = crc8Table[br.crc8^b]
+		br.crc16 = br.crc16<<8 ^ crc16Table[byte(br.crc16>>8)^b]
+		br.cache |= uint64(b) << (56 - br.n)
+		br.n += 8
+	}
+	return true
+}
+
+// read reads n bits, n <= 32. it reads zeros after the input ends, the error is kept.
+func (br *bitReader) read(n uint) uint32 {
+	if n == 0 {
+		return 0
+	}
+	if !br.fill(n) {
+		br.cache, br.n = 0, 0
+		return 0
+	}
+	x := uint32(br.cache >> (64 - n))
+	br.cache <<= n
+	br.n -= n
+	return x
+}
+
+// readSigned reads n bits of two's complement, n <= 33
+func (br *bitReader) readSigned(n uint) int64 {
+	if n == 0 {
+		return 0
+	}
+	var x int64
+	if n > 32 {
+		x = int64(br.read(n-32)) << 32
+		x |= int64(br.read(32))
+	} else {
+		x = int64(br.read(n))
+	}
+	return x << (64 - n) >> (64 - n)
+}
+
+// readUnary counts the 0 bits before a 1 bit
+func (br *bitReader) readUnary() (q uint32) {
+	for {
+		if br.n == 0 && !br.fill(8) {
+			return q
+		}
+		z := uint(bits.LeadingZeros64(br.cache))
+		if z < br.n {
+			br.cache <<= z + 1
+			br.n -= z + 1
+			return q + uint32(z)
+		}
+		q += uint32(br.n)
+		br.cache, br.n = 0, 0
+	}
+}
+
+// align drops the bits to byte boundary
+func (br *bitReader) align() {
+	br.read(br.n % 8)
+}
+
+// frameHeader is the header of frame, section 9.1 of RFC 9639
+type frameHeader struct {
+	variable   bool   // variable block size, number is the sample number
+	blockSize  int    // samples of each channel
+	sampleRate int    // 0 if it is the rate of STREAMINFO
+	channels   int    // channels of frame
+	stereo     int    // 0 independent, 8 left/side, 9 side/right, 10 mid/side
+	bps        int    // bits per sample, 0 if it is the bits of STREAMINFO
+	number     uint64 // the frame number or the sample number
+}
+
+// sampleRates of the 4 bits code, 0 is from STREAMINFO, 12 to 14 are coded at the end
+var sampleRates = [12]int{0, 88200, 176400, 192000, 8000, 16000, 22050, 24000, 32000, 44100, 48000, 96000}
+
+// sampleSizes of the 3 bits code, 0 is from STREAMINFO, 3 is reserved
+var sampleSizes = [8]int{0, 8, 12, -1, 16, 20, 24, 32}
+
+// readFrameHeader reads the frame header, the bit reader must be at a frame boundary.
+// io.EOF is returned if the input ends before the frame.
+func (br *bitReader) readFrameHeader(h *frameHeader) error {
+	br.crc8, br.crc16 = 0, 0
+	if !br.fill(8) {
+		if br.err == io.ErrUnexpectedEOF {
+			br.err = nil
+			return io.EOF
+		}
+		return br.err
+	}
+	if br.read(15) != 0x7ffc {
+		return errSync
+	}
+	h.variable = br.read(1) == 1
+	bsCode := br.read(4)
+	srCode := br.read(4)
+	chCode := br.read(4)
+	ssCode := br.read(3)
+	if br.read(1) != 0 {
+		return errReserved
+	}
+
+	// the utf-8 like coded number, up to 36 bits
+	x := br.read(8)
+	n := bits.LeadingZeros8(^uint8(x))
+	if n == 1 || n > 7 {
+		return errReserved
+	}
+	h.number = uint64(x)
+	if n > 1 {
+		h.number = uint64(x & (0xff >> uint(n+1)))
+		for i := 1; i < n; i++ {
+			c := br.read(8)
+			if c&0xc0 != 0x80 {
+				return errReserved
+			}
+			h.number = h.number<<6 | uint64(c&0x3f)
+		}
+	}
+
+	switch {
+	case bsCode == 0:
+		return errReserved
+	case bsCode == 1:
+		h.blockSize = 192
+	case bsCode <= 5:
+		h.blockSize = 576 << (bsCode - 2)
+	case bsCode == 6:
+		h.blockSize = int(br.read(8)) + 1
+	case bsCode == 7:
+		h.blockSize = int(br.read(16)) + 1
+	default:
+		h.blockSize = 256 << (bsCode - 8)
+	}
+	switch {
+	case srCode < 12:
+		h.sampleRate = sampleRates[srCode]
+	case srCode == 12:
+		h.sampleRate = int(br.read(8)) * 1000
+	case srCode == 13:
+		h.sampleRate = int(br.read(16))
+	case srCode == 14:
+		h.sampleRate = int(br.read(16)) * 10
+	default:
+		return errReserved
+	}
+	switch {
+	case chCode < 8:
+		h.channels, h.stereo = int(chCode)+1, 0
+	case chCode <= 10:
+		h.channels, h.stereo = 2, int(chCode)
+	default:
+		return errReserved
+	}
+	if h.bps = sampleSizes[ssCode]; h.bps < 0 {
+		return errReserved
+	}
+
+	crc := br.crc8 // the header is byte aligned, the cache is empty
+	if got := br.read(8); br.err != nil {
+		return br.err
+	} else if uint8(got) != crc {
+		return errCRC
+	}
+	return nil
+}
+
+var (
+	errSubframe = errors.New("flac: malformed subframe")
+	errResidual = errors.New("flac: malformed residual")
+)
+
+// readFrame reads the subframes of h into the channels of samples, and decorrelates the
+// stereo. bps is the bits per sample of stream.
+func (br *bitReader) readFrame(h *frameHeader, bps int, samples [][]int64) error {
+	for ch := 0; ch < h.channels; ch++ {
+		n := uint(bps)
+		// the side channel has one more bit
+		if (h.stereo == 8 || h.stereo == 10) && ch == 1 || h.stereo == 9 && ch == 0 {
+			n++
+		}
+		if err := br.readSubframe(samples[ch][:h.blockSize], n); err != nil {
+			return err
+		}
+	}
+	br.align()
+	crc := br.crc16 // byte aligned, the cache is empty
+	if got := br.read(16); br.err != nil {
+		return br.err
+	} else if uint16(got) != crc {
+		return errCRC
+	}
+
+	if h.stereo == 0 {
+		return nil
+	}
+	a, b := samples[0][:h.blockSize], samples[1][:h.blockSize]
+	switch h.stereo {
+	case 8: // left, side
+		for i := range a {
+			b[i] = a[i] - b[i]
+		}
+	case 9: // side, right
+		for i := range a {
+			a[i] += b[i]
+		}
+	case 10: // mid, side
+		for i := range a {
+			mid := a[i]<<1 | b[i]&1
+			a[i] = (mid + b[i]) >> 1
+			b[i] = (mid - b[i]) >> 1
+		}
+	}
+	return nil
+}
+
+// readSubframe reads a subframe of bps bits, section 9.2 of RFC 9639
+func (br *bitReader) readSubframe(out []int64, bps uint) error {
+	if br.read(1) != 0 {
+		return errSubframe
+	}
+	typ := br.read(6)
+	var wasted uint
+	if br.read(1) == 1 {
+		wasted = uint(br.readUnary()) + 1
+		if wasted >= bps {
+			return errSubframe
+		}
+		bps -= wasted
+	}
+
+	switch {
+	case typ == 0: // constant
+		x := br.readSigned(bps)
+		for i := range out {
+			out[i] = x
+		}
+	case typ == 1: // verbatim
+		for i := range out {
+			out[i] = br.readSigned(bps)
+		}
+	case typ >= 8 && typ <= 12: // fixed
+		order := int(typ - 8)
+		if order > len(out) {
+			return errSubframe
+		}
+		for i := 0; i < order; i++ {
+			out[i] = br.readSigned(bps)
+		}
+		if err := br.readResidual(out, order); err != nil {
+			return err
+		}
+		fixedPredict(out, order)
+	case typ >= 32: // lpc
+		order := int(typ-32) + 1
+		if order > len(out) {
+			return errSubframe
+		}
+		for i := 0; i < order; i++ {
+			out[i] = br.readSigned(bps)
+		}
+		precision := uint(br.read(4)) + 1
+		if precision == 16 {
+			return errSubframe
+		}
+		shift := int(br.readSigned(5))
+		if shift < 0 {
+			return errSubframe
+		}
+		var coefs [32]int64
+		for i := 0; i < order; i++ {
+			coefs[i] = br.readSigned(precision)
+		}
+		if err := br.readResidual(out, order); err != nil {
+			return err
+		}
+		lpcPredict(out, coefs[:order], uint(shift))
+	default:
+		return errSubframe
+	}
+	if br.err != nil {
+		return br.err
+	}
+	if wasted != 0 {
+		for i := range out {
+			out[i] <<= wasted
+		}
+	}
+	return nil
+}
+
+// readResidual reads the rice coded residual after the warm-up samples
+func (br *bitReader) readResidual(out []int64, order int) error {
+	method := br.read(2)
+	if method > 1 {
+		return errResidual
+	}
+	paramBits, escape := uint(4), uint32(15)
+	if method == 1 {
+		paramBits, escape = 5, 31
+	}
+	partOrder := br.read(4)
+	parts := 1 << partOrder
+	if len(out)%parts != 0 || len(out)>>partOrder < order {
+		return errResidual
+	}
+	i := order
+	for p := 0; p < parts; p++ {
+		end := (p + 1) * (len(out) >> partOrder)
+		k := br.read(paramBits)
+		if k == escape {
+			n := uint(br.read(5))
+			for ; i < end; i++ {
+				out[i] = br.readSigned(n)
+			}
+			continue
+		}
+		for ; i < end; i++ {
+			u := uint64(br.readUnary())<<k | uint64(br.read(uint(k)))
+			out[i] = int64(u>>1) ^ -int64(u&1)
+		}
+		if br.err != nil {
+			return br.err
+		}
+	}
+	return nil
+}
+
+// fixedPredict restores the samples from the residual of fixed predictor
+func fixedPredict(x []int64, order int) {
+	switch order {
+	case 1:
+		for i := 1; i < len(x); i++ {
+			x[i] += x[i-1]
+		}
+	case 2:
+		for i := 2; i < len(x); i++ {
+			x[i] += 2*x[i-1] - x[i-2]
+		}
+	case 3:
+		for i := 3; i < len(x); i++ {
+			x[i] += 3*x[i-1] - 3*x[i-2] + x[i-3]
+		}
+	case 4:
+		for i := 4; i < len(x); i++ {
+			x[i] += 4*x[i-1] - 6*x[i-2] + 4*x[i-3] - x[i-4]
+		}
+	}
+}
+
+// lpcPredict restores the samples from the residual of linear predictor
+func lpcPredict(x []int64, coefs []int64, shift uint) {
+	order := len(coefs)
+	for i := order; i < len(x); i++ {
+		var sum int64
+		for j, c := range coefs {
+			sum += c * x[i-1-j]
+		}
+		x[i] += sum >> shift
+	}
+}
@@ -0,0 +1,166 @@
+package flac
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// metadata block types
+const (
+	blockStreamInfo    = 0
+	blockPadding       = 1
+	blockApplication   = 2
+	blockSeekTable     = 3
+	blockVorbisComment = 4
+	blockCueSheet      = 5
+	blockPicture       = 6
+)
+
+// the metadata blocks larger than it are rejected, except the skipped ones
+const maxMetadataSize = 1 << 20
+
+// StreamInfo is the STREAMINFO metadata block
+type StreamInfo struct {
+	MinBlockSize  int // samples, the last block may be smaller
+	MaxBlockSize  int
+	MinFrameSize  int // bytes, 0 if unknown
+	MaxFrameSize  int
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	TotalSamples  int64 // samples of each channel, 0 if unknown
+	MD5           [16]byte
+}
+
+// SeekPoint is a point of SEEKTABLE
+type SeekPoint struct {
+	Sample  int64 // the first sample of target frame
+	Offset  int64 // bytes from the first frame to the target frame
+	Samples int   // samples of target frame
+}
+
+// placeholder seek points are skipped
+const placeholderPoint = 0xffffffffffffffff
+
+func parseStreamInfo(b []byte) (si StreamInfo, err error) {
+	if len(b) < 34 {
+		return si, ErrFormat
+	}
+	si.MinBlockSize = int(binary.BigEndian.Uint16(b))
+	si.MaxBlockSize = int(binary.BigEndian.Uint16(b[2:]))
+	si.MinFrameSize = int(b[4])<<16 | int(b[5])<<8 | int(b[6])
+	si.MaxFrameSize = int(b[7])<<16 | int(b[8])<<8 | int(b[9])
+	x := binary.BigEndian.Uint64(b[10:])
+	si.SampleRate = int(x >> 44)
+	si.Channels = int(x>>41&0x07) + 1
+	si.BitsPerSample = int(x>>36&0x1f) + 1
+	si.TotalSamples = int64(x & 0xfffffffff)
+	copy(si.MD5[:], b[18:34])
+	if si.MaxBlockSize < 16 || si.SampleRate == 0 || si.BitsPerSample < 4 {
+		return si, ErrFormat
+	}
+	return si, nil
+}
+
+func parseSeekTable(b []byte) (points []SeekPoint) {
+	for ; len(b) >= 18; b = b[18:] {
+		sample := binary.BigEndian.Uint64(b)
+		if sample == placeholderPoint {
+			continue
+		}
+		points = append(points, SeekPoint{
+			Sample:  int64(sample),
+			Offset:  int64(binary.BigEndian.Uint64(b[8:])),
+			Samples: int(binary.BigEndian.Uint16(b[16:])),
+		})
+	}
+	return
+}
+
+// parseVorbisComment parses the VORBIS_COMMENT block, it is the comment header of vorbis
+// without the packet type and framing bit, the numbers are little endian. the comments
+// of the same name are joined by '|', like the vorbis package.
+func parseVorbisComment(b []byte) (vendor string, comments map[string]string, err error) {
+	str := func() (string, bool) {
+		if len(b) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(b)
+		b = b[4:]
+		if uint64(n) > uint64(len(b)) {
+			return "", false
+		}
+		s := string(b[:n])
+		b = b[n:]
+		return s, true
+	}
+	var ok bool
+	if vendor, ok = str(); !ok || len(b) < 4 {
+		return "", nil, ErrFormat
+	}
+	count := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	comments = make(map[string]string)
+	for i := uint32(0); i < count; i++ {
+		s, ok := str()
+		if !ok {
+			return "", nil, ErrFormat
+		}
+		if pos := strings.IndexByte(s, '='); pos != -1 {
+			k, v := s[:pos], s[pos+1:]
+			if v0, ok := comments[k]; ok {
+				comments[k] = v0 + "|" + v
+			} else {
+				comments[k] = v
+			}
+		}
+	}
+	return vendor, comments, nil
+}
+
+// parseMetadata parses a metadata block, the blocks not used are ignored
+func (fl *Flac) parseMetadata(typ int, b []byte) (err error) {
+	switch typ {
+	case blockStreamInfo:
+		fl.info, err = parseStreamInfo(b)
+		fl.hasInfo = err == nil
+	case blockSeekTable:
+		fl.seekTable = parseSeekTable(b)
+	case blockVorbisComment:
+		fl.vendor, fl.comments, err = parseVorbisComment(b)
+	}
+	return
+}
+
+// readMetadata reads the metadata blocks after the "fLaC" marker, it reports the bytes read
+func (fl *Flac) readMetadata(r io.Reader) (n int64, err error) {
+	var head [4]byte
+	for last := false; !last; {
+		if _, err = io.ReadFull(r, head[:]); err != nil {
+			return n, err
+		}
+		last = head[0]&0x80 != 0
+		typ := int(head[0] & 0x7f)
+		size := int64(head[1])<<16 | int64(head[2])<<8 | int64(head[3])
+		n += 4 + size
+		switch typ {
+		case blockStreamInfo, blockSeekTable, blockVorbisComment:
+			if size > maxMetadataSize {
+				return n, ErrFormat
+			}
+			b := make([]byte, size)
+			if _, err = io.ReadFull(r, b); err != nil {
+				return n, err
+			}
+			if err = fl.parseMetadata(typ, b); err != nil {
+				return n, err
+			}
+		default:
+			if _, err = io.CopyN(io.Discard, r, size); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,656 @@
+// Command gen rewrites a stream encoded by libFLAC into a stream of the features which the
+// libFLAC encoder never emits: the variable blocking strategy and the escaped residual
+// partitions. the subframes of libFLAC are kept, they are only split at the new block
+// boundaries, so the predictors, the quantized coefficients and the warm-up samples are
+// those of libFLAC. the 8 bits source is widened to 16 bits by 8 wasted bits.
+//
+// the source is decoded and checked against the MD5 of its STREAMINFO before it is
+// rewritten, the MD5 of the output is computed from the checked samples.
+//
+//	cd flac/testdata/gen && go run .
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math/bits"
+	"math/rand"
+	"os"
+)
+
+// bitReader reads bits msb first
+type bitReader struct {
+	buf []byte
+	pos uint // in bits
+}
+
+func (r *bitReader) read(n uint) uint64 {
+	var x uint64
+	for i := uint(0); i < n; i++ {
+		b := r.buf[r.pos/8] >> (7 - r.pos%8) & 1
+		x = x<<1 | uint64(b)
+		r.pos++
+	}
+	return x
+}
+
+func (r *bitReader) readSigned(n uint) int64 {
+	if n == 0 {
+		return 0
+	}
+	x := r.read(n)
+	return int64(x<<(64-n)) >> (64 - n)
+}
+
+func (r *bitReader) readUnary() uint64 {
+	var q uint64
+	for r.read(1) == 0 {
+		q++
+	}
+	return q
+}
+
+func (r *bitReader) align() {
+	r.pos = (r.pos + 7) &^ 7
+}
+
+// bitWriter writes bits msb first
+type bitWriter struct {
+	buf []byte
+	n   uint // bits written
+}
+
+func (w *bitWriter) write(x uint64, n uint) {
+	for i := int(n) - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if x>>uint(i)&1 != 0 {
+			w.buf[len(w.buf)-1] |= 0x80 >> (w.n % 8)
+		}
+		w.n++
+	}
+}
+
+func (w *bitWriter) writeSigned(x int64, n uint) {
+	w.write(uint64(x)&(1<<n-1), n)
+}
+
+func (w *bitWriter) align() {
+	for w.n%8 != 0 {
+		w.write(0, 1)
+	}
+}
+
+func crc8(b []byte) byte {
+	var c byte
+	for _, x := range b {
+		c ^= x
+		for i := 0; i < 8; i++ {
+			if c&0x80 != 0 {
+				c = c<<1 ^ 0x07
+			} else {
+				c <<= 1
+			}
+		}
+	}
+	return c
+}
+
+func crc16(b []byte) uint16 {
+	var c uint16
+	for _, x := range b {
+		c ^= uint16(x) << 8
+		for i := 0; i < 8; i++ {
+			if c&0x8000 != 0 {
+				c = c<<1 ^ 0x8005
+			} else {
+				c <<= 1
+			}
+		}
+	}
+	return c
+}
+
+// subframe kinds
+const (
+	kindConstant = iota
+	kindVerbatim
+	kindFixed
+	kindLPC
+)
+
+// subframe of a coded channel, the samples are before the wasted bits are shifted in
+type subframe struct {
+	kind      int
+	order     int
+	wasted    uint
+	precision uint
+	shift     int
+	coefs     []int64
+	samples   []int64
+	residual  []int64 // of the samples after the warm-up
+}
+
+type frame struct {
+	stereo    int // the channel assignment
+	subframes []subframe
+}
+
+var fixedCoefs = [][]int64{{}, {1}, {2, -1}, {3, -3, 1}, {4, -6, 4, -1}}
+
+// stream is the decoded source
+type stream struct {
+	info     []byte // STREAMINFO
+	comment  []byte // VORBIS_COMMENT, nil if none
+	rate     int
+	channels int
+	bps      int
+	frames   []frame
+}
+
+func parse(data []byte) (*stream, error) {
+	if len(data) < 4 || string(data[:4]) != "fLaC" {
+		return nil, errors.New("not a flac stream")
+	}
+	s := &stream{}
+	pos := 4
+	for last := false; !last; {
+		last = data[pos]&0x80 != 0
+		typ := data[pos] & 0x7f
+		size := int(data[pos+1])<<16 | int(data[pos+2])<<8 | int(data[pos+3])
+		block := data[pos+4 : pos+4+size]
+		switch typ {
+		case 0:
+			s.info = append([]byte(nil), block...)
+		case 4:
+			s.comment = append([]byte(nil), block...)
+		}
+		pos += 4 + size
+	}
+	x := binary.BigEndian.Uint64(s.info[10:])
+	s.rate = int(x >> 44)
+	s.channels = int(x>>41&7) + 1
+	s.bps = int(x>>36&0x1f) + 1
+
+	r := &bitReader{buf: data, pos: uint(pos) * 8}
+	for int(r.pos/8) < len(data) {
+		f, err := s.readFrame(r)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %v", len(s.frames), err)
+		}
+		s.frames = append(s.frames, f)
+	}
+	return s, nil
+}
+
+func (s *stream) readFrame(r *bitReader) (f frame, err error) {
+	begin := r.pos / 8
+	if r.read(15) != 0x7ffc {
+		return f, errors.New("lost sync")
+	}
+	r.read(1)
+	bsCode, srCode, chCode := r.read(4), r.read(4), r.read(4)
+	r.read(4)
+	lead := bits.LeadingZeros8(^uint8(r.read(8)))
+	for i := 1; i < lead; i++ {
+		r.read(8)
+	}
+	var size int
+	switch {
+	case bsCode == 1:
+		size = 192
+	case bsCode <= 5:
+		size = 576 << (bsCode - 2)
+	case bsCode == 6:
+		size = int(r.read(8)) + 1
+	case bsCode == 7:
+		size = int(r.read(16)) + 1
+	default:
+		size = 256 << (bsCode - 8)
+	}
+	switch srCode {
+	case 12:
+		r.read(8)
+	case 13, 14:
+		r.read(16)
+	}
+	if byte(r.read(8)) != crc8(r.buf[begin:r.pos/8-1]) {
+		return f, errors.New("header crc")
+	}
+	f.stereo = int(chCode)
+	for ch := 0; ch < s.channels; ch++ {
+		bps := uint(s.bps)
+		if f.stereo == 8 && ch == 1 || f.stereo == 9 && ch == 0 || f.stereo == 10 && ch == 1 {
+			bps++
+		}
+		sf, err := readSubframe(r, size, bps)
+		if err != nil {
+			return f, err
+		}
+		f.subframes = append(f.subframes, sf)
+	}
+	r.align()
+	if uint16(r.read(16)) != crc16(r.buf[begin:r.pos/8-2]) {
+		return f, errors.New("frame crc")
+	}
+	return f, nil
+}
+
+func readSubframe(r *bitReader, size int, bps uint) (sf subframe, err error) {
+	r.read(1)
+	typ := int(r.read(6))
+	if r.read(1) != 0 {
+		sf.wasted = uint(r.readUnary()) + 1
+		bps -= sf.wasted
+	}
+	sf.samples = make([]int64, size)
+	switch {
+	case typ == 0:
+		sf.kind = kindConstant
+		v := r.readSigned(bps)
+		for i := range sf.samples {
+			sf.samples[i] = v
+		}
+		return sf, nil
+	case typ == 1:
+		sf.kind = kindVerbatim
+		for i := range sf.samples {
+			sf.samples[i] = r.readSigned(bps)
+		}
+		return sf, nil
+	case typ >= 8 && typ <= 12:
+		sf.kind, sf.order = kindFixed, typ-8
+		sf.coefs = fixedCoefs[sf.order]
+	case typ >= 32:
+		sf.kind, sf.order = kindLPC, typ-31
+	default:
+		return sf, fmt.Errorf("subframe type %d", typ)
+	}
+	for i := 0; i < sf.order; i++ {
+		sf.samples[i] = r.readSigned(bps)
+	}
+	if sf.kind == kindLPC {
+		sf.precision = uint(r.read(4)) + 1
+		sf.shift = int(r.readSigned(5))
+		sf.coefs = make([]int64, sf.order)
+		for i := range sf.coefs {
+			sf.coefs[i] = r.readSigned(sf.precision)
+		}
+	}
+
+	// the residual
+	paramBits, escape := uint(4), uint64(15)
+	if r.read(2) == 1 {
+		paramBits, escape = 5, 31
+	}
+	partOrder := uint(r.read(4))
+	for p := 0; p < 1<<partOrder; p++ {
+		n := size >> partOrder
+		if p == 0 {
+			n -= sf.order
+		}
+		k := r.read(paramBits)
+		if k == escape {
+			m := uint(r.read(5))
+			for i := 0; i < n; i++ {
+				sf.residual = append(sf.residual, r.readSigned(m))
+			}
+			continue
+		}
+		for i := 0; i < n; i++ {
+			u := r.readUnary()<<k | r.read(uint(k))
+			sf.residual = append(sf.residual, int64(u>>1)^-int64(u&1))
+		}
+	}
+	for i := sf.order; i < size; i++ {
+		var sum int64
+		for j, c := range sf.coefs {
+			sum += c * sf.samples[i-1-j]
+		}
+		if sf.kind == kindLPC {
+			sum >>= uint(sf.shift)
+		}
+		sf.samples[i] = sf.residual[i-sf.order] + sum
+	}
+	return sf, nil
+}
+
+// pcm reports the interleaved samples of the frames
+func (s *stream) pcm() []int64 {
+	var out []int64
+	for _, f := range s.frames {
+		ch := make([][]int64, s.channels)
+		for c, sf := range f.subframes {
+			ch[c] = make([]int64, len(sf.samples))
+			for i, v := range sf.samples {
+				ch[c][i] = v << sf.wasted
+			}
+		}
+		for i := range ch[0] {
+			switch f.stereo {
+			case 8: // left, side
+				ch[1][i] = ch[0][i] - ch[1][i]
+			case 9: // side, right
+				ch[0][i] += ch[1][i]
+			case 10: // mid, side
+				mid := ch[0][i]<<1 | ch[1][i]&1
+				ch[0][i] = (mid + ch[1][i]) >> 1
+				ch[1][i] = (mid - ch[1][i]) >> 1
+			}
+			for c := range ch {
+				out = append(out, ch[c][i])
+			}
+		}
+	}
+	return out
+}
+
+// md5sum of the samples as libFLAC computes, little endian of the bytes of bps
+func md5sum(pcm []int64, bps int) [16]byte {
+	size := (bps + 7) / 8
+	b := make([]byte, 0, len(pcm)*size)
+	for _, v := range pcm {
+		for i := 0; i < size; i++ {
+			b = append(b, byte(v>>(8*uint(i))))
+		}
+	}
+	return md5.Sum(b)
+}
+
+// split the subframe at the sample m, m is not less than the order
+func (sf subframe) split(m int) (a, b subframe) {
+	a, b = sf, sf
+	a.samples, b.samples = sf.samples[:m], sf.samples[m:]
+	if sf.kind == kindFixed || sf.kind == kindLPC {
+		a.residual = sf.residual[:m-sf.order]
+		b.residual = sf.residual[m:]
+	}
+	return a, b
+}
+
+// rewrite splits the frames at random, and widens the samples by wasted bits
+func (s *stream) rewrite(rnd *rand.Rand, widen uint) {
+	var frames []frame
+	for _, f := range s.frames {
+		for {
+			size := len(f.subframes[0].samples)
+			if size < 64 || rnd.Intn(4) == 0 {
+				break
+			}
+			m := 16 + rnd.Intn(size-32)
+			// the warm-up of the second part must be in it
+			for _, sf := range f.subframes {
+				if m < sf.order || size-m < sf.order {
+					m = size
+				}
+			}
+			if m == size {
+				break
+			}
+			a := frame{stereo: f.stereo}
+			b := frame{stereo: f.stereo}
+			for _, sf := range f.subframes {
+				x, y := sf.split(m)
+				a.subframes = append(a.subframes, x)
+				b.subframes = append(b.subframes, y)
+			}
+			frames = append(frames, a)
+			f = b
+		}
+		frames = append(frames, f)
+	}
+	s.frames = frames
+	if widen != 0 {
+		s.bps += int(widen)
+		for _, f := range s.frames {
+			for i := range f.subframes {
+				f.subframes[i].wasted += widen
+			}
+		}
+	}
+}
+
+func (s *stream) write(rnd *rand.Rand, md5 [16]byte) []byte {
+	var out bytes.Buffer
+	out.WriteString("fLaC")
+
+	minBlock, maxBlock := 65535, 0
+	for i, f := range s.frames {
+		n := len(f.subframes[0].samples)
+		if n > maxBlock {
+			maxBlock = n
+		}
+		if n < minBlock && i < len(s.frames)-1 {
+			minBlock = n
+		}
+	}
+	info := append([]byte(nil), s.info...)
+	binary.BigEndian.PutUint16(info, uint16(minBlock))
+	binary.BigEndian.PutUint16(info[2:], uint16(maxBlock))
+	for i := 4; i < 10; i++ {
+		info[i] = 0 // the frame sizes are unknown
+	}
+	x := binary.BigEndian.Uint64(info[10:])
+	x = x&^(0x1f<<36) | uint64(s.bps-1)<<36
+	binary.BigEndian.PutUint64(info[10:], x)
+	copy(info[18:], md5[:])
+	blocks := [][]byte{info}
+	if s.comment != nil {
+		blocks = append(blocks, s.comment)
+	}
+	for i, b := range blocks {
+		typ := byte(0)
+		if i > 0 {
+			typ = 4
+		}
+		if i == len(blocks)-1 {
+			typ |= 0x80
+		}
+		out.Write([]byte{typ, byte(len(b) >> 16), byte(len(b) >> 8), byte(len(b))})
+		out.Write(b)
+	}
+
+	var sample uint64
+	for _, f := range s.frames {
+		out.Write(s.writeFrame(rnd, f, sample))
+		sample += uint64(len(f.subframes[0].samples))
+	}
+	return out.Bytes()
+}
+
+func (s *stream) writeFrame(rnd *rand.Rand, f frame, sample uint64) []byte {
+	w := &bitWriter{}
+	size := len(f.subframes[0].samples)
+	w.write(0xfff9, 16) // the variable blocking strategy
+	w.write(7, 4)       // the block size at the end of header
+	w.write(0, 4)       // the sample rate of STREAMINFO
+	w.write(uint64(f.stereo), 4)
+	w.write(0, 3) // the sample size of STREAMINFO
+	w.write(0, 1)
+	// the sample number, utf-8 like coded
+	switch {
+	case sample < 0x80:
+		w.write(sample, 8)
+	default:
+		n := 2
+		for sample >= 1<<(uint(5*n+1)) {
+			n++
+		}
+		w.write(uint64(0xff00>>uint(n))&0xff|sample>>(6*uint(n-1)), 8)
+		for i := n - 2; i >= 0; i-- {
+			w.write(0x80|sample>>(6*uint(i))&0x3f, 8)
+		}
+	}
+	w.write(uint64(size-1), 16)
+	w.write(uint64(crc8(w.buf)), 8)
+
+	for c, sf := range f.subframes {
+		bps := uint(s.bps)
+		if f.stereo == 8 && c == 1 || f.stereo == 9 && c == 0 || f.stereo == 10 && c == 1 {
+			bps++
+		}
+		writeSubframe(w, rnd, sf, bps-sf.wasted)
+	}
+	w.align()
+	w.write(uint64(crc16(w.buf)), 16)
+	return w.buf
+}
+
+func writeSubframe(w *bitWriter, rnd *rand.Rand, sf subframe, bps uint) {
+	w.write(0, 1)
+	switch sf.kind {
+	case kindConstant:
+		w.write(0, 6)
+	case kindVerbatim:
+		w.write(1, 6)
+	case kindFixed:
+		w.write(uint64(8+sf.order), 6)
+	case kindLPC:
+		w.write(uint64(31+sf.order), 6)
+	}
+	if sf.wasted != 0 {
+		w.write(1, 1)
+		w.write(1, sf.wasted) // unary of wasted-1
+	} else {
+		w.write(0, 1)
+	}
+	switch sf.kind {
+	case kindConstant:
+		w.writeSigned(sf.samples[0], bps)
+		return
+	case kindVerbatim:
+		for _, v := range sf.samples {
+			w.writeSigned(v, bps)
+		}
+		return
+	}
+	for _, v := range sf.samples[:sf.order] {
+		w.writeSigned(v, bps)
+	}
+	if sf.kind == kindLPC {
+		w.write(uint64(sf.precision-1), 4)
+		w.writeSigned(int64(sf.shift), 5)
+		for _, c := range sf.coefs {
+			w.writeSigned(c, sf.precision)
+		}
+	}
+
+	// the highest partition order of the block size and the order
+	size := len(sf.samples)
+	partOrder := uint(0)
+	for partOrder < 8 && size%(2<<partOrder) == 0 && size>>(partOrder+1) > sf.order {
+		partOrder++
+	}
+	var parts [][]int64
+	res := sf.residual
+	for p := 0; p < 1<<partOrder; p++ {
+		n := size >> partOrder
+		if p == 0 {
+			n -= sf.order
+		}
+		parts = append(parts, res[:n])
+		res = res[n:]
+	}
+	params := make([]uint, len(parts))
+	method := uint64(0)
+	for i, part := range parts {
+		params[i] = riceParam(part)
+		if params[i] > 14 {
+			method = 1
+		}
+	}
+	paramBits, escape := uint(4), uint64(15)
+	if method == 1 {
+		paramBits, escape = 5, 31
+	}
+	w.write(method, 2)
+	w.write(uint64(partOrder), 4)
+	for i, part := range parts {
+		// about a third of the partitions are escaped
+		if rnd.Intn(3) == 0 {
+			n := uint(0) // 0 if the residual is all zero
+			for _, v := range part {
+				for v != 0 && (v < -(1<<n>>1) || v >= 1<<n>>1) {
+					n++
+				}
+			}
+			w.write(escape, paramBits)
+			w.write(uint64(n), 5)
+			for _, v := range part {
+				w.writeSigned(v, n)
+			}
+			continue
+		}
+		k := params[i]
+		w.write(uint64(k), paramBits)
+		for _, v := range part {
+			u := uint64(v<<1) ^ uint64(v>>63)
+			for q := u >> k; q > 0; q-- {
+				w.write(0, 1)
+			}
+			w.write(1, 1)
+			w.write(u, k)
+		}
+	}
+}
+
+// riceParam reports the parameter of the least bits
+func riceParam(part []int64) uint {
+	best, bestBits := uint(0), uint64(1<<63)
+	for k := uint(0); k < 31; k++ {
+		var n uint64
+		for _, v := range part {
+			u := uint64(v<<1) ^ uint64(v>>63)
+			n += u>>k + 1 + uint64(k)
+		}
+		if n < bestBits {
+			best, bestBits = k, n
+		}
+	}
+	return best
+}
+
+func main() {
+	for _, c := range []struct {
+		src, dst string
+		widen    uint
+	}{
+		{"../44127.flac", "../44127-variable.flac", 8},
+	} {
+		data, err := os.ReadFile(c.src)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s, err := parse(data)
+		if err != nil {
+			log.Fatalf("%s: %v", c.src, err)
+		}
+		pcm := s.pcm()
+		if sum := md5sum(pcm, s.bps); !bytes.Equal(sum[:], s.info[18:34]) {
+			log.Fatalf("%s: MD5 mismatch", c.src)
+		}
+		rnd := rand.New(rand.NewSource(1))
+		s.rewrite(rnd, c.widen)
+		for i := range pcm {
+			pcm[i] <<= c.widen
+		}
+		sum := md5sum(pcm, s.bps)
+		out := s.write(rnd, sum)
+		// parse the output again
+		if s, err = parse(out); err != nil {
+			log.Fatalf("%s: %v", c.dst, err)
+		}
+		if sum = md5sum(s.pcm(), s.bps); !bytes.Equal(sum[:], s.info[18:34]) {
+			log.Fatalf("%s: MD5 mismatch", c.dst)
+		}
+		if err = os.WriteFile(c.dst, out, 0644); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s: %d frames\n", c.dst, len(s.frames))
+	}
+}
@@ -0,0 +1,169 @@
+package mp3
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Version is the MPEG audio version
+type Version int
+
+// MPEG audio versions
+const (
+	MPEG1 Version = iota
+	MPEG2
+	MPEG25
+)
+
+func (v Version) String() string {
+	switch v {
+	case MPEG1:
+		return "MPEG-1"
+	case MPEG2:
+		return "MPEG-2"
+	case MPEG25:
+		return "MPEG-2.5"
+	default:
+		return fmt.Sprintf("unknown mpeg version %d", int(v))
+	}
+}
+
+// ChannelMode of frame
+type ChannelMode int
+
+// Channel modes
+const (
+	Stereo ChannelMode = iota
+	JointStereo
+	DualChannel
+	Mono
+)
+
+func (m ChannelMode) String() string {
+	switch m {
+	case Stereo:
+		return "stereo"
+	case JointStereo:
+		return "joint stereo"
+	case DualChannel:
+		return "dual channel"
+	case Mono:
+		return "mono"
+	default:
+		return fmt.Sprintf("unknown channel mode %d", int(m))
+	}
+}
+
+// FrameHeader is the 4 bytes header of a Layer III frame
+type FrameHeader struct {
+	Version       Version
+	Protected     bool // a CRC-16 follows the header
+	Bitrate       int  // kbit/s
+	SampleRate    int
+	Padding       bool
+	Mode          ChannelMode
+	ModeExtension int
+	Copyright     bool
+	Original      bool
+	Emphasis      int
+}
+
+var bitrates = [2][16]int{
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, -1}, // MPEG-1
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, -1},     // MPEG-2 and 2.5
+}
+
+var sampleRates = [3][4]int{
+	{44100, 48000, 32000, -1},
+	{22050, 24000, 16000, -1},
+	{11025, 12000, 8000, -1},
+}
+
+// parseHeader parses the header at the beginning of b. the free format and the layers
+// other than III are not supported, they are reported as invalid.
+func parseHeader(b []byte) (h FrameHeader, ok bool) {
+	if len(b) < 4 {
+		return h, false
+	}
+	x := binary.BigEndian.Uint32(b)
+	if x>>21 != 0x7ff {
+		return h, false
+	}
+	switch x >> 19 & 3 {
+	case 0:
+		h.Version = MPEG25
+	case 2:
+		h.Version = MPEG2
+	case 3:
+		h.Version = MPEG1
+	default:
+		return h, false
+	}
+	if x>>17&3 != 1 { // layer III
+		return h, false
+	}
+	h.Protected = x>>16&1 == 0
+	table := 0
+	if h.Version != MPEG1 {
+		table = 1
+	}
+	h.Bitrate = bitrates[table][x>>12&15]
+	h.SampleRate = sampleRates[h.Version][x>>10&3]
+	if h.Bitrate <= 0 || h.SampleRate < 0 {
+		return h, false
+	}
+	h.Padding = x>>9&1 == 1
+	h.Mode = ChannelMode(x >> 6 & 3)
+	h.ModeExtension = int(x >> 4 & 3)
+	h.Copyright = x>>3&1 == 1
+	h.Original = x>>2&1 == 1
+	h.Emphasis = int(x & 3)
+	if h.Emphasis == 2 {
+		return h, false // reserved
+	}
+	return h, true
+}
+
+// Samples reports the samples of each channel in the frame
+func (h *FrameHeader) Samples() int {
+	if h.Version == MPEG1 {
+		return 1152
+	}
+	return 576
+}
+
+// Size reports the bytes of the frame, the header is included
+func (h *FrameHeader) Size() int {
+	n := h.Samples() / 8 * h.Bitrate * 1000 / h.SampleRate
+	if h.Padding {
+		n++
+	}
+	return n
+}
+
+// Channels reports the channels of the frame
+func (h *FrameHeader) Channels() int {
+	if h.Mode == Mono {
+		return 1
+	}
+	return 2
+}
+
+// sideInfoSize reports the bytes of side information after the header and CRC
+func (h *FrameHeader) sideInfoSize() int {
+	if h.Version == MPEG1 {
+		if h.Mode == Mono {
+			return 17
+		}
+		return 32
+	}
+	if h.Mode == Mono {
+		return 9
+	}
+	return 17
+}
+
+// similar reports whether the frames are of the same stream, for resync
+func (h *FrameHeader) similar(o *FrameHeader) bool {
+	return h.Version == o.Version && h.SampleRate == o.SampleRate && h.Channels() == o.Channels()
+}
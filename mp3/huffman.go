@@ -0,0 +1,72 @@
+package mp3
+
+// huffmanTree decodes a code table, the children of node i are at 2i and 2i+1 for the
+// bit 0 and 1. a negative child is the leaf of value ^child.
+type huffmanTree []int16
+
+// huffmanTrees of the table_select of big values, and of count1 tables at 32 and 33
+var huffmanTrees, huffmanSizes = func() (trees [34]huffmanTree, sizes [34]int) {
+	for i, codes := range huffmanCodes {
+		if codes == nil {
+			continue
+		}
+		trees[i] = newHuffmanTree(codes)
+		for sizes[i]*sizes[i] < len(codes) {
+			sizes[i]++
+		}
+	}
+	for i := 17; i < 32; i++ {
+		if i != 24 {
+			trees[i], sizes[i] = trees[i&^7], sizes[i&^7]
+		}
+	}
+	return
+}()
+
+func newHuffmanTree(codes []uint32) huffmanTree {
+	t := huffmanTree{0, 0}
+	for v, c := range codes {
+		n, code := c>>24, c&0xffffff
+		node := 0
+		for i := n - 1; i > 0; i-- {
+			b := 2*node + int(code>>i&1)
+			if t[b] == 0 {
+				t[b] = int16(len(t) / 2)
+				t = append(t, 0, 0)
+			}
+			node = int(t[b])
+		}
+		t[2*node+int(code&1)] = ^int16(v)
+	}
+	return t
+}
+
+// bitstream reads the bits msb first, zeros are read after the end
+type bitstream struct {
+	b   []byte
+	pos int // in bits
+}
+
+func (bs *bitstream) read(n int) int {
+	x := 0
+	for ; n > 0; n-- {
+		x <<= 1
+		if i := bs.pos >> 3; i < len(bs.b) {
+			x |= int(bs.b[i]>>(7-uint(bs.pos&7))) & 1
+		}
+		bs.pos++
+	}
+	return x
+}
+
+// decode reads a huffman code of the complete tree t
+func (bs *bitstream) decode(t huffmanTree) int {
+	node := 0
+	for {
+		c := t[2*node+bs.read(1)]
+		if c < 0 {
+			return int(^c)
+		}
+		node = int(c)
+	}
+}
@@ -0,0 +1,233 @@
+package mp3
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// the ID3v2 tags larger than it are skipped without parsing, they are mostly pictures
+const maxTagSize = 16 << 20
+
+// syncsafe decodes the integer of 7 bits per byte
+func syncsafe(b []byte) int {
+	n := 0
+	for _, x := range b {
+		n = n<<7 | int(x&0x7f)
+	}
+	return n
+}
+
+// unsync removes the 0x00 inserted after 0xff
+func unsync(b []byte) []byte {
+	out := b[:0]
+	for i := 0; i < len(b); i++ {
+		out = append(out, b[i])
+		if b[i] == 0xff && i+1 < len(b) && b[i+1] == 0 {
+			i++
+		}
+	}
+	return out
+}
+
+// readID3v2 reads the ID3v2 tags at the beginning of r, if any. it reports the bytes of
+// the tags. the text frames are kept by frame id, i.e. "TIT2". the user defined texts are
+// kept as "TXXX:description", and the comments as "COMM". the values of the same id are
+// joined by '|'.
+func readID3v2(r *bufio.Reader) (tags map[string]string, n int64, err error) {
+	for {
+		head, err := r.Peek(10)
+		if err != nil || string(head[:3]) != "ID3" {
+			return tags, n, nil
+		}
+		major, flags := head[3], head[5]
+		size := syncsafe(head[6:10])
+		total := 10 + size
+		if flags&0x10 != 0 {
+			total += 10 // footer
+		}
+		if _, err = r.Discard(10); err != nil {
+			return tags, n, err
+		}
+		n += int64(total)
+		if size > maxTagSize || major < 2 || major > 4 {
+			if _, err = r.Discard(total - 10); err != nil {
+				return tags, n, err
+			}
+			continue
+		}
+		body := make([]byte, total-10)
+		if _, err = io.ReadFull(r, body); err != nil {
+			return tags, n, err
+		}
+		body = body[:size]
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		parseID3v2(major, flags, body, tags)
+	}
+}
+
+func parseID3v2(major, flags byte, b []byte, tags map[string]string) {
+	if flags&0x80 != 0 && major < 4 {
+		b = unsync(b) // the whole tag, frames of v2.4 are unsynchronized separately
+	}
+	if flags&0x40 != 0 && major >= 3 && len(b) >= 4 {
+		// extended header, the size of v2.3 excludes itself
+		ext := int(binary.BigEndian.Uint32(b)) + 4
+		if major == 4 {
+			ext = syncsafe(b[:4])
+		}
+		if ext > len(b) {
+			return
+		}
+		b = b[ext:]
+	}
+	idLen, headLen := 4, 10
+	if major == 2 {
+		idLen, headLen = 3, 6
+	}
+	for len(b) >= headLen && b[0] != 0 {
+		id := string(b[:idLen])
+		var size int
+		var fflags uint16
+		switch major {
+		case 2:
+			size = int(b[3])<<16 | int(b[4])<<8 | int(b[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(b[4:]))
+			fflags = binary.BigEndian.Uint16(b[8:])
+		default:
+			size = syncsafe(b[4:8])
+			fflags = binary.BigEndian.Uint16(b[8:])
+		}
+		if size > len(b)-headLen {
+			return
+		}
+		data := b[headLen : headLen+size]
+		b = b[headLen+size:]
+
+		if major == 3 && fflags&0x00c0 != 0 || major == 4 && fflags&0x000c != 0 {
+			continue // compressed or encrypted
+		}
+		if major == 4 {
+			if fflags&0x0001 != 0 { // data length indicator
+				if len(data) < 4 {
+					continue
+				}
+				data = data[4:]
+			}
+			if fflags&0x0002 != 0 {
+				data = unsync(append([]byte(nil), data...))
+			}
+		}
+		if major == 2 {
+			id = id3v22Names[id]
+		}
+		addID3Frame(tags, id, data)
+	}
+}
+
+// the text frames of v2.2 in the names of v2.3
+var id3v22Names = map[string]string{
+	"TT2": "TIT2", "TP1": "TPE1", "TP2": "TPE2", "TAL": "TALB", "TYE": "TYER",
+	"TRK": "TRCK", "TCO": "TCON", "TCM": "TCOM", "TXX": "TXXX", "COM": "COMM",
+}
+
+func addID3Frame(tags map[string]string, id string, data []byte) {
+	if len(data) < 1 {
+		return
+	}
+	enc, data := data[0], data[1:]
+	var values []string
+	switch {
+	case id == "TXXX":
+		fields := decodeStrings(enc, data)
+		if len(fields) < 2 {
+			return
+		}
+		id = "TXXX:" + fields[0]
+		values = fields[1:]
+	case id == "COMM":
+		if len(data) < 3 {
+			return
+		}
+		fields := decodeStrings(enc, data[3:]) // skip the language
+		if len(fields) < 2 {
+			return
+		}
+		values = fields[1:]
+	case strings.HasPrefix(id, "T"):
+		values = decodeStrings(enc, data)
+	default:
+		return
+	}
+	v := strings.Join(values, "|")
+	if v0, ok := tags[id]; ok {
+		tags[id] = v0 + "|" + v
+	} else {
+		tags[id] = v
+	}
+}
+
+// decodeStrings decodes the null separated strings of the text encoding
+func decodeStrings(enc byte, b []byte) (out []string) {
+	if enc == 1 || enc == 2 {
+		// UTF-16, the null is 2 bytes aligned
+		for len(b) >= 2 {
+			end := len(b) &^ 1
+			for i := 0; i+1 < len(b); i += 2 {
+				if b[i] == 0 && b[i+1] == 0 {
+					end = i
+					break
+				}
+			}
+			out = append(out, decodeUTF16(enc, b[:end]))
+			if end+2 > len(b) {
+				break
+			}
+			b = b[end+2:]
+		}
+	} else {
+		for _, s := range bytes.Split(b, []byte{0}) {
+			if enc == 0 {
+				r := make([]rune, len(s)) // ISO-8859-1
+				for i, c := range s {
+					r[i] = rune(c)
+				}
+				out = append(out, string(r))
+			} else {
+				out = append(out, string(s))
+			}
+		}
+	}
+	// drop the terminating null
+	if len(out) > 1 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+func decodeUTF16(enc byte, b []byte) string {
+	bigEndian := enc == 2
+	if enc == 1 && len(b) >= 2 {
+		switch {
+		case b[0] == 0xfe && b[1] == 0xff:
+			bigEndian, b = true, b[2:]
+		case b[0] == 0xff && b[1] == 0xfe:
+			b = b[2:]
+		}
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		if bigEndian {
+			u[i] = binary.BigEndian.Uint16(b[2*i:])
+		} else {
+			u[i] = binary.LittleEndian.Uint16(b[2*i:])
+		}
+	}
+	return string(utf16.Decode(u))
+}
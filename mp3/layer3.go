@@ -0,0 +1,525 @@
+package mp3
+
+import "math"
+
+// granuleInfo is the side information of a granule of a channel
+type granuleInfo struct {
+	part23Length     int // bits of the scalefactors and huffman data
+	bigValues        int
+	globalGain       int
+	scalefacCompress int
+	blockType        int // 0 normal, 1 start, 2 short and 3 stop
+	mixed            bool
+	tableSelect      [3]int
+	subblockGain     [3]int
+	region0Count     int
+	region1Count     int
+	preflag          bool
+	scalefacScale    uint
+	count1Table      int
+}
+
+// kind of the blocks, 0 long, 1 short and 2 mixed
+func (g *granuleInfo) kind() int {
+	switch {
+	case g.blockType != 2:
+		return 0
+	case g.mixed:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// sideInfo of a frame, MPEG-2 and 2.5 have one granule
+type sideInfo struct {
+	mainDataBegin int
+	scfsi         [2][4]bool
+	gr            [2][2]granuleInfo
+}
+
+// readSideInfo reads the side information after the header and CRC
+func readSideInfo(h *FrameHeader, b []byte, si *sideInfo) {
+	bs := bitstream{b: b}
+	channels, granules := h.Channels(), 1
+	if h.Version == MPEG1 {
+		granules = 2
+		si.mainDataBegin = bs.read(9)
+		if channels == 1 {
+			bs.read(5)
+		} else {
+			bs.read(3)
+		}
+		for ch := 0; ch < channels; ch++ {
+			for i := range si.scfsi[ch] {
+				si.scfsi[ch][i] = bs.read(1) == 1
+			}
+		}
+	} else {
+		si.mainDataBegin = bs.read(8)
+		bs.read(channels)
+	}
+	for gr := 0; gr < granules; gr++ {
+		for ch := 0; ch < channels; ch++ {
+			g := &si.gr[gr][ch]
+			g.part23Length = bs.read(12)
+			g.bigValues = bs.read(9)
+			g.globalGain = bs.read(8)
+			if h.Version == MPEG1 {
+				g.scalefacCompress = bs.read(4)
+			} else {
+				g.scalefacCompress = bs.read(9)
+			}
+			if bs.read(1) == 1 { // window switching
+				g.blockType = bs.read(2)
+				g.mixed = bs.read(1) == 1
+				for i := 0; i < 2; i++ {
+					g.tableSelect[i] = bs.read(5)
+				}
+				g.tableSelect[2] = 0
+				for i := range g.subblockGain {
+					g.subblockGain[i] = bs.read(3)
+				}
+				// the regions are implied, the third region is empty
+				g.region0Count, g.region1Count = 7, 36
+				if g.blockType == 2 && !g.mixed {
+					g.region0Count = 8
+				}
+			} else {
+				g.blockType, g.mixed = 0, false
+				for i := range g.tableSelect {
+					g.tableSelect[i] = bs.read(5)
+				}
+				g.subblockGain = [3]int{}
+				g.region0Count = bs.read(4)
+				g.region1Count = bs.read(3)
+			}
+			g.preflag = false
+			if h.Version == MPEG1 {
+				g.preflag = bs.read(1) == 1
+			}
+			g.scalefacScale = uint(bs.read(1))
+			g.count1Table = bs.read(1)
+		}
+	}
+}
+
+// band is a scalefactor band of a granule in the order of bitstream, win is the window of
+// short blocks, -1 of long blocks
+type band struct {
+	start, end int
+	sfb, win   int
+}
+
+// bandTables are the scalefactor bands of the sample rates, of long, short and mixed
+// blocks. the bands of the short blocks are interleaved by window. the long bands of
+// mixed blocks cover the first 2 subbands.
+var bandTables = func() (t [9][3][]band) {
+	for r := range t {
+		long, short := longBands[r][:], shortBands[r][:]
+		shortOf := func(from int) (bands []band) {
+			for s := from; s < 13; s++ {
+				width := short[s+1] - short[s]
+				for w := 0; w < 3; w++ {
+					start := 3*short[s] + w*width
+					bands = append(bands, band{start, start + width, s, w})
+				}
+			}
+			return bands
+		}
+		for sfb := 0; sfb < 22; sfb++ {
+			t[r][0] = append(t[r][0], band{long[sfb], long[sfb+1], sfb, -1})
+		}
+		t[r][1] = shortOf(0)
+		s := 0
+		for sfb := 0; long[sfb] < 36; sfb++ {
+			t[r][2] = append(t[r][2], band{long[sfb], long[sfb+1], sfb, -1})
+		}
+		for 3*short[s] < 36 {
+			s++
+		}
+		t[r][2] = append(t[r][2], shortOf(s)...)
+	}
+	return t
+}()
+
+// rateIndex reports the index of sample rate in the tables
+func (h *FrameHeader) rateIndex() int {
+	for i, rate := range sampleRates[h.Version] {
+		if rate == h.SampleRate {
+			return int(h.Version)*3 + i
+		}
+	}
+	return 0
+}
+
+// intensity reports whether the intensity stereo is on
+func (h *FrameHeader) intensity() bool {
+	return h.Mode == JointStereo && h.ModeExtension&1 != 0
+}
+
+// midSide reports whether the middle/side stereo is on
+func (h *FrameHeader) midSide() bool {
+	return h.Mode == JointStereo && h.ModeExtension&2 != 0
+}
+
+// scalefactors of a channel. the values equal to max are the illegal intensity positions.
+type scalefactors struct {
+	l, maxL [22]int
+	s, maxS [13][3]int
+}
+
+// readScalefactors reads the part 2 of the main data, the scalefactors of MPEG-1 are
+// reused from the first granule by scfsi
+func readScalefactors(bs *bitstream, h *FrameHeader, si *sideInfo, gr, ch int, bands []band, sf *scalefactors) {
+	g := &si.gr[gr][ch]
+	var counts, lens [4]int
+	kind := g.kind()
+	if h.Version == MPEG1 {
+		s1, s2 := slen[g.scalefacCompress][0], slen[g.scalefacCompress][1]
+		switch kind {
+		case 0:
+			counts, lens = [4]int{6, 5, 5, 5}, [4]int{s1, s1, s2, s2}
+		case 1:
+			counts, lens = [4]int{18, 18}, [4]int{s1, s2}
+		case 2:
+			counts, lens = [4]int{17, 18}, [4]int{s1, s2}
+		}
+	} else {
+		row, c := 0, g.scalefacCompress
+		if ch == 1 && h.intensity() {
+			switch c >>= 1; {
+			case c < 180:
+				row, lens = 3, [4]int{c / 36, c % 36 / 6, c % 6}
+			case c < 244:
+				c -= 180
+				row, lens = 4, [4]int{c >> 4, c >> 2 & 3, c & 3}
+			default:
+				c -= 244
+				row, lens = 5, [4]int{c / 3, c % 3}
+			}
+		} else {
+			switch {
+			case c < 400:
+				row, lens = 0, [4]int{c >> 4 / 5, c >> 4 % 5, c >> 2 & 3, c & 3}
+			case c < 500:
+				c -= 400
+				row, lens = 1, [4]int{c >> 2 / 5, c >> 2 % 5, c & 3}
+			default:
+				c -= 500
+				row, lens = 2, [4]int{c / 3, c % 3}
+				g.preflag = true
+			}
+		}
+		counts = nrOfSfb[row][kind]
+	}
+
+	// the last band of each window has no scalefactor
+	slots := len(bands) - 1
+	if kind != 0 {
+		slots = len(bands) - 3
+	}
+	k := 0
+	for p, n := range counts {
+		if h.Version == MPEG1 && gr == 1 && kind == 0 && si.scfsi[ch][p] {
+			k += n
+			continue
+		}
+		max := 1<<uint(lens[p]) - 1
+		if h.Version == MPEG1 {
+			max = 7
+		}
+		for ; n > 0; n-- {
+			v := bs.read(lens[p])
+			if k < slots {
+				if b := bands[k]; b.win < 0 {
+					sf.l[b.sfb], sf.maxL[b.sfb] = v, max
+				} else {
+					sf.s[b.sfb][b.win], sf.maxS[b.sfb][b.win] = v, max
+				}
+			}
+			k++
+		}
+	}
+}
+
+// regions reports the beginnings of the region 1 and 2 of big values
+func (g *granuleInfo) regions(rate int) (r1, r2 int) {
+	switch g.kind() {
+	case 1:
+		return 3 * shortBands[rate][3], 576
+	case 2:
+		return 36, 576
+	}
+	r1 = longBands[rate][minInt(g.region0Count+1, 22)]
+	r2 = longBands[rate][minInt(g.region0Count+g.region1Count+2, 22)]
+	return r1, r2
+}
+
+// readHuffman reads the part 3 of the main data, the quantized values, end is the bit
+// position after the part. it reports false if a table is invalid.
+func readHuffman(bs *bitstream, g *granuleInfo, rate, end int, is *[576]int) bool {
+	n := minInt(2*g.bigValues, 576)
+	r1, r2 := g.regions(rate)
+	i := 0
+	for ; i < n; i += 2 {
+		table := g.tableSelect[0]
+		if i >= r2 {
+			table = g.tableSelect[2]
+		} else if i >= r1 {
+			table = g.tableSelect[1]
+		}
+		if table == 0 {
+			is[i], is[i+1] = 0, 0
+			continue
+		}
+		t := huffmanTrees[table]
+		if t == nil {
+			return false
+		}
+		v := bs.decode(t)
+		x, y := v/huffmanSizes[table], v%huffmanSizes[table]
+		is[i] = readLinbits(bs, x, linbits[table])
+		is[i+1] = readLinbits(bs, y, linbits[table])
+	}
+
+	t := huffmanTrees[32+g.count1Table]
+	for i+4 <= 576 && bs.pos < end {
+		v := bs.decode(t)
+		q := [4]int{v >> 3 & 1, v >> 2 & 1, v >> 1 & 1, v & 1}
+		for j := range q {
+			q[j] = signed(bs, q[j])
+		}
+		if bs.pos > end {
+			break // the last quadruple overruns the part
+		}
+		copy(is[i:], q[:])
+		i += 4
+	}
+	for ; i < 576; i++ {
+		is[i] = 0
+	}
+	return true
+}
+
+// readLinbits reads the linbits of x of 15, then the sign bit
+func readLinbits(bs *bitstream, x int, linbits uint) int {
+	if x == 15 && linbits != 0 {
+		x += bs.read(int(linbits))
+	}
+	return signed(bs, x)
+}
+
+// signed reads the sign bit of a nonzero x
+func signed(bs *bitstream, x int) int {
+	if x != 0 && bs.read(1) == 1 {
+		return -x
+	}
+	return x
+}
+
+// pow43 is x^(4/3) of the quantized values
+var pow43 = func() (t [8207]float32) {
+	for i := range t {
+		t[i] = float32(math.Pow(float64(i), 4.0/3))
+	}
+	return t
+}()
+
+// requantize scales the quantized values of the bands into xr
+func requantize(g *granuleInfo, sf *scalefactors, bands []band, is *[576]int, xr *[576]float32) {
+	*xr = [576]float32{}
+	for _, b := range bands {
+		// the exponent of 2 in 1/4
+		e := g.globalGain - 210
+		if b.win < 0 {
+			k := sf.l[b.sfb]
+			if g.preflag {
+				k += pretab[b.sfb]
+			}
+			e -= k << (1 + g.scalefacScale)
+		} else {
+			e -= 8*g.subblockGain[b.win] + sf.s[b.sfb][b.win]<<(1+g.scalefacScale)
+		}
+		scale := float32(math.Exp2(float64(e) / 4))
+		for i := b.start; i < b.end; i++ {
+			if x := is[i]; x > 0 {
+				xr[i] = pow43[x] * scale
+			} else if x < 0 {
+				xr[i] = -pow43[-x] * scale
+			}
+		}
+	}
+}
+
+// the intensity ratios of MPEG-1, tan(pos*pi/12) / (1+tan(pos*pi/12))
+var intensityRatios = func() (t [7]float32) {
+	for i := range t {
+		k := math.Tan(float64(i) * math.Pi / 12)
+		t[i] = float32(k / (1 + k))
+	}
+	t[6] = 1
+	return t
+}()
+
+// stereo restores the left and right channels of joint stereo, the bands are of the right
+// channel. the intensity stereo applies to the bands above the last nonzero band of the
+// right channel in each window.
+func stereo(h *FrameHeader, g *granuleInfo, sf *scalefactors, bands []band, l, r *[576]float32) {
+	ms := h.midSide()
+	if !h.intensity() {
+		if ms {
+			midSide(l[:], r[:])
+		}
+		return
+	}
+	top := [3]int{-1, -1, -1}
+	for i, b := range bands {
+		for _, x := range r[b.start:b.end] {
+			if x != 0 {
+				top[maxInt(b.win, 0)] = i
+				break
+			}
+		}
+	}
+	if bands[0].win < 0 {
+		m := maxInt(maxInt(top[0], top[1]), top[2])
+		top = [3]int{m, m, m}
+	}
+	// the io of MPEG-2, by the intensity_scale
+	io := math.Exp2(-0.25 * float64(1+g.scalefacCompress&1))
+	for i, b := range bands {
+		// the band without scalefactor takes the position of the band below
+		var pos, max int
+		if b.win < 0 {
+			sfb := minInt(b.sfb, 20)
+			pos, max = sf.l[sfb], sf.maxL[sfb]
+		} else {
+			sfb := minInt(b.sfb, 11)
+			pos, max = sf.s[sfb][b.win], sf.maxS[sfb][b.win]
+		}
+		if i <= top[maxInt(b.win, 0)] || pos == max || h.Version == MPEG1 && pos > 6 {
+			if ms {
+				midSide(l[b.start:b.end], r[b.start:b.end])
+			}
+			continue
+		}
+		var kl, kr float32
+		switch {
+		case h.Version == MPEG1:
+			kl = intensityRatios[pos]
+			kr = 1 - kl
+		case pos&1 == 1:
+			kl, kr = float32(math.Pow(io, float64(pos+1)/2)), 1
+		default:
+			kl, kr = 1, float32(math.Pow(io, float64(pos)/2))
+		}
+		for j := b.start; j < b.end; j++ {
+			x := l[j]
+			l[j], r[j] = x*kl, x*kr
+		}
+	}
+}
+
+// midSide converts the middle and side into left and right
+func midSide(l, r []float32) {
+	for i := range l {
+		m, s := l[i], r[i]
+		l[i], r[i] = (m+s)*math.Sqrt2/2, (m-s)*math.Sqrt2/2
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// maxReservoir is the bytes of main data kept from the previous frames, the
+// main_data_begin is up to 511
+const maxReservoir = 511
+
+// decoder is the state of the Layer III decoding across frames
+type decoder struct {
+	si        sideInfo
+	reservoir []byte
+	sf        [2]scalefactors
+	is        [576]int
+	xr        [2][576]float32
+	overlap   [2][576]float32
+	fifo      [2][1024]float32
+	pcm       []float32
+}
+
+// decode decodes the frame into the interleaved pcm. the granules whose main data
+// begins before the reservoir, or which are corrupted, are decoded as silence.
+func (d *decoder) decode(h *FrameHeader, frame []byte) []float32 {
+	channels, granules := h.Channels(), 1
+	if h.Version == MPEG1 {
+		granules = 2
+	}
+	off := 4
+	if h.Protected {
+		off += 2
+	}
+	end := minInt(off+h.sideInfoSize(), len(frame))
+	si := &d.si
+	readSideInfo(h, frame[off:end], si)
+
+	avail := len(d.reservoir)
+	buf := append(d.reservoir, frame[end:]...)
+	ok := si.mainDataBegin <= avail
+	bs := bitstream{}
+	if ok {
+		bs.b = buf[avail-si.mainDataBegin:]
+	}
+
+	rate := h.rateIndex()
+	if n := granules * 576 * channels; cap(d.pcm) < n {
+		d.pcm = make([]float32, n)
+	}
+	d.pcm = d.pcm[:granules*576*channels]
+	for gr := 0; gr < granules; gr++ {
+		for ch := 0; ch < channels; ch++ {
+			g := &si.gr[gr][ch]
+			bands := bandTables[rate][g.kind()]
+			end := bs.pos + g.part23Length
+			if ok {
+				readScalefactors(&bs, h, si, gr, ch, bands, &d.sf[ch])
+			}
+			if ok && readHuffman(&bs, g, rate, end, &d.is) {
+				requantize(g, &d.sf[ch], bands, &d.is, &d.xr[ch])
+			} else {
+				d.xr[ch] = [576]float32{}
+			}
+			bs.pos = end
+		}
+		if channels == 2 {
+			g := &si.gr[gr][1]
+			stereo(h, g, &d.sf[1], bandTables[rate][g.kind()], &d.xr[0], &d.xr[1])
+		}
+		for ch := 0; ch < channels; ch++ {
+			g := &si.gr[gr][ch]
+			if g.blockType == 2 {
+				reorder(bandTables[rate][g.kind()], &d.xr[ch])
+			}
+			antialias(g, &d.xr[ch])
+			hybrid(g, &d.xr[ch], &d.overlap[ch])
+			synthesize(&d.xr[ch], &d.fifo[ch], d.pcm[gr*576*channels+ch:], channels)
+		}
+	}
+
+	if len(buf) > maxReservoir {
+		buf = append(buf[:0], buf[len(buf)-maxReservoir:]...)
+	}
+	d.reservoir = buf
+	return d.pcm
+}
@@ -0,0 +1,352 @@
+// Package mp3 decodes MPEG-1, 2 and 2.5 Layer III streams.
+//
+// the frames are located with sync and resync, the ID3v2 tags are read, and the Xing,
+// LAME and VBRI headers give the length and the gapless encoder delay and padding, which
+// are trimmed from the output of Read. the raw frames can be taken by ReadFrame instead.
+package mp3
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/toy80/audio/wav"
+)
+
+// ErrFormat indicates no Layer III frame is found
+var ErrFormat = errors.New("mp3: no mpeg layer III frame found")
+
+// MP3 decoder
+type MP3 struct {
+	r       io.Reader
+	br      *bufio.Reader
+	seeker  io.ReadSeeker // nil if not seekable
+	tags    map[string]string
+	tagSize int64 // bytes of the ID3v2 tags
+
+	first   FrameHeader // of the first audio frame
+	vbr     *VBRInfo
+	pending []byte // the first audio frame, read by New
+	frame   []byte
+	frames  int64 // audio frames, -1 if unknown
+	outType wav.Type
+
+	dec     decoder
+	skip    int   // samples of the gapless trim not discarded yet
+	emitted int64 // samples returned after the trim
+	out     []byte
+	outBuf  []byte // the part of out not read yet
+	fault   error
+}
+
+// New mp3 decoder, the ID3v2 tags and the first frame are read from r. if r is an
+// io.ReadSeeker, and the stream has no Xing or VBRI header, the frames are counted
+// by scanning the stream.
+func New(r io.Reader, t wav.Type) (*MP3, error) {
+	if t.Bits() == 0 {
+		return nil, fmt.Errorf("mp3: unsupported target PCM format %s", t)
+	}
+	m := &MP3{r: r, outType: t, frames: -1}
+	var begin int64
+	if rs, ok := r.(io.ReadSeeker); ok {
+		var err error
+		if begin, err = rs.Seek(0, io.SeekCurrent); err == nil {
+			m.seeker = rs
+		}
+	}
+	m.br = bufio.NewReader(r)
+	var err error
+	if m.tags, m.tagSize, err = readID3v2(m.br); err != nil {
+		return nil, err
+	}
+	h, frame, err := m.ReadFrame()
+	if err != nil {
+		if err == io.EOF {
+			err = ErrFormat
+		}
+		return nil, err
+	}
+	m.first = h
+	if m.vbr, _ = parseVBRInfo(&h, frame); m.vbr != nil {
+		if m.vbr.Frames > 0 {
+			m.frames = m.vbr.Frames
+		}
+		if m.first, frame, err = m.ReadFrame(); err != nil {
+			if err == io.EOF {
+				err = ErrFormat
+			}
+			return nil, err
+		}
+	}
+	m.pending = append([]byte(nil), frame...)
+
+	if m.frames < 0 && m.seeker != nil {
+		// count the frames, then come back
+		pos := begin + m.tagSize
+		if m.frames, err = m.countFrames(); err != nil {
+			return nil, err
+		}
+		if _, err = m.seeker.Seek(pos, io.SeekStart); err != nil {
+			return nil, err
+		}
+		m.br.Reset(m.seeker)
+		m.pending = nil
+		if m.vbr != nil {
+			m.ReadFrame()
+		}
+		if _, frame, err = m.ReadFrame(); err != nil {
+			return nil, err
+		}
+		m.pending = append([]byte(nil), frame...)
+	}
+	m.skip, _ = m.GaplessTrim()
+	return m, nil
+}
+
+// Open the mp3 file, the output is 16bits signed
+func Open(filename string) (*MP3, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	m, err := New(f, wav.I16)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Close closes the underlying reader if it is an io.Closer
+func (m *MP3) Close() error {
+	if c, ok := m.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Tags reports the text frames of ID3v2 tags, see readID3v2
+func (m *MP3) Tags() map[string]string {
+	return m.tags
+}
+
+// Tag reports the text frame of ID3v2 tags, i.e. "TIT2" is the title
+func (m *MP3) Tag(id string) string {
+	return m.tags[id]
+}
+
+// VBRInfo reports the Xing or VBRI header, nil if there is none
+func (m *MP3) VBRInfo() *VBRInfo {
+	return m.vbr
+}
+
+// Header reports the header of the first audio frame
+func (m *MP3) Header() FrameHeader {
+	return m.first
+}
+
+// SampleType reports the output sample type
+func (m *MP3) SampleType() wav.Type {
+	return m.outType
+}
+
+// Frequency reports the sample rate
+func (m *MP3) Frequency() int {
+	return m.first.SampleRate
+}
+
+// NumTracks reports the channels
+func (m *MP3) NumTracks() int {
+	return m.first.Channels()
+}
+
+// NumFrames reports the PCM frames after the encoder delay and padding are removed,
+// -1 if unknown
+func (m *MP3) NumFrames() int64 {
+	if m.frames < 0 {
+		return -1
+	}
+	n := m.frames * int64(m.first.Samples())
+	if m.vbr != nil {
+		n -= int64(m.vbr.Delay + m.vbr.Padding)
+	}
+	if n < 0 {
+		n = 0
+	}
+	return n
+}
+
+// GaplessTrim reports the PCM frames to discard from the beginning and the end of the
+// decoded output, the decoder delay of the synthesis filterbank is counted
+func (m *MP3) GaplessTrim() (begin, end int) {
+	if m.vbr == nil || m.vbr.Delay == 0 && m.vbr.Padding == 0 {
+		return 0, 0
+	}
+	begin = m.vbr.Delay + decoderDelay
+	end = m.vbr.Padding - decoderDelay
+	if end < 0 {
+		end = 0
+	}
+	return begin, end
+}
+
+// Duration of the stream, 0 if unknown
+func (m *MP3) Duration() time.Duration {
+	n := m.NumFrames()
+	if n <= 0 {
+		return 0
+	}
+	return time.Second * time.Duration(n) / time.Duration(m.first.SampleRate)
+}
+
+// Read decodes the PCM, the gapless trim is applied, so the samples are NumFrames if it
+// is known
+func (m *MP3) Read(buf []byte) (n int, err error) {
+	for len(buf) != 0 {
+		if len(m.outBuf) != 0 {
+			n1 := copy(buf, m.outBuf)
+			buf = buf[n1:]
+			m.outBuf = m.outBuf[n1:]
+			n += n1
+			continue
+		}
+		if m.fault != nil {
+			return n, m.fault
+		}
+		if err = m.decodeFrame(); err != nil {
+			m.fault = err
+			if n != 0 {
+				err = nil
+			}
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// decodeFrame decodes the next frame into outBuf, it may be empty
+func (m *MP3) decodeFrame() error {
+	h, data, err := m.ReadFrame()
+	if err != nil {
+		return err
+	}
+	pcm := m.dec.decode(&h, data)
+	channels := m.first.Channels()
+	samples := len(pcm) / channels
+	begin := minInt(m.skip, samples)
+	m.skip -= begin
+	end := samples
+	if total := m.NumFrames(); total >= 0 && m.emitted+int64(end-begin) > total {
+		end = begin + int(total-m.emitted)
+	}
+	m.emitted += int64(end - begin)
+	m.output(pcm[begin*channels : end*channels])
+	return nil
+}
+
+// output converts the pcm into outBuf
+func (m *MP3) output(pcm []float32) {
+	size := m.outType.Bits() / 8
+	if cap(m.out) < len(pcm)*size {
+		m.out = make([]byte, len(pcm)*size)
+	}
+	b := m.out[:len(pcm)*size]
+	for i, x := range pcm {
+		if x > 1 {
+			x = 1
+		} else if x < -1 {
+			x = -1
+		}
+		switch m.outType {
+		case wav.U8:
+			b[i] = byte(x*127 + 128)
+		case wav.I16:
+			v := uint16(int16(x * 32767))
+			b[2*i], b[2*i+1] = byte(v), byte(v>>8)
+		case wav.I24:
+			v := uint32(int32(float64(x) * (1<<23 - 1)))
+			b[3*i], b[3*i+1], b[3*i+2] = byte(v), byte(v>>8), byte(v>>16)
+		case wav.I32:
+			v := uint32(int32(float64(x) * (1<<31 - 1)))
+			b[4*i], b[4*i+1], b[4*i+2], b[4*i+3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+		case wav.F32:
+			v := math.Float32bits(x)
+			b[4*i], b[4*i+1], b[4*i+2], b[4*i+3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+		case wav.F64:
+			v := math.Float64bits(float64(x))
+			for j := 0; j < 8; j++ {
+				b[8*i+j] = byte(v >> (8 * j))
+			}
+		}
+	}
+	m.outBuf = b
+}
+
+// ReadFrame reads the next frame, the header is included in data, which is valid until
+// the next call. the data between frames is skipped, a frame is accepted after resync
+// only if the next frame follows it. io.EOF is returned at the end of stream.
+func (m *MP3) ReadFrame() (h FrameHeader, data []byte, err error) {
+	if m.pending != nil {
+		data, m.pending = m.pending, nil
+		h, _ = parseHeader(data)
+		return h, data, nil
+	}
+	synced := true
+	for {
+		b, err := m.br.Peek(4)
+		if len(b) < 4 {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return h, nil, err
+		}
+		var ok bool
+		if h, ok = parseHeader(b); ok && m.first.SampleRate != 0 {
+			ok = h.similar(&m.first)
+		}
+		if ok && !synced {
+			// the next frame must follow, unless the stream ends
+			next, _ := m.br.Peek(h.Size() + 4)
+			if len(next) == h.Size()+4 {
+				h2, ok2 := parseHeader(next[h.Size():])
+				ok = ok2 && h2.similar(&h)
+			}
+		}
+		if !ok {
+			synced = false
+			m.br.Discard(1)
+			continue
+		}
+		if cap(m.frame) < h.Size() {
+			m.frame = make([]byte, h.Size())
+		}
+		data = m.frame[:h.Size()]
+		if _, err = io.ReadFull(m.br, data); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF // the last frame is truncated
+			}
+			return h, nil, err
+		}
+		return h, data, nil
+	}
+}
+
+// countFrames counts the audio frames from current position to the end
+func (m *MP3) countFrames() (n int64, err error) {
+	if m.pending != nil {
+		n++
+		m.pending = nil
+	}
+	for {
+		if _, _, err = m.ReadFrame(); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
@@ -0,0 +1,243 @@
+package mp3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/toy80/audio/wav"
+)
+
+// MPEG-1 Layer III, 128 kbit/s, 44100 Hz, joint stereo, the frame is 417 bytes
+var frameHeader = []byte{0xff, 0xfb, 0x90, 0x40}
+
+func testFrame() []byte {
+	f := make([]byte, 417)
+	copy(f, frameHeader)
+	return f
+}
+
+func infoFrame(frames, delay, padding int) []byte {
+	f := testFrame()
+	b := f[4+32:]
+	copy(b, "Info")
+	binary.BigEndian.PutUint32(b[4:], 0x0f)
+	binary.BigEndian.PutUint32(b[8:], uint32(frames))
+	binary.BigEndian.PutUint32(b[12:], uint32(frames*417))
+	lame := b[8+8+100+4:]
+	copy(lame, "LAME3.100")
+	lame[21] = byte(delay >> 4)
+	lame[22] = byte(delay<<4) | byte(padding>>8)
+	lame[23] = byte(padding)
+	return f
+}
+
+func id3Frame(id string, data []byte) []byte {
+	b := append([]byte(id), 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[4:], uint32(len(data)))
+	return append(b, data...)
+}
+
+func id3Tag(major byte, frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	n := len(body)
+	return append([]byte{'I', 'D', '3', major, 0, 0, byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}, body...)
+}
+
+func TestHeader(t *testing.T) {
+	h, ok := parseHeader(frameHeader)
+	if !ok || h.Version != MPEG1 || h.Bitrate != 128 || h.SampleRate != 44100 || h.Mode != JointStereo || h.Size() != 417 || h.Samples() != 1152 {
+		t.Fatalf("header %+v", h)
+	}
+	// MPEG-2.5, 8 kbit/s, 8000 Hz, mono, padded
+	h, ok = parseHeader([]byte{0xff, 0xe3, 0x1a, 0xc0})
+	if !ok || h.Version != MPEG25 || h.Bitrate != 8 || h.SampleRate != 8000 || h.Channels() != 1 || h.Size() != 73 || h.Samples() != 576 {
+		t.Fatalf("header %+v", h)
+	}
+	for _, b := range [][]byte{
+		{0xff, 0xfd, 0x90, 0x40}, // layer II
+		{0xff, 0xfb, 0x00, 0x40}, // free format
+		{0xff, 0xfb, 0xf0, 0x40}, // bad bitrate
+		{0xff, 0xfb, 0x9c, 0x40}, // bad sample rate
+		{0xff, 0xf3, 0x90, 0x42}, // reserved emphasis
+	} {
+		if _, ok = parseHeader(b); ok {
+			t.Fatalf("%x: expect invalid header", b)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	title := []byte{1, 0xff, 0xfe, 'S', 0, 0xf6, 0, 'n', 0, 'g', 0, 0, 0} // UTF-16 "Söng"
+	tag := id3Tag(3,
+		id3Frame("TIT2", title),
+		id3Frame("TPE1", []byte("\x00Caf\xe9\x00Band")),
+		id3Frame("TXXX", []byte("\x03replaygain_track_gain\x00-6.5 dB")),
+		id3Frame("COMM", []byte("\x00engdesc\x00nice")),
+		id3Frame("APIC", make([]byte, 100)),
+		make([]byte, 20), // padding
+	)
+
+	var data []byte
+	data = append(data, tag...)
+	for i := 0; i < 3; i++ {
+		data = append(data, testFrame()...)
+	}
+	m, err := New(bytes.NewReader(data), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]string{
+		"TIT2":                       "Söng",
+		"TPE1":                       "Café|Band",
+		"TXXX:replaygain_track_gain": "-6.5 dB",
+		"COMM":                       "nice",
+	} {
+		if got := m.Tag(id); got != want {
+			t.Fatalf("%s: %q, want %q", id, got, want)
+		}
+	}
+	if m.NumFrames() != 3*1152 {
+		t.Fatalf("%d frames", m.NumFrames())
+	}
+}
+
+func TestFrames(t *testing.T) {
+	var data []byte
+	data = append(data, infoFrame(10, 576, 1000)...)
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			// garbage with a false sync
+			data = append(data, 0x00, 0xff, 0xfb, 0x90, 0x40, 0x12, 0x34)
+		}
+		data = append(data, testFrame()...)
+	}
+	data = append(data, "TAG"...)
+	data = append(data, make([]byte, 125)...)
+
+	m, err := New(io.MultiReader(bytes.NewReader(data)), wav.I16) // not seekable
+	if err != nil {
+		t.Fatal(err)
+	}
+	vbr := m.VBRInfo()
+	if vbr == nil || vbr.Tag != "Info" || vbr.Frames != 10 || vbr.Encoder != "LAME3.100" || vbr.Delay != 576 || vbr.Padding != 1000 {
+		t.Fatalf("vbr info %+v", vbr)
+	}
+	want := int64(10*1152 - 576 - 1000)
+	if m.NumFrames() != want || m.Duration() != time.Second*time.Duration(want)/44100 {
+		t.Fatalf("%d frames, duration %v", m.NumFrames(), m.Duration())
+	}
+	if b, e := m.GaplessTrim(); b != 576+529 || e != 1000-529 {
+		t.Fatalf("gapless trim %d %d", b, e)
+	}
+	n := 0
+	for {
+		h, f, err := m.ReadFrame()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if len(f) != 417 || h.Bitrate != 128 {
+			t.Fatalf("frame %d: %d bytes %+v", n, len(f), h)
+		}
+		n++
+	}
+	if n != 10 {
+		t.Fatalf("%d frames read", n)
+	}
+	// the decoded samples are trimmed
+	m, err = New(bytes.NewReader(data), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := io.ReadAll(m); err != nil || int64(len(got)) != 4*want {
+		t.Fatalf("%d frames decoded, %v", len(got)/4, err)
+	}
+
+	// count the frames without Xing header
+	m, err = New(bytes.NewReader(data[417:]), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.NumFrames() != 10*1152 || m.NumTracks() != 2 || m.Frequency() != 44100 {
+		t.Fatalf("%d frames", m.NumFrames())
+	}
+	// the frames of no main data are silence
+	got, err := io.ReadAll(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4*10*1152 {
+		t.Fatalf("%d frames decoded", len(got)/4)
+	}
+	for i, b := range got {
+		if b != 0 {
+			t.Fatalf("byte %d of silence: %d", i, b)
+		}
+	}
+
+	if _, err = New(bytes.NewReader(make([]byte, 1000)), wav.I16); err != ErrFormat {
+		t.Fatalf("expect ErrFormat, got %v", err)
+	}
+}
+
+func TestCorpus(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no .mp3 file in testdata")
+	}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := New(f, wav.F32)
+		if err != nil {
+			f.Close()
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := io.ReadAll(m)
+		m.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if int64(len(got)) != m.NumFrames()*int64(4*m.NumTracks()) {
+			t.Fatalf("%s: %d bytes decoded, %d frames", name, len(got), m.NumFrames())
+		}
+		ref, err := os.ReadFile(strings.TrimSuffix(name, ".mp3") + ".f32")
+		if os.IsNotExist(err) {
+			// no reference of MPEG-2.5, the stream must not be silent
+			var peak float64
+			for i := 0; i < len(got); i += 4 {
+				peak = math.Max(peak, math.Abs(float64(math.Float32frombits(binary.LittleEndian.Uint32(got[i:])))))
+			}
+			if peak < 0.01 {
+				t.Fatalf("%s: peak %v", name, peak)
+			}
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(ref) {
+			t.Fatalf("%s: %d bytes decoded, want %d", name, len(got), len(ref))
+		}
+		// the reference is truncated to 16 bits
+		for i := 0; i < len(got); i += 4 {
+			x := math.Float32frombits(binary.LittleEndian.Uint32(got[i:]))
+			y := math.Float32frombits(binary.LittleEndian.Uint32(ref[i:]))
+			if math.Abs(float64(x-y)) > 1.5/32767 {
+				t.Fatalf("%s: sample %d is %v, want %v", name, i/4, x, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package mp3
+
+import "math"
+
+var (
+	// the butterflies of alias reduction
+	aliasCS, aliasCA = func() (cs, ca [8]float32) {
+		for i, c := range [8]float64{-0.6, -0.535, -0.33, -0.185, -0.095, -0.041, -0.0142, -0.0037} {
+			sq := math.Sqrt(1 + c*c)
+			cs[i], ca[i] = float32(1/sq), float32(c/sq)
+		}
+		return
+	}()
+
+	// imdctLong[i][k] is cos(pi/72*(2i+1+18)*(2k+1)), imdctShort[i][k] is
+	// cos(pi/24*(2i+1+6)*(2k+1))
+	imdctLong, imdctShort = func() (l [36][18]float32, s [12][6]float32) {
+		for i := range l {
+			for k := range l[i] {
+				l[i][k] = float32(math.Cos(math.Pi / 72 * float64((2*i+1+18)*(2*k+1))))
+			}
+		}
+		for i := range s {
+			for k := range s[i] {
+				s[i][k] = float32(math.Cos(math.Pi / 24 * float64((2*i+1+6)*(2*k+1))))
+			}
+		}
+		return
+	}()
+
+	// imdctWindows of the block types, the short window is of 12 samples
+	imdctWindows = func() (w [4][36]float32) {
+		for i := 0; i < 36; i++ {
+			w[0][i] = float32(math.Sin(math.Pi / 36 * (float64(i) + 0.5)))
+		}
+		for i := 0; i < 18; i++ {
+			w[1][i], w[3][i+18] = w[0][i], w[0][i+18]
+		}
+		for i := 18; i < 24; i++ {
+			w[1][i], w[3][i-6] = 1, 1
+		}
+		for i := 24; i < 30; i++ {
+			w[1][i] = float32(math.Sin(math.Pi / 12 * (float64(i-18) + 0.5)))
+			w[3][i-18] = float32(math.Sin(math.Pi / 12 * (float64(i-24) + 0.5)))
+		}
+		for i := 0; i < 12; i++ {
+			w[2][i] = float32(math.Sin(math.Pi / 12 * (float64(i) + 0.5)))
+		}
+		return
+	}()
+
+	// synthCos[i][k] is cos((16+i)*(2k+1)*pi/64) of the matrixing
+	synthCos = func() (t [64][32]float32) {
+		for i := range t {
+			for k := range t[i] {
+				t[i][k] = float32(math.Cos(float64((16+i)*(2*k+1)) * math.Pi / 64))
+			}
+		}
+		return
+	}()
+)
+
+// reorder rearranges the short bands from the order of windows into the order of
+// subbands, 3 windows of each line are adjacent
+func reorder(bands []band, xr *[576]float32) {
+	var tmp [576]float32
+	for _, b := range bands {
+		if b.win < 0 {
+			continue
+		}
+		width := b.end - b.start
+		first := b.start - b.win*width // of the window 0
+		for j := 0; j < width; j++ {
+			tmp[first+3*j+b.win] = xr[b.start+j]
+		}
+	}
+	for _, b := range bands {
+		if b.win >= 0 {
+			copy(xr[b.start:b.end], tmp[b.start:b.end])
+		}
+	}
+}
+
+// antialias applies the butterflies between the subbands of long blocks
+func antialias(g *granuleInfo, xr *[576]float32) {
+	limit := 32
+	switch g.kind() {
+	case 1:
+		return
+	case 2:
+		limit = 2
+	}
+	for sb := 1; sb < limit; sb++ {
+		for i := 0; i < 8; i++ {
+			a, b := xr[18*sb-1-i], xr[18*sb+i]
+			xr[18*sb-1-i] = a*aliasCS[i] - b*aliasCA[i]
+			xr[18*sb+i] = b*aliasCS[i] + a*aliasCA[i]
+		}
+	}
+}
+
+// hybrid transforms the lines of subbands into the time samples by IMDCT, overlaps them
+// with the previous granule, and inverts the odd subbands
+func hybrid(g *granuleInfo, xr *[576]float32, overlap *[576]float32) {
+	for sb := 0; sb < 32; sb++ {
+		bt := g.blockType
+		if g.mixed && sb < 2 {
+			bt = 0
+		}
+		in := xr[18*sb : 18*sb+18]
+		var out [36]float32
+		if bt == 2 {
+			for w := 0; w < 3; w++ {
+				for i := 0; i < 12; i++ {
+					var sum float32
+					for k := 0; k < 6; k++ {
+						sum += in[3*k+w] * imdctShort[i][k]
+					}
+					out[6+6*w+i] += sum * imdctWindows[2][i]
+				}
+			}
+		} else {
+			for i := 0; i < 36; i++ {
+				var sum float32
+				for k := 0; k < 18; k++ {
+					sum += in[k] * imdctLong[i][k]
+				}
+				out[i] = sum * imdctWindows[bt][i]
+			}
+		}
+		prev := overlap[18*sb : 18*sb+18]
+		for i := 0; i < 18; i++ {
+			in[i] = out[i] + prev[i]
+			prev[i] = out[18+i]
+		}
+		if sb&1 == 1 {
+			for i := 1; i < 18; i += 2 {
+				in[i] = -in[i]
+			}
+		}
+	}
+}
+
+// synthesize runs the polyphase filterbank on the 18 time samples of the 32 subbands,
+// the 576 samples are written to out of the stride. v is the fifo of the filterbank.
+func synthesize(xr *[576]float32, v *[1024]float32, out []float32, stride int) {
+	for t := 0; t < 18; t++ {
+		copy(v[64:], v[:960])
+		for i := 0; i < 64; i++ {
+			var sum float32
+			for k := 0; k < 32; k++ {
+				sum += synthCos[i][k] * xr[18*k+t]
+			}
+			v[i] = sum
+		}
+		for j := 0; j < 32; j++ {
+			var sum float32
+			for i := 0; i < 512; i += 64 {
+				sum += v[2*i+j]*synthWindow[i+j] + v[2*i+96+j]*synthWindow[i+32+j]
+			}
+			out[(32*t+j)*stride] = sum
+		}
+	}
+}
@@ -0,0 +1,10 @@
+module gen
+
+go 1.16
+
+require (
+	github.com/hajimehoshi/go-mp3 v0.3.4
+	github.com/toy80/audio v0.0.0
+)
+
+replace github.com/toy80/audio => ../../..
@@ -0,0 +1,166 @@
+// Command gen cuts the streams of the mp3 corpus from the examples of go-mp3 and the
+// testdata of mimetype, and writes their reference PCM decoded by go-mp3.
+//
+// the source modules are fetched by the go command, so it must be on the PATH.
+//
+//	cd mp3/testdata/gen && go run .
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	gomp3 "github.com/hajimehoshi/go-mp3"
+	"github.com/toy80/audio/mp3"
+)
+
+// a stream of the corpus, of the frames [first, last) of the source
+type stream struct {
+	name   string
+	module string
+	source string // the path in module
+	first  int
+	last   int  // 0 for all frames
+	silent bool // the first frame is silenced, keeping its main data for the reservoir
+	ref    bool // go-mp3 has no MPEG-2.5 support
+}
+
+var streams = []stream{
+	{"classic", "github.com/hajimehoshi/go-mp3@v0.3.4", "example/classic.mp3", 0, 40, false, true},
+	{"mpeg2", "github.com/hajimehoshi/go-mp3@v0.3.4", "example/mpeg2.mp3", 0, 150, false, true},
+	{"jointstereo", "github.com/gabriel-vasile/mimetype@v1.4.3", "testdata/mp3.mp3", 60, 100, true, true},
+	{"mpeg25", "github.com/gabriel-vasile/mimetype@v1.4.3", "testdata/mp3.v2.5.notag.mp3", 0, 0, false, false},
+}
+
+// moduleDir downloads the module of path@version and reports its directory
+func moduleDir(mod string) (string, error) {
+	out, err := exec.Command("go", "mod", "download", "-json", mod).Output()
+	if err != nil {
+		return "", fmt.Errorf("go mod download %s: %v", mod, err)
+	}
+	var info struct{ Dir string }
+	if err = json.Unmarshal(out, &info); err != nil {
+		return "", err
+	}
+	return info.Dir, nil
+}
+
+// cut copies the frames of s, the tags of the source are dropped
+func cut(s *stream) ([]byte, error) {
+	dir, err := moduleDir(s.module)
+	if err != nil {
+		return nil, err
+	}
+	m, err := mp3.Open(filepath.Join(dir, filepath.FromSlash(s.source)))
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+	var out bytes.Buffer
+	for i := 0; s.last == 0 || i < s.last; i++ {
+		h, data, err := m.ReadFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if i < s.first {
+			continue
+		}
+		if i == s.first && s.silent {
+			if h.Version != mp3.MPEG1 || h.Channels() != 2 || h.Protected {
+				return nil, fmt.Errorf("%s: frame %d is not unprotected MPEG-1 stereo", s.source, i)
+			}
+			// main_data_begin, the scfsi and the part2_3_length of the granules are 0
+			data = append([]byte(nil), data...)
+			for j := 4; j < 4+32; j++ {
+				data[j] = 0
+			}
+		}
+		out.Write(data)
+	}
+	return out.Bytes(), nil
+}
+
+// reference decodes the stream by go-mp3, to the float32 samples of the stream, after
+// the gapless trim
+func reference(name string) ([]byte, error) {
+	m, err := mp3.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Close()
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, err := gomp3.NewDecoder(f)
+	if err != nil {
+		return nil, err
+	}
+	pcm, err := io.ReadAll(d)
+	if err != nil {
+		return nil, err
+	}
+	h := m.Header()
+	skip, _ := m.GaplessTrim()
+	if m.VBRInfo() != nil {
+		skip += h.Samples() // go-mp3 decodes the Xing frame as silence
+	}
+	n := len(pcm)/4 - skip
+	if frames := m.NumFrames(); frames >= 0 && int64(n) > frames {
+		n = int(frames)
+	}
+	channels := m.NumTracks()
+	out := make([]byte, 4*channels*n)
+	for i := 0; i < n; i++ {
+		// go-mp3 writes stereo of 16 bits, the mono channel is duplicated
+		for ch := 0; ch < channels; ch++ {
+			x := int16(binary.LittleEndian.Uint16(pcm[4*(skip+i)+2*ch:]))
+			binary.LittleEndian.PutUint32(out[4*(channels*i+ch):], math.Float32bits(float32(x)/32767))
+		}
+	}
+	return out, nil
+}
+
+func main() {
+	for i := range streams {
+		s := &streams[i]
+		data, err := cut(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		name := "../" + s.name + ".mp3"
+		if err = os.WriteFile(name, data, 0644); err != nil {
+			log.Fatal(err)
+		}
+		m, err := mp3.Open(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		h := m.Header()
+		fmt.Printf("%s: %s %d Hz, %d channels, %d frames of %d samples\n", s.name,
+			h.Version, h.SampleRate, h.Channels(), m.NumFrames()/int64(h.Samples()), h.Samples())
+		m.Close()
+		if !s.ref {
+			continue
+		}
+		ref, err := reference(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = os.WriteFile("../"+s.name+".f32", ref, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
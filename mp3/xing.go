@@ -0,0 +1,73 @@
+package mp3
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// the delay of the synthesis filterbank of decoder, the LAME tag counts without it
+const decoderDelay = 529
+
+// VBRInfo is the Xing, Info or VBRI header in the first frame. the frame carries no audio.
+type VBRInfo struct {
+	Tag     string // "Xing", "Info" or "VBRI"
+	Frames  int64  // audio frames, 0 if unknown
+	Bytes   int64  // bytes of audio frames, 0 if unknown
+	TOC     []byte // the seek table of 100 entries of Xing
+	Encoder string // the encoder of LAME tag, i.e. "LAME3.100"
+	Delay   int    // samples added by the encoder at the beginning
+	Padding int    // samples added by the encoder at the end
+}
+
+// parseVBRInfo parses the Xing or VBRI header of frame, which is the whole frame
+func parseVBRInfo(h *FrameHeader, frame []byte) (*VBRInfo, bool) {
+	off := 4 + h.sideInfoSize()
+	if h.Protected {
+		off += 2
+	}
+	if len(frame) >= off+8 {
+		tag := string(frame[off : off+4])
+		if tag == "Xing" || tag == "Info" {
+			return parseXing(tag, frame[off+4:]), true
+		}
+	}
+	// VBRI is always 32 bytes after the header
+	if len(frame) >= 36+26 && string(frame[36:40]) == "VBRI" {
+		b := frame[40:]
+		return &VBRInfo{
+			Tag:    "VBRI",
+			Delay:  int(binary.BigEndian.Uint16(b[2:])),
+			Bytes:  int64(binary.BigEndian.Uint32(b[6:])),
+			Frames: int64(binary.BigEndian.Uint32(b[10:])),
+		}, true
+	}
+	return nil, false
+}
+
+func parseXing(tag string, b []byte) *VBRInfo {
+	info := &VBRInfo{Tag: tag}
+	flags := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	if flags&1 != 0 && len(b) >= 4 {
+		info.Frames = int64(binary.BigEndian.Uint32(b))
+		b = b[4:]
+	}
+	if flags&2 != 0 && len(b) >= 4 {
+		info.Bytes = int64(binary.BigEndian.Uint32(b))
+		b = b[4:]
+	}
+	if flags&4 != 0 && len(b) >= 100 {
+		info.TOC = append([]byte(nil), b[:100]...)
+		b = b[100:]
+	}
+	if flags&8 != 0 && len(b) >= 4 {
+		b = b[4:] // quality
+	}
+	// the LAME tag, the delay and padding are 12 bits each at byte 21
+	if len(b) >= 24 && (strings.HasPrefix(string(b[:4]), "LAME") || strings.HasPrefix(string(b[:4]), "Lavc")) {
+		info.Encoder = strings.TrimRight(string(b[:9]), "\x00 ")
+		info.Delay = int(b[21])<<4 | int(b[22])>>4
+		info.Padding = int(b[22]&0x0f)<<8 | int(b[23])
+	}
+	return info
+}
@@ -0,0 +1,139 @@
+package ogg
+
+// Packet is a complete packet assembled by Demuxer
+type Packet struct {
+	Data    []byte
+	Index   int   // index of the packet in logical stream
+	Granule int64 // granule of the page where the packet ends, -1 if it is not the last one ends there
+	Last    bool  // the packet ends within the last page of stream
+}
+
+// Demuxer is the push mode counterpart of Reader. the ogg stream is written into it piece
+// by piece, and the packets can be taken as soon as they are complete. the partial page
+// and the packet continued to next page are buffered.
+type Demuxer struct {
+	buf      []byte   // unparsed data, begin with a page
+	partial  []byte   // the packet continues to next page
+	spanning bool     // the last page ends within a packet
+	packets  []Packet // complete packets not taken yet
+	index    int
+	eos      bool
+	err      error
+	h        PageHeader
+	offset   int64 // offset of the page in stream
+
+	onPage func(offset int64, h *PageHeader)
+}
+
+// OnPage sets the function called after each page is parsed, h is valid only during the call
+func (d *Demuxer) OnPage(f func(offset int64, h *PageHeader)) {
+	d.onPage = f
+}
+
+// Write appends the ogg stream data, the complete pages are parsed immediately.
+// once the stream is found corrupted, the error is returned by every call.
+func (d *Demuxer) Write(p []byte) (n int, err error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	if d.eos {
+		return len(p), nil // ignore the data after end of stream
+	}
+	d.buf = append(d.buf, p...)
+	off := 0
+	for !d.eos {
+		size, err := d.parsePage(d.buf[off:])
+		if err != nil {
+			d.err = err
+			return len(p), err
+		}
+		if size == 0 {
+			break // partial page
+		}
+		off += size
+	}
+	if off != 0 {
+		d.buf = append(d.buf[:0], d.buf[off:]...)
+	}
+	return len(p), nil
+}
+
+// parsePage parses the page at the beginning of b, reports 0 if the page is not complete
+func (d *Demuxer) parsePage(b []byte) (size int, err error) {
+	h := &d.h
+	if len(b) < 27 {
+		return 0, nil
+	}
+	if err = parsePageHeader(b, h); err != nil {
+		return 0, err
+	}
+	if len(b) < h.HeaderSize() {
+		return 0, nil
+	}
+	copy(h.Segments[:h.NumSegs], b[27:])
+	size = h.Size()
+	if len(b) < size {
+		return 0, nil
+	}
+
+	body := b[h.HeaderSize():size]
+	if !h.Continued() || !d.spanning {
+		// packet lost between pages, or the stream is joined in the middle of packet
+		d.partial = d.partial[:0]
+	}
+	skip := h.Continued() && !d.spanning
+	last := -1 // index of the last packet ends within the page
+	for _, x := range h.Segments[:h.NumSegs] {
+		if !skip {
+			d.partial = append(d.partial, body[:x]...)
+		}
+		body = body[x:]
+		if x < 255 {
+			if !skip {
+				last = len(d.packets)
+				d.packets = append(d.packets, Packet{
+					Data:    append([]byte(nil), d.partial...),
+					Index:   d.index,
+					Granule: -1,
+					Last:    h.Last(),
+				})
+				d.index++
+			}
+			skip = false
+			d.partial = d.partial[:0]
+		}
+	}
+	d.spanning = h.NumSegs != 0 && h.Segments[h.NumSegs-1] == 255
+	if last >= 0 {
+		d.packets[last].Granule = int64(h.Granule)
+	}
+	if h.Last() {
+		d.eos = true
+	}
+	if d.onPage != nil {
+		d.onPage(d.offset, h)
+	}
+	d.offset += int64(size)
+	return size, nil
+}
+
+// Packet takes the next complete packet, ok is false if there is none
+func (d *Demuxer) Packet() (p Packet, ok bool) {
+	if len(d.packets) == 0 {
+		return p, false
+	}
+	p = d.packets[0]
+	d.packets[0] = Packet{}
+	d.packets = d.packets[1:]
+	return p, true
+}
+
+// Buffered reports the complete packets not taken yet
+func (d *Demuxer) Buffered() int {
+	return len(d.packets)
+}
+
+// EndOfStream reports whether the last page of stream has been parsed
+func (d *Demuxer) EndOfStream() bool {
+	return d.eos
+}
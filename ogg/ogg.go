@@ -29,8 +29,18 @@ var (
 // for vorbis decode, we read the ogg file, decode into packets, pass the packet
 // to vorbis decoder bit by bit.
 type Reader struct {
-	r      io.Reader // upstream reader
-	closer io.Closer // for Close
+	r      io.Reader     // upstream reader
+	closer io.Closer     // for Close
+	seeker io.Seeker     // upstream seeker, nil if not seekable
+	br     *bufio.Reader // buffer of upstream file, must reset after seek
+
+	begin      int64 // upstream position when Init
+	pos        int64 // bytes consumed since begin
+	pageOffset int64 // position of current page
+	pageSize   int   // bytes of current page, include the header
+
+	// the page was just located by SeekPage, next packet starts within it
+	fresh bool
 
 	// page info, order is not critical
 	flags    uint8      // 5
@@ -42,10 +52,11 @@ type Reader struct {
 	tabSegs  [255]uint8 // 27 ~    segments table
 	// end of page info
 
-	idxSeg    int
-	lenSeg    int // bytes of current segment
-	idxPage   int
-	idxPacket int
+	idxSeg     int
+	lenSeg     int // bytes of current segment
+	idxPage    int
+	idxPacket  int
+	packetSize int // bytes of current packet, in the pages have been read
 
 	// no more data in current packet, readPacketBits will return zero,
 	// until switch to next packet.
@@ -60,6 +71,30 @@ type Reader struct {
 	// buffer for bits reading
 	bitsbuf uint64
 	numbits uint32
+
+	// the bytes read into bitsbuf, the page header and the discarded bytes, they
+	// are fields, or they escape to heap by io.Reader
+	bytesbuf [8]byte
+	headbuf  [27]byte
+	skipbuf  [255]byte
+	strbuf   []byte // reused by ReadString
+
+	// bits consumed from current packet
+	bitsRead int
+
+	onPage func(offset int64, h *PageHeader)
+	page   PageHeader // passed to onPage
+}
+
+// OnPage sets the function called after each page header is read, h is valid only
+// during the call. it must be set before Init to see the first page.
+func (o *Reader) OnPage(f func(offset int64, h *PageHeader)) {
+	o.onPage = f
+}
+
+// BitsRead reports the bits consumed from current packet
+func (o *Reader) BitsRead() int {
+	return o.bitsRead
 }
 
 func (o *Reader) Init(r io.Reader) (err error) {
@@ -79,6 +114,110 @@ func (o *Reader) NextPacket() (err error) {
 	return o.switchNextPacket()
 }
 
+// PacketSize reports bytes of current packet. if the packet spans pages, only the
+// pages have been read are counted.
+func (o *Reader) PacketSize() int {
+	return o.packetSize
+}
+
+// Seekable reports whether the underlying reader can seek
+func (o *Reader) Seekable() bool {
+	return o.seeker != nil
+}
+
+// PageOffset reports the position of current page, relative to the stream begin
+func (o *Reader) PageOffset() int64 {
+	return o.pageOffset
+}
+
+// PageSize reports bytes of current page, include the page header
+func (o *Reader) PageSize() int {
+	return o.pageSize
+}
+
+// SeekPage jump to the page at offset, which must be a page returned by PageOffset,
+// the next call of NextPacket will turn to the first packet begin within that page.
+func (o *Reader) SeekPage(offset int64) (err error) {
+	if o.seeker == nil {
+		return errors.New("ogg: not seekable")
+	}
+	if _, err = o.seeker.Seek(o.begin+offset, io.SeekStart); err != nil {
+		return err
+	}
+	if o.br != nil {
+		o.br.Reset(o.seeker.(io.Reader))
+	}
+	o.pos = offset
+	o.flags = 0
+	o.numbits = 0
+	o.bitsbuf = 0
+	o.endOfPacket = true
+	o.endOfStream = false
+	if err = o.initNextPage(); err != nil {
+		o.endOfStream = true
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = ErrCorrupted
+		}
+		return err
+	}
+	o.fresh = true
+	return nil
+}
+
+// Granule reports the granule position of the page where current packet ends, or -1 if
+// the packet is not the last one ends within that page. the unread data of current packet
+// is discarded, so the packet can't be read any more.
+func (o *Reader) Granule() int64 {
+	if o.fresh || o.endOfStream || o.finishPacket() != nil {
+		return -1
+	}
+	for i := o.idxSeg + 1; i < int(o.numSegs); i++ {
+		if o.tabSegs[i] < 255 {
+			return -1 // another packet ends within the page
+		}
+	}
+	return int64(o.granule)
+}
+
+// LastPage reports whether current page is the last page of stream
+func (o *Reader) LastPage() bool {
+	return o.pageFlagEos()
+}
+
+// finishPacket discards the unread data of current packet, stops at its last segment
+func (o *Reader) finishPacket() (err error) {
+	o.endOfPacket = true
+	o.numbits = 0
+	o.bitsbuf = 0
+	for {
+		if o.lenSeg > 0 {
+			if err = o.discardInput(o.lenSeg); err != nil {
+				return err
+			}
+			o.lenSeg = 0
+		}
+		if o.tabSegs[o.idxSeg] < 255 {
+			return nil
+		}
+		if o.idxSeg+1 >= int(o.numSegs) {
+			// across page edge
+			if o.pageFlagEos() {
+				return errors.New("packet cross end-of-stream")
+			}
+			if err = o.initNextPage(); err != nil {
+				return err
+			}
+			if !o.pageFlagCon() {
+				return errors.New("packet cross page, but next page is not mark as continuation")
+			}
+			o.packetSize += o.segmentRun(0)
+		} else {
+			o.idxSeg++
+		}
+		o.lenSeg = int(o.tabSegs[o.idxSeg])
+	}
+}
+
 func (o *Reader) EndOfPacket() bool {
 	return o.endOfPacket
 }
@@ -111,6 +250,17 @@ func u32(b []byte) uint32 {
 // 	return uint16(b[0]) | uint16(b[1])<<8
 // }
 
+// bytes of the segments from i, until the end of packet or page
+func (o *Reader) segmentRun(i int) (n int) {
+	for ; i < int(o.numSegs); i++ {
+		n += int(o.tabSegs[i])
+		if o.tabSegs[i] < 255 {
+			break
+		}
+	}
+	return n
+}
+
 func (o *Reader) pageFlagCon() bool { return o.flags&0x01 == 0x01 }
 
 func (o *Reader) pageFlagBos() bool { return o.flags&0x02 == 0x02 } // first page
@@ -122,9 +272,16 @@ func (o *Reader) initOgg(r io.Reader) (err error) {
 		o.closer = c
 	}
 
+	if s, ok := r.(io.Seeker); ok {
+		if o.begin, err = s.Seek(0, io.SeekCurrent); err == nil {
+			o.seeker = s
+		}
+	}
+
 	if f, ok := r.(*os.File); ok {
 		// we need bufio for a file, or the system call becomes bottle neck
-		r = bufio.NewReaderSize(f, 4096)
+		o.br = bufio.NewReaderSize(f, 4096)
+		r = o.br
 	}
 
 	o.r = r
@@ -135,6 +292,7 @@ func (o *Reader) initOgg(r io.Reader) (err error) {
 		}
 		return err
 	}
+	o.packetSize = o.segmentRun(0)
 	return nil
 }
 
@@ -142,6 +300,7 @@ func (o *Reader) initOgg(r io.Reader) (err error) {
 func (o *Reader) switchNextPacket() (err error) {
 	o.numbits = 0
 	o.bitsbuf = 0 // important
+	o.bitsRead = 0
 	if o.endOfStream {
 		return io.EOF
 	}
@@ -149,6 +308,17 @@ func (o *Reader) switchNextPacket() (err error) {
 		o.endOfPacket = err != nil
 	}()
 
+	if o.fresh {
+		o.fresh = false
+		if !o.pageFlagCon() {
+			// already at the first packet of page
+			o.idxPacket++
+			o.packetSize = o.segmentRun(0)
+			return nil
+		}
+		// otherwise skip the tail of the packet continued from previous page
+	}
+
 	var isPacketEdge bool
 	for !isPacketEdge {
 		if o.lenSeg > 0 {
@@ -180,6 +350,7 @@ func (o *Reader) switchNextPacket() (err error) {
 
 	//o.eop = false
 	o.idxPacket++
+	o.packetSize = o.segmentRun(o.idxSeg)
 	if debug.ON {
 		exact := ">="
 		n := 0
@@ -208,6 +379,7 @@ func (o *Reader) readInput(b []byte) (n int, err error) {
 			b = b[x:]
 		}
 	}
+	o.pos += int64(n)
 	return n, err
 }
 
@@ -222,6 +394,7 @@ func (o *Reader) discardInput(n int) (err error) {
 	}); ok {
 		var x int
 		x, err = d.Discard(n)
+		o.pos += int64(x)
 		if err != nil {
 			return
 		}
@@ -229,13 +402,14 @@ func (o *Reader) discardInput(n int) (err error) {
 	}
 
 	// fallback to read and drop method
-	var tmp [256]byte
+	tmp := o.skipbuf[:]
 	for n > 0 && err == nil {
 		x := n
 		if x > 255 {
 			x = 255
 		}
 		x, err = o.r.Read(tmp[:x])
+		o.pos += int64(x)
 		n -= x
 	}
 	return
@@ -269,8 +443,9 @@ func (o *Reader) initNextPage() error {
 	if o.endOfStream || o.pageFlagEos() {
 		return io.EOF
 	}
-	var buf [27]byte
-	n, err := o.readInput(buf[:])
+	buf := o.headbuf[:]
+	offset := o.pos
+	n, err := o.readInput(buf)
 	if n != len(buf) {
 		return err
 	}
@@ -294,6 +469,16 @@ func (o *Reader) initNextPage() error {
 	o.idxPage++
 	o.idxSeg = 0
 	o.lenSeg = int(o.tabSegs[0])
+	o.pageOffset = offset
+	o.pageSize = len(buf) + int(o.numSegs)
+	for _, x := range o.tabSegs[:o.numSegs] {
+		o.pageSize += int(x)
+	}
+	if o.onPage != nil {
+		o.page = PageHeader{Flags: o.flags, Granule: o.granule, Stream: o.stream,
+			Sequence: o.pagesn, Checksum: o.checksum, NumSegs: o.numSegs, Segments: o.tabSegs}
+		o.onPage(offset, &o.page)
+	}
 
 	if debug.ON {
 		sflags := ""
@@ -350,19 +535,17 @@ func (o *Reader) _readPacket(_buf []byte) (n int, err error) {
 			if o.idxSeg >= int(o.numSegs) {
 				// across page edge
 				if o.pageFlagEos() {
-					fmt.Println("packet cross end-of-stream.")
 					err = errors.New("packet cross end-of-stream")
 					break
 				}
 				if err = o.initNextPage(); err != nil {
-					fmt.Println("packet cross page, but failed to read next page.")
 					break
 				}
 				if !o.pageFlagCon() {
-					fmt.Println("packet cross page, but next page is not mark as continuation.")
 					err = errors.New("packet cross page, but next page is not mark as continuation")
 					break
 				}
+				o.packetSize += o.segmentRun(0)
 			}
 			o.lenSeg = int(o.tabSegs[o.idxSeg])
 		}
@@ -386,10 +569,13 @@ func (o *Reader) peekPacketBits(_n uint32) uint32 {
 		//debug.Assert(!o.endOfPacket)
 		room := 8 - ((o.numbits + 0x07) >> 3)
 		//debug.Assert(room > 0)
-		var buf [8]byte
+		buf := o.bytesbuf[:]
+		for i := range buf {
+			buf[i] = 0
+		}
 		n, _ := o._readPacket(buf[:room])
 		if n != 0 {
-			tmp := u64(buf[:]) // TODO: optimize
+			tmp := u64(buf) // TODO: optimize
 			o.bitsbuf |= tmp << o.numbits
 			o.numbits += uint32(n) << 3
 		}
@@ -404,9 +590,11 @@ func (o *Reader) dropPacketBits(bits uint32) {
 			debug.Println("end of packet")
 			o.endOfPacket = true
 		}
+		o.bitsRead += int(o.numbits)
 		o.bitsbuf = 0
 		o.numbits = 0
 	} else {
+		o.bitsRead += int(bits)
 		o.bitsbuf >>= bits
 		o.numbits -= bits
 	}
@@ -452,7 +640,15 @@ func (o *Reader) readPacketString() string {
 	if length == 0 {
 		return ""
 	}
-	buf := make([]byte, length)
-	o.readPacketBytes(buf)
+	// the length is not trusted, the string never exceeds the packet
+	buf := o.strbuf[:0]
+	for i := uint32(0); i < length; i++ {
+		c := uint8(o.readPacketBits(8))
+		if o.endOfPacket {
+			break
+		}
+		buf = append(buf, c)
+	}
+	o.strbuf = buf
 	return string(buf)
 }
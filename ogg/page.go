@@ -0,0 +1,94 @@
+package ogg
+
+import (
+	"fmt"
+	"io"
+)
+
+// PageHeader is the header of an ogg page
+type PageHeader struct {
+	Flags    uint8      // 5
+	Granule  uint64     // 6 ~ 13
+	Stream   uint32     // 14 ~ 17  stream S/N
+	Sequence uint32     // 18 ~ 21  page S/N
+	Checksum uint32     // 22 ~ 25  page checksum
+	NumSegs  uint8      // 26       segments count
+	Segments [255]uint8 // 27 ~     segments table
+}
+
+// Continued reports whether the page begin with a packet continued from previous page
+func (h *PageHeader) Continued() bool { return h.Flags&0x01 == 0x01 }
+
+// First reports whether the page is the first page of stream
+func (h *PageHeader) First() bool { return h.Flags&0x02 == 0x02 }
+
+// Last reports whether the page is the last page of stream
+func (h *PageHeader) Last() bool { return h.Flags&0x04 == 0x04 }
+
+// HeaderSize reports bytes of the page header, include the segments table
+func (h *PageHeader) HeaderSize() int {
+	return 27 + int(h.NumSegs)
+}
+
+// BodySize reports bytes of the page body
+func (h *PageHeader) BodySize() (n int) {
+	for _, x := range h.Segments[:h.NumSegs] {
+		n += int(x)
+	}
+	return n
+}
+
+// Size reports bytes of whole page
+func (h *PageHeader) Size() int {
+	return h.HeaderSize() + h.BodySize()
+}
+
+// NumPacketStarts reports how many packets begin within the page
+func (h *PageHeader) NumPacketStarts() (n int) {
+	begin := !h.Continued()
+	for _, x := range h.Segments[:h.NumSegs] {
+		if begin {
+			n++
+		}
+		begin = x < 255
+	}
+	return n
+}
+
+// ReadPageHeader read the header of page at offset off
+func ReadPageHeader(r io.ReaderAt, off int64, h *PageHeader) error {
+	var buf [27]byte
+	if n, err := r.ReadAt(buf[:], off); n != len(buf) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if err := parsePageHeader(buf[:], h); err != nil {
+		return err
+	}
+	if n, err := r.ReadAt(h.Segments[:h.NumSegs], off+27); n != int(h.NumSegs) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+// parse the fixed 27 bytes of page header, the segments table is not included
+func parsePageHeader(buf []byte, h *PageHeader) error {
+	if buf[0] != 'O' || buf[1] != 'g' || buf[2] != 'g' || buf[3] != 'S' {
+		return ErrCorrupted
+	}
+	if buf[4] != 0 {
+		return fmt.Errorf("ogg: stream version %d is not supported yet", buf[4])
+	}
+	h.Flags = buf[5]
+	h.Granule = u64(buf[6:])
+	h.Stream = u32(buf[14:])
+	h.Sequence = u32(buf[18:])
+	h.Checksum = u32(buf[22:])
+	h.NumSegs = buf[26]
+	return nil
+}
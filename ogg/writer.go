@@ -0,0 +1,156 @@
+package ogg
+
+import (
+	"errors"
+	"io"
+)
+
+// nominal body size of page, same as libogg
+const pageBodySize = 4096
+
+var crcTable = func() (t [256]uint32) {
+	for i := range t {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return
+}()
+
+// PageChecksum computes the CRC of a whole page, the checksum field is taken as zero
+func PageChecksum(page []byte) uint32 {
+	var crc uint32
+	for i, b := range page {
+		if i >= 22 && i < 26 {
+			b = 0
+		}
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// Writer writes the packets of a logical stream into ogg pages
+type Writer struct {
+	w         io.Writer
+	serial    uint32
+	sequence  uint32
+	segs      []byte
+	body      []byte
+	granule   int64 // of the last packet ends within current page, -1 if none
+	continued bool  // current page begins with the tail of a packet
+	eos       bool
+	err       error
+}
+
+// NewWriter creates writer of the logical stream with serial number
+func NewWriter(w io.Writer, serial uint32) *Writer {
+	return &Writer{w: w, serial: serial, granule: -1}
+}
+
+// WritePacket writes the packet, granule is the position after the packet, or -1 if
+// it is unknown. the packets are buffered and written when the page is full.
+func (w *Writer) WritePacket(p []byte, granule int64) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.eos {
+		return errors.New("ogg: write after end of stream")
+	}
+	if len(w.body) >= pageBodySize {
+		if err := w.writePage(false); err != nil {
+			return err
+		}
+	}
+	for {
+		if len(w.segs) == 255 {
+			// the packet continues to next page
+			if err := w.writePage(false); err != nil {
+				return err
+			}
+			w.continued = true
+		}
+		n := len(p)
+		if n > 255 {
+			n = 255
+		}
+		w.segs = append(w.segs, byte(n))
+		w.body = append(w.body, p[:n]...)
+		p = p[n:]
+		if n < 255 {
+			break // the last segment is less than 255, maybe 0
+		}
+	}
+	w.granule = granule
+	return nil
+}
+
+// Flush writes the buffered packets, the next packet begins with a fresh page
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.segs) == 0 {
+		return nil
+	}
+	return w.writePage(false)
+}
+
+// Close writes the buffered packets as the last page of stream, the underlying writer
+// is not closed. at least one packet must be written before Close.
+func (w *Writer) Close() error {
+	if w.err != nil || w.eos {
+		return w.err
+	}
+	if len(w.segs) == 0 && w.sequence == 0 {
+		return errors.New("ogg: close empty stream")
+	}
+	return w.writePage(true)
+}
+
+func (w *Writer) writePage(last bool) error {
+	page := make([]byte, 27+len(w.segs)+len(w.body))
+	copy(page, "OggS")
+	if w.continued {
+		page[5] |= 0x01
+	}
+	if w.sequence == 0 {
+		page[5] |= 0x02
+	}
+	if last {
+		page[5] |= 0x04
+		w.eos = true
+	}
+	put64(page[6:], uint64(w.granule))
+	put32(page[14:], w.serial)
+	put32(page[18:], w.sequence)
+	page[26] = byte(len(w.segs))
+	copy(page[27:], w.segs)
+	copy(page[27+len(w.segs):], w.body)
+	put32(page[22:], PageChecksum(page))
+
+	w.sequence++
+	w.segs = w.segs[:0]
+	w.body = w.body[:0]
+	w.granule = -1
+	w.continued = false
+	if _, err := w.w.Write(page); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+func put32(b []byte, x uint32) {
+	b[0], b[1], b[2], b[3] = byte(x), byte(x>>8), byte(x>>16), byte(x>>24)
+}
+
+func put64(b []byte, x uint64) {
+	put32(b, uint32(x))
+	put32(b[4:], uint32(x>>32))
+}
@@ -0,0 +1,617 @@
+package opus
+
+// the CELT decoder, section 4.3 of RFC 6716. the decoder is a port of the float
+// build of libopus, always of the 48 kHz mode with 20 ms frames
+
+const (
+	celtShortMdctSize = 120
+	celtMaxLM         = 3
+	celtOverlap       = 120
+	decodeBufferSize  = 2048
+	maxPeriod         = 1024
+
+	combFilterMinPeriod = 15
+
+	// the pitch lag of the pitch-based PLC, of 66.67 Hz to 480 Hz
+	plcPitchLagMax = 720
+	plcPitchLagMin = 100
+
+	preemphCoef = 0.85000610
+)
+
+type celtDecoder struct {
+	channels       int // of the output
+	streamChannels int // coded in the frame
+	start, end     int // the bands coded
+
+	// the state cleared by reset
+	rng                 uint32
+	lastPitchIndex      int
+	lossCount           int
+	postfilterPeriod    int
+	postfilterPeriodOld int
+	postfilterGain      float32
+	postfilterGainOld   float32
+	postfilterTapset    int
+	postfilterTapsetOld int
+	preemphMem          [2]float32
+	decodeMem           [2][decodeBufferSize + celtOverlap]float32
+	lpc                 [2][lpcOrder]float32
+	oldBandE            [2 * celtNbBands]float32
+	oldLogE             [2 * celtNbBands]float32
+	oldLogE2            [2 * celtNbBands]float32
+	backgroundLogE      [2 * celtNbBands]float32
+
+	fftBuf [480]fftCpx
+	freq   [2][960]float32
+	x      [2 * 960]float32
+}
+
+func newCeltDecoder(channels int) *celtDecoder {
+	d := &celtDecoder{channels: channels, streamChannels: channels, end: celtNbBands}
+	d.reset()
+	return d
+}
+
+func (d *celtDecoder) reset() {
+	d.rng = 0
+	d.lastPitchIndex = 0
+	d.lossCount = 0
+	d.postfilterPeriod, d.postfilterPeriodOld = 0, 0
+	d.postfilterGain, d.postfilterGainOld = 0, 0
+	d.postfilterTapset, d.postfilterTapsetOld = 0, 0
+	d.preemphMem = [2]float32{}
+	d.decodeMem = [2][decodeBufferSize + celtOverlap]float32{}
+	d.lpc = [2][lpcOrder]float32{}
+	d.oldBandE = [2 * celtNbBands]float32{}
+	d.backgroundLogE = [2 * celtNbBands]float32{}
+	for i := range d.oldLogE {
+		d.oldLogE[i] = -28
+		d.oldLogE2[i] = -28
+	}
+}
+
+// deemphasis undoes the pre-emphasis of out and writes the interleaved pcm
+func (d *celtDecoder) deemphasis(out [][]float32, pcm []float32, n int) {
+	cc := d.channels
+	for c := 0; c < cc; c++ {
+		x := out[c]
+		m := d.preemphMem[c]
+		for j := 0; j < n; j++ {
+			tmp := x[j] + m + verySmall
+			m = preemphCoef * tmp
+			pcm[j*cc+c] = tmp * (1 / 32768.)
+		}
+		d.preemphMem[c] = m
+	}
+}
+
+// synthesis computes the time domain of the normalized spectrum x into out
+func (d *celtDecoder) synthesis(x []float32, out [][]float32, oldBandE []float32, start, effEnd, c, cc int, isTransient bool, lm int, silence bool) {
+	m := 1 << uint(lm)
+	n := celtShortMdctSize << uint(lm)
+	b, nb, shift := 1, n, celtMaxLM-lm
+	if isTransient {
+		b, nb, shift = m, celtShortMdctSize, celtMaxLM
+	}
+	freq := d.freq[0][:n]
+	switch {
+	case cc == 2 && c == 1:
+		// copy the mono stream to both channels
+		denormaliseBands(x, freq, oldBandE, start, effEnd, m, silence)
+		copy(d.freq[1][:n], freq)
+		for k := 0; k < b; k++ {
+			mdctBackward(freq[k:], out[0][nb*k:], celtOverlap, shift, b, d.fftBuf[:])
+		}
+		for k := 0; k < b; k++ {
+			mdctBackward(d.freq[1][k:], out[1][nb*k:], celtOverlap, shift, b, d.fftBuf[:])
+		}
+	case cc == 1 && c == 2:
+		// downmix the stereo stream to mono
+		freq2 := d.freq[1][:n]
+		denormaliseBands(x, freq, oldBandE, start, effEnd, m, silence)
+		denormaliseBands(x[n:], freq2, oldBandE[celtNbBands:], start, effEnd, m, silence)
+		for i := range freq {
+			freq[i] = .5 * (freq[i] + freq2[i])
+		}
+		for k := 0; k < b; k++ {
+			mdctBackward(freq[k:], out[0][nb*k:], celtOverlap, shift, b, d.fftBuf[:])
+		}
+	default:
+		for ch := 0; ch < cc; ch++ {
+			denormaliseBands(x[ch*n:], freq, oldBandE[ch*celtNbBands:], start, effEnd, m, silence)
+			for k := 0; k < b; k++ {
+				mdctBackward(freq[k:], out[ch][nb*k:], celtOverlap, shift, b, d.fftBuf[:])
+			}
+		}
+	}
+}
+
+// combFilter is the pitch post-filter of y[yo:yo+n] from x[xo:], which reads the
+// history before xo. y and x may be the same buffer, then it filters in place. the
+// gains are cross-faded by the window in the first overlap samples
+func combFilter(y []float32, yo int, x []float32, xo int, t0, t1, n int, g0, g1 float32, tapset0, tapset1 int, overlap int) {
+	if g0 == 0 && g1 == 0 {
+		copy(y[yo:yo+n], x[xo:xo+n])
+		return
+	}
+	g00 := g0 * combFilterGains[tapset0][0]
+	g01 := g0 * combFilterGains[tapset0][1]
+	g02 := g0 * combFilterGains[tapset0][2]
+	g10 := g1 * combFilterGains[tapset1][0]
+	g11 := g1 * combFilterGains[tapset1][1]
+	g12 := g1 * combFilterGains[tapset1][2]
+	x1 := x[xo-t1+1]
+	x2 := x[xo-t1]
+	x3 := x[xo-t1-1]
+	x4 := x[xo-t1-2]
+	// the overlap is needless if the filter didn't change
+	if g0 == g1 && t0 == t1 && tapset0 == tapset1 {
+		overlap = 0
+	}
+	i := 0
+	for ; i < overlap; i++ {
+		x0 := x[xo+i-t1+2]
+		f := celtWindow[i] * celtWindow[i]
+		y[yo+i] = x[xo+i] +
+			((1-f)*g00)*x[xo+i-t0] +
+			((1-f)*g01)*(x[xo+i-t0+1]+x[xo+i-t0-1]) +
+			((1-f)*g02)*(x[xo+i-t0+2]+x[xo+i-t0-2]) +
+			(f*g10)*x2 +
+			(f*g11)*(x1+x3) +
+			(f*g12)*(x0+x4)
+		x4, x3, x2, x1 = x3, x2, x1, x0
+	}
+	if g1 == 0 {
+		copy(y[yo+overlap:yo+n], x[xo+overlap:xo+n])
+		return
+	}
+
+	// the part of the constant filter
+	x4 = x[xo+i-t1-2]
+	x3 = x[xo+i-t1-1]
+	x2 = x[xo+i-t1]
+	x1 = x[xo+i-t1+1]
+	for ; i < n; i++ {
+		x0 := x[xo+i-t1+2]
+		y[yo+i] = x[xo+i] + g10*x2 + g11*(x1+x3) + g12*(x0+x4)
+		x4, x3, x2, x1 = x3, x2, x1, x0
+	}
+}
+
+// tfDecode decodes the time-frequency resolution of the bands
+func tfDecode(start, end int, isTransient bool, tfRes []int, lm int, dec *rangeDecoder) {
+	budget := len(dec.buf) * 8
+	tell := dec.tell()
+	logp := 4
+	if isTransient {
+		logp = 2
+	}
+	tfSelectRsv := lm > 0 && tell+logp+1 <= budget
+	budget -= b2i(tfSelectRsv)
+	tfChanged, curr := 0, 0
+	for i := start; i < end; i++ {
+		if tell+logp <= budget {
+			curr ^= b2i(dec.bitLogp(uint(logp)))
+			tell = dec.tell()
+			tfChanged |= curr
+		}
+		tfRes[i] = curr
+		logp = 5
+		if isTransient {
+			logp = 4
+		}
+	}
+	tfSelect := 0
+	t := lm*8 + 4*b2i(isTransient)
+	if tfSelectRsv && tfSelectTable[t+tfChanged] != tfSelectTable[t+2+tfChanged] {
+		tfSelect = b2i(dec.bitLogp(1))
+	}
+	for i := start; i < end; i++ {
+		tfRes[i] = int(tfSelectTable[t+2*tfSelect+tfRes[i]])
+	}
+}
+
+// outSyn reports the buffers of the last n samples of each channel
+func (d *celtDecoder) outSyn(n int) [][]float32 {
+	out := make([][]float32, d.channels)
+	for c := range out {
+		out[c] = d.decodeMem[c][decodeBufferSize-n:]
+	}
+	return out
+}
+
+// plcPitchSearch finds the pitch period of the decoded history
+func (d *celtDecoder) plcPitchSearch() int {
+	lp := make([]float32, decodeBufferSize>>1)
+	mem := make([][]float32, d.channels)
+	for c := range mem {
+		mem[c] = d.decodeMem[c][:]
+	}
+	pitchDownsample(mem, lp, decodeBufferSize, d.channels)
+	pitch := pitchSearch(lp[plcPitchLagMax>>1:], lp, decodeBufferSize-plcPitchLagMax, plcPitchLagMax-plcPitchLagMin)
+	return plcPitchLagMax - pitch
+}
+
+// decodeLost conceals a lost frame of n samples, by the noise of the last energies
+// or by repeating the pitch period
+func (d *celtDecoder) decodeLost(n, lm int) {
+	cc := d.channels
+	out := d.outSyn(n)
+	start := d.start
+	if d.lossCount >= 5 || start != 0 {
+		// noise-based PLC and CNG
+		end := d.end
+		effEnd := maxInt(start, minInt(end, celtNbBands))
+		x := d.x[:cc*n]
+		var decay float32 = .5
+		if d.lossCount == 0 {
+			decay = 1.5
+		}
+		for c := 0; c < cc; c++ {
+			for i := start; i < end; i++ {
+				k := c*celtNbBands + i
+				d.oldBandE[k] = max16(d.backgroundLogE[k], d.oldBandE[k]-decay)
+			}
+		}
+		seed := d.rng
+		for c := 0; c < cc; c++ {
+			for i := start; i < effEnd; i++ {
+				boffs := n*c + int(celtBands[i])<<uint(lm)
+				blen := int(celtBands[i+1]-celtBands[i]) << uint(lm)
+				for j := 0; j < blen; j++ {
+					seed = lcgRand(seed)
+					x[boffs+j] = float32(int32(seed) >> 20)
+				}
+				renormaliseVector(x[boffs:], blen, 1)
+			}
+		}
+		d.rng = seed
+		for c := 0; c < cc; c++ {
+			mem := d.decodeMem[c][:]
+			copy(mem, mem[n:decodeBufferSize+celtOverlap>>1])
+		}
+		d.synthesis(x, out, d.oldBandE[:], start, effEnd, cc, cc, false, lm, false)
+		d.lossCount++
+		return
+	}
+
+	// pitch-based PLC
+	var fade float32 = 1
+	var pitchIndex int
+	if d.lossCount == 0 {
+		pitchIndex = d.plcPitchSearch()
+		d.lastPitchIndex = pitchIndex
+	} else {
+		pitchIndex = d.lastPitchIndex
+		fade = .8
+	}
+	etmp := make([]float32, celtOverlap)
+	exc := make([]float32, maxPeriod)
+	var lpcMem [lpcOrder]float32
+	for c := 0; c < cc; c++ {
+		buf := d.decodeMem[c][:]
+		lpc := d.lpc[c][:]
+		copy(exc, buf[decodeBufferSize-maxPeriod:decodeBufferSize])
+		if d.lossCount == 0 {
+			// the LPC of the last period before the first loss, to work in the
+			// excitation-filter domain
+			var ac [lpcOrder + 1]float32
+			celtAutocorr(exc, ac[:], celtWindow[:], celtOverlap, lpcOrder, maxPeriod)
+			// a noise floor of -40 dB
+			ac[0] *= 1.0001
+			// the lag windowing stabilizes the Levinson-Durbin recursion
+			w := float32(.008)
+			w *= w
+			for i := 1; i <= lpcOrder; i++ {
+				ac[i] -= ac[i] * w * float32(i) * float32(i)
+			}
+			celtLPC(lpc, ac[:], lpcOrder)
+		}
+		// the excitation of 2 pitch periods, to look for a decaying signal
+		excLength := minInt(2*pitchIndex, maxPeriod)
+		for i := 0; i < lpcOrder; i++ {
+			lpcMem[i] = buf[decodeBufferSize-excLength-1-i]
+		}
+		celtFir(exc[maxPeriod-excLength:], lpc, exc[maxPeriod-excLength:], excLength, lpcOrder, lpcMem[:])
+
+		// don't add energy to a decaying segment
+		var decay float32
+		{
+			var e1, e2 float32 = 1, 1
+			decayLength := excLength >> 1
+			for i := 0; i < decayLength; i++ {
+				e := exc[maxPeriod-decayLength+i]
+				e1 += e * e
+				e = exc[maxPeriod-2*decayLength+i]
+				e2 += e * e
+			}
+			if e2 < e1 {
+				e1 = e2
+			}
+			decay = celtSqrt(e1 / e2)
+		}
+
+		// room for the new frame, the overlap past the end of the buffer is unused
+		copy(buf, buf[n:decodeBufferSize])
+
+		// extrapolate the excitation of the pitch period, attenuated by the decay
+		// of each period, to a complete MDCT window
+		extrapolationOffset := maxPeriod - pitchIndex
+		extrapolationLen := n + celtOverlap
+		attenuation := fade * decay
+		var s1 float32
+		for i, j := 0, 0; i < extrapolationLen; i, j = i+1, j+1 {
+			if j >= pitchIndex {
+				j -= pitchIndex
+				attenuation = attenuation * decay
+			}
+			buf[decodeBufferSize-n+i] = attenuation * exc[extrapolationOffset+j]
+			// the energy of the decoded signal whose excitation is copied
+			tmp := buf[decodeBufferSize-maxPeriod-n+extrapolationOffset+j]
+			s1 += tmp * tmp
+		}
+
+		// the synthesis filter of the last decoded samples, for a continuous signal
+		for i := 0; i < lpcOrder; i++ {
+			lpcMem[i] = buf[decodeBufferSize-n-1-i]
+		}
+		syn := buf[decodeBufferSize-n:]
+		celtIIR(syn, lpc, syn, extrapolationLen, lpcOrder, lpcMem[:])
+
+		// attenuate if the synthesis has more energy than expected, the
+		// comparison also catches NaN
+		var s2 float32
+		for i := 0; i < extrapolationLen; i++ {
+			s2 += syn[i] * syn[i]
+		}
+		if !(s1 > .2*s2) {
+			for i := 0; i < extrapolationLen; i++ {
+				syn[i] = 0
+			}
+		} else if s1 < s2 {
+			ratio := celtSqrt((s1 + 1) / (s2 + 1))
+			for i := 0; i < celtOverlap; i++ {
+				g := 1 - celtWindow[i]*(1-ratio)
+				syn[i] = g * syn[i]
+			}
+			for i := celtOverlap; i < extrapolationLen; i++ {
+				syn[i] = ratio * syn[i]
+			}
+		}
+
+		// the pre-filter of the overlap for the next frame, the post-filter
+		// applies again in the decoder after the overlap
+		combFilter(etmp, 0, buf, decodeBufferSize, d.postfilterPeriod, d.postfilterPeriod, celtOverlap,
+			-d.postfilterGain, -d.postfilterGain, d.postfilterTapset, d.postfilterTapset, 0)
+
+		// simulate the TDAC, to blend with the MDCT of the next frame
+		for i := 0; i < celtOverlap/2; i++ {
+			buf[decodeBufferSize+i] = celtWindow[i]*etmp[celtOverlap-1-i] + celtWindow[celtOverlap-i-1]*etmp[i]
+		}
+	}
+	d.lossCount++
+}
+
+// decode decodes the frame data of frameSize samples into the interleaved pcm, data
+// of at most 1 byte is lost. the range decoder is of the hybrid frame, it is nil
+// to decode data alone
+func (d *celtDecoder) decode(data []byte, pcm []float32, frameSize int, dec *rangeDecoder) error {
+	cc := d.channels
+	c := d.streamChannels
+	start, end := d.start, d.end
+	lm := 0
+	for lm <= celtMaxLM && celtShortMdctSize<<uint(lm) != frameSize {
+		lm++
+	}
+	if lm > celtMaxLM || len(data) > maxFrameBytes {
+		return errPacket
+	}
+	m := 1 << uint(lm)
+	n := m * celtShortMdctSize
+	out := d.outSyn(n)
+	effEnd := minInt(end, celtNbBands)
+
+	if len(data) <= 1 {
+		d.decodeLost(n, lm)
+		d.deemphasis(out, pcm, n)
+		return nil
+	}
+	if dec == nil {
+		dec = new(rangeDecoder)
+		dec.init(data)
+	}
+	oldBandE := d.oldBandE[:]
+	if c == 1 {
+		for i := 0; i < celtNbBands; i++ {
+			oldBandE[i] = max16(oldBandE[i], oldBandE[celtNbBands+i])
+		}
+	}
+
+	totalBits := len(data) * 8
+	tell := dec.tell()
+	silence := false
+	if tell >= totalBits {
+		silence = true
+	} else if tell == 1 {
+		silence = dec.bitLogp(15)
+	}
+	if silence {
+		// pretend all the remaining bits are read
+		tell = len(data) * 8
+		dec.nbitsTotal += tell - dec.tell()
+	}
+
+	var postfilterGain float32
+	postfilterPitch, postfilterTapset := 0, 0
+	if start == 0 && tell+16 <= totalBits {
+		if dec.bitLogp(1) {
+			octave := dec.uint(6)
+			postfilterPitch = 16<<octave + int(dec.bits(uint(4+octave))) - 1
+			qg := dec.bits(3)
+			if dec.tell()+2 <= totalBits {
+				postfilterTapset = dec.icdf(tapsetICDF, 2)
+			}
+			postfilterGain = .09375 * float32(qg+1)
+		}
+		tell = dec.tell()
+	}
+
+	isTransient := false
+	if lm > 0 && tell+3 <= totalBits {
+		isTransient = dec.bitLogp(3)
+		tell = dec.tell()
+	}
+	intraEner := false
+	if tell+3 <= totalBits {
+		intraEner = dec.bitLogp(3)
+	}
+
+	// the band energies
+	unquantCoarseEnergy(oldBandE, start, end, intraEner, dec, c, lm)
+	var tfRes, caps, offsets [celtNbBands]int
+	tfDecode(start, end, isTransient, tfRes[:], lm, dec)
+
+	tell = dec.tell()
+	spread := spreadNormal
+	if tell+4 <= totalBits {
+		spread = dec.icdf(spreadICDF, 5)
+	}
+	initCaps(caps[:], lm, c)
+
+	dynallocLogp := 6
+	totalBits <<= bitRes
+	tell = dec.tellFrac()
+	for i := start; i < end; i++ {
+		width := c * int(celtBands[i+1]-celtBands[i]) << uint(lm)
+		// 6 bits, but no more than 1 bit per sample and no less than 1/8 bit
+		quanta := minInt(width<<bitRes, maxInt(6<<bitRes, width))
+		loopLogp := dynallocLogp
+		boost := 0
+		for tell+loopLogp<<bitRes < totalBits && boost < caps[i] {
+			flag := dec.bitLogp(uint(loopLogp))
+			tell = dec.tellFrac()
+			if !flag {
+				break
+			}
+			boost += quanta
+			totalBits -= quanta
+			loopLogp = 1
+		}
+		offsets[i] = boost
+		// make the dynalloc more likely
+		if boost > 0 {
+			dynallocLogp = maxInt(2, dynallocLogp-1)
+		}
+	}
+
+	allocTrim := 5
+	if tell+6<<bitRes <= totalBits {
+		allocTrim = dec.icdf(trimICDF, 7)
+	}
+	bits := len(data)*8<<bitRes - dec.tellFrac() - 1
+	antiCollapseRsv := 0
+	if isTransient && lm >= 2 && bits >= (lm+2)<<bitRes {
+		antiCollapseRsv = 1 << bitRes
+	}
+	bits -= antiCollapseRsv
+	var a allocation
+	computeAllocation(&a, start, end, offsets[:], caps[:], allocTrim, bits, c, lm, dec)
+	unquantFineEnergy(oldBandE, start, end, a.fineQuant[:], dec, c)
+
+	for ch := 0; ch < cc; ch++ {
+		mem := d.decodeMem[ch][:]
+		copy(mem, mem[n:decodeBufferSize+celtOverlap/2])
+	}
+
+	// the fixed codebook
+	var collapseMasks [2 * celtNbBands]uint8
+	x := d.x[:c*n]
+	var y []float32
+	if c == 2 {
+		y = x[n:]
+	}
+	quantAllBands(start, end, x, y, collapseMasks[:], a.pulses[:], isTransient, spread, a.dualStereo, a.intensity,
+		tfRes[:], len(data)*(8<<bitRes)-antiCollapseRsv, a.balance, dec, lm, a.codedBands, &d.rng)
+
+	antiCollapseOn := false
+	if antiCollapseRsv > 0 {
+		antiCollapseOn = dec.bits(1) != 0
+	}
+	unquantEnergyFinalise(oldBandE, start, end, a.fineQuant[:], a.finePriority[:], len(data)*8-dec.tell(), dec, c)
+	if antiCollapseOn {
+		antiCollapse(x, collapseMasks[:], lm, c, n, start, end, oldBandE, d.oldLogE[:], d.oldLogE2[:], a.pulses[:], d.rng)
+	}
+	if silence {
+		for i := 0; i < c*celtNbBands; i++ {
+			oldBandE[i] = -28
+		}
+	}
+
+	d.synthesis(x, out, oldBandE, start, effEnd, c, cc, isTransient, lm, silence)
+
+	for ch := 0; ch < cc; ch++ {
+		d.postfilterPeriod = maxInt(d.postfilterPeriod, combFilterMinPeriod)
+		d.postfilterPeriodOld = maxInt(d.postfilterPeriodOld, combFilterMinPeriod)
+		mem := d.decodeMem[ch][:]
+		o := decodeBufferSize - n
+		combFilter(mem, o, mem, o, d.postfilterPeriodOld, d.postfilterPeriod, celtShortMdctSize,
+			d.postfilterGainOld, d.postfilterGain, d.postfilterTapsetOld, d.postfilterTapset, celtOverlap)
+		if lm != 0 {
+			o += celtShortMdctSize
+			combFilter(mem, o, mem, o, d.postfilterPeriod, postfilterPitch, n-celtShortMdctSize,
+				d.postfilterGain, postfilterGain, d.postfilterTapset, postfilterTapset, celtOverlap)
+		}
+	}
+	d.postfilterPeriodOld = d.postfilterPeriod
+	d.postfilterGainOld = d.postfilterGain
+	d.postfilterTapsetOld = d.postfilterTapset
+	d.postfilterPeriod = postfilterPitch
+	d.postfilterGain = postfilterGain
+	d.postfilterTapset = postfilterTapset
+	if lm != 0 {
+		d.postfilterPeriodOld = d.postfilterPeriod
+		d.postfilterGainOld = d.postfilterGain
+		d.postfilterTapsetOld = d.postfilterTapset
+	}
+
+	if c == 1 {
+		copy(oldBandE[celtNbBands:], oldBandE[:celtNbBands])
+	}
+	// in case start or end were to change
+	if !isTransient {
+		d.oldLogE2 = d.oldLogE
+		d.oldLogE = d.oldBandE
+		// the noise floor increases by up to 2.4 dB/second normally, but up to
+		// 6 dB for each update in DTX
+		var maxBackgroundIncrease float32 = 1
+		if d.lossCount < 10 {
+			maxBackgroundIncrease = float32(m) * .001
+		}
+		for i := range d.backgroundLogE {
+			d.backgroundLogE[i] = min16(d.backgroundLogE[i]+maxBackgroundIncrease, oldBandE[i])
+		}
+	} else {
+		for i := range d.oldLogE {
+			d.oldLogE[i] = min16(d.oldLogE[i], oldBandE[i])
+		}
+	}
+	for ch := 0; ch < 2; ch++ {
+		for i := 0; i < celtNbBands; i++ {
+			if i >= start && i < end {
+				continue
+			}
+			k := ch*celtNbBands + i
+			oldBandE[k] = 0
+			d.oldLogE[k] = -28
+			d.oldLogE2[k] = -28
+		}
+	}
+	d.rng = dec.rng
+	d.deemphasis(out, pcm, n)
+	d.lossCount = 0
+	if dec.tell() > 8*len(data) {
+		return errPacket
+	}
+	return nil
+}
@@ -0,0 +1,789 @@
+package opus
+
+// the decoding of the normalized bands of CELT, section 4.3.4 of RFC 6716
+
+func lcgRand(seed uint32) uint32 {
+	return 1664525*seed + 1013904223
+}
+
+// fracMul16 is the Q15 multiplication of 16 bits integers
+func fracMul16(a, b int) int {
+	return (16384 + int(int32(int16(a))*int32(int16(b)))) >> 15
+}
+
+// bitexactCos is a cos approximation that is bit exact on every platform
+func bitexactCos(x int) int {
+	tmp := (4096 + int(int32(x)*int32(x))) >> 13
+	x2 := tmp
+	x2 = (32767 - x2) + fracMul16(x2, -7651+fracMul16(x2, 8277+fracMul16(-626, x2)))
+	return 1 + x2
+}
+
+func bitexactLog2tan(isin, icos int) int {
+	lc := ilog(uint32(icos))
+	ls := ilog(uint32(isin))
+	icos <<= uint(15 - lc)
+	isin <<= uint(15 - ls)
+	return (ls-lc)*(1<<11) +
+		fracMul16(isin, fracMul16(isin, -2597)+7932) -
+		fracMul16(icos, fracMul16(icos, -2597)+7932)
+}
+
+func isqrt32(val uint32) uint32 {
+	g := uint32(0)
+	bshift := (ilog(val) - 1) >> 1
+	b := uint32(1) << uint(bshift)
+	for {
+		t := (g<<1 + b) << uint(bshift)
+		if t <= val {
+			g += b
+			val -= t
+		}
+		b >>= 1
+		bshift--
+		if bshift < 0 {
+			break
+		}
+	}
+	return g
+}
+
+// denormaliseBands applies the band energies to the normalized spectrum
+func denormaliseBands(x, freq []float32, bandLogE []float32, start, end, m int, silence bool) {
+	n := m * 120
+	bound := m * int(celtBands[end])
+	if silence {
+		bound = 0
+		start, end = 0, 0
+	}
+	f := 0
+	for i := 0; i < m*int(celtBands[start]); i++ {
+		freq[f] = 0
+		f++
+	}
+	xi := m * int(celtBands[start])
+	for i := start; i < end; i++ {
+		j := m * int(celtBands[i])
+		bandEnd := m * int(celtBands[i+1])
+		lg := bandLogE[i] + celtEMeans[i]
+		g := celtExp2(lg)
+		for ; j < bandEnd; j++ {
+			freq[f] = x[xi] * g
+			f++
+			xi++
+		}
+	}
+	for i := bound; i < n; i++ {
+		freq[i] = 0
+	}
+}
+
+// antiCollapse fills the collapsed short blocks of transients with noise
+func antiCollapse(x []float32, collapseMasks []uint8, lm, c, size, start, end int, logE, prev1logE, prev2logE []float32, pulses []int, seed uint32) {
+	for i := start; i < end; i++ {
+		n0 := int(celtBands[i+1] - celtBands[i])
+		// depth in 1/8 bits
+		depth := (1 + pulses[i]) / n0 >> uint(lm)
+		thresh := .5 * celtExp2(-.125*float32(depth))
+		sqrt1 := celtRsqrt(float32(n0 << uint(lm)))
+		for ch := 0; ch < c; ch++ {
+			prev1 := prev1logE[ch*celtNbBands+i]
+			prev2 := prev2logE[ch*celtNbBands+i]
+			if c == 1 {
+				prev1 = max16(prev1, prev1logE[celtNbBands+i])
+				prev2 = max16(prev2, prev2logE[celtNbBands+i])
+			}
+			ediff := logE[ch*celtNbBands+i] - min16(prev1, prev2)
+			if ediff < 0 {
+				ediff = 0
+			}
+			// r is multiplied by 2 or 2*sqrt(2) depending on LM, because short
+			// blocks don't have the same energy as long
+			r := 2 * celtExp2(-ediff)
+			if lm == 3 {
+				r *= 1.41421356
+			}
+			r = min16(thresh, r)
+			r = r * sqrt1
+
+			xb := x[ch*size+int(celtBands[i])<<uint(lm):]
+			renormalize := false
+			for k := 0; k < 1<<uint(lm); k++ {
+				// detect the collapse
+				if collapseMasks[i*c+ch]&(1<<uint(k)) == 0 {
+					// fill with noise
+					for j := 0; j < n0; j++ {
+						seed = lcgRand(seed)
+						if seed&0x8000 != 0 {
+							xb[j<<uint(lm)+k] = r
+						} else {
+							xb[j<<uint(lm)+k] = -r
+						}
+					}
+					renormalize = true
+				}
+			}
+			// renormalise for the energy just added
+			if renormalize {
+				renormaliseVector(xb, n0<<uint(lm), 1)
+			}
+		}
+	}
+}
+
+func stereoMerge(x, y []float32, mid float32, n int) {
+	// the norm of X+Y and X-Y as |X|^2 + |Y|^2 +/- sum(xy)
+	var xp, side float32
+	for i := 0; i < n; i++ {
+		xp += y[i] * x[i]
+		side += y[i] * y[i]
+	}
+	// compensate for the mid normalization
+	xp = mid * xp
+	// mid and side are in Q15, not Q14 like X and Y
+	mid2 := mid
+	el := mid2*mid2 + side - 2*xp
+	er := mid2*mid2 + side + 2*xp
+	if er < 6e-4 || el < 6e-4 {
+		copy(y[:n], x[:n])
+		return
+	}
+	lgain := celtRsqrt(el)
+	rgain := celtRsqrt(er)
+	for j := 0; j < n; j++ {
+		// apply the mid scaling, the side is already scaled
+		l := mid * x[j]
+		r := y[j]
+		x[j] = lgain * (l - r)
+		y[j] = rgain * (l + r)
+	}
+}
+
+func deinterleaveHadamard(x, tmp []float32, n0, stride int, hadamard bool) {
+	n := n0 * stride
+	tmp = tmp[:n]
+	if hadamard {
+		ordery := orderyTable[stride-2:]
+		for i := 0; i < stride; i++ {
+			for j := 0; j < n0; j++ {
+				tmp[ordery[i]*n0+j] = x[j*stride+i]
+			}
+		}
+	} else {
+		for i := 0; i < stride; i++ {
+			for j := 0; j < n0; j++ {
+				tmp[i*n0+j] = x[j*stride+i]
+			}
+		}
+	}
+	copy(x, tmp)
+}
+
+func interleaveHadamard(x, tmp []float32, n0, stride int, hadamard bool) {
+	n := n0 * stride
+	tmp = tmp[:n]
+	if hadamard {
+		ordery := orderyTable[stride-2:]
+		for i := 0; i < stride; i++ {
+			for j := 0; j < n0; j++ {
+				tmp[j*stride+i] = x[ordery[i]*n0+j]
+			}
+		}
+	} else {
+		for i := 0; i < stride; i++ {
+			for j := 0; j < n0; j++ {
+				tmp[j*stride+i] = x[i*n0+j]
+			}
+		}
+	}
+	copy(x, tmp)
+}
+
+func haar1(x []float32, n0, stride int) {
+	n0 >>= 1
+	for i := 0; i < stride; i++ {
+		for j := 0; j < n0; j++ {
+			tmp1 := .70710678 * x[stride*2*j+i]
+			tmp2 := .70710678 * x[stride*(2*j+1)+i]
+			x[stride*2*j+i] = tmp1 + tmp2
+			x[stride*(2*j+1)+i] = tmp1 - tmp2
+		}
+	}
+}
+
+var exp2Table8 = [8]int{16384, 17866, 19483, 21247, 23170, 25267, 27554, 30048}
+
+// computeQn is the resolution of the split angle theta
+func computeQn(n, b, offset, pulseCap int, stereo bool) int {
+	n2 := 2*n - 1
+	if stereo && n == 2 {
+		n2--
+	}
+	// the upper limit ensures that in a stereo split with itheta==16384, there are
+	// always enough bits left over to code at least one pulse in the side
+	qb := (b + n2*offset) / n2
+	qb = minInt(b-pulseCap-(4<<bitRes), qb)
+	qb = minInt(8<<bitRes, qb)
+	if qb < 1<<bitRes>>1 {
+		return 1
+	}
+	qn := exp2Table8[qb&0x7] >> uint(14-qb>>bitRes)
+	return (qn + 1) >> 1 << 1
+}
+
+// bandCtx is the state shared by the bands of a frame
+type bandCtx struct {
+	dec           *rangeDecoder
+	i             int // the band
+	intensity     int
+	spread        int
+	tfChange      int
+	remainingBits int
+	seed          uint32
+	tmp           []float32 // of the hadamard interleaving
+}
+
+type splitCtx struct {
+	inv    bool
+	imid   int
+	iside  int
+	delta  int
+	itheta int
+	qalloc int
+}
+
+func (ctx *bandCtx) computeTheta(sctx *splitCtx, n int, b *int, bb, b0, lm int, stereo bool, fill *int) {
+	dec := ctx.dec
+	i := ctx.i
+	itheta := 0
+	inv := false
+
+	// the resolution of the split parameter theta
+	pulseCap := int(celtLogN[i]) + lm*(1<<bitRes)
+	offset := pulseCap>>1 - qthetaOffset
+	if stereo && n == 2 {
+		offset = pulseCap>>1 - qthetaOffset2
+	}
+	qn := computeQn(n, *b, offset, pulseCap, stereo)
+	if stereo && i >= ctx.intensity {
+		qn = 1
+	}
+	tell := dec.tellFrac()
+	if qn != 1 {
+		// a uniform pdf for the time split, a step for stereo, and a triangular
+		// one for the rest
+		if stereo && n > 2 {
+			const p0 = 3
+			x0 := qn / 2
+			ft := uint32(p0*(x0+1) + x0)
+			fs := int(dec.decode(ft))
+			var x int
+			if fs < (x0+1)*p0 {
+				x = fs / p0
+			} else {
+				x = x0 + 1 + (fs - (x0+1)*p0)
+			}
+			if x <= x0 {
+				dec.update(uint32(p0*x), uint32(p0*(x+1)), ft)
+			} else {
+				dec.update(uint32((x-1-x0)+(x0+1)*p0), uint32((x-x0)+(x0+1)*p0), ft)
+			}
+			itheta = x
+		} else if b0 > 1 || stereo {
+			itheta = int(dec.uint(uint32(qn + 1)))
+		} else {
+			ft := ((qn >> 1) + 1) * ((qn >> 1) + 1)
+			fm := int(dec.decode(uint32(ft)))
+			var fs, fl int
+			if fm < (qn>>1)*((qn>>1)+1)>>1 {
+				itheta = int(isqrt32(8*uint32(fm)+1)-1) >> 1
+				fs = itheta + 1
+				fl = itheta * (itheta + 1) >> 1
+			} else {
+				itheta = (2*(qn+1) - int(isqrt32(8*uint32(ft-fm-1)+1))) >> 1
+				fs = qn + 1 - itheta
+				fl = ft - ((qn + 1 - itheta) * (qn + 2 - itheta) >> 1)
+			}
+			dec.update(uint32(fl), uint32(fl+fs), uint32(ft))
+		}
+		itheta = itheta * 16384 / qn
+	} else if stereo {
+		if *b > 2<<bitRes && ctx.remainingBits > 2<<bitRes {
+			inv = dec.bitLogp(2)
+		}
+		itheta = 0
+	}
+	qalloc := dec.tellFrac() - tell
+	*b -= qalloc
+
+	var imid, iside, delta int
+	switch itheta {
+	case 0:
+		imid = 32767
+		iside = 0
+		*fill &= 1<<uint(bb) - 1
+		delta = -16384
+	case 16384:
+		imid = 0
+		iside = 32767
+		*fill &= (1<<uint(bb) - 1) << uint(bb)
+		delta = 16384
+	default:
+		imid = bitexactCos(itheta)
+		iside = bitexactCos(16384 - itheta)
+		// the mid vs side allocation that minimizes squared error in the band
+		delta = fracMul16((n-1)<<7, bitexactLog2tan(iside, imid))
+	}
+	*sctx = splitCtx{inv, imid, iside, delta, itheta, qalloc}
+}
+
+// quantBandN1 decodes the band of one sample, the sign only
+func (ctx *bandCtx) quantBandN1(x, y []float32, b int, lowbandOut []float32) uint {
+	xc := x
+	for c := 0; c < 1+b2i(y != nil); c++ {
+		sign := uint32(0)
+		if ctx.remainingBits >= 1<<bitRes {
+			sign = ctx.dec.bits(1)
+			ctx.remainingBits -= 1 << bitRes
+			b -= 1 << bitRes
+		}
+		if sign != 0 {
+			xc[0] = -1
+		} else {
+			xc[0] = 1
+		}
+		xc = y
+	}
+	if lowbandOut != nil {
+		lowbandOut[0] = x[0]
+	}
+	return 1
+}
+
+// quantPartition decodes a mono partition, it may split the band in two halves
+// recursively, up to 8 parts
+func (ctx *bandCtx) quantPartition(x []float32, n, b, bb int, lowband []float32, lm int, gain float32, fill int) uint {
+	b0 := bb
+	var cm uint
+	i := ctx.i
+
+	// split the band in two if more than 1.5 bits than can be produced are needed
+	split := false
+	if lm != -1 && n > 2 {
+		cache := pulseCache(i, lm)
+		split = b > int(cache[cache[0]])+12
+	}
+	if split {
+		var sctx splitCtx
+		var nextLowband2 []float32
+		n >>= 1
+		y := x[n:]
+		lm--
+		if bb == 1 {
+			fill = fill&1 | fill<<1
+		}
+		bb = (bb + 1) >> 1
+
+		ctx.computeTheta(&sctx, n, &b, bb, b0, lm, false, &fill)
+		imid, iside := sctx.imid, sctx.iside
+		delta, itheta := sctx.delta, sctx.itheta
+		mid := (1. / 32768) * float32(imid)
+		side := (1. / 32768) * float32(iside)
+
+		// more bits to low-energy MDCTs than they would otherwise deserve
+		if b0 > 1 && itheta&0x3fff != 0 {
+			if itheta > 8192 {
+				// rough approximation for pre-echo masking
+				delta -= delta >> uint(4-lm)
+			} else {
+				// a forward-masking slope of 1.5 dB per 10 ms
+				delta = minInt(0, delta+(n<<bitRes>>uint(5-lm)))
+			}
+		}
+		mbits := maxInt(0, minInt(b, (b-delta)/2))
+		sbits := b - mbits
+		ctx.remainingBits -= sctx.qalloc
+
+		if lowband != nil {
+			nextLowband2 = lowband[n:]
+		}
+		rebalance := ctx.remainingBits
+		if mbits >= sbits {
+			cm = ctx.quantPartition(x, n, mbits, bb, lowband, lm, gain*mid, fill)
+			rebalance = mbits - (rebalance - ctx.remainingBits)
+			if rebalance > 3<<bitRes && itheta != 0 {
+				sbits += rebalance - (3 << bitRes)
+			}
+			cm |= ctx.quantPartition(y, n, sbits, bb, nextLowband2, lm, gain*side, fill>>uint(bb)) << uint(b0>>1)
+		} else {
+			cm = ctx.quantPartition(y, n, sbits, bb, nextLowband2, lm, gain*side, fill>>uint(bb)) << uint(b0>>1)
+			rebalance = sbits - (rebalance - ctx.remainingBits)
+			if rebalance > 3<<bitRes && itheta != 16384 {
+				mbits += rebalance - (3 << bitRes)
+			}
+			cm |= ctx.quantPartition(x, n, mbits, bb, lowband, lm, gain*mid, fill)
+		}
+		return cm
+	}
+
+	// the basic no-split case
+	q := bits2pulses(i, lm, b)
+	currBits := pulses2bits(i, lm, q)
+	ctx.remainingBits -= currBits
+	// never bust the budget
+	for ctx.remainingBits < 0 && q > 0 {
+		ctx.remainingBits += currBits
+		q--
+		currBits = pulses2bits(i, lm, q)
+		ctx.remainingBits -= currBits
+	}
+	if q != 0 {
+		return algUnquant(x, n, getPulses(q), ctx.spread, bb, ctx.dec, gain)
+	}
+
+	// no pulse, fill the band anyway
+	cmMask := uint(1)<<uint(bb) - 1
+	fill &= int(cmMask)
+	if fill == 0 {
+		for j := 0; j < n; j++ {
+			x[j] = 0
+		}
+		return 0
+	}
+	if lowband == nil {
+		// noise
+		for j := 0; j < n; j++ {
+			ctx.seed = lcgRand(ctx.seed)
+			x[j] = float32(int32(ctx.seed) >> 20)
+		}
+		cm = cmMask
+	} else {
+		// folded spectrum
+		for j := 0; j < n; j++ {
+			ctx.seed = lcgRand(ctx.seed)
+			// about 48 dB below the normal folding level
+			tmp := float32(1. / 256)
+			if ctx.seed&0x8000 == 0 {
+				tmp = -tmp
+			}
+			x[j] = lowband[j] + tmp
+		}
+		cm = uint(fill)
+	}
+	renormaliseVector(x, n, gain)
+	return cm
+}
+
+var (
+	bitInterleaveTable   = [16]uint8{0, 1, 1, 1, 2, 3, 3, 3, 2, 3, 3, 3, 2, 3, 3, 3}
+	bitDeinterleaveTable = [16]uint8{
+		0x00, 0x03, 0x0C, 0x0F, 0x30, 0x33, 0x3C, 0x3F,
+		0xC0, 0xC3, 0xCC, 0xCF, 0xF0, 0xF3, 0xFC, 0xFF,
+	}
+)
+
+// quantBand decodes a band of the mono case
+func (ctx *bandCtx) quantBand(x []float32, n, b, bb int, lowband []float32, lm int, lowbandOut []float32, gain float32, lowbandScratch []float32, fill int) uint {
+	n0 := n
+	nb := n
+	b0 := bb
+	timeDivide := 0
+	recombine := 0
+	longBlocks := b0 == 1
+	tfChange := ctx.tfChange
+
+	nb /= bb
+	// the special case of one sample
+	if n == 1 {
+		return ctx.quantBandN1(x, nil, b, lowbandOut)
+	}
+	if tfChange > 0 {
+		recombine = tfChange
+	}
+	// band recombining to increase the frequency resolution
+	if lowbandScratch != nil && lowband != nil && (recombine != 0 || (nb&1 == 0 && tfChange < 0) || b0 > 1) {
+		copy(lowbandScratch[:n], lowband[:n])
+		lowband = lowbandScratch
+	}
+	for k := 0; k < recombine; k++ {
+		if lowband != nil {
+			haar1(lowband, n>>uint(k), 1<<uint(k))
+		}
+		fill = int(bitInterleaveTable[fill&0xF] | bitInterleaveTable[fill>>4]<<2)
+	}
+	bb >>= uint(recombine)
+	nb <<= uint(recombine)
+
+	// increase the time resolution
+	for nb&1 == 0 && tfChange < 0 {
+		if lowband != nil {
+			haar1(lowband, nb, bb)
+		}
+		fill |= fill << uint(bb)
+		bb <<= 1
+		nb >>= 1
+		timeDivide++
+		tfChange++
+	}
+	b0 = bb
+	nb0 := nb
+
+	// reorganize the samples in time order instead of frequency order
+	if b0 > 1 && lowband != nil {
+		deinterleaveHadamard(lowband, ctx.tmp, nb>>uint(recombine), b0<<uint(recombine), longBlocks)
+	}
+
+	cm := ctx.quantPartition(x, n, b, bb, lowband, lm, gain, fill)
+
+	// undo the sample reorganization
+	if b0 > 1 {
+		interleaveHadamard(x, ctx.tmp, nb>>uint(recombine), b0<<uint(recombine), longBlocks)
+	}
+	// undo the time-frequency changes
+	nb = nb0
+	bb = b0
+	for k := 0; k < timeDivide; k++ {
+		bb >>= 1
+		nb <<= 1
+		cm |= cm >> uint(bb)
+		haar1(x, nb, bb)
+	}
+	for k := 0; k < recombine; k++ {
+		cm = uint(bitDeinterleaveTable[cm])
+		haar1(x, n0>>uint(k), 1<<uint(k))
+	}
+	bb <<= uint(recombine)
+
+	// scale the output for later folding
+	if lowbandOut != nil {
+		ns := celtSqrt(float32(n0))
+		for j := 0; j < n0; j++ {
+			lowbandOut[j] = ns * x[j]
+		}
+	}
+	return cm & (1<<uint(bb) - 1)
+}
+
+// quantBandStereo decodes a band of the stereo case
+func (ctx *bandCtx) quantBandStereo(x, y []float32, n, b, bb int, lowband []float32, lm int, lowbandOut, lowbandScratch []float32, fill int) uint {
+	var cm uint
+	// the special case of one sample
+	if n == 1 {
+		return ctx.quantBandN1(x, y, b, lowbandOut)
+	}
+	origFill := fill
+
+	var sctx splitCtx
+	ctx.computeTheta(&sctx, n, &b, bb, bb, lm, true, &fill)
+	inv := sctx.inv
+	imid, iside := sctx.imid, sctx.iside
+	delta, itheta := sctx.delta, sctx.itheta
+	mid := (1. / 32768) * float32(imid)
+	side := (1. / 32768) * float32(iside)
+
+	if n == 2 {
+		// mid and side are orthogonal, the side is coded with just one bit
+		mbits := b
+		sbits := 0
+		if itheta != 0 && itheta != 16384 {
+			sbits = 1 << bitRes
+		}
+		mbits -= sbits
+		c := itheta > 8192
+		ctx.remainingBits -= sctx.qalloc + sbits
+
+		x2, y2 := x, y
+		if c {
+			x2, y2 = y, x
+		}
+		sign := uint32(0)
+		if sbits != 0 {
+			sign = ctx.dec.bits(1)
+		}
+		s := float32(1 - 2*int(sign))
+		// orig_fill folds the side, the low bits of fill are cleared if
+		// itheta==16384
+		cm = ctx.quantBand(x2, n, mbits, bb, lowband, lm, lowbandOut, 1, lowbandScratch, origFill)
+		// N=2 bands are not split, so cm is either 1 or 0
+		y2[0] = -s * x2[1]
+		y2[1] = s * x2[0]
+		x[0] = mid * x[0]
+		x[1] = mid * x[1]
+		y[0] = side * y[0]
+		y[1] = side * y[1]
+		tmp := x[0]
+		x[0] = tmp - y[0]
+		y[0] = tmp + y[0]
+		tmp = x[1]
+		x[1] = tmp - y[1]
+		y[1] = tmp + y[1]
+	} else {
+		// the normal split
+		mbits := maxInt(0, minInt(b, (b-delta)/2))
+		sbits := b - mbits
+		ctx.remainingBits -= sctx.qalloc
+
+		rebalance := ctx.remainingBits
+		if mbits >= sbits {
+			// the mid is not scaled in stereo, the normalized mid is needed for
+			// folding later
+			cm = ctx.quantBand(x, n, mbits, bb, lowband, lm, lowbandOut, 1, lowbandScratch, fill)
+			rebalance = mbits - (rebalance - ctx.remainingBits)
+			if rebalance > 3<<bitRes && itheta != 0 {
+				sbits += rebalance - (3 << bitRes)
+			}
+			// the high bits of fill are always zero in a stereo split, the side
+			// is not folded
+			cm |= ctx.quantBand(y, n, sbits, bb, nil, lm, nil, side, nil, fill>>uint(bb))
+		} else {
+			cm = ctx.quantBand(y, n, sbits, bb, nil, lm, nil, side, nil, fill>>uint(bb))
+			rebalance = sbits - (rebalance - ctx.remainingBits)
+			if rebalance > 3<<bitRes && itheta != 16384 {
+				mbits += rebalance - (3 << bitRes)
+			}
+			cm |= ctx.quantBand(x, n, mbits, bb, lowband, lm, lowbandOut, 1, lowbandScratch, fill)
+		}
+	}
+
+	if n != 2 {
+		stereoMerge(x, y, mid, n)
+	}
+	if inv {
+		for j := 0; j < n; j++ {
+			y[j] = -y[j]
+		}
+	}
+	return cm
+}
+
+// quantAllBands decodes the normalized spectrum of the bands into x and y, y is
+// nil for mono
+func quantAllBands(start, end int, x, y []float32, collapseMasks []uint8, pulses []int, shortBlocks bool,
+	spread int, dualStereo bool, intensity int, tfRes []int, totalBits, balance int, dec *rangeDecoder,
+	lm, codedBands int, seed *uint32) {
+	m := 1 << uint(lm)
+	bb := 1
+	if shortBlocks {
+		bb = m
+	}
+	c := 1
+	if y != nil {
+		c = 2
+	}
+	normOffset := m * int(celtBands[start])
+	// the last band needs no folding source
+	normLen := m*int(celtBands[celtNbBands-1]) - normOffset
+	norm := make([]float32, c*normLen)
+	norm2 := norm[normLen:]
+	lowbandScratch := make([]float32, m*int(celtBands[celtNbBands]-celtBands[celtNbBands-1]))
+
+	ctx := bandCtx{
+		dec:       dec,
+		intensity: intensity,
+		spread:    spread,
+		seed:      *seed,
+		tmp:       make([]float32, m*int(celtBands[celtNbBands]-celtBands[celtNbBands-1])),
+	}
+	lowbandOffset := 0
+	updateLowband := true
+	for i := start; i < end; i++ {
+		ctx.i = i
+		last := i == end-1
+		xb := x[m*int(celtBands[i]):]
+		var yb []float32
+		if y != nil {
+			yb = y[m*int(celtBands[i]):]
+		}
+		n := m*int(celtBands[i+1]) - m*int(celtBands[i])
+		tell := dec.tellFrac()
+
+		// the bits of this band
+		if i != start {
+			balance -= tell
+		}
+		remainingBits := totalBits - tell - 1
+		ctx.remainingBits = remainingBits
+		b := 0
+		if i <= codedBands-1 {
+			currBalance := balance / minInt(3, codedBands-i)
+			b = maxInt(0, minInt(16383, minInt(remainingBits+1, pulses[i]+currBalance)))
+		}
+		if m*int(celtBands[i])-n >= m*int(celtBands[start]) && (updateLowband || lowbandOffset == 0) {
+			lowbandOffset = i
+		}
+		ctx.tfChange = tfRes[i]
+		scratch := lowbandScratch
+		if last {
+			scratch = nil
+		}
+
+		// a conservative estimate of the collapse masks of the bands folded from
+		effectiveLowband := -1
+		var xcm, ycm uint
+		if lowbandOffset != 0 && (spread != spreadAggressive || bb > 1 || ctx.tfChange < 0) {
+			// never repeat spectral content within one band
+			effectiveLowband = maxInt(0, m*int(celtBands[lowbandOffset])-normOffset-n)
+			foldStart := lowbandOffset
+			for {
+				foldStart--
+				if m*int(celtBands[foldStart]) <= effectiveLowband+normOffset {
+					break
+				}
+			}
+			foldEnd := lowbandOffset - 1
+			for {
+				foldEnd++
+				if m*int(celtBands[foldEnd]) >= effectiveLowband+normOffset+n {
+					break
+				}
+			}
+			for fi := foldStart; fi < foldEnd; fi++ {
+				xcm |= uint(collapseMasks[fi*c+0])
+				ycm |= uint(collapseMasks[fi*c+c-1])
+			}
+		} else {
+			// the LCG folds, all blocks are (almost always) non-zero
+			xcm = 1<<uint(bb) - 1
+			ycm = xcm
+		}
+
+		if dualStereo && i == intensity {
+			// switch off dual stereo to do intensity
+			dualStereo = false
+			for j := 0; j < m*int(celtBands[i])-normOffset; j++ {
+				norm[j] = .5 * (norm[j] + norm2[j])
+			}
+		}
+		var lowband, lowband2, out, out2 []float32
+		if effectiveLowband != -1 {
+			lowband = norm[effectiveLowband:]
+			if c == 2 {
+				lowband2 = norm2[effectiveLowband:]
+			}
+		}
+		if !last {
+			out = norm[m*int(celtBands[i])-normOffset:]
+			if c == 2 {
+				out2 = norm2[m*int(celtBands[i])-normOffset:]
+			}
+		}
+		if dualStereo {
+			xcm = ctx.quantBand(xb, n, b/2, bb, lowband, lm, out, 1, scratch, int(xcm))
+			ycm = ctx.quantBand(yb, n, b/2, bb, lowband2, lm, out2, 1, scratch, int(ycm))
+		} else {
+			if yb != nil {
+				xcm = ctx.quantBandStereo(xb, yb, n, b, bb, lowband, lm, out, scratch, int(xcm|ycm))
+			} else {
+				xcm = ctx.quantBand(xb, n, b, bb, lowband, lm, out, 1, scratch, int(xcm|ycm))
+			}
+			ycm = xcm
+		}
+		collapseMasks[i*c+0] = uint8(xcm)
+		collapseMasks[i*c+c-1] = uint8(ycm)
+		balance += pulses[i] + tell
+
+		// update the folding position only as long as there is 1 bit/sample depth
+		updateLowband = b > n<<bitRes
+	}
+	*seed = ctx.seed
+}
@@ -0,0 +1,83 @@
+package opus
+
+// the band energy of CELT, section 4.3.2 of RFC 6716. the energies are log2 of
+// the amplitude, relative to the means of celtEMeans
+
+func unquantCoarseEnergy(oldE []float32, start, end int, intra bool, dec *rangeDecoder, c, lm int) {
+	model := celtEProbModel[(lm*2+b2i(intra))*42:]
+	var prev [2]float32
+	coef, beta := celtPredCoef[lm], celtBetaCoef[lm]
+	if intra {
+		coef, beta = 0, celtBetaIntra
+	}
+	budget := len(dec.buf) * 8
+
+	// decode at a fixed coarse resolution
+	for i := start; i < end; i++ {
+		for ch := 0; ch < c; ch++ {
+			var qi int
+			tell := dec.tell()
+			switch {
+			case budget-tell >= 15:
+				pi := 2 * minInt(i, 20)
+				qi = dec.laplace(uint32(model[pi])<<7, int(model[pi+1])<<6)
+			case budget-tell >= 2:
+				qi = dec.icdf(smallEnergyICDF, 2)
+				qi = qi>>1 ^ -(qi & 1)
+			case budget-tell >= 1:
+				qi = -b2i(dec.bitLogp(1))
+			default:
+				qi = -1
+			}
+			q := float32(qi)
+			e := &oldE[i+ch*celtNbBands]
+			*e = max16(-9, *e)
+			*e = coef**e + prev[ch] + q
+			prev[ch] = prev[ch] + q - beta*q
+		}
+	}
+}
+
+func unquantFineEnergy(oldE []float32, start, end int, fineQuant []int, dec *rangeDecoder, c int) {
+	for i := start; i < end; i++ {
+		if fineQuant[i] <= 0 {
+			continue
+		}
+		for ch := 0; ch < c; ch++ {
+			q2 := dec.bits(uint(fineQuant[i]))
+			offset := (float32(q2)+.5)*float32(int(1)<<uint(14-fineQuant[i]))*(1./16384) - .5
+			oldE[i+ch*celtNbBands] += offset
+		}
+	}
+}
+
+// unquantEnergyFinalise uses up the bits left for fine energy
+func unquantEnergyFinalise(oldE []float32, start, end int, fineQuant, finePriority []int, bitsLeft int, dec *rangeDecoder, c int) {
+	for prio := 0; prio < 2; prio++ {
+		for i := start; i < end && bitsLeft >= c; i++ {
+			if fineQuant[i] >= maxFineBits || finePriority[i] != prio {
+				continue
+			}
+			for ch := 0; ch < c; ch++ {
+				q2 := dec.bits(1)
+				offset := (float32(q2) - .5) * float32(int(1)<<uint(14-fineQuant[i]-1)) * (1. / 16384)
+				oldE[i+ch*celtNbBands] += offset
+				bitsLeft--
+			}
+		}
+	}
+}
+
+func max16(a, b float32) float32 {
+	if a < b {
+		return b
+	}
+	return a
+}
+
+func min16(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,290 @@
+package opus
+
+// the pitch search and the LPC of the CELT packet loss concealment
+
+const lpcOrder = 24
+
+func findBestPitch(xcorr, y []float32, n, maxPitch int, bestPitch *[2]int) {
+	var syy float32 = 1
+	bestNum := [2]float32{-1, -1}
+	var bestDen [2]float32
+	bestPitch[0], bestPitch[1] = 0, 1
+	for j := 0; j < n; j++ {
+		syy += y[j] * y[j]
+	}
+	for i := 0; i < maxPitch; i++ {
+		if xcorr[i] > 0 {
+			// avoid both underflows and overflows when squaring
+			xcorr16 := xcorr[i] * 1e-12
+			num := xcorr16 * xcorr16
+			if num*bestDen[1] > bestNum[1]*syy {
+				if num*bestDen[0] > bestNum[0]*syy {
+					bestNum[1] = bestNum[0]
+					bestDen[1] = bestDen[0]
+					bestPitch[1] = bestPitch[0]
+					bestNum[0] = num
+					bestDen[0] = syy
+					bestPitch[0] = i
+				} else {
+					bestNum[1] = num
+					bestDen[1] = syy
+					bestPitch[1] = i
+				}
+			}
+		}
+		syy += y[i+n]*y[i+n] - y[i]*y[i]
+		if syy < 1 {
+			syy = 1
+		}
+	}
+}
+
+func celtFir5(x, num, y []float32, n int, mem []float32) {
+	num0, num1, num2, num3, num4 := num[0], num[1], num[2], num[3], num[4]
+	mem0, mem1, mem2, mem3, mem4 := mem[0], mem[1], mem[2], mem[3], mem[4]
+	for i := 0; i < n; i++ {
+		sum := x[i]
+		sum += num0 * mem0
+		sum += num1 * mem1
+		sum += num2 * mem2
+		sum += num3 * mem3
+		sum += num4 * mem4
+		mem4 = mem3
+		mem3 = mem2
+		mem2 = mem1
+		mem1 = mem0
+		mem0 = x[i]
+		y[i] = sum
+	}
+	mem[0], mem[1], mem[2], mem[3], mem[4] = mem0, mem1, mem2, mem3, mem4
+}
+
+// pitchDownsample low-passes and decimates the channels of x by 2 into xlp
+func pitchDownsample(x [][]float32, xlp []float32, n, c int) {
+	var ac [5]float32
+	var tmp float32 = 1
+	var lpc [4]float32
+	var mem [5]float32
+	var lpc2 [5]float32
+	var c1 float32 = .8
+	for i := 1; i < n>>1; i++ {
+		xlp[i] = .5 * (.5*(x[0][2*i-1]+x[0][2*i+1]) + x[0][2*i])
+	}
+	xlp[0] = .5 * (.5*x[0][1] + x[0][0])
+	if c == 2 {
+		for i := 1; i < n>>1; i++ {
+			xlp[i] += .5 * (.5*(x[1][2*i-1]+x[1][2*i+1]) + x[1][2*i])
+		}
+		xlp[0] += .5 * (.5*x[1][1] + x[1][0])
+	}
+	celtAutocorr(xlp, ac[:], nil, 0, 4, n>>1)
+
+	// noise floor -40 dB
+	ac[0] *= 1.0001
+	// lag windowing
+	for i := 1; i <= 4; i++ {
+		w := .008 * float32(i)
+		ac[i] -= ac[i] * w * w
+	}
+	celtLPC(lpc[:], ac[:], 4)
+	for i := 0; i < 4; i++ {
+		tmp = .9 * tmp
+		lpc[i] = lpc[i] * tmp
+	}
+	// add a zero
+	lpc2[0] = lpc[0] + .8
+	lpc2[1] = lpc[1] + c1*lpc[0]
+	lpc2[2] = lpc[2] + c1*lpc[1]
+	lpc2[3] = lpc[3] + c1*lpc[2]
+	lpc2[4] = c1 * lpc[3]
+	celtFir5(xlp, lpc2[:], xlp, n>>1, mem[:])
+}
+
+func pitchXcorr(x, y, xcorr []float32, n, maxPitch int) {
+	for i := 0; i < maxPitch; i++ {
+		xcorr[i] = innerProd(x, y[i:], n)
+	}
+}
+
+// pitchSearch reports the pitch of xlp in y, of the max lag maxPitch
+func pitchSearch(xlp, y []float32, n, maxPitch int) int {
+	var bestPitch [2]int
+	lag := n + maxPitch
+	xlp4 := make([]float32, n>>2)
+	ylp4 := make([]float32, lag>>2)
+	xcorr := make([]float32, maxPitch>>1)
+
+	// downsample by 2 again
+	for j := range xlp4 {
+		xlp4[j] = xlp[2*j]
+	}
+	for j := range ylp4 {
+		ylp4[j] = y[2*j]
+	}
+
+	// coarse search with 4x decimation
+	pitchXcorr(xlp4, ylp4, xcorr, n>>2, maxPitch>>2)
+	findBestPitch(xcorr, ylp4, n>>2, maxPitch>>2, &bestPitch)
+
+	// finer search with 2x decimation
+	for i := 0; i < maxPitch>>1; i++ {
+		xcorr[i] = 0
+		if absInt(i-2*bestPitch[0]) > 2 && absInt(i-2*bestPitch[1]) > 2 {
+			continue
+		}
+		sum := innerProd(xlp, y[i:], n>>1)
+		if sum < -1 {
+			sum = -1
+		}
+		xcorr[i] = sum
+	}
+	findBestPitch(xcorr, y, n>>1, maxPitch>>1, &bestPitch)
+
+	// refine by pseudo-interpolation
+	offset := 0
+	if bestPitch[0] > 0 && bestPitch[0] < (maxPitch>>1)-1 {
+		a := xcorr[bestPitch[0]-1]
+		b := xcorr[bestPitch[0]]
+		c := xcorr[bestPitch[0]+1]
+		if c-a > .7*(b-a) {
+			offset = 1
+		} else if a-c > .7*(b-c) {
+			offset = -1
+		}
+	}
+	return 2*bestPitch[0] - offset
+}
+
+// celtLPC computes the LPC of order p from the autocorrelation ac, by the
+// Levinson-Durbin recursion
+func celtLPC(lpc, ac []float32, p int) {
+	e := ac[0]
+	for i := 0; i < p; i++ {
+		lpc[i] = 0
+	}
+	if ac[0] == 0 {
+		return
+	}
+	for i := 0; i < p; i++ {
+		// the reflection coefficient of this iteration
+		var rr float32
+		for j := 0; j < i; j++ {
+			rr += lpc[j] * ac[i-j]
+		}
+		rr += ac[i+1]
+		r := -(rr / e)
+		// update the LPC coefficients and the total error
+		lpc[i] = r
+		for j := 0; j < (i+1)>>1; j++ {
+			tmp1 := lpc[j]
+			tmp2 := lpc[i-1-j]
+			lpc[j] = tmp1 + r*tmp2
+			lpc[i-1-j] = tmp2 + r*tmp1
+		}
+		e = e - r*r*e
+		// bail out once 30 dB gain is reached
+		if e < .001*ac[0] {
+			break
+		}
+	}
+}
+
+// celtFir filters x into y by the FIR of num, x and y may be the same
+func celtFir(x, num, y []float32, n, ord int, mem []float32) {
+	rnum := make([]float32, ord)
+	buf := make([]float32, n+ord)
+	for i := 0; i < ord; i++ {
+		rnum[i] = num[ord-i-1]
+	}
+	for i := 0; i < ord; i++ {
+		buf[i] = mem[ord-i-1]
+	}
+	copy(buf[ord:], x[:n])
+	for i := 0; i < ord; i++ {
+		mem[i] = x[n-i-1]
+	}
+	for i := 0; i < n; i++ {
+		sum := innerProd(rnum, buf[i:], ord)
+		y[i] = x[i] + sum
+	}
+}
+
+// celtIIR filters x into y by the IIR of den, x and y may be the same
+func celtIIR(x, den, y []float32, n, ord int, mem []float32) {
+	rden := make([]float32, ord)
+	buf := make([]float32, n+ord)
+	for i := 0; i < ord; i++ {
+		rden[i] = den[ord-i-1]
+	}
+	for i := 0; i < ord; i++ {
+		buf[i] = -mem[ord-i-1]
+	}
+	i := 0
+	for ; i < n-3; i += 4 {
+		// unrolled by 4 as if it were an FIR filter
+		var sum [4]float32
+		copy(sum[:], x[i:i+4])
+		for k := 0; k < 4; k++ {
+			for j := 0; j < ord; j++ {
+				sum[k] += rden[j] * buf[i+k+j]
+			}
+		}
+		// patch up the result for the IIR
+		buf[i+ord] = -sum[0]
+		y[i] = sum[0]
+		sum[1] += buf[i+ord] * den[0]
+		buf[i+ord+1] = -sum[1]
+		y[i+1] = sum[1]
+		sum[2] += buf[i+ord+1] * den[0]
+		sum[2] += buf[i+ord] * den[1]
+		buf[i+ord+2] = -sum[2]
+		y[i+2] = sum[2]
+		sum[3] += buf[i+ord+2] * den[0]
+		sum[3] += buf[i+ord+1] * den[1]
+		sum[3] += buf[i+ord] * den[2]
+		buf[i+ord+3] = -sum[3]
+		y[i+3] = sum[3]
+	}
+	for ; i < n; i++ {
+		sum := x[i]
+		for j := 0; j < ord; j++ {
+			sum -= rden[j] * buf[i+j]
+		}
+		buf[i+ord] = sum
+		y[i] = sum
+	}
+	for i := 0; i < ord; i++ {
+		mem[i] = y[n-i-1]
+	}
+}
+
+// celtAutocorr computes the autocorrelation of x up to lag, the ends of x are
+// windowed by the overlap
+func celtAutocorr(x, ac, window []float32, overlap, lag, n int) {
+	fastN := n - lag
+	xp := x
+	if overlap != 0 {
+		xx := make([]float32, n)
+		copy(xx, x[:n])
+		for i := 0; i < overlap; i++ {
+			xx[i] = x[i] * window[i]
+			xx[n-i-1] = x[n-i-1] * window[i]
+		}
+		xp = xx
+	}
+	pitchXcorr(xp, xp, ac, fastN, lag+1)
+	for k := 0; k <= lag; k++ {
+		var d float32
+		for i := k + fastN; i < n; i++ {
+			d += xp[i] * xp[i-k]
+		}
+		ac[k] += d
+	}
+}
+
+func absInt(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
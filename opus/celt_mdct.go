@@ -0,0 +1,227 @@
+package opus
+
+// the inverse MDCT of CELT, a complex FFT of N/4 points with pre and post rotation
+
+type fftCpx struct {
+	r, i float32
+}
+
+// fftState is the mixed radix FFT of one size, the twiddles are shared with the
+// largest size and strided by 1<<shift
+type fftState struct {
+	nfft    int
+	shift   int
+	factors []int // pairs of radix and the stride after it
+	bitrev  []int16
+}
+
+var celtFFT = [4]fftState{
+	{480, 0, []int{5, 96, 3, 32, 4, 8, 2, 4, 4, 1}, fftBitrev480[:]},
+	{240, 1, []int{5, 48, 3, 16, 4, 4, 4, 1}, fftBitrev240[:]},
+	{120, 2, []int{5, 24, 3, 8, 2, 4, 4, 1}, fftBitrev120[:]},
+	{60, 3, []int{5, 12, 3, 4, 4, 1}, fftBitrev60[:]},
+}
+
+func (st *fftState) bfly2(f []fftCpx, n int) {
+	const tw = 0.7071067812
+	for i := 0; i < n; i++ {
+		f := f[8*i:]
+		f2 := f[4:]
+		t := f2[0]
+		f2[0] = fftCpx{f[0].r - t.r, f[0].i - t.i}
+		f[0] = fftCpx{f[0].r + t.r, f[0].i + t.i}
+
+		t = fftCpx{(f2[1].r + f2[1].i) * tw, (f2[1].i - f2[1].r) * tw}
+		f2[1] = fftCpx{f[1].r - t.r, f[1].i - t.i}
+		f[1] = fftCpx{f[1].r + t.r, f[1].i + t.i}
+
+		t = fftCpx{f2[2].i, -f2[2].r}
+		f2[2] = fftCpx{f[2].r - t.r, f[2].i - t.i}
+		f[2] = fftCpx{f[2].r + t.r, f[2].i + t.i}
+
+		t = fftCpx{(f2[3].i - f2[3].r) * tw, (-f2[3].i - f2[3].r) * tw}
+		f2[3] = fftCpx{f[3].r - t.r, f[3].i - t.i}
+		f[3] = fftCpx{f[3].r + t.r, f[3].i + t.i}
+	}
+}
+
+func cmul(a, b fftCpx) fftCpx {
+	return fftCpx{a.r*b.r - a.i*b.i, a.r*b.i + a.i*b.r}
+}
+
+func (st *fftState) bfly4(f []fftCpx, fstride, m, n, mm int) {
+	if m == 1 {
+		// all the twiddles are 1
+		for i := 0; i < n; i++ {
+			f := f[4*i:]
+			s0 := fftCpx{f[0].r - f[2].r, f[0].i - f[2].i}
+			f[0] = fftCpx{f[0].r + f[2].r, f[0].i + f[2].i}
+			s1 := fftCpx{f[1].r + f[3].r, f[1].i + f[3].i}
+			f[2] = fftCpx{f[0].r - s1.r, f[0].i - s1.i}
+			f[0] = fftCpx{f[0].r + s1.r, f[0].i + s1.i}
+			s1 = fftCpx{f[1].r - f[3].r, f[1].i - f[3].i}
+			f[1] = fftCpx{s0.r + s1.i, s0.i - s1.r}
+			f[3] = fftCpx{s0.r - s1.i, s0.i + s1.r}
+		}
+		return
+	}
+	for i := 0; i < n; i++ {
+		f := f[i*mm:]
+		for j := 0; j < m; j++ {
+			s0 := cmul(f[j+m], fftTwiddles[j*fstride])
+			s1 := cmul(f[j+2*m], fftTwiddles[2*j*fstride])
+			s2 := cmul(f[j+3*m], fftTwiddles[3*j*fstride])
+			s5 := fftCpx{f[j].r - s1.r, f[j].i - s1.i}
+			f[j] = fftCpx{f[j].r + s1.r, f[j].i + s1.i}
+			s3 := fftCpx{s0.r + s2.r, s0.i + s2.i}
+			s4 := fftCpx{s0.r - s2.r, s0.i - s2.i}
+			f[j+2*m] = fftCpx{f[j].r - s3.r, f[j].i - s3.i}
+			f[j] = fftCpx{f[j].r + s3.r, f[j].i + s3.i}
+			f[j+m] = fftCpx{s5.r + s4.i, s5.i - s4.r}
+			f[j+3*m] = fftCpx{s5.r - s4.i, s5.i + s4.r}
+		}
+	}
+}
+
+func (st *fftState) bfly3(f []fftCpx, fstride, m, n, mm int) {
+	epi3 := fftTwiddles[fstride*m]
+	for i := 0; i < n; i++ {
+		f := f[i*mm:]
+		for k := 0; k < m; k++ {
+			s1 := cmul(f[k+m], fftTwiddles[k*fstride])
+			s2 := cmul(f[k+2*m], fftTwiddles[2*k*fstride])
+			s3 := fftCpx{s1.r + s2.r, s1.i + s2.i}
+			s0 := fftCpx{s1.r - s2.r, s1.i - s2.i}
+			f[k+m] = fftCpx{f[k].r - s3.r*.5, f[k].i - s3.i*.5}
+			s0 = fftCpx{s0.r * epi3.i, s0.i * epi3.i}
+			f[k] = fftCpx{f[k].r + s3.r, f[k].i + s3.i}
+			f[k+2*m] = fftCpx{f[k+m].r + s0.i, f[k+m].i - s0.r}
+			f[k+m] = fftCpx{f[k+m].r - s0.i, f[k+m].i + s0.r}
+		}
+	}
+}
+
+func (st *fftState) bfly5(f []fftCpx, fstride, m, n, mm int) {
+	ya := fftTwiddles[fstride*m]
+	yb := fftTwiddles[fstride*2*m]
+	for i := 0; i < n; i++ {
+		f := f[i*mm:]
+		f0, f1, f2, f3, f4 := f, f[m:], f[2*m:], f[3*m:], f[4*m:]
+		for u := 0; u < m; u++ {
+			s0 := f0[u]
+			s1 := cmul(f1[u], fftTwiddles[u*fstride])
+			s2 := cmul(f2[u], fftTwiddles[2*u*fstride])
+			s3 := cmul(f3[u], fftTwiddles[3*u*fstride])
+			s4 := cmul(f4[u], fftTwiddles[4*u*fstride])
+
+			s7 := fftCpx{s1.r + s4.r, s1.i + s4.i}
+			s10 := fftCpx{s1.r - s4.r, s1.i - s4.i}
+			s8 := fftCpx{s2.r + s3.r, s2.i + s3.i}
+			s9 := fftCpx{s2.r - s3.r, s2.i - s3.i}
+
+			f0[u].r += s7.r + s8.r
+			f0[u].i += s7.i + s8.i
+
+			s5 := fftCpx{s0.r + s7.r*ya.r + s8.r*yb.r, s0.i + s7.i*ya.r + s8.i*yb.r}
+			s6 := fftCpx{s10.i*ya.i + s9.i*yb.i, -(s10.r * ya.i) - s9.r*yb.i}
+			f1[u] = fftCpx{s5.r - s6.r, s5.i - s6.i}
+			f4[u] = fftCpx{s5.r + s6.r, s5.i + s6.i}
+
+			s11 := fftCpx{s0.r + s7.r*yb.r + s8.r*ya.r, s0.i + s7.i*yb.r + s8.i*ya.r}
+			s12 := fftCpx{-(s10.i * yb.i) + s9.i*ya.i, s10.r*yb.i - s9.r*ya.i}
+			f2[u] = fftCpx{s11.r + s12.r, s11.i + s12.i}
+			f3[u] = fftCpx{s11.r - s12.r, s11.i - s12.i}
+		}
+	}
+}
+
+// fft transforms f in place, the input is in bit reversed order
+func (st *fftState) fft(f []fftCpx) {
+	var fstride [8]int
+	fstride[0] = 1
+	l := 0
+	for {
+		p, m := st.factors[2*l], st.factors[2*l+1]
+		fstride[l+1] = fstride[l] * p
+		l++
+		if m == 1 {
+			break
+		}
+	}
+	m := st.factors[2*l-1]
+	for i := l - 1; i >= 0; i-- {
+		m2 := 1
+		if i != 0 {
+			m2 = st.factors[2*i-1]
+		}
+		switch st.factors[2*i] {
+		case 2:
+			st.bfly2(f, fstride[i])
+		case 4:
+			st.bfly4(f, fstride[i]<<uint(st.shift), m, fstride[i], m2)
+		case 3:
+			st.bfly3(f, fstride[i]<<uint(st.shift), m, fstride[i], m2)
+		case 5:
+			st.bfly5(f, fstride[i]<<uint(st.shift), m, fstride[i], m2)
+		}
+		m = m2
+	}
+}
+
+// mdctBackward is the inverse MDCT of the 1920 points mode scaled down by 1<<shift.
+// the coefficients are read from in with the stride, the overlap of out is windowed
+// for the TDAC with the previous frame
+func mdctBackward(in []float32, out []float32, overlap, shift, stride int, buf []fftCpx) {
+	n := 1920
+	trig := mdctTwiddles[:]
+	for i := 0; i < shift; i++ {
+		n >>= 1
+		trig = trig[n:]
+	}
+	n2 := n >> 1
+	n4 := n >> 2
+	st := &celtFFT[shift]
+
+	// pre-rotate, stored in the bit reversed order
+	f := buf[:n4]
+	for i := 0; i < n4; i++ {
+		x1 := in[2*i*stride]
+		x2 := in[stride*(n2-1-2*i)]
+		yr := x2*trig[i] + x1*trig[n4+i]
+		yi := x1*trig[i] - x2*trig[n4+i]
+		// swap real and imaginary, it is an FFT instead of an IFFT
+		f[st.bitrev[i]] = fftCpx{yi, yr}
+	}
+	st.fft(f)
+
+	// post-rotate and de-shuffle from both ends of the buffer at once
+	y := out[overlap>>1:]
+	for i := 0; i < n4; i++ {
+		y[2*i], y[2*i+1] = f[i].r, f[i].i
+	}
+	for i := 0; i < (n4+1)>>1; i++ {
+		p0 := 2 * i
+		p1 := n2 - 2 - 2*i
+		re, im := y[p0+1], y[p0]
+		t0, t1 := trig[i], trig[n4+i]
+		yr := re*t0 + im*t1
+		yi := re*t1 - im*t0
+		re, im = y[p1+1], y[p1]
+		y[p0] = yr
+		y[p1+1] = yi
+		t0, t1 = trig[n4-i-1], trig[n2-i-1]
+		yr = re*t0 + im*t1
+		yi = re*t1 - im*t0
+		y[p1] = yr
+		y[p0+1] = yi
+	}
+
+	// mirror on both sides for TDAC
+	for i := 0; i < overlap/2; i++ {
+		x1 := out[overlap-1-i]
+		x2 := out[i]
+		w1, w2 := celtWindow[i], celtWindow[overlap-1-i]
+		out[i] = w2*x2 - w1*x1
+		out[overlap-1-i] = w1*x2 + w2*x1
+	}
+}
@@ -0,0 +1,377 @@
+package opus
+
+// the bit allocation of CELT, section 4.3.3 of RFC 6716
+
+const (
+	celtNbBands   = 21
+	maxFineBits   = 8
+	fineOffset    = 21
+	qthetaOffset  = 4
+	qthetaOffset2 = 16 // of the two phase stereo
+	allocSteps    = 6
+)
+
+func getPulses(i int) int {
+	if i < 8 {
+		return i
+	}
+	return (8 + i&7) << uint(i>>3-1)
+}
+
+// pulseCache is the bits needed for each number of pulses in the band of LM
+func pulseCache(band, lm int) []uint8 {
+	return celtCacheBits[celtCacheIndex[(lm+1)*celtNbBands+band]:]
+}
+
+func bits2pulses(band, lm, bits int) int {
+	cache := pulseCache(band, lm)
+	lo, hi := 0, int(cache[0])
+	bits--
+	for i := 0; i < 6; i++ {
+		mid := (lo + hi + 1) >> 1
+		if int(cache[mid]) >= bits {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	low := -1
+	if lo != 0 {
+		low = int(cache[lo])
+	}
+	if bits-low <= int(cache[hi])-bits {
+		return lo
+	}
+	return hi
+}
+
+func pulses2bits(band, lm, pulses int) int {
+	if pulses == 0 {
+		return 0
+	}
+	return int(pulseCache(band, lm)[pulses]) + 1
+}
+
+// initCaps computes the maximum bits of each band
+func initCaps(caps []int, lm, c int) {
+	for i := 0; i < celtNbBands; i++ {
+		n := int(celtBands[i+1]-celtBands[i]) << uint(lm)
+		caps[i] = (int(celtCacheCaps[celtNbBands*(2*lm+c-1)+i]) + 64) * c * n >> 2
+	}
+}
+
+// allocation is the result of computeAllocation
+type allocation struct {
+	codedBands   int
+	intensity    int
+	dualStereo   bool
+	balance      int
+	pulses       [celtNbBands]int
+	fineQuant    [celtNbBands]int
+	finePriority [celtNbBands]int
+}
+
+// computeAllocation splits the total bits among the bands and decodes the skip,
+// intensity and dual stereo parameters
+func computeAllocation(a *allocation, start, end int, offsets, caps []int, allocTrim, total, c, lm int, dec *rangeDecoder) {
+	var bits1, bits2, thresh, trimOffset [celtNbBands]int
+	if total < 0 {
+		total = 0
+	}
+	skipStart := start
+	// reserve a bit to signal the end of manually skipped bands
+	skipRsv := 0
+	if total >= 1<<bitRes {
+		skipRsv = 1 << bitRes
+	}
+	total -= skipRsv
+	// reserve bits for the intensity and dual stereo parameters
+	intensityRsv, dualStereoRsv := 0, 0
+	if c == 2 {
+		intensityRsv = int(log2FracTable[end-start])
+		if intensityRsv > total {
+			intensityRsv = 0
+		} else {
+			total -= intensityRsv
+			if total >= 1<<bitRes {
+				dualStereoRsv = 1 << bitRes
+			}
+			total -= dualStereoRsv
+		}
+	}
+	for j := start; j < end; j++ {
+		n := int(celtBands[j+1] - celtBands[j])
+		// below this threshold, no PVQ bits are allocated
+		thresh[j] = maxInt(c<<bitRes, (3*n<<uint(lm)<<bitRes)>>4)
+		// tilt of the allocation curve
+		trimOffset[j] = c * n * (allocTrim - 5 - lm) * (end - j - 1) * (1 << uint(lm+bitRes)) >> 6
+		// less resolution to single-coefficient bands
+		if n<<uint(lm) == 1 {
+			trimOffset[j] -= c << bitRes
+		}
+	}
+	const nbAllocVectors = 11
+	lo, hi := 1, nbAllocVectors-1
+	for lo <= hi {
+		done := false
+		psum := 0
+		mid := (lo + hi) >> 1
+		for j := end - 1; j >= start; j-- {
+			n := int(celtBands[j+1] - celtBands[j])
+			bitsj := c * n * int(celtAllocVectors[mid*celtNbBands+j]) << uint(lm) >> 2
+			if bitsj > 0 {
+				bitsj = maxInt(0, bitsj+trimOffset[j])
+			}
+			bitsj += offsets[j]
+			if bitsj >= thresh[j] || done {
+				done = true
+				psum += minInt(bitsj, caps[j])
+			} else if bitsj >= c<<bitRes {
+				psum += c << bitRes
+			}
+		}
+		if psum > total {
+			hi = mid - 1
+		} else {
+			lo = mid + 1
+		}
+	}
+	hi = lo
+	lo--
+	for j := start; j < end; j++ {
+		n := int(celtBands[j+1] - celtBands[j])
+		bits1j := c * n * int(celtAllocVectors[lo*celtNbBands+j]) << uint(lm) >> 2
+		bits2j := caps[j]
+		if hi < nbAllocVectors {
+			bits2j = c * n * int(celtAllocVectors[hi*celtNbBands+j]) << uint(lm) >> 2
+		}
+		if bits1j > 0 {
+			bits1j = maxInt(0, bits1j+trimOffset[j])
+		}
+		if bits2j > 0 {
+			bits2j = maxInt(0, bits2j+trimOffset[j])
+		}
+		if lo > 0 {
+			bits1j += offsets[j]
+		}
+		bits2j += offsets[j]
+		if offsets[j] > 0 {
+			skipStart = j
+		}
+		bits1[j] = bits1j
+		bits2[j] = maxInt(0, bits2j-bits1j)
+	}
+	interpBits2pulses(a, start, end, skipStart, bits1[:], bits2[:], thresh[:], caps, total,
+		skipRsv, intensityRsv, dualStereoRsv, c, lm, dec)
+}
+
+func interpBits2pulses(a *allocation, start, end, skipStart int, bits1, bits2, thresh, caps []int,
+	total, skipRsv, intensityRsv, dualStereoRsv, c, lm int, dec *rangeDecoder) {
+	bits := a.pulses[:]
+	ebits := a.fineQuant[:]
+	finePriority := a.finePriority[:]
+	allocFloor := c << bitRes
+	stereo := 0
+	if c > 1 {
+		stereo = 1
+	}
+	logM := lm << bitRes
+	lo, hi := 0, 1<<allocSteps
+	for i := 0; i < allocSteps; i++ {
+		mid := (lo + hi) >> 1
+		psum := 0
+		done := false
+		for j := end - 1; j >= start; j-- {
+			tmp := bits1[j] + mid*bits2[j]>>allocSteps
+			if tmp >= thresh[j] || done {
+				done = true
+				// don't allocate more than can be used
+				psum += minInt(tmp, caps[j])
+			} else if tmp >= allocFloor {
+				psum += allocFloor
+			}
+		}
+		if psum > total {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	psum := 0
+	done := false
+	for j := end - 1; j >= start; j-- {
+		tmp := bits1[j] + lo*bits2[j]>>allocSteps
+		if tmp < thresh[j] && !done {
+			if tmp >= allocFloor {
+				tmp = allocFloor
+			} else {
+				tmp = 0
+			}
+		} else {
+			done = true
+		}
+		tmp = minInt(tmp, caps[j])
+		bits[j] = tmp
+		psum += tmp
+	}
+
+	// decide which bands to skip, working backwards from the end
+	codedBands := end
+	for ; ; codedBands-- {
+		j := codedBands - 1
+		// never skip the first band, nor a band boosted by dynalloc
+		if j <= skipStart {
+			// give the bit reserved to end skipping back
+			total += skipRsv
+			break
+		}
+		// the left-over bits that would be added to this band
+		left := total - psum
+		percoeff := left / int(celtBands[codedBands]-celtBands[start])
+		left -= int(celtBands[codedBands]-celtBands[start]) * percoeff
+		rem := maxInt(left-int(celtBands[j]-celtBands[start]), 0)
+		bandWidth := int(celtBands[codedBands] - celtBands[j])
+		bandBits := bits[j] + percoeff*bandWidth + rem
+		// a skip decision is only coded above the threshold of the band
+		if bandBits >= maxInt(thresh[j], allocFloor+1<<bitRes) {
+			if dec.bitLogp(1) {
+				break
+			}
+			// a bit was used to skip this band
+			psum += 1 << bitRes
+			bandBits -= 1 << bitRes
+		}
+		// reclaim the bits originally allocated to this band
+		psum -= bits[j] + intensityRsv
+		if intensityRsv > 0 {
+			intensityRsv = int(log2FracTable[j-start])
+		}
+		psum += intensityRsv
+		if bandBits >= allocFloor {
+			// enough for a fine energy bit per channel
+			psum += allocFloor
+			bits[j] = allocFloor
+		} else {
+			bits[j] = 0
+		}
+	}
+
+	// the intensity and dual stereo parameters
+	a.intensity = 0
+	if intensityRsv > 0 {
+		a.intensity = start + int(dec.uint(uint32(codedBands+1-start)))
+	}
+	if a.intensity <= start {
+		total += dualStereoRsv
+		dualStereoRsv = 0
+	}
+	a.dualStereo = false
+	if dualStereoRsv > 0 {
+		a.dualStereo = dec.bitLogp(1)
+	}
+
+	// allocate the remaining bits
+	left := total - psum
+	percoeff := left / int(celtBands[codedBands]-celtBands[start])
+	left -= int(celtBands[codedBands]-celtBands[start]) * percoeff
+	for j := start; j < codedBands; j++ {
+		bits[j] += percoeff * int(celtBands[j+1]-celtBands[j])
+	}
+	for j := start; j < codedBands; j++ {
+		tmp := minInt(left, int(celtBands[j+1]-celtBands[j]))
+		bits[j] += tmp
+		left -= tmp
+	}
+
+	balance := 0
+	j := start
+	for ; j < codedBands; j++ {
+		n0 := int(celtBands[j+1] - celtBands[j])
+		n := n0 << uint(lm)
+		bit := bits[j] + balance
+		excess := 0
+		if n > 1 {
+			excess = maxInt(bit-caps[j], 0)
+			bits[j] = bit - excess
+
+			// compensate for the extra degree of freedom in stereo
+			den := c * n
+			if c == 2 && n > 2 && !a.dualStereo && j < a.intensity {
+				den++
+			}
+			nclogn := den * (int(celtLogN[j]) + logM)
+
+			// offset of the fine bits by log2(N)/2 + fineOffset compared to
+			// their fair share of total/N
+			offset := nclogn>>1 - den*fineOffset
+			// N=2 is the only point that doesn't match the curve
+			if n == 2 {
+				offset += den << bitRes >> 2
+			}
+			// the offset of the second and third fine energy bit
+			if bits[j]+offset < den*2<<bitRes {
+				offset += nclogn >> 2
+			} else if bits[j]+offset < den*3<<bitRes {
+				offset += nclogn >> 3
+			}
+
+			// divide with rounding
+			ebits[j] = maxInt(0, bits[j]+offset+den<<(bitRes-1))
+			ebits[j] = ebits[j] / den >> bitRes
+			// make sure not to bust
+			if c*ebits[j] > bits[j]>>bitRes {
+				ebits[j] = bits[j] >> uint(stereo) >> bitRes
+			}
+			// more than that is useless, it is as far as PVQ can go
+			ebits[j] = minInt(ebits[j], maxFineBits)
+
+			// a band rounded down or capped is a candidate for the final fine
+			// energy pass
+			finePriority[j] = b2i(ebits[j]*(den<<bitRes) >= bits[j]+offset)
+
+			// the rest goes to PVQ
+			bits[j] -= c * ebits[j] << bitRes
+		} else {
+			// for N=1, all bits go to fine energy except for a sign bit
+			excess = maxInt(0, bit-c<<bitRes)
+			bits[j] = bit - excess
+			ebits[j] = 0
+			finePriority[j] = 1
+		}
+
+		// fine energy can't take advantage of the rebalancing of the bands, do
+		// it here
+		if excess > 0 {
+			extraFine := minInt(excess>>uint(stereo+bitRes), maxFineBits-ebits[j])
+			ebits[j] += extraFine
+			extraBits := extraFine * c << bitRes
+			finePriority[j] = b2i(extraBits >= excess-balance)
+			excess -= extraBits
+		}
+		balance = excess
+	}
+	// the bits over the cap are rebalanced while decoding the bands
+	a.balance = balance
+
+	// the skipped bands use all their bits for fine energy
+	for ; j < end; j++ {
+		ebits[j] = bits[j] >> uint(stereo) >> bitRes
+		bits[j] = 0
+		finePriority[j] = b2i(ebits[j] < 1)
+	}
+	a.codedBands = codedBands
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
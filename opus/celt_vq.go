@@ -0,0 +1,217 @@
+package opus
+
+import "math"
+
+// the pyramid vector quantizer of CELT, section 4.3.4 of RFC 6716
+
+const (
+	spreadNone = iota
+	spreadLight
+	spreadNormal
+	spreadAggressive
+)
+
+func celtCosNorm(x float32) float32 {
+	return float32(math.Cos(float64(.5 * float32(math.Pi) * x)))
+}
+
+func celtRsqrt(x float32) float32 {
+	return 1 / float32(math.Sqrt(float64(x)))
+}
+
+func celtSqrt(x float32) float32 {
+	return float32(math.Sqrt(float64(x)))
+}
+
+func celtExp2(x float32) float32 {
+	return float32(math.Exp(0.6931471805599453094 * float64(x)))
+}
+
+func celtLog2(x float32) float32 {
+	return float32(1.442695040888963387 * math.Log(float64(x)))
+}
+
+func innerProd(x, y []float32, n int) float32 {
+	var xy float32
+	for i := 0; i < n; i++ {
+		xy += x[i] * y[i]
+	}
+	return xy
+}
+
+// unext computes the next row of u[i][j] = u[i-1][j] + u[i][j-1] + u[i-1][j-1],
+// ui0 is the first value of the new row
+func unext(u []uint32, n int, ui0 uint32) {
+	j := 1
+	for {
+		ui1 := u[j] + u[j-1] + ui0
+		u[j-1] = ui0
+		ui0 = ui1
+		j++
+		if j >= n {
+			break
+		}
+	}
+	u[j-1] = ui0
+}
+
+// uprev computes the previous row of the recurrence of unext
+func uprev(u []uint32, n int, ui0 uint32) {
+	j := 1
+	for {
+		ui1 := u[j] - u[j-1] - ui0
+		u[j-1] = ui0
+		ui0 = ui1
+		j++
+		if j >= n {
+			break
+		}
+	}
+	u[j-1] = ui0
+}
+
+// ncwrsUrow computes U(n, 0..k+1) into u, and reports V(n, k), the number of
+// codewords of k pulses in n dimensions
+func ncwrsUrow(n, k int, u []uint32) uint32 {
+	u[0] = 0
+	u[1] = 1
+	for i := 2; i < k+2; i++ {
+		u[i] = uint32(i<<1 - 1)
+	}
+	for i := 2; i < n; i++ {
+		unext(u[1:], k+1, 1)
+	}
+	return u[k] + u[k+1]
+}
+
+// cwrsi decodes the codeword i of k pulses in n dimensions into y, u holds the row
+// of ncwrsUrow. it reports the squared norm of y
+func cwrsi(n, k int, i uint32, y []int, u []uint32) float32 {
+	var yy float32
+	for j := 0; j < n; j++ {
+		p := u[k+1]
+		s := 0
+		if i >= p {
+			s = -1
+			i -= p
+		}
+		yj := k
+		p = u[k]
+		for p > i {
+			k--
+			p = u[k]
+		}
+		i -= p
+		yj -= k
+		val := int(int16((yj + s) ^ s))
+		y[j] = val
+		yy += float32(val) * float32(val)
+		uprev(u, k+2, 0)
+	}
+	return yy
+}
+
+func decodePulses(y []int, n, k int, dec *rangeDecoder) float32 {
+	u := make([]uint32, k+2)
+	return cwrsi(n, k, dec.uint(ncwrsUrow(n, k, u)), y, u)
+}
+
+func expRotation1(x []float32, n, stride int, c, s float32) {
+	ms := -s
+	for i := 0; i < n-stride; i++ {
+		x1, x2 := x[i], x[i+stride]
+		x[i+stride] = c*x2 + s*x1
+		x[i] = c*x1 + ms*x2
+	}
+	for i := n - 2*stride - 1; i >= 0; i-- {
+		x1, x2 := x[i], x[i+stride]
+		x[i+stride] = c*x2 + s*x1
+		x[i] = c*x1 + ms*x2
+	}
+}
+
+var spreadFactor = [3]int{15, 10, 5}
+
+func expRotation(x []float32, n, dir, stride, k, spread int) {
+	if 2*k >= n || spread == spreadNone {
+		return
+	}
+	factor := spreadFactor[spread-1]
+	gain := float32(n) / float32(n+factor*k)
+	theta := .5 * (gain * gain)
+	c := celtCosNorm(theta)
+	s := celtCosNorm(1 - theta) // sin(theta)
+	stride2 := 0
+	if n >= 8*stride {
+		// sqrt(n/stride) with rounding
+		stride2 = 1
+		for (stride2*stride2+stride2)*stride+stride>>2 < n {
+			stride2++
+		}
+	}
+	n /= stride
+	for i := 0; i < stride; i++ {
+		x := x[i*n:]
+		if dir < 0 {
+			if stride2 != 0 {
+				expRotation1(x, n, stride2, s, c)
+			}
+			expRotation1(x, n, 1, c, s)
+		} else {
+			expRotation1(x, n, 1, c, -s)
+			if stride2 != 0 {
+				expRotation1(x, n, stride2, s, -c)
+			}
+		}
+	}
+}
+
+// normaliseResidual scales the pulses to the norm of gain
+func normaliseResidual(iy []int, x []float32, n int, ryy, gain float32) {
+	g := celtRsqrt(ryy) * gain
+	for i := 0; i < n; i++ {
+		x[i] = g * float32(iy[i])
+	}
+}
+
+// extractCollapseMask reports which of the b blocks have pulses
+func extractCollapseMask(iy []int, n, b int) uint {
+	if b <= 1 {
+		return 1
+	}
+	n0 := n / b
+	mask := uint(0)
+	for i := 0; i < b; i++ {
+		tmp := 0
+		for j := 0; j < n0; j++ {
+			tmp |= iy[i*n0+j]
+		}
+		if tmp != 0 {
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask
+}
+
+// algUnquant decodes k pulses of n dimensions into x of the norm gain, it reports
+// the collapse mask of the b blocks
+func algUnquant(x []float32, n, k, spread, b int, dec *rangeDecoder, gain float32) uint {
+	iy := make([]int, n)
+	ryy := decodePulses(iy, n, k, dec)
+	normaliseResidual(iy, x, n, ryy, gain)
+	expRotation(x, n, -1, b, k, spread)
+	return extractCollapseMask(iy, n, b)
+}
+
+func renormaliseVector(x []float32, n int, gain float32) {
+	e := epsilon + innerProd(x, x, n)
+	g := celtRsqrt(e) * gain
+	for i := 0; i < n; i++ {
+		x[i] = g * x[i]
+	}
+}
+
+const (
+	epsilon   = 1e-15
+	verySmall = 1e-30
+)
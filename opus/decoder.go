@@ -0,0 +1,294 @@
+package opus
+
+// the decoder of an opus stream, section 4 of RFC 6716. it runs the SILK and CELT
+// layers by the mode of the frames, and smooths the transitions between the modes
+
+// modeNone is the mode before the first frame
+const modeNone Mode = -1
+
+// the frame sizes at 48 kHz
+const (
+	frame20ms  = sampleRate / 50
+	frame10ms  = frame20ms / 2
+	frame5ms   = frame10ms / 2
+	frame2_5ms = frame5ms / 2
+)
+
+// decoder decodes the frames of an opus stream into interleaved pcm of 1 or 2
+// channels, it is a port of the float build of libopus
+type decoder struct {
+	channels int
+	silk     *silkDecoder
+	celt     *celtDecoder
+
+	// of the last frame
+	mode           Mode
+	bandwidth      Bandwidth
+	frameSize      int
+	streamChannels int
+
+	prevMode       Mode
+	prevRedundancy bool
+
+	// the SILK parameters of the last frame which is not lost
+	silkFsKHz    int
+	silkChannels int
+
+	silkPCM    []int16
+	transition []float32
+	redundant  []float32
+}
+
+func newDecoder(channels int) *decoder {
+	return &decoder{
+		channels:   channels,
+		silk:       newSilkDecoder(),
+		celt:       newCeltDecoder(channels),
+		prevMode:   modeNone,
+		silkPCM:    make([]int16, 3*frame20ms*channels),
+		transition: make([]float32, frame5ms*channels),
+		redundant:  make([]float32, frame5ms*channels),
+	}
+}
+
+// decodeFrame decodes a frame of the packet of toc into pcm, data of at most 1 byte
+// is concealed. it reports the samples decoded
+func (d *decoder) decodeFrame(toc TOC, data []byte, pcm []float32) (int, error) {
+	d.mode = toc.Mode()
+	d.bandwidth = toc.Bandwidth()
+	d.frameSize = toc.FrameSize()
+	d.streamChannels = 1
+	if toc.Stereo() {
+		d.streamChannels = 2
+	}
+	return d.decode(data, d.frameSize, pcm)
+}
+
+// conceal generates n samples for a lost packet, n is a multiple of 2.5 ms
+func (d *decoder) conceal(pcm []float32, n int) error {
+	for k := 0; k < n; {
+		m, err := d.decode(nil, n-k, pcm[k*d.channels:])
+		if err != nil {
+			return err
+		}
+		k += m
+	}
+	return nil
+}
+
+// decode decodes the frame data of at most frameSize samples, data of at most 1 byte
+// is concealed
+func (d *decoder) decode(data []byte, frameSize int, pcm []float32) (int, error) {
+	channels := d.channels
+	frameSize = minInt(frameSize, 3*frame20ms)
+	if len(data) <= 1 {
+		data = nil
+		// don't conceal more than the last packet
+		frameSize = minInt(frameSize, d.frameSize)
+	}
+
+	var dec rangeDecoder
+	audioSize := d.frameSize
+	mode := d.mode
+	if data != nil {
+		dec.init(data)
+	} else {
+		audioSize = frameSize
+		mode = d.prevMode
+		if mode == modeNone {
+			// zeros before the first packet
+			for i := range pcm[:audioSize*channels] {
+				pcm[i] = 0
+			}
+			return audioSize, nil
+		}
+		// the PLC runs on the sizes of 2.5, 5, 10 or 20 ms
+		if audioSize > frame20ms {
+			for k := 0; k < audioSize; {
+				n, err := d.decode(nil, minInt(audioSize-k, frame20ms), pcm[k*channels:])
+				if err != nil {
+					return 0, err
+				}
+				k += n
+			}
+			return frameSize, nil
+		} else if audioSize < frame20ms {
+			if audioSize > frame10ms {
+				audioSize = frame10ms
+			} else if mode != ModeSILK && audioSize > frame5ms && audioSize < frame10ms {
+				audioSize = frame5ms
+			}
+		}
+	}
+
+	transition := false
+	if data != nil && d.prevMode != modeNone &&
+		(mode == ModeCELT && d.prevMode != ModeCELT && !d.prevRedundancy ||
+			mode != ModeCELT && d.prevMode == ModeCELT) {
+		transition = true
+	}
+	if transition && mode == ModeCELT {
+		if _, err := d.decode(nil, minInt(frame5ms, audioSize), d.transition); err != nil {
+			return 0, err
+		}
+	}
+	frameSize = audioSize
+
+	// SILK
+	if mode != ModeCELT {
+		if d.prevMode == ModeCELT {
+			d.silk.reset()
+		}
+		// the SILK PLC can't produce frames of less than 10 ms
+		payloadMs := maxInt(10, 1000*audioSize/sampleRate)
+		if data != nil {
+			d.silkChannels = d.streamChannels
+			switch {
+			case mode == ModeHybrid:
+				d.silkFsKHz = 16
+			case d.bandwidth == Narrowband:
+				d.silkFsKHz = 8
+			case d.bandwidth == Mediumband:
+				d.silkFsKHz = 12
+			default:
+				d.silkFsKHz = 16
+			}
+		}
+		for n := 0; n < frameSize; {
+			k, err := d.silk.decode(&dec, d.silkPCM[n*channels:], channels, d.silkChannels, d.silkFsKHz, payloadMs, n == 0, data == nil)
+			if err != nil {
+				return 0, err
+			}
+			n += k
+		}
+	}
+
+	// the redundant CELT frame of a mode transition
+	length := len(data)
+	redundancy, celtToSilk := false, false
+	redundancyBytes := 0
+	extra := 0
+	if d.mode == ModeHybrid {
+		extra = 20
+	}
+	if data != nil && mode != ModeCELT && dec.tell()+17+extra <= 8*length {
+		redundancy = mode != ModeHybrid || dec.bitLogp(12)
+		if redundancy {
+			celtToSilk = dec.bitLogp(1)
+			if mode == ModeHybrid {
+				redundancyBytes = int(dec.uint(256)) + 2
+			} else {
+				redundancyBytes = length - (dec.tell()+7)>>3
+			}
+			length -= redundancyBytes
+			if length*8 < dec.tell() {
+				length = 0
+				redundancyBytes = 0
+				redundancy = false
+			}
+			// the raw bits of the range decoder end before the redundant frame
+			dec.buf = dec.buf[:len(dec.buf)-redundancyBytes]
+		}
+	}
+	startBand := 0
+	if mode != ModeCELT {
+		startBand = 17
+	}
+	switch d.bandwidth {
+	case Narrowband:
+		d.celt.end = 13
+	case Mediumband, Wideband:
+		d.celt.end = 17
+	case SuperWideband:
+		d.celt.end = 19
+	default:
+		d.celt.end = 21
+	}
+	d.celt.streamChannels = d.streamChannels
+
+	if redundancy {
+		transition = false
+	}
+	if transition && mode != ModeCELT {
+		if _, err := d.decode(nil, minInt(frame5ms, audioSize), d.transition); err != nil {
+			return 0, err
+		}
+	}
+
+	// the 5 ms redundant frame of CELT to SILK
+	if redundancy && celtToSilk {
+		d.celt.start = 0
+		d.celt.decode(data[length:length+redundancyBytes], d.redundant, frame5ms, nil)
+	}
+	d.celt.start = startBand
+
+	// CELT
+	if mode != ModeSILK {
+		// discard the previous CELT state
+		if mode != d.prevMode && d.prevMode != modeNone && !d.prevRedundancy {
+			d.celt.reset()
+		}
+		var err error
+		if data != nil {
+			err = d.celt.decode(data[:length], pcm, minInt(frame20ms, frameSize), &dec)
+		} else {
+			err = d.celt.decode(nil, pcm, minInt(frame20ms, frameSize), nil)
+		}
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		for i := range pcm[:frameSize*channels] {
+			pcm[i] = 0
+		}
+		// fade out hybrid to SILK by the MDCT of a silence CELT frame
+		if d.prevMode == ModeHybrid && !(redundancy && celtToSilk && d.prevRedundancy) {
+			d.celt.start = 0
+			d.celt.decode([]byte{0xff, 0xff}, pcm, frame2_5ms, nil)
+		}
+	}
+
+	if mode != ModeCELT {
+		for i := range pcm[:frameSize*channels] {
+			pcm[i] += (1. / 32768) * float32(d.silkPCM[i])
+		}
+	}
+
+	// the 5 ms redundant frame of SILK to CELT
+	if redundancy && !celtToSilk {
+		d.celt.reset()
+		d.celt.start = 0
+		d.celt.decode(data[length:length+redundancyBytes], d.redundant, frame5ms, nil)
+		p := pcm[channels*(frameSize-frame2_5ms):]
+		smoothFade(p, d.redundant[channels*frame2_5ms:], p, channels)
+	}
+	if redundancy && celtToSilk {
+		copy(pcm[:channels*frame2_5ms], d.redundant)
+		p := pcm[channels*frame2_5ms:]
+		smoothFade(d.redundant[channels*frame2_5ms:], p, p, channels)
+	}
+	if transition {
+		if audioSize >= frame5ms {
+			copy(pcm[:channels*frame2_5ms], d.transition)
+			p := pcm[channels*frame2_5ms:]
+			smoothFade(d.transition[channels*frame2_5ms:], p, p, channels)
+		} else {
+			smoothFade(d.transition, pcm, pcm, channels)
+		}
+	}
+
+	d.prevMode = mode
+	d.prevRedundancy = redundancy && !celtToSilk
+	return audioSize, nil
+}
+
+// smoothFade cross-fades 2.5 ms of in1 to in2 by the square of the CELT window
+func smoothFade(in1, in2, out []float32, channels int) {
+	for c := 0; c < channels; c++ {
+		for i := 0; i < frame2_5ms; i++ {
+			w := celtWindow[i] * celtWindow[i]
+			k := i*channels + c
+			out[k] = float32(w*in2[k]) + float32((1-w)*in1[k])
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package opus
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// Head is the identification header, section 5.1 of RFC 7845
+type Head struct {
+	Version       uint8
+	Channels      int    // output channels
+	PreSkip       int    // samples at 48 kHz to discard from the beginning of decoded output
+	InputRate     int    // the sample rate of the original input, informational only
+	OutputGain    int16  // gain in dB of Q7.8, applied to the decoded output
+	MappingFamily uint8  // 0 is mono or stereo, 1 is the Vorbis channel order
+	StreamCount   int    // opus streams in each packet
+	CoupledCount  int    // the first CoupledCount streams are stereo
+	Mapping       []byte // the decoded channel of each output channel, 255 is silence
+}
+
+func parseHead(p []byte) (*Head, error) {
+	if len(p) < 19 || string(p[:8]) != "OpusHead" {
+		return nil, ErrFormat
+	}
+	h := &Head{
+		Version:       p[8],
+		Channels:      int(p[9]),
+		PreSkip:       int(binary.LittleEndian.Uint16(p[10:])),
+		InputRate:     int(binary.LittleEndian.Uint32(p[12:])),
+		OutputGain:    int16(binary.LittleEndian.Uint16(p[16:])),
+		MappingFamily: p[18],
+	}
+	// the major version is the upper 4 bits, only version 0 is defined
+	if h.Version>>4 != 0 || h.Channels == 0 {
+		return nil, ErrFormat
+	}
+	if h.MappingFamily == 0 {
+		if h.Channels > 2 {
+			return nil, ErrFormat
+		}
+		h.StreamCount = 1
+		h.CoupledCount = h.Channels - 1
+		h.Mapping = []byte{0, 1}[:h.Channels]
+		return h, nil
+	}
+	if len(p) < 21+h.Channels {
+		return nil, ErrFormat
+	}
+	h.StreamCount = int(p[19])
+	h.CoupledCount = int(p[20])
+	if h.StreamCount == 0 || h.CoupledCount > h.StreamCount || h.StreamCount+h.CoupledCount > 255 {
+		return nil, ErrFormat
+	}
+	h.Mapping = append([]byte(nil), p[21:21+h.Channels]...)
+	for _, m := range h.Mapping {
+		if m != 255 && int(m) >= h.StreamCount+h.CoupledCount {
+			return nil, ErrFormat
+		}
+	}
+	return h, nil
+}
+
+// parseTags parses the comment header, section 5.2 of RFC 7845. the comments of the same
+// name are joined by '|', like the vorbis package.
+func parseTags(p []byte) (vendor string, comments map[string]string, err error) {
+	if len(p) < 16 || string(p[:8]) != "OpusTags" {
+		return "", nil, ErrFormat
+	}
+	p = p[8:]
+	str := func() (string, bool) {
+		if len(p) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(p)
+		p = p[4:]
+		if uint64(n) > uint64(len(p)) {
+			return "", false
+		}
+		s := string(p[:n])
+		p = p[n:]
+		return s, true
+	}
+	var ok bool
+	if vendor, ok = str(); !ok || len(p) < 4 {
+		return "", nil, ErrFormat
+	}
+	count := binary.LittleEndian.Uint32(p)
+	p = p[4:]
+	comments = make(map[string]string)
+	for i := uint32(0); i < count; i++ {
+		s, ok := str()
+		if !ok {
+			return "", nil, ErrFormat
+		}
+		if pos := strings.IndexByte(s, '='); pos != -1 {
+			k, v := s[:pos], s[pos+1:]
+			if v0, ok := comments[k]; ok {
+				comments[k] = v0 + "|" + v
+			} else {
+				comments[k] = v
+			}
+		}
+	}
+	return vendor, comments, nil
+}
@@ -0,0 +1,388 @@
+// Package opus reads Ogg Opus streams, RFC 7845.
+//
+// the SILK, CELT and hybrid modes of RFC 6716 are decoded, including the packet loss
+// concealment of empty frames and multistream packets. the decoder follows the float
+// build of libopus.
+package opus
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/toy80/audio/ogg"
+	"github.com/toy80/audio/wav"
+)
+
+// the decoded output is always 48 kHz
+const sampleRate = 48000
+
+// ErrFormat indicates the stream is not ogg opus
+var ErrFormat = errors.New("opus: not an ogg opus stream")
+
+// frameDecoder decodes the frames of one opus stream into interleaved pcm of 1 or 2
+// channels, it reports the samples decoded
+type frameDecoder interface {
+	decodeFrame(toc TOC, data []byte, pcm []float32) (int, error)
+}
+
+// Opus decoder
+type Opus struct {
+	r        io.Reader
+	dmx      ogg.Demuxer
+	inbuf    []byte
+	head     *Head
+	vendor   string
+	comments map[string]string
+	outType  wav.Type
+	gain     float32 // linear output gain
+	total    int64   // samples after pre-skip, from the last granule, -1 if unknown
+
+	dec     []frameDecoder // of each stream
+	frames  [][]byte
+	stream  []float32 // pcm of a stream
+	pcm     []float32 // interleaved pcm of output channels
+	skip    int       // samples of pre-skip not discarded yet
+	emitted int64     // samples returned after pre-skip
+	out     []byte
+	outBuf  []byte // the part of out not read yet
+	fault   error
+}
+
+// New opus decoder, the headers are parsed from r
+func New(r io.Reader, t wav.Type) (*Opus, error) {
+	if t.Bits() == 0 {
+		return nil, fmt.Errorf("opus: unsupported target PCM format %s", t)
+	}
+	op := &Opus{r: r, outType: t, total: -1, inbuf: make([]byte, 16<<10)}
+	rs, seekable := r.(io.ReadSeeker)
+	var begin int64
+	if seekable {
+		var err error
+		if begin, err = rs.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		}
+	}
+	p, err := op.nextPacket()
+	if err != nil {
+		return nil, err
+	}
+	if op.head, err = parseHead(p.Data); err != nil {
+		return nil, err
+	}
+	if p, err = op.nextPacket(); err != nil {
+		return nil, err
+	}
+	if op.vendor, op.comments, err = parseTags(p.Data); err != nil {
+		return nil, err
+	}
+	op.gain = float32(math.Pow(10, float64(op.head.OutputGain)/(20*256)))
+	op.skip = op.head.PreSkip
+	op.dec = make([]frameDecoder, op.head.StreamCount)
+	for i := range op.dec {
+		if i < op.head.CoupledCount {
+			op.dec[i] = newDecoder(2)
+		} else {
+			op.dec[i] = newDecoder(1)
+		}
+	}
+	op.stream = make([]float32, 2*maxPacketSize)
+	op.pcm = make([]float32, op.head.Channels*maxPacketSize)
+	op.out = make([]byte, op.head.Channels*maxPacketSize*t.Bits()/8)
+	if seekable {
+		if g := lastGranule(rs, begin); g >= 0 {
+			op.total = maxInt64(g-int64(op.head.PreSkip), 0)
+		}
+	}
+	return op, nil
+}
+
+// Open the ogg opus file, the output is 16bits signed
+func Open(filename string) (*Opus, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	op, err := New(f, wav.I16)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return op, nil
+}
+
+// Close closes the underlying reader if it is an io.Closer
+func (op *Opus) Close() error {
+	if c, ok := op.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Head reports the identification header
+func (op *Opus) Head() Head {
+	return *op.head
+}
+
+// Vendor reports the vendor string of comment header
+func (op *Opus) Vendor() string {
+	return op.vendor
+}
+
+// Comments reports the user comments
+func (op *Opus) Comments() map[string]string {
+	return op.comments
+}
+
+// Comment reports the user comment of name
+func (op *Opus) Comment(name string) string {
+	return op.comments[name]
+}
+
+// SampleType reports the output sample type
+func (op *Opus) SampleType() wav.Type {
+	return op.outType
+}
+
+// Frequency is always 48000, the rate of decoded opus
+func (op *Opus) Frequency() int {
+	return sampleRate
+}
+
+// NumTracks reports the output channels
+func (op *Opus) NumTracks() int {
+	return op.head.Channels
+}
+
+// NumFrames reports the frames after pre-skip, -1 if the reader is not seekable
+func (op *Opus) NumFrames() int64 {
+	return op.total
+}
+
+// Duration of the stream, 0 if the reader is not seekable
+func (op *Opus) Duration() time.Duration {
+	if op.total <= 0 {
+		return 0
+	}
+	return time.Second * time.Duration(op.total) / sampleRate
+}
+
+func (op *Opus) Read(buf []byte) (n int, err error) {
+	for len(buf) != 0 {
+		if len(op.outBuf) != 0 {
+			n1 := copy(buf, op.outBuf)
+			buf = buf[n1:]
+			op.outBuf = op.outBuf[n1:]
+			n += n1
+			continue
+		}
+		if op.fault != nil {
+			return n, op.fault
+		}
+		if err = op.decodePacket(); err != nil {
+			op.fault = err
+			if n != 0 {
+				err = nil
+			}
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// nextPacket reads the next complete packet, io.EOF at the end of stream
+func (op *Opus) nextPacket() (ogg.Packet, error) {
+	for {
+		if p, ok := op.dmx.Packet(); ok {
+			return p, nil
+		}
+		if op.dmx.EndOfStream() {
+			return ogg.Packet{}, io.EOF
+		}
+		n, err := op.r.Read(op.inbuf)
+		if n != 0 {
+			if _, err := op.dmx.Write(op.inbuf[:n]); err != nil {
+				return ogg.Packet{}, err
+			}
+			continue
+		}
+		if err == io.EOF {
+			return ogg.Packet{}, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return ogg.Packet{}, err
+		}
+	}
+}
+
+// decodePacket decodes the next audio packet into outBuf, it may be empty
+func (op *Opus) decodePacket() error {
+	p, err := op.nextPacket()
+	if err != nil {
+		if err == io.EOF && op.dmx.EndOfStream() {
+			return io.EOF
+		}
+		return err
+	}
+	samples, err := op.decodeStreams(p.Data)
+	if err != nil {
+		return err
+	}
+
+	// discard the pre-skip, then trim the end by the granule of the last page
+	begin := 0
+	if op.skip > 0 {
+		begin = op.skip
+		if begin > samples {
+			begin = samples
+		}
+		op.skip -= begin
+	}
+	end := samples
+	if p.Last && p.Granule >= 0 {
+		limit := p.Granule - int64(op.head.PreSkip) - op.emitted
+		if limit < int64(end-begin) {
+			end = begin + int(maxInt64(limit, 0))
+		}
+	}
+	op.emitted += int64(end - begin)
+	op.output(op.pcm[begin*op.head.Channels : end*op.head.Channels])
+	return nil
+}
+
+// decodeStreams decodes the streams of the packet, and maps them into output channels
+func (op *Opus) decodeStreams(data []byte) (samples int, err error) {
+	h := op.head
+	channels := h.Channels
+	samples = -1
+	for s := 0; s < h.StreamCount; s++ {
+		var pkt Packet
+		pkt, data, err = parsePacket(data, s < h.StreamCount-1, op.frames)
+		if err != nil {
+			return 0, err
+		}
+		op.frames = pkt.Frames
+		if samples < 0 {
+			samples = pkt.Samples()
+		} else if samples != pkt.Samples() {
+			return 0, errPacket // the streams must have the same duration
+		}
+		sc := 1 // channels of the stream
+		if s < h.CoupledCount {
+			sc = 2
+		}
+		n := 0
+		for _, f := range pkt.Frames {
+			k, err := op.dec[s].decodeFrame(pkt.TOC, f, op.stream[n*sc:])
+			if err != nil {
+				return 0, err
+			}
+			n += k
+		}
+		if n != samples {
+			return 0, errPacket
+		}
+
+		// decoded channel index of the stream, coupled streams come first
+		first := s + minInt(s, h.CoupledCount)
+		for ch, m := range h.Mapping {
+			c := int(m) - first
+			if m == 255 || c < 0 || c >= sc {
+				continue
+			}
+			for i := 0; i < samples; i++ {
+				op.pcm[i*channels+ch] = op.stream[i*sc+c]
+			}
+		}
+	}
+	for ch, m := range h.Mapping {
+		if m == 255 {
+			for i := 0; i < samples; i++ {
+				op.pcm[i*channels+ch] = 0
+			}
+		}
+	}
+	return samples, nil
+}
+
+// output applies the gain and converts the pcm into outBuf
+func (op *Opus) output(pcm []float32) {
+	size := op.outType.Bits() / 8
+	b := op.out[:len(pcm)*size]
+	for i, x := range pcm {
+		x *= op.gain
+		if x > 1 {
+			x = 1
+		} else if x < -1 {
+			x = -1
+		}
+		switch op.outType {
+		case wav.U8:
+			b[i] = byte(x*127 + 128)
+		case wav.I16:
+			v := uint16(int16(x * 32767))
+			b[2*i], b[2*i+1] = byte(v), byte(v>>8)
+		case wav.I24:
+			v := uint32(int32(float64(x) * (1<<23 - 1)))
+			b[3*i], b[3*i+1], b[3*i+2] = byte(v), byte(v>>8), byte(v>>16)
+		case wav.I32:
+			v := uint32(int32(float64(x) * (1<<31 - 1)))
+			b[4*i], b[4*i+1], b[4*i+2], b[4*i+3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+		case wav.F32:
+			v := math.Float32bits(x)
+			b[4*i], b[4*i+1], b[4*i+2], b[4*i+3] = byte(v), byte(v>>8), byte(v>>16), byte(v>>24)
+		case wav.F64:
+			v := math.Float64bits(float64(x))
+			for j := 0; j < 8; j++ {
+				b[8*i+j] = byte(v >> (8 * j))
+			}
+		}
+	}
+	op.outBuf = b
+}
+
+// lastGranule finds the granule of the last page after begin, the reader is restored
+// to its position
+func lastGranule(rs io.ReadSeeker, begin int64) (g int64) {
+	cur, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+	defer rs.Seek(cur, io.SeekStart)
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return -1
+	}
+	// the last page is at most 65307 bytes
+	off := maxInt64(begin, end-65307)
+	buf := make([]byte, end-off)
+	if _, err = rs.Seek(off, io.SeekStart); err != nil {
+		return -1
+	}
+	if _, err = io.ReadFull(rs, buf); err != nil {
+		return -1
+	}
+	for i := len(buf) - 27; i >= 0; i-- {
+		if string(buf[i:i+4]) == "OggS" && buf[i+5]&0x04 != 0 {
+			return int64(binary.LittleEndian.Uint64(buf[i+6:]))
+		}
+	}
+	return -1
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,258 @@
+package opus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toy80/audio/ogg"
+	"github.com/toy80/audio/wav"
+)
+
+func TestParsePacket(t *testing.T) {
+	for _, c := range []struct {
+		data    []byte
+		sizes   []int
+		padding int
+	}{
+		{[]byte{31 << 3, 1, 2, 3}, []int{3}, 0},                                // code 0
+		{[]byte{31<<3 | 1, 1, 2, 3, 4}, []int{2, 2}, 0},                        // code 1
+		{[]byte{31<<3 | 2, 1, 9, 8, 7}, []int{1, 2}, 0},                        // code 2
+		{[]byte{31<<3 | 3, 3, 1, 2, 3}, []int{1, 1, 1}, 0},                     // code 3 cbr
+		{[]byte{31<<3 | 3, 0x80 | 0x40 | 2, 1, 1, 9, 8, 7, 0}, []int{1, 2}, 1}, // code 3 vbr, padding
+		{[]byte{1<<3 | 3, 0x40 | 1, 2, 0xaa, 0xbb}, []int{0}, 2},               // SILK 20 ms, padding only
+	} {
+		p, err := ParsePacket(c.data)
+		if err != nil {
+			t.Fatalf("%v: %v", c.data, err)
+		}
+		if len(p.Frames) != len(c.sizes) || p.Padding != c.padding {
+			t.Fatalf("%v: %d frames, padding %d", c.data, len(p.Frames), p.Padding)
+		}
+		for i, f := range p.Frames {
+			if len(f) != c.sizes[i] {
+				t.Fatalf("%v: frame %d of %d bytes", c.data, i, len(f))
+			}
+		}
+	}
+	for _, data := range [][]byte{
+		{},
+		{31<<3 | 1, 1, 2, 3},        // code 1 of odd bytes
+		{31<<3 | 2, 5, 1},           // code 2 beyond the packet
+		{31<<3 | 3, 0},              // code 3 of no frame
+		{11<<3 | 3, 3},              // code 3 of 180 ms
+		{31<<3 | 3, 2, 1, 2, 3},     // code 3 cbr of uneven bytes
+		{31<<3 | 3, 0x40 | 1, 9, 1}, // padding beyond the packet
+	} {
+		if _, err := ParsePacket(data); err == nil {
+			t.Fatalf("%v: expect error", data)
+		}
+	}
+
+	toc := TOC(13<<3 | 4)
+	if toc.Mode() != ModeHybrid || toc.Bandwidth() != SuperWideband || toc.FrameSize() != 960 || !toc.Stereo() {
+		t.Fatalf("toc %d: %s %s %d", toc.Config(), toc.Mode(), toc.Bandwidth(), toc.FrameSize())
+	}
+}
+
+// constDecoder decodes every frame into the value of channel
+type constDecoder struct {
+	values []float32
+}
+
+func (d constDecoder) decodeFrame(toc TOC, data []byte, pcm []float32) (int, error) {
+	n, channels := toc.FrameSize(), len(d.values)
+	for i := 0; i < n; i++ {
+		copy(pcm[i*channels:i*channels+channels], d.values)
+	}
+	return n, nil
+}
+
+// makeStream writes the ogg opus stream of the head and packets, the granule of last
+// page is given, the other granules are counted from packets
+func makeStream(t *testing.T, head []byte, packets [][]byte, last int64) []byte {
+	var buf bytes.Buffer
+	w := ogg.NewWriter(&buf, 1)
+	tags := []byte("OpusTags\x04\x00\x00\x00test\x01\x00\x00\x00\x0a\x00\x00\x00TITLE=song")
+	for _, p := range [][]byte{head, tags} {
+		if err := w.WritePacket(p, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var granule int64
+	for i, data := range packets {
+		p, _, err := parsePacket(data, false, nil)
+		if err == nil {
+			granule += int64(p.Samples())
+		}
+		if i == len(packets)-1 {
+			granule = last
+		}
+		if err := w.WritePacket(data, granule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	head := []byte("OpusHead\x01\x02\x38\x01\x80\xbb\x00\x00\x00\x00\x00")
+	binary.LittleEndian.PutUint16(head[16:], uint16(6*256)) // +6 dB
+	frame := []byte{31<<3 | 4, 0xff, 0xff}                  // CELT FB 20 ms stereo, silence
+	packets := [][]byte{frame, frame, frame, frame}
+	data := makeStream(t, head, packets, 3000)
+
+	op, err := New(bytes.NewReader(data), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := op.Head()
+	if h.Channels != 2 || h.PreSkip != 312 || h.InputRate != 48000 || op.Vendor() != "test" || op.Comment("TITLE") != "song" {
+		t.Fatalf("head %+v, vendor %q, comments %v", h, op.Vendor(), op.Comments())
+	}
+	if op.NumFrames() != 3000-312 || op.Frequency() != 48000 || op.NumTracks() != 2 {
+		t.Fatalf("%d frames", op.NumFrames())
+	}
+	got, err := io.ReadAll(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4*(3000-312) {
+		t.Fatalf("%d frames decoded", len(got)/4)
+	}
+	for i, b := range got {
+		if b != 0 {
+			t.Fatalf("byte %d of silence: %d", i, b)
+		}
+	}
+
+	// the output stage with a fake frame decoder
+	op, err = New(bytes.NewReader(data), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op.dec[0] = constDecoder{[]float32{0.25, -0.25}}
+	got, err = io.ReadAll(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4*(3000-312) {
+		t.Fatalf("%d frames decoded", len(got)/4)
+	}
+	want := int16(0.25 * math.Pow(10, 6.0/20) * 32767)
+	for i := 0; i < len(got); i += 4 {
+		l := int16(binary.LittleEndian.Uint16(got[i:]))
+		r := int16(binary.LittleEndian.Uint16(got[i+2:]))
+		if l != want || r != -want {
+			t.Fatalf("frame %d: %d %d, want %d", i/4, l, r, want)
+		}
+	}
+	// 24bits output
+	op, err = New(bytes.NewReader(data), wav.I24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op.dec[0] = constDecoder{[]float32{0.25, -0.25}}
+	if got, err = io.ReadAll(op); err != nil {
+		t.Fatal(err)
+	}
+	want24 := int32(float32(0.25*math.Pow(10, 6.0/20)) * (1<<23 - 1))
+	if len(got) != 6*(3000-312) {
+		t.Fatalf("%d bytes decoded", len(got))
+	}
+	if l := int32(uint32(got[0])<<8|uint32(got[1])<<16|uint32(got[2])<<24) >> 8; l != want24 {
+		t.Fatalf("24bits sample %d, want %d", l, want24)
+	}
+}
+
+func TestMultistream(t *testing.T) {
+	// 3 channels of a coupled stream and a mono stream, the mapping is C, L, R
+	head := []byte("OpusHead\x01\x03\x00\x00\x80\xbb\x00\x00\x00\x00\x01\x02\x01\x02\x00\x01")
+	// the first stream is self-delimited, code 0 with a frame of 1 byte
+	packet := []byte{31<<3 | 4, 1, 0, 31 << 3, 0}
+	data := makeStream(t, head, [][]byte{packet, packet}, 1920)
+	op, err := New(bytes.NewReader(data), wav.F32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op.dec[0] = constDecoder{[]float32{0.5, -0.5}}
+	op.dec[1] = constDecoder{[]float32{0.125}}
+	got, err := io.ReadAll(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 12*1920 {
+		t.Fatalf("%d frames decoded", len(got)/12)
+	}
+	for ch, want := range []float32{0.125, 0.5, -0.5} {
+		if x := math.Float32frombits(binary.LittleEndian.Uint32(got[4*ch:])); x != want {
+			t.Fatalf("channel %d: %v, want %v", ch, x, want)
+		}
+	}
+
+	// the streams of different durations
+	bad := []byte{31<<3 | 4, 1, 0, 30 << 3, 0}
+	data = makeStream(t, head, [][]byte{bad}, 960)
+	if op, err = New(bytes.NewReader(data), wav.F32); err != nil {
+		t.Fatal(err)
+	}
+	op.dec[0] = constDecoder{[]float32{0.5, -0.5}}
+	op.dec[1] = constDecoder{[]float32{0.125}}
+	if _, err = io.ReadAll(op); err == nil {
+		t.Fatal("expect error of malformed packet")
+	}
+}
+
+// TestCorpus decodes the streams encoded by libopus, and compares them with the output
+// of libopus, see testdata/README.md
+func TestCorpus(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.opus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no .opus file in testdata")
+	}
+	for _, name := range files {
+		ref, err := os.ReadFile(strings.TrimSuffix(name, ".opus") + ".f32")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		op, err := New(f, wav.F32)
+		if err != nil {
+			f.Close()
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := io.ReadAll(op)
+		op.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(got) != len(ref) || op.NumFrames()*int64(4*op.NumTracks()) != int64(len(ref)) {
+			t.Fatalf("%s: %d bytes decoded, %d frames, want %d bytes", name, len(got), op.NumFrames(), len(ref))
+		}
+		// the reference is rounded to 16 bits
+		for i := 0; i < len(got); i += 4 {
+			x := math.Float32frombits(binary.LittleEndian.Uint32(got[i:]))
+			y := math.Float32frombits(binary.LittleEndian.Uint32(ref[i:]))
+			if math.Abs(float64(x-y)) > 1.0/32768 {
+				t.Fatalf("%s: sample %d is %v, want %v", name, i/4, x, y)
+			}
+		}
+	}
+}
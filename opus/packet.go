@@ -0,0 +1,266 @@
+package opus
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Mode is the coding mode of an opus frame
+type Mode int
+
+// Coding modes
+const (
+	ModeSILK Mode = iota
+	ModeHybrid
+	ModeCELT
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeSILK:
+		return "SILK"
+	case ModeHybrid:
+		return "Hybrid"
+	case ModeCELT:
+		return "CELT"
+	default:
+		return fmt.Sprintf("unknown opus mode %d", int(m))
+	}
+}
+
+// Bandwidth is the audio bandwidth of an opus frame
+type Bandwidth int
+
+// Audio bandwidths
+const (
+	Narrowband    Bandwidth = iota // 4 kHz
+	Mediumband                     // 6 kHz
+	Wideband                       // 8 kHz
+	SuperWideband                  // 12 kHz
+	Fullband                       // 20 kHz
+)
+
+func (b Bandwidth) String() string {
+	switch b {
+	case Narrowband:
+		return "NB"
+	case Mediumband:
+		return "MB"
+	case Wideband:
+		return "WB"
+	case SuperWideband:
+		return "SWB"
+	case Fullband:
+		return "FB"
+	default:
+		return fmt.Sprintf("unknown opus bandwidth %d", int(b))
+	}
+}
+
+// TOC is the table-of-contents byte of an opus packet, section 3.1 of RFC 6716
+type TOC byte
+
+// Config reports the configuration number, 0 to 31
+func (t TOC) Config() int { return int(t >> 3) }
+
+// Stereo reports whether the frames are coded in stereo
+func (t TOC) Stereo() bool { return t&0x04 != 0 }
+
+// Code reports the frame count code, 0 to 3
+func (t TOC) Code() int { return int(t & 0x03) }
+
+// Mode reports the coding mode of the frames
+func (t TOC) Mode() Mode {
+	switch c := t.Config(); {
+	case c < 12:
+		return ModeSILK
+	case c < 16:
+		return ModeHybrid
+	default:
+		return ModeCELT
+	}
+}
+
+// Bandwidth reports the audio bandwidth of the frames
+func (t TOC) Bandwidth() Bandwidth {
+	switch c := t.Config(); {
+	case c < 12:
+		return Bandwidth(c / 4) // NB, MB, WB
+	case c < 16:
+		return SuperWideband + Bandwidth(c-12)/2
+	case c < 20:
+		return Narrowband
+	default:
+		return Wideband + Bandwidth(c-20)/4 // WB, SWB, FB
+	}
+}
+
+// FrameSize reports the samples of each frame at 48 kHz
+func (t TOC) FrameSize() int {
+	switch c := t.Config(); {
+	case c < 12:
+		return [4]int{480, 960, 1920, 2880}[c%4]
+	case c < 16:
+		return [2]int{480, 960}[c%2]
+	default:
+		return [4]int{120, 240, 480, 960}[c%4]
+	}
+}
+
+const (
+	maxFrameBytes  = 1275
+	maxPacketSize  = 5760 // samples of 120 ms at 48 kHz
+	maxFrameCounts = 48
+)
+
+// errPacket is the error of malformed packet
+var errPacket = errors.New("opus: malformed packet")
+
+// Packet is an opus packet split into frames
+type Packet struct {
+	TOC     TOC
+	Frames  [][]byte
+	Padding int // bytes of padding, code 3 only
+}
+
+// Samples reports the samples of the packet at 48 kHz
+func (p *Packet) Samples() int {
+	return len(p.Frames) * p.TOC.FrameSize()
+}
+
+// ParsePacket splits the packet into frames, section 3.2 of RFC 6716. the frames refer
+// to the memory of data.
+func ParsePacket(data []byte) (p Packet, err error) {
+	p, _, err = parsePacket(data, false, nil)
+	return
+}
+
+// parsePacket parses a packet, self-delimited is the framing of all but the last stream
+// of a multistream packet, appendix B of RFC 6716. the rest of data is returned.
+func parsePacket(data []byte, selfDelimited bool, frames [][]byte) (p Packet, rest []byte, err error) {
+	if len(data) < 1 {
+		return p, nil, errPacket
+	}
+	p.TOC = TOC(data[0])
+	data = data[1:]
+	var sizes [maxFrameCounts]int
+	count := 1
+	cbr := true
+	switch p.TOC.Code() {
+	case 0:
+	case 1:
+		count = 2
+	case 2:
+		count, cbr = 2, false
+		n, ok := frameLength(&data)
+		if !ok {
+			return p, nil, errPacket
+		}
+		sizes[0] = n
+	case 3:
+		if len(data) < 1 {
+			return p, nil, errPacket
+		}
+		b := data[0]
+		data = data[1:]
+		count = int(b & 0x3f)
+		cbr = b&0x80 == 0
+		if count == 0 || count*p.TOC.FrameSize() > maxPacketSize {
+			return p, nil, errPacket
+		}
+		if b&0x40 != 0 {
+			for {
+				if len(data) < 1 {
+					return p, nil, errPacket
+				}
+				x := int(data[0])
+				data = data[1:]
+				if x == 255 {
+					p.Padding += 254
+					continue
+				}
+				p.Padding += x
+				break
+			}
+		}
+		if !cbr {
+			for i := 0; i < count-1; i++ {
+				n, ok := frameLength(&data)
+				if !ok {
+					return p, nil, errPacket
+				}
+				sizes[i] = n
+			}
+		}
+	}
+
+	// the last frame, or all frames of cbr, has an explicit length if self-delimited
+	last := count - 1
+	if selfDelimited {
+		n, ok := frameLength(&data)
+		if !ok {
+			return p, nil, errPacket
+		}
+		if cbr {
+			for i := range sizes[:count] {
+				sizes[i] = n
+			}
+		} else {
+			sizes[last] = n
+		}
+	} else {
+		if p.Padding > len(data) {
+			return p, nil, errPacket
+		}
+		avail := len(data) - p.Padding
+		if cbr {
+			if avail%count != 0 {
+				return p, nil, errPacket
+			}
+			for i := range sizes[:count] {
+				sizes[i] = avail / count
+			}
+		} else {
+			for _, n := range sizes[:last] {
+				avail -= n
+			}
+			if avail < 0 {
+				return p, nil, errPacket
+			}
+			sizes[last] = avail
+		}
+	}
+
+	p.Frames = frames[:0]
+	for _, n := range sizes[:count] {
+		if n > maxFrameBytes || n > len(data) {
+			return p, nil, errPacket
+		}
+		p.Frames = append(p.Frames, data[:n])
+		data = data[n:]
+	}
+	if selfDelimited {
+		if p.Padding > len(data) {
+			return p, nil, errPacket
+		}
+		data = data[p.Padding:]
+	}
+	return p, data, nil
+}
+
+// frameLength reads the 1 or 2 bytes frame length
+func frameLength(data *[]byte) (int, bool) {
+	b := *data
+	if len(b) < 1 {
+		return 0, false
+	}
+	if b[0] < 252 {
+		*data = b[1:]
+		return int(b[0]), true
+	}
+	if len(b) < 2 {
+		return 0, false
+	}
+	*data = b[2:]
+	return int(b[0]) + 4*int(b[1]), true
+}
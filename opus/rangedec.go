@@ -0,0 +1,249 @@
+package opus
+
+import "math/bits"
+
+// the range decoder of section 4.1 of RFC 6716, shared by SILK and CELT
+
+const (
+	ecSymBits   = 8
+	ecCodeBits  = 32
+	ecSymMax    = 1<<ecSymBits - 1
+	ecCodeTop   = 1 << (ecCodeBits - 1)
+	ecCodeBot   = ecCodeTop >> ecSymBits
+	ecCodeExtra = (ecCodeBits-2)%ecSymBits + 1
+	ecUintBits  = 8
+	ecWindow    = 32
+
+	// the resolution of fractional bits, 1/8 bit
+	bitRes = 3
+)
+
+type rangeDecoder struct {
+	buf        []byte
+	offs       int // read from the front
+	endOffs    int // raw bits read from the end
+	endWindow  uint32
+	nendBits   int
+	nbitsTotal int
+	rng        uint32
+	val        uint32
+	ext        uint32
+	rem        int
+	err        bool
+}
+
+// ilog is the number of bits to represent x, 0 for 0
+func ilog(x uint32) int {
+	return bits.Len32(x)
+}
+
+func (d *rangeDecoder) init(buf []byte) {
+	*d = rangeDecoder{buf: buf}
+	d.nbitsTotal = ecCodeBits + 1 - ((ecCodeBits-ecCodeExtra)/ecSymBits)*ecSymBits
+	d.rng = 1 << ecCodeExtra
+	d.rem = d.readByte()
+	d.val = d.rng - 1 - uint32(d.rem>>(ecSymBits-ecCodeExtra))
+	d.normalize()
+}
+
+func (d *rangeDecoder) readByte() int {
+	if d.offs < len(d.buf) {
+		d.offs++
+		return int(d.buf[d.offs-1])
+	}
+	return 0
+}
+
+func (d *rangeDecoder) readByteFromEnd() int {
+	if d.endOffs < len(d.buf) {
+		d.endOffs++
+		return int(d.buf[len(d.buf)-d.endOffs])
+	}
+	return 0
+}
+
+func (d *rangeDecoder) normalize() {
+	for d.rng <= ecCodeBot {
+		d.nbitsTotal += ecSymBits
+		d.rng <<= ecSymBits
+		sym := d.rem
+		d.rem = d.readByte()
+		sym = (sym<<ecSymBits | d.rem) >> (ecSymBits - ecCodeExtra)
+		d.val = ((d.val << ecSymBits) + (ecSymMax &^ uint32(sym))) & (ecCodeTop - 1)
+	}
+}
+
+// decode reports the cumulative frequency of the next symbol, of total ft
+func (d *rangeDecoder) decode(ft uint32) uint32 {
+	d.ext = d.rng / ft
+	s := d.val / d.ext
+	return ft - minUint32(s+1, ft)
+}
+
+// decodeBin is decode with a total of 1<<n
+func (d *rangeDecoder) decodeBin(n uint) uint32 {
+	d.ext = d.rng >> n
+	s := d.val / d.ext
+	return 1<<n - minUint32(s+1, 1<<n)
+}
+
+// update advances past the symbol of [fl, fh) decoded by decode
+func (d *rangeDecoder) update(fl, fh, ft uint32) {
+	s := d.ext * (ft - fh)
+	d.val -= s
+	if fl > 0 {
+		d.rng = d.ext * (fh - fl)
+	} else {
+		d.rng -= s
+	}
+	d.normalize()
+}
+
+// bitLogp decodes a bit whose probability of 1 is 1/(1<<logp)
+func (d *rangeDecoder) bitLogp(logp uint) bool {
+	r := d.rng
+	v := d.val
+	s := r >> logp
+	ret := v < s
+	if !ret {
+		d.val = v - s
+		d.rng = r - s
+	} else {
+		d.rng = s
+	}
+	d.normalize()
+	return ret
+}
+
+// icdf decodes a symbol of the inverse cumulative table of total 1<<ftb
+func (d *rangeDecoder) icdf(icdf []uint8, ftb uint) int {
+	s := d.rng
+	v := d.val
+	r := s >> ftb
+	ret := -1
+	var t uint32
+	for {
+		t = s
+		ret++
+		s = r * uint32(icdf[ret])
+		if v >= s {
+			break
+		}
+	}
+	d.val = v - s
+	d.rng = t - s
+	d.normalize()
+	return ret
+}
+
+// uint decodes an integer of [0, ft)
+func (d *rangeDecoder) uint(ft uint32) uint32 {
+	ft--
+	ftb := ilog(ft)
+	if ftb > ecUintBits {
+		ftb -= ecUintBits
+		f := ft>>uint(ftb) + 1
+		s := d.decode(f)
+		d.update(s, s+1, f)
+		t := s<<uint(ftb) | d.bits(uint(ftb))
+		if t <= ft {
+			return t
+		}
+		d.err = true
+		return ft
+	}
+	ft++
+	s := d.decode(ft)
+	d.update(s, s+1, ft)
+	return s
+}
+
+// bits reads raw bits from the end of the frame
+func (d *rangeDecoder) bits(n uint) uint32 {
+	window := d.endWindow
+	available := d.nendBits
+	if uint(available) < n {
+		for {
+			window |= uint32(d.readByteFromEnd()) << uint(available)
+			available += ecSymBits
+			if available > ecWindow-ecSymBits {
+				break
+			}
+		}
+	}
+	ret := window & (1<<n - 1)
+	window >>= n
+	available -= int(n)
+	d.endWindow = window
+	d.nendBits = available
+	d.nbitsTotal += int(n)
+	return ret
+}
+
+// tell reports the bits used so far, rounded up
+func (d *rangeDecoder) tell() int {
+	return d.nbitsTotal - ilog(d.rng)
+}
+
+// tellFrac reports the bits used so far in 1/8 bits, rounded up
+func (d *rangeDecoder) tellFrac() int {
+	nbits := uint32(d.nbitsTotal) << bitRes
+	l := ilog(d.rng)
+	r := d.rng >> uint(l-16)
+	for i := bitRes; i > 0; i-- {
+		r = r * r >> 15
+		b := int(r >> 16)
+		l = l<<1 | b
+		r >>= uint(b)
+	}
+	return int(nbits - uint32(l))
+}
+
+const (
+	laplaceLogMinP = 0
+	laplaceMinP    = 1 << laplaceLogMinP
+	laplaceNMin    = 16
+)
+
+func laplaceFreq1(fs0 uint32, decay int) uint32 {
+	ft := 32768 - laplaceMinP*(2*laplaceNMin) - fs0
+	return uint32(int32(ft) * int32(16384-decay) >> 15)
+}
+
+// laplace decodes a value of the laplace distribution, for the coarse energy
+func (d *rangeDecoder) laplace(fs uint32, decay int) int {
+	val := 0
+	fm := d.decodeBin(15)
+	fl := uint32(0)
+	if fm >= fs {
+		val++
+		fl = fs
+		fs = laplaceFreq1(fs, decay) + laplaceMinP
+		for fs > laplaceMinP && fm >= fl+2*fs {
+			fs *= 2
+			fl += fs
+			fs = uint32(int32(fs-2*laplaceMinP) * int32(decay) >> 15)
+			fs += laplaceMinP
+			val++
+		}
+		if fs <= laplaceMinP {
+			di := (fm - fl) >> (laplaceLogMinP + 1)
+			val += int(di)
+			fl += 2 * di * laplaceMinP
+		}
+		if fm < fl+fs {
+			val = -val
+		} else {
+			fl += fs
+		}
+	}
+	d.update(fl, minUint32(fl+fs, 32768), 32768)
+	return val
+}
+
+func minUint32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,383 @@
+package opus
+
+// the SILK decoder of a stream, it decodes the SILK frames of 10, 20, 40 or 60 ms
+// in an opus frame and resamples them to 48 kHz
+
+const (
+	silkStereoInterpLenMs = 8
+	silkResamplerBatchMs  = 10
+	silkResamplerOrderFIR = 8
+)
+
+// silkStereo is the state of the mid/side to left/right conversion
+type silkStereo struct {
+	predPrevQ13 [2]int16
+	sMid        [2]int16
+	sSide       [2]int16
+}
+
+// silkDecoder decodes the SILK layer of 1 or 2 channels
+type silkDecoder struct {
+	ch                   [2]silkChannel
+	stereo               silkStereo
+	channelsAPI          int
+	channelsInternal     int
+	prevDecodeOnlyMiddle bool
+
+	out     [2][silkMaxFrameLength + 2]int16 // of the internal rate, 2 samples of history
+	resampl [silkMaxFrameLength * 48 / 8]int16
+}
+
+func newSilkDecoder() *silkDecoder {
+	d := new(silkDecoder)
+	d.reset()
+	return d
+}
+
+func (d *silkDecoder) reset() {
+	d.ch[0].init()
+	d.ch[1].init()
+	d.stereo = silkStereo{}
+	d.prevDecodeOnlyMiddle = false
+}
+
+// decode decodes a SILK frame of 10 or 20 ms into the interleaved pcm of channels
+// at 48 kHz. newPacket is true at the first frame of the opus frame, payloadMs is the
+// duration of the opus frame, lost conceals the frame. it reports the samples decoded
+func (d *silkDecoder) decode(dec *rangeDecoder, pcm []int16, channels, streamChannels, fsKHz, payloadMs int, newPacket, lost bool) (int, error) {
+	ch := &d.ch
+	decodeOnlyMiddle := false
+	var predQ13 [2]int32
+
+	if newPacket {
+		for n := 0; n < streamChannels; n++ {
+			ch[n].nFramesDecoded = 0
+		}
+	}
+	// mono to stereo transition, init the state of the second channel
+	if streamChannels > d.channelsInternal {
+		ch[1].init()
+	}
+	stereoToMono := streamChannels == 1 && d.channelsInternal == 2 && fsKHz == ch[0].fsKHz
+
+	if ch[0].nFramesDecoded == 0 {
+		for n := 0; n < streamChannels; n++ {
+			switch payloadMs {
+			case 10:
+				ch[n].nFramesPerPacket, ch[n].nbSubfr = 1, 2
+			case 20:
+				ch[n].nFramesPerPacket, ch[n].nbSubfr = 1, 4
+			case 40:
+				ch[n].nFramesPerPacket, ch[n].nbSubfr = 2, 4
+			case 60:
+				ch[n].nFramesPerPacket, ch[n].nbSubfr = 3, 4
+			default:
+				return 0, errPacket
+			}
+			ch[n].setFs(fsKHz, sampleRate)
+		}
+	}
+
+	if channels == 2 && streamChannels == 2 && (d.channelsAPI == 1 || d.channelsInternal == 1) {
+		d.stereo.predPrevQ13 = [2]int16{}
+		d.stereo.sSide = [2]int16{}
+		ch[1].resampler = ch[0].resampler
+	}
+	d.channelsAPI = channels
+	d.channelsInternal = streamChannels
+
+	if !lost && ch[0].nFramesDecoded == 0 {
+		// the VAD flags and the LBRR flags of the first frame of the payload
+		for n := 0; n < streamChannels; n++ {
+			for i := 0; i < ch[n].nFramesPerPacket; i++ {
+				ch[n].vadFlags[i] = dec.bitLogp(1)
+			}
+			ch[n].lbrrFlag = dec.bitLogp(1)
+		}
+		for n := 0; n < streamChannels; n++ {
+			ch[n].lbrrFlags = [silkMaxFramesPerPkt]bool{}
+			if !ch[n].lbrrFlag {
+				continue
+			}
+			if ch[n].nFramesPerPacket == 1 {
+				ch[n].lbrrFlags[0] = true
+			} else {
+				sym := dec.icdf(silkLBRRFlagsICDF[ch[n].nFramesPerPacket-2], 8) + 1
+				for i := 0; i < ch[n].nFramesPerPacket; i++ {
+					ch[n].lbrrFlags[i] = sym>>uint(i)&1 != 0
+				}
+			}
+		}
+
+		// skip the LBRR data, it is only for the forward error correction
+		var pulses [silkMaxFrameLength]int16
+		for i := 0; i < ch[0].nFramesPerPacket; i++ {
+			for n := 0; n < streamChannels; n++ {
+				if !ch[n].lbrrFlags[i] {
+					continue
+				}
+				if streamChannels == 2 && n == 0 {
+					stereoDecodePred(dec, &predQ13)
+					if !ch[1].lbrrFlags[i] {
+						decodeOnlyMiddle = dec.icdf(silkStereoOnlyCodeMidICDF, 8) != 0
+					}
+				}
+				condCoding := silkCodeIndependently
+				if i > 0 && ch[n].lbrrFlags[i-1] {
+					condCoding = silkCodeConditionally
+				}
+				ch[n].decodeIndices(dec, i, true, condCoding)
+				silkDecodePulses(dec, pulses[:], int(ch[n].indices.signalType), int(ch[n].indices.quantOffsetType), ch[n].frameLength)
+			}
+		}
+	}
+
+	// the mid/side predictors
+	if streamChannels == 2 {
+		if !lost {
+			stereoDecodePred(dec, &predQ13)
+			if !ch[1].vadFlags[ch[0].nFramesDecoded] {
+				decodeOnlyMiddle = dec.icdf(silkStereoOnlyCodeMidICDF, 8) != 0
+			} else {
+				decodeOnlyMiddle = false
+			}
+		} else {
+			predQ13[0] = int32(d.stereo.predPrevQ13[0])
+			predQ13[1] = int32(d.stereo.predPrevQ13[1])
+		}
+	}
+
+	// reset the prediction of the side channel at its first frame after mid only
+	if streamChannels == 2 && !decodeOnlyMiddle && d.prevDecodeOnlyMiddle {
+		s := &ch[1]
+		s.outBuf = [len(s.outBuf)]int16{}
+		s.sLPCQ14 = [silkMaxLPCOrder]int32{}
+		s.lagPrev = 100
+		s.lastGainIndex = 10
+		s.prevSignalType = silkTypeNoVoiceActivity
+		s.firstFrameAfterReset = true
+	}
+
+	hasSide := !decodeOnlyMiddle
+	if lost {
+		hasSide = !d.prevDecodeOnlyMiddle
+	}
+	nSamples := 0
+	for n := 0; n < streamChannels; n++ {
+		if n == 0 || hasSide {
+			frameIndex := ch[0].nFramesDecoded - n
+			condCoding := silkCodeConditionally
+			if frameIndex <= 0 {
+				condCoding = silkCodeIndependently
+			} else if n > 0 && d.prevDecodeOnlyMiddle {
+				// the LTP state is well defined when a side frame was skipped
+				condCoding = silkCodeIndependentlyNoLTPScal
+			}
+			nSamples = ch[n].decodeFrame(dec, d.out[n][2:], lost, condCoding)
+		} else {
+			for i := 0; i < nSamples; i++ {
+				d.out[n][2+i] = 0
+			}
+		}
+		ch[n].nFramesDecoded++
+	}
+
+	if channels == 2 && streamChannels == 2 {
+		d.stereo.msToLR(d.out[0][:], d.out[1][:], predQ13, ch[0].fsKHz, nSamples)
+	} else {
+		copy(d.out[0][:2], d.stereo.sMid[:])
+		copy(d.stereo.sMid[:], d.out[0][nSamples:nSamples+2])
+	}
+
+	// resample to 48 kHz and interleave
+	nOut := nSamples * sampleRate / (ch[0].fsKHz * 1000)
+	for n := 0; n < minInt(channels, streamChannels); n++ {
+		ch[n].resampler.resample(d.resampl[:], d.out[n][1:], nSamples)
+		for i := 0; i < nOut; i++ {
+			pcm[n+channels*i] = d.resampl[i]
+		}
+	}
+
+	// two channels of a mono stream
+	if channels == 2 && streamChannels == 1 {
+		if stereoToMono {
+			// resample the right channel for the newly collapsed stereo
+			ch[1].resampler.resample(d.resampl[:], d.out[0][1:], nSamples)
+			for i := 0; i < nOut; i++ {
+				pcm[1+2*i] = d.resampl[i]
+			}
+		} else {
+			for i := 0; i < nOut; i++ {
+				pcm[1+2*i] = pcm[2*i]
+			}
+		}
+	}
+
+	if lost {
+		// remove the gain clamping to not bounce back the energy of a loss
+		for i := 0; i < d.channelsInternal; i++ {
+			ch[i].lastGainIndex = 10
+		}
+	} else {
+		d.prevDecodeOnlyMiddle = decodeOnlyMiddle
+	}
+	return nOut, nil
+}
+
+// stereoDecodePred decodes the mid/side predictors
+func stereoDecodePred(dec *rangeDecoder, predQ13 *[2]int32) {
+	var ix [2][3]int
+	n := dec.icdf(silkStereoPredJointICDF[:], 8)
+	ix[0][2] = n / 5
+	ix[1][2] = n - 5*ix[0][2]
+	for n := 0; n < 2; n++ {
+		ix[n][0] = dec.icdf(silkUniform3ICDF, 8)
+		ix[n][1] = dec.icdf(silkUniform5ICDF, 8)
+	}
+	for n := 0; n < 2; n++ {
+		ix[n][0] += 3 * ix[n][2]
+		low := int32(silkStereoPredQuantQ13[ix[n][0]])
+		step := smulwb(int32(silkStereoPredQuantQ13[ix[n][0]+1])-low, 6554) // 0.1 in Q16
+		predQ13[n] = smlabb(low, step, int32(2*ix[n][1]+1))
+	}
+	// subtract the second from the first predictor
+	predQ13[0] -= predQ13[1]
+}
+
+// msToLR converts the mid/side to the left/right signals, x1 and x2 have 2 samples of
+// history in front
+func (s *silkStereo) msToLR(x1, x2 []int16, predQ13 [2]int32, fsKHz, n int) {
+	copy(x1[:2], s.sMid[:])
+	copy(x2[:2], s.sSide[:])
+	copy(s.sMid[:], x1[n:n+2])
+	copy(s.sSide[:], x2[n:n+2])
+
+	// interpolate the predictors and add the prediction to the side channel
+	pred0 := int32(s.predPrevQ13[0])
+	pred1 := int32(s.predPrevQ13[1])
+	interp := silkStereoInterpLenMs * fsKHz
+	denomQ16 := int32(1<<16) / int32(interp)
+	delta0 := rshiftRound(smulbb(predQ13[0]-int32(s.predPrevQ13[0]), denomQ16), 16)
+	delta1 := rshiftRound(smulbb(predQ13[1]-int32(s.predPrevQ13[1]), denomQ16), 16)
+	for i := 0; i < n; i++ {
+		if i < interp {
+			pred0 += delta0
+			pred1 += delta1
+		} else {
+			pred0, pred1 = predQ13[0], predQ13[1]
+		}
+		sum := (int32(x1[i]) + int32(x1[i+2]) + int32(x1[i+1])<<1) << 9 // Q11
+		sum = smlawb(int32(x2[i+1])<<8, sum, pred0)                     // Q8
+		sum = smlawb(sum, int32(x1[i+1])<<11, pred1)                    // Q8
+		x2[i+1] = int16(sat16(rshiftRound(sum, 8)))
+	}
+	s.predPrevQ13[0] = int16(predQ13[0])
+	s.predPrevQ13[1] = int16(predQ13[1])
+
+	for i := 0; i < n; i++ {
+		sum := int32(x1[i+1]) + int32(x2[i+1])
+		diff := int32(x1[i+1]) - int32(x2[i+1])
+		x1[i+1] = int16(sat16(sum))
+		x2[i+1] = int16(sat16(diff))
+	}
+}
+
+var (
+	silkResamplerUp2HQ0 = [3]int32{1746, 14986, 39083 - 65536}
+	silkResamplerUp2HQ1 = [3]int32{6854, 25769, 55542 - 65536}
+)
+
+// silkResampler upsamples 8, 12 or 16 kHz to 48 kHz, by the allpass based 2x
+// upsampling and the FIR interpolation
+type silkResampler struct {
+	sIIR       [6]int32
+	sFIR       [silkResamplerOrderFIR]int16
+	delayBuf   [silkMaxFsKHz]int16
+	batchSize  int
+	invRatio   int32
+	fsInKHz    int
+	fsOutKHz   int
+	inputDelay int
+	buf        [2*silkResamplerBatchMs*silkMaxFsKHz + silkResamplerOrderFIR]int16
+}
+
+func (r *silkResampler) init(fsIn, fsOut int) {
+	*r = silkResampler{}
+	switch fsIn {
+	case 12000:
+		r.inputDelay = 4
+	case 16000:
+		r.inputDelay = 7
+	}
+	r.fsInKHz = fsIn / 1000
+	r.fsOutKHz = fsOut / 1000
+	r.batchSize = r.fsInKHz * silkResamplerBatchMs
+	r.invRatio = int32(fsIn<<15/fsOut) << 2
+	// round the ratio up
+	for smulww(r.invRatio, int32(fsOut)) < int32(fsIn<<1) {
+		r.invRatio++
+	}
+}
+
+// resample resamples n samples of in, n is at least 1 ms
+func (r *silkResampler) resample(out, in []int16, n int) {
+	k := r.fsInKHz - r.inputDelay
+	copy(r.delayBuf[r.inputDelay:r.fsInKHz], in[:k])
+	r.iirFIR(out, r.delayBuf[:r.fsInKHz])
+	r.iirFIR(out[r.fsOutKHz:], in[k:n-r.inputDelay])
+	copy(r.delayBuf[:r.inputDelay], in[n-r.inputDelay:n])
+}
+
+func (r *silkResampler) iirFIR(out, in []int16) {
+	buf := r.buf[:]
+	copy(buf, r.sFIR[:])
+	var n int
+	for {
+		n = minInt(len(in), r.batchSize)
+		up2HQ(&r.sIIR, buf[silkResamplerOrderFIR:], in[:n])
+		maxIndex := int32(n) << 17
+		for idx := int32(0); idx < maxIndex; idx += r.invRatio {
+			t := smulwb(idx&0xffff, 12)
+			p := buf[idx>>16:]
+			a, b := &silkResamplerFracFIR12[t], &silkResamplerFracFIR12[11-t]
+			res := int32(p[0])*int32(a[0]) + int32(p[1])*int32(a[1]) + int32(p[2])*int32(a[2]) + int32(p[3])*int32(a[3]) +
+				int32(p[4])*int32(b[3]) + int32(p[5])*int32(b[2]) + int32(p[6])*int32(b[1]) + int32(p[7])*int32(b[0])
+			out[0] = int16(sat16(rshiftRound(res, 15)))
+			out = out[1:]
+		}
+		in = in[n:]
+		if len(in) == 0 {
+			break
+		}
+		copy(buf, buf[n<<1:n<<1+silkResamplerOrderFIR])
+	}
+	copy(r.sFIR[:], buf[n<<1:])
+}
+
+// up2HQ upsamples in by 2 into out, with the allpass filters of the state s in Q10
+func up2HQ(s *[6]int32, out, in []int16) {
+	allpass := func(x int32, s *int32, coef int32, last bool) int32 {
+		y := x - *s
+		var v int32
+		if last {
+			v = smlawb(y, y, coef)
+		} else {
+			v = smulwb(y, coef)
+		}
+		o := *s + v
+		*s = x + v
+		return o
+	}
+	for k, x := range in {
+		in32 := int32(x) << 10
+		o := allpass(in32, &s[0], silkResamplerUp2HQ0[0], false)
+		o = allpass(o, &s[1], silkResamplerUp2HQ0[1], false)
+		o = allpass(o, &s[2], silkResamplerUp2HQ0[2], true)
+		out[2*k] = int16(sat16(rshiftRound(o, 10)))
+
+		o = allpass(in32, &s[3], silkResamplerUp2HQ1[0], false)
+		o = allpass(o, &s[4], silkResamplerUp2HQ1[1], false)
+		o = allpass(o, &s[5], silkResamplerUp2HQ1[2], true)
+		out[2*k+1] = int16(sat16(rshiftRound(o, 10)))
+	}
+}
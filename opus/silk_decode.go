@@ -0,0 +1,619 @@
+package opus
+
+// the SILK decoder of one channel, section 4.2 of RFC 6716, ported from the fixed
+// point decoder of libopus
+
+const (
+	silkMaxNbSubfr        = 4
+	silkMaxFramesPerPkt   = 3
+	silkLTPOrder          = 5
+	silkMinLPCOrder       = 10
+	silkSubfrLengthMs     = 5
+	silkMaxFsKHz          = 16
+	silkMaxSubfrLength    = silkSubfrLengthMs * silkMaxFsKHz
+	silkMaxFrameLength    = silkMaxNbSubfr * silkMaxSubfrLength
+	silkLTPMemLengthMs    = 20
+	silkShellFrameLength  = 16
+	silkMaxPulses         = 16
+	silkNRateLevels       = 10
+	silkQuantLevelAdjQ10  = 80
+	silkBWEAfterLossQ16   = 63570
+	silkNLevelsQGain      = 64
+	silkMinDeltaGainQuant = -4
+	silkMaxDeltaGainQuant = 36
+	silkGainOffset        = (2*128)/6 + 16*128
+	silkGainInvScaleQ16   = (65536 * ((86 * 128) / 6)) / (silkNLevelsQGain - 1)
+
+	// signal types
+	silkTypeNoVoiceActivity = 0
+	silkTypeUnvoiced        = 1
+	silkTypeVoiced          = 2
+
+	// conditional coding types
+	silkCodeIndependently          = 0
+	silkCodeIndependentlyNoLTPScal = 1
+	silkCodeConditionally          = 2
+)
+
+// silkIndices is the quantization indices of a frame
+type silkIndices struct {
+	gains           [silkMaxNbSubfr]int8
+	ltp             [silkMaxNbSubfr]int8
+	nlsf            [silkMaxLPCOrder + 1]int8
+	lag             int16
+	contour         int8
+	signalType      int8
+	quantOffsetType int8
+	nlsfInterpQ2    int8
+	perIndex        int8
+	ltpScale        int8
+	seed            int8
+}
+
+// silkControl is the parameters of a frame
+type silkControl struct {
+	pitchL      [silkMaxNbSubfr]int
+	gainsQ16    [silkMaxNbSubfr]int32
+	predCoefQ12 [2][silkMaxLPCOrder]int16
+	ltpCoefQ14  [silkLTPOrder * silkMaxNbSubfr]int16
+	ltpScaleQ14 int32
+}
+
+// silkPLC is the state of the packet loss concealment
+type silkPLC struct {
+	pitchLQ8        int32
+	ltpCoefQ14      [silkLTPOrder]int16
+	prevLPCQ12      [silkMaxLPCOrder]int16
+	lastFrameLost   bool
+	randSeed        int32
+	randScaleQ14    int16
+	concEnergy      int32
+	concEnergyShift int
+	prevLTPScaleQ14 int16
+	prevGainQ16     [2]int32
+	fsKHz           int
+	nbSubfr         int
+	subfrLength     int
+}
+
+// silkCNG is the state of the comfort noise generation
+type silkCNG struct {
+	excBufQ14   [silkMaxFrameLength]int32
+	smthNLSFQ15 [silkMaxLPCOrder]int16
+	synthState  [silkMaxLPCOrder]int32
+	smthGainQ16 int32
+	randSeed    int32
+	fsKHz       int
+}
+
+// silkChannel is the decoder state of a channel
+type silkChannel struct {
+	prevGainQ16          int32
+	excQ14               [silkMaxFrameLength]int32
+	sLPCQ14              [silkMaxLPCOrder]int32
+	outBuf               [silkMaxFrameLength + 2*silkMaxSubfrLength]int16
+	lagPrev              int
+	lastGainIndex        int8
+	fsKHz                int
+	fsAPIHz              int
+	nbSubfr              int
+	frameLength          int
+	subfrLength          int
+	ltpMemLength         int
+	lpcOrder             int
+	prevNLSFQ15          [silkMaxLPCOrder]int16
+	firstFrameAfterReset bool
+	pitchLagLowBitsICDF  []uint8
+	pitchContourICDF     []uint8
+
+	nFramesDecoded   int
+	nFramesPerPacket int
+
+	ecPrevSignalType int
+	ecPrevLagIndex   int16
+
+	vadFlags  [silkMaxFramesPerPkt]bool
+	lbrrFlag  bool
+	lbrrFlags [silkMaxFramesPerPkt]bool
+
+	resampler silkResampler
+	nlsfCB    *silkNLSFCodebook
+	indices   silkIndices
+	cng       silkCNG
+
+	lossCnt        int
+	prevSignalType int
+	plc            silkPLC
+}
+
+func (ch *silkChannel) init() {
+	*ch = silkChannel{}
+	ch.firstFrameAfterReset = true
+	ch.prevGainQ16 = 65536
+	ch.cngReset()
+	ch.plcReset()
+}
+
+// setFs sets the internal rate and the frame length of nbSubfr
+func (ch *silkChannel) setFs(fsKHz, fsAPIHz int) {
+	ch.subfrLength = silkSubfrLengthMs * fsKHz
+	frameLength := ch.nbSubfr * ch.subfrLength
+	if ch.fsKHz != fsKHz || ch.fsAPIHz != fsAPIHz {
+		ch.resampler.init(fsKHz*1000, fsAPIHz)
+		ch.fsAPIHz = fsAPIHz
+	}
+	if ch.fsKHz != fsKHz || frameLength != ch.frameLength {
+		switch {
+		case fsKHz == 8 && ch.nbSubfr == silkMaxNbSubfr:
+			ch.pitchContourICDF = silkPitchContourNBICDF[:]
+		case fsKHz == 8:
+			ch.pitchContourICDF = silkPitchContour10msNBICDF[:]
+		case ch.nbSubfr == silkMaxNbSubfr:
+			ch.pitchContourICDF = silkPitchContourICDF[:]
+		default:
+			ch.pitchContourICDF = silkPitchContour10msICDF[:]
+		}
+		if ch.fsKHz != fsKHz {
+			ch.ltpMemLength = silkLTPMemLengthMs * fsKHz
+			if fsKHz == 16 {
+				ch.lpcOrder = silkMaxLPCOrder
+				ch.nlsfCB = silkNLSFCodebookWB
+			} else {
+				ch.lpcOrder = silkMinLPCOrder
+				ch.nlsfCB = silkNLSFCodebookNBMB
+			}
+			switch fsKHz {
+			case 16:
+				ch.pitchLagLowBitsICDF = silkUniform8ICDF
+			case 12:
+				ch.pitchLagLowBitsICDF = silkUniform6ICDF
+			default:
+				ch.pitchLagLowBitsICDF = silkUniform4ICDF
+			}
+			ch.firstFrameAfterReset = true
+			ch.lagPrev = 100
+			ch.lastGainIndex = 10
+			ch.prevSignalType = silkTypeNoVoiceActivity
+			ch.outBuf = [len(ch.outBuf)]int16{}
+			ch.sLPCQ14 = [silkMaxLPCOrder]int32{}
+		}
+		ch.fsKHz = fsKHz
+		ch.frameLength = frameLength
+	}
+}
+
+// decodeIndices decodes the side information of frame
+func (ch *silkChannel) decodeIndices(dec *rangeDecoder, frame int, lbrr bool, condCoding int) {
+	idx := &ch.indices
+	var ix int
+	if lbrr || ch.vadFlags[frame] {
+		ix = dec.icdf(silkTypeOffsetVADICDF, 8) + 2
+	} else {
+		ix = dec.icdf(silkTypeOffsetNoVADICDF, 8)
+	}
+	idx.signalType = int8(ix >> 1)
+	idx.quantOffsetType = int8(ix & 1)
+
+	// gains
+	if condCoding == silkCodeConditionally {
+		idx.gains[0] = int8(dec.icdf(silkDeltaGainICDF[:], 8))
+	} else {
+		idx.gains[0] = int8(dec.icdf(silkGainICDF[idx.signalType][:], 8) << 3)
+		idx.gains[0] += int8(dec.icdf(silkUniform8ICDF, 8))
+	}
+	for i := 1; i < ch.nbSubfr; i++ {
+		idx.gains[i] = int8(dec.icdf(silkDeltaGainICDF[:], 8))
+	}
+
+	// LSFs
+	cb := ch.nlsfCB
+	idx.nlsf[0] = int8(dec.icdf(cb.cb1ICDF[int(idx.signalType>>1)*cb.nVectors:], 8))
+	var (
+		ecIx   [silkMaxLPCOrder]int16
+		predQ8 [silkMaxLPCOrder]uint8
+	)
+	nlsfUnpack(ecIx[:], predQ8[:], cb, int(idx.nlsf[0]))
+	for i := 0; i < cb.order; i++ {
+		ix = dec.icdf(cb.ecICDF[ecIx[i]:], 8)
+		if ix == 0 {
+			ix -= dec.icdf(silkNLSFExtICDF, 8)
+		} else if ix == 2*silkNLSFQuantMax {
+			ix += dec.icdf(silkNLSFExtICDF, 8)
+		}
+		idx.nlsf[i+1] = int8(ix - silkNLSFQuantMax)
+	}
+	if ch.nbSubfr == silkMaxNbSubfr {
+		idx.nlsfInterpQ2 = int8(dec.icdf(silkNLSFInterpolationFactorICDF, 8))
+	} else {
+		idx.nlsfInterpQ2 = 4
+	}
+
+	if idx.signalType == silkTypeVoiced {
+		// pitch lags
+		absolute := true
+		if condCoding == silkCodeConditionally && ch.ecPrevSignalType == silkTypeVoiced {
+			if delta := int16(dec.icdf(silkPitchDeltaICDF[:], 8)); delta > 0 {
+				idx.lag = ch.ecPrevLagIndex + delta - 9
+				absolute = false
+			}
+		}
+		if absolute {
+			idx.lag = int16(dec.icdf(silkPitchLagICDF[:], 8) * (ch.fsKHz >> 1))
+			idx.lag += int16(dec.icdf(ch.pitchLagLowBitsICDF, 8))
+		}
+		ch.ecPrevLagIndex = idx.lag
+		idx.contour = int8(dec.icdf(ch.pitchContourICDF, 8))
+
+		// LTP gains
+		idx.perIndex = int8(dec.icdf(silkLTPPerIndexICDF[:], 8))
+		for k := 0; k < ch.nbSubfr; k++ {
+			idx.ltp[k] = int8(dec.icdf(silkLTPGainICDF[idx.perIndex], 8))
+		}
+		if condCoding == silkCodeIndependently {
+			idx.ltpScale = int8(dec.icdf(silkLTPScaleICDF, 8))
+		} else {
+			idx.ltpScale = 0
+		}
+	}
+	ch.ecPrevSignalType = int(idx.signalType)
+	idx.seed = int8(dec.icdf(silkUniform4ICDF, 8))
+}
+
+// silkDecodePulses decodes the excitation of frameLength, pulses is rounded up to the
+// shell blocks
+func silkDecodePulses(dec *rangeDecoder, pulses []int16, signalType, quantOffsetType, frameLength int) {
+	var sumPulses, nLshifts [silkMaxFrameLength / silkShellFrameLength]int
+	rateLevel := dec.icdf(silkRateLevelsICDF[signalType>>1][:], 8)
+	iter := frameLength / silkShellFrameLength
+	if iter*silkShellFrameLength < frameLength {
+		iter++ // 10 ms at 12 kHz
+	}
+
+	for i := 0; i < iter; i++ {
+		sumPulses[i] = dec.icdf(silkPulsesPerBlockICDF[rateLevel][:], 8)
+		for sumPulses[i] == silkMaxPulses+1 {
+			nLshifts[i]++
+			// with 10 LSBs already, the table is shifted to not allow more
+			t := silkPulsesPerBlockICDF[silkNRateLevels-1][:]
+			if nLshifts[i] == 10 {
+				t = t[1:]
+			}
+			sumPulses[i] = dec.icdf(t, 8)
+		}
+	}
+
+	for i := 0; i < iter; i++ {
+		p := pulses[i*silkShellFrameLength : (i+1)*silkShellFrameLength]
+		if sumPulses[i] > 0 {
+			shellDecoder(p, dec, sumPulses[i])
+		} else {
+			for k := range p {
+				p[k] = 0
+			}
+		}
+	}
+
+	for i := 0; i < iter; i++ {
+		if n := nLshifts[i]; n > 0 {
+			p := pulses[i*silkShellFrameLength : (i+1)*silkShellFrameLength]
+			for k := range p {
+				q := int32(p[k])
+				for j := 0; j < n; j++ {
+					q = q<<1 + int32(dec.icdf(silkLSBICDF, 8))
+				}
+				p[k] = int16(q)
+			}
+			sumPulses[i] |= n << 5
+		}
+	}
+
+	decodeSigns(dec, pulses, frameLength, signalType, quantOffsetType, sumPulses[:])
+}
+
+func decodeSplit(dec *rangeDecoder, p int, table []uint8) (int16, int16) {
+	if p > 0 {
+		c := dec.icdf(table[silkShellCodeTableOffsets[p]:], 8)
+		return int16(c), int16(p - c)
+	}
+	return 0, 0
+}
+
+// shellDecoder decodes the 16 pulse amplitudes of a shell block of the sum p
+func shellDecoder(out []int16, dec *rangeDecoder, p int) {
+	var p3 [2]int16
+	var p2 [4]int16
+	var p1 [8]int16
+	p3[0], p3[1] = decodeSplit(dec, p, silkShellCodeTable3[:])
+	for i := 0; i < 2; i++ {
+		p2[2*i], p2[2*i+1] = decodeSplit(dec, int(p3[i]), silkShellCodeTable2[:])
+		for j := 2 * i; j < 2*i+2; j++ {
+			p1[2*j], p1[2*j+1] = decodeSplit(dec, int(p2[j]), silkShellCodeTable1[:])
+			for k := 2 * j; k < 2*j+2; k++ {
+				out[2*k], out[2*k+1] = decodeSplit(dec, int(p1[k]), silkShellCodeTable0[:])
+			}
+		}
+	}
+}
+
+// decodeSigns attaches the signs to the pulses
+func decodeSigns(dec *rangeDecoder, pulses []int16, length, signalType, quantOffsetType int, sumPulses []int) {
+	icdf := []uint8{0, 0}
+	table := silkSignICDF[7*(quantOffsetType+signalType<<1):]
+	length = (length + silkShellFrameLength/2) / silkShellFrameLength
+	for i := 0; i < length; i++ {
+		p := sumPulses[i]
+		if p <= 0 {
+			continue
+		}
+		icdf[0] = table[minInt(p&0x1f, 6)]
+		q := pulses[i*silkShellFrameLength : (i+1)*silkShellFrameLength]
+		for j := range q {
+			if q[j] > 0 {
+				q[j] *= int16(dec.icdf(icdf, 8)<<1 - 1)
+			}
+		}
+	}
+}
+
+// gainsDequant converts the gain indices to the gains in Q16
+func gainsDequant(gainQ16 []int32, ind []int8, prevInd *int8, conditional bool, nbSubfr int) {
+	for k := 0; k < nbSubfr; k++ {
+		prev := int(*prevInd)
+		if k == 0 && !conditional {
+			// not allowed to go down more than 16 steps
+			prev = maxInt(int(ind[k]), prev-16)
+		} else {
+			tmp := int(ind[k]) + silkMinDeltaGainQuant
+			threshold := 2*silkMaxDeltaGainQuant - silkNLevelsQGain + prev
+			if tmp > threshold {
+				prev += tmp<<1 - threshold
+			} else {
+				prev += tmp
+			}
+		}
+		prev = limitInt(prev, 0, silkNLevelsQGain-1)
+		*prevInd = int8(prev)
+		gainQ16[k] = log2lin(min32(smulwb(silkGainInvScaleQ16, int32(prev))+silkGainOffset, 3967))
+	}
+}
+
+// decodePitch converts the lag index and the contour to the pitch lags of subframes
+func decodePitch(lagIndex int16, contour int8, lags []int, fsKHz, nbSubfr int) {
+	minLag := 2 * fsKHz
+	maxLag := 18 * fsKHz
+	lag := minLag + int(lagIndex)
+	for k := 0; k < nbSubfr; k++ {
+		var d int8
+		switch {
+		case fsKHz == 8 && nbSubfr == silkMaxNbSubfr:
+			d = silkCBLagsStage2[k][contour]
+		case fsKHz == 8:
+			d = silkCBLagsStage210ms[k][contour]
+		case nbSubfr == silkMaxNbSubfr:
+			d = silkCBLagsStage3[k][contour]
+		default:
+			d = silkCBLagsStage310ms[k][contour]
+		}
+		lags[k] = limitInt(lag+int(d), minLag, maxLag)
+	}
+}
+
+// decodeParameters dequantizes the side information
+func (ch *silkChannel) decodeParameters(ctl *silkControl, condCoding int) {
+	idx := &ch.indices
+	gainsDequant(ctl.gainsQ16[:], idx.gains[:], &ch.lastGainIndex, condCoding == silkCodeConditionally, ch.nbSubfr)
+
+	var nlsf, nlsf0 [silkMaxLPCOrder]int16
+	nlsfDecode(nlsf[:], idx.nlsf[:], ch.nlsfCB)
+	nlsf2a(ctl.predCoefQ12[1][:], nlsf[:], ch.lpcOrder)
+
+	// no interpolation just after a reset
+	if ch.firstFrameAfterReset {
+		idx.nlsfInterpQ2 = 4
+	}
+	if idx.nlsfInterpQ2 < 4 {
+		for i := 0; i < ch.lpcOrder; i++ {
+			nlsf0[i] = ch.prevNLSFQ15[i] + int16(int32(idx.nlsfInterpQ2)*(int32(nlsf[i])-int32(ch.prevNLSFQ15[i]))>>2)
+		}
+		nlsf2a(ctl.predCoefQ12[0][:], nlsf0[:], ch.lpcOrder)
+	} else {
+		copy(ctl.predCoefQ12[0][:ch.lpcOrder], ctl.predCoefQ12[1][:ch.lpcOrder])
+	}
+	copy(ch.prevNLSFQ15[:ch.lpcOrder], nlsf[:ch.lpcOrder])
+
+	// bandwidth expansion after a loss
+	if ch.lossCnt != 0 {
+		bwexpander(ctl.predCoefQ12[0][:ch.lpcOrder], silkBWEAfterLossQ16)
+		bwexpander(ctl.predCoefQ12[1][:ch.lpcOrder], silkBWEAfterLossQ16)
+	}
+
+	if idx.signalType == silkTypeVoiced {
+		decodePitch(idx.lag, idx.contour, ctl.pitchL[:], ch.fsKHz, ch.nbSubfr)
+		cb := silkLTPVQQ7[idx.perIndex]
+		for k := 0; k < ch.nbSubfr; k++ {
+			for i := 0; i < silkLTPOrder; i++ {
+				ctl.ltpCoefQ14[k*silkLTPOrder+i] = int16(cb[idx.ltp[k]][i]) << 7
+			}
+		}
+		ctl.ltpScaleQ14 = int32(silkLTPScalesQ14[idx.ltpScale])
+	} else {
+		for k := 0; k < ch.nbSubfr; k++ {
+			ctl.pitchL[k] = 0
+		}
+		for i := 0; i < silkLTPOrder*ch.nbSubfr; i++ {
+			ctl.ltpCoefQ14[i] = 0
+		}
+		idx.perIndex = 0
+		ctl.ltpScaleQ14 = 0
+	}
+}
+
+// lpcAnalysisFilter is the MA prediction filter of order d, the first d outputs
+// are zeros
+func lpcAnalysisFilter(out, in []int16, b []int16, n, d int) {
+	for ix := d; ix < n; ix++ {
+		acc := int32(0)
+		for j := 0; j < d; j++ {
+			acc += int32(in[ix-1-j]) * int32(b[j])
+		}
+		v := rshiftRound(int32(in[ix])<<12-acc, 12)
+		out[ix] = int16(sat16(v))
+	}
+	for j := 0; j < d; j++ {
+		out[j] = 0
+	}
+}
+
+// lpcSynthesis runs the short term prediction of order on the state in Q14, the
+// state holds silkMaxLPCOrder samples of history in front
+func lpcPredQ10(s []int32, i int, a []int16, order int) int32 {
+	pred := int32(order >> 1)
+	for j := 0; j < order; j++ {
+		pred = smlawb(pred, s[silkMaxLPCOrder+i-j-1], int32(a[j]))
+	}
+	return pred
+}
+
+// decodeCore is the inverse noise shaping quantization of a frame
+func (ch *silkChannel) decodeCore(ctl *silkControl, xq []int16, pulses []int16) {
+	var (
+		sLTP    [silkLTPMemLengthMs * silkMaxFsKHz]int16
+		sLTPQ15 [silkLTPMemLengthMs*silkMaxFsKHz + silkMaxFrameLength]int32
+		resQ14  [silkMaxSubfrLength]int32
+		sLPCQ14 [silkMaxSubfrLength + silkMaxLPCOrder]int32
+		aQ12    [silkMaxLPCOrder]int16
+	)
+	idx := &ch.indices
+	offsetQ10 := int32(silkQuantizationOffsetsQ10[idx.signalType>>1][idx.quantOffsetType])
+	nlsfInterpolation := idx.nlsfInterpQ2 < 4
+
+	// excitation
+	seed := int32(idx.seed)
+	for i := 0; i < ch.frameLength; i++ {
+		seed = silkRand(seed)
+		e := int32(pulses[i]) << 14
+		if e > 0 {
+			e -= silkQuantLevelAdjQ10 << 4
+		} else if e < 0 {
+			e += silkQuantLevelAdjQ10 << 4
+		}
+		e += offsetQ10 << 4
+		if seed < 0 {
+			e = -e
+		}
+		ch.excQ14[i] = e
+		seed += int32(pulses[i])
+	}
+
+	copy(sLPCQ14[:silkMaxLPCOrder], ch.sLPCQ14[:])
+	exc := ch.excQ14[:]
+	out := xq
+	ltpBufIdx := ch.ltpMemLength
+	lag := 0
+	for k := 0; k < ch.nbSubfr; k++ {
+		res := resQ14[:]
+		copy(aQ12[:ch.lpcOrder], ctl.predCoefQ12[k>>1][:ch.lpcOrder])
+		b := ctl.ltpCoefQ14[k*silkLTPOrder : (k+1)*silkLTPOrder]
+		signalType := int(idx.signalType)
+
+		gainQ10 := ctl.gainsQ16[k] >> 6
+		invGainQ31 := inverse32VarQ(ctl.gainsQ16[k], 47)
+
+		// gain adjustment
+		gainAdjQ16 := int32(1 << 16)
+		if ctl.gainsQ16[k] != ch.prevGainQ16 {
+			gainAdjQ16 = div32VarQ(ch.prevGainQ16, ctl.gainsQ16[k], 16)
+			for i := 0; i < silkMaxLPCOrder; i++ {
+				sLPCQ14[i] = smulww(gainAdjQ16, sLPCQ14[i])
+			}
+		}
+		ch.prevGainQ16 = ctl.gainsQ16[k]
+
+		// avoid an abrupt transition from the voiced PLC to unvoiced decoding
+		if ch.lossCnt != 0 && ch.prevSignalType == silkTypeVoiced && idx.signalType != silkTypeVoiced && k < silkMaxNbSubfr/2 {
+			for i := range b {
+				b[i] = 0
+			}
+			b[silkLTPOrder/2] = 4096 // 0.25 in Q14
+			signalType = silkTypeVoiced
+			ctl.pitchL[k] = ch.lagPrev
+		}
+
+		if signalType == silkTypeVoiced {
+			lag = ctl.pitchL[k]
+			// re-whitening
+			if k == 0 || (k == 2 && nlsfInterpolation) {
+				start := ch.ltpMemLength - lag - ch.lpcOrder - silkLTPOrder/2
+				if k == 2 {
+					copy(ch.outBuf[ch.ltpMemLength:], xq[:2*ch.subfrLength])
+				}
+				lpcAnalysisFilter(sLTP[start:], ch.outBuf[start+k*ch.subfrLength:], aQ12[:], ch.ltpMemLength-start, ch.lpcOrder)
+				// LTP downscaling to reduce the inter-packet dependency
+				if k == 0 {
+					invGainQ31 = smulwb(invGainQ31, ctl.ltpScaleQ14) << 2
+				}
+				for i := 0; i < lag+silkLTPOrder/2; i++ {
+					sLTPQ15[ltpBufIdx-i-1] = smulwb(invGainQ31, int32(sLTP[ch.ltpMemLength-i-1]))
+				}
+			} else if gainAdjQ16 != 1<<16 {
+				for i := 0; i < lag+silkLTPOrder/2; i++ {
+					sLTPQ15[ltpBufIdx-i-1] = smulww(gainAdjQ16, sLTPQ15[ltpBufIdx-i-1])
+				}
+			}
+
+			// long term prediction
+			p := ltpBufIdx - lag + silkLTPOrder/2
+			for i := 0; i < ch.subfrLength; i++ {
+				pred := int32(2)
+				for j := 0; j < silkLTPOrder; j++ {
+					pred = smlawb(pred, sLTPQ15[p+i-j], int32(b[j]))
+				}
+				res[i] = exc[i] + pred<<1
+				sLTPQ15[ltpBufIdx] = res[i] << 1
+				ltpBufIdx++
+			}
+		} else {
+			res = exc
+		}
+
+		// short term prediction
+		for i := 0; i < ch.subfrLength; i++ {
+			pred := lpcPredQ10(sLPCQ14[:], i, aQ12[:], ch.lpcOrder)
+			sLPCQ14[silkMaxLPCOrder+i] = res[i] + pred<<4
+			out[i] = int16(sat16(rshiftRound(smulww(sLPCQ14[silkMaxLPCOrder+i], gainQ10), 8)))
+		}
+		copy(sLPCQ14[:silkMaxLPCOrder], sLPCQ14[ch.subfrLength:ch.subfrLength+silkMaxLPCOrder])
+		exc = exc[ch.subfrLength:]
+		out = out[ch.subfrLength:]
+	}
+	copy(ch.sLPCQ14[:], sLPCQ14[:silkMaxLPCOrder])
+}
+
+// decodeFrame decodes a frame of frameLength samples into out, lost conceals it
+func (ch *silkChannel) decodeFrame(dec *rangeDecoder, out []int16, lost bool, condCoding int) int {
+	var ctl silkControl
+	n := ch.frameLength
+	if !lost {
+		var pulses [silkMaxFrameLength]int16
+		ch.decodeIndices(dec, ch.nFramesDecoded, false, condCoding)
+		silkDecodePulses(dec, pulses[:], int(ch.indices.signalType), int(ch.indices.quantOffsetType), n)
+		ch.decodeParameters(&ctl, condCoding)
+		ch.decodeCore(&ctl, out, pulses[:])
+		ch.runPLC(&ctl, out, false)
+		ch.lossCnt = 0
+		ch.prevSignalType = int(ch.indices.signalType)
+		ch.firstFrameAfterReset = false
+	} else {
+		ch.runPLC(&ctl, out, true)
+	}
+
+	// update the output buffer
+	mv := ch.ltpMemLength - n
+	copy(ch.outBuf[:mv], ch.outBuf[n:n+mv])
+	copy(ch.outBuf[mv:], out[:n])
+
+	ch.cngApply(&ctl, out, n)
+	ch.plcGlueFrames(out, n)
+	ch.lagPrev = ctl.pitchL[ch.nbSubfr-1]
+	return n
+}
@@ -0,0 +1,249 @@
+package opus
+
+import "math/bits"
+
+// the fixed point arithmetic of SILK, they wrap around as the int32 of C
+
+const (
+	int16Max = 32767
+	int16Min = -32768
+	int32Max = 1<<31 - 1
+	int32Min = -1 << 31
+)
+
+// smulwb is (a * int16(b)) >> 16
+func smulwb(a, b int32) int32 {
+	return int32(int64(a) * int64(int16(b)) >> 16)
+}
+
+// smlawb is a + (b * int16(c)) >> 16
+func smlawb(a, b, c int32) int32 {
+	return a + int32(int64(b)*int64(int16(c))>>16)
+}
+
+// smulwt is (a * (b >> 16)) >> 16
+func smulwt(a, b int32) int32 {
+	return (a>>16)*(b>>16) + (a&0xffff)*(b>>16)>>16
+}
+
+// smlawt is a + (b * (c >> 16)) >> 16
+func smlawt(a, b, c int32) int32 {
+	return a + int32(int64(b)*(int64(c)>>16)>>16)
+}
+
+// smulbb is int16(a) * int16(b)
+func smulbb(a, b int32) int32 {
+	return int32(int16(a)) * int32(int16(b))
+}
+
+// smlabb is a + int16(b) * int16(c)
+func smlabb(a, b, c int32) int32 {
+	return a + int32(int16(b))*int32(int16(c))
+}
+
+// smulww is (a * b) >> 16
+func smulww(a, b int32) int32 {
+	return int32(int64(a) * int64(b) >> 16)
+}
+
+// smlaww is a + (b * c) >> 16
+func smlaww(a, b, c int32) int32 {
+	return a + int32(int64(b)*int64(c)>>16)
+}
+
+// smmul is (a * b) >> 32
+func smmul(a, b int32) int32 {
+	return int32(int64(a) * int64(b) >> 32)
+}
+
+// rshiftRound is a >> s rounded, s > 0
+func rshiftRound(a int32, s uint) int32 {
+	if s == 1 {
+		return a>>1 + a&1
+	}
+	return (a>>(s-1) + 1) >> 1
+}
+
+func rshiftRound64(a int64, s uint) int64 {
+	if s == 1 {
+		return a>>1 + a&1
+	}
+	return (a>>(s-1) + 1) >> 1
+}
+
+// limit32 clamps a between the limits, in either order
+func limit32(a, l1, l2 int32) int32 {
+	if l1 > l2 {
+		l1, l2 = l2, l1
+	}
+	if a > l2 {
+		return l2
+	}
+	if a < l1 {
+		return l1
+	}
+	return a
+}
+
+func limitInt(a, l1, l2 int) int {
+	if l1 > l2 {
+		l1, l2 = l2, l1
+	}
+	if a > l2 {
+		return l2
+	}
+	if a < l1 {
+		return l1
+	}
+	return a
+}
+
+// lshiftSat32 is a << s saturated
+func lshiftSat32(a int32, s uint) int32 {
+	return limit32(a, int32Min>>s, int32Max>>s) << s
+}
+
+func sat16(a int32) int32 {
+	if a > int16Max {
+		return int16Max
+	}
+	if a < int16Min {
+		return int16Min
+	}
+	return a
+}
+
+func addSat32(a, b int32) int32 {
+	s := int64(a) + int64(b)
+	if s > int32Max {
+		return int32Max
+	}
+	if s < int32Min {
+		return int32Min
+	}
+	return int32(s)
+}
+
+func subSat32(a, b int32) int32 {
+	s := int64(a) - int64(b)
+	if s > int32Max {
+		return int32Max
+	}
+	if s < int32Min {
+		return int32Min
+	}
+	return int32(s)
+}
+
+func abs32(a int32) int32 {
+	if a > 0 {
+		return a
+	}
+	return -a
+}
+
+func min32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// clz32 counts the leading zeros
+func clz32(a int32) int32 {
+	return int32(bits.LeadingZeros32(uint32(a)))
+}
+
+// clzFrac reports the leading zeros and the 7 bits after the leading one
+func clzFrac(a int32) (lz, fracQ7 int32) {
+	lz = clz32(a)
+	fracQ7 = int32(bits.RotateLeft32(uint32(a), int(lz-24))) & 0x7f
+	return
+}
+
+// sqrtApprox is the approximated square root
+func sqrtApprox(x int32) int32 {
+	if x <= 0 {
+		return 0
+	}
+	lz, frac := clzFrac(x)
+	y := int32(46214) // sqrt(2) * 32768
+	if lz&1 != 0 {
+		y = 32768
+	}
+	y >>= uint(lz >> 1)
+	return smlawb(y, y, smulbb(213, frac))
+}
+
+// div32VarQ approximates (a << q) / b
+func div32VarQ(a, b int32, q int) int32 {
+	aHeadroom := clz32(abs32(a)) - 1
+	aNrm := a << uint(aHeadroom)
+	bHeadroom := clz32(abs32(b)) - 1
+	bNrm := b << uint(bHeadroom)
+	// inverse of b with 14 bits of precision
+	bInv := (int32Max >> 2) / (bNrm >> 16)
+	result := smulwb(aNrm, bInv)
+	aNrm -= smmul(bNrm, result) << 3
+	result = smlawb(result, aNrm, bInv)
+	lshift := 29 + int(aHeadroom) - int(bHeadroom) - q
+	if lshift < 0 {
+		return lshiftSat32(result, uint(-lshift))
+	}
+	if lshift < 32 {
+		return result >> uint(lshift)
+	}
+	return 0
+}
+
+// inverse32VarQ approximates (1 << q) / b
+func inverse32VarQ(b int32, q int) int32 {
+	bHeadroom := clz32(abs32(b)) - 1
+	bNrm := b << uint(bHeadroom)
+	bInv := (int32Max >> 2) / (bNrm >> 16)
+	result := bInv << 16
+	errQ32 := (1<<29 - smulwb(bNrm, bInv)) << 3
+	result = smlaww(result, errQ32, bInv)
+	lshift := 61 - int(bHeadroom) - q
+	if lshift <= 0 {
+		return lshiftSat32(result, uint(-lshift))
+	}
+	if lshift < 32 {
+		return result >> uint(lshift)
+	}
+	return 0
+}
+
+// lin2log approximates 128 * log2(x)
+func lin2log(x int32) int32 {
+	lz, frac := clzFrac(x)
+	return (31-lz)<<7 + smlawb(frac, frac*(128-frac), 179)
+}
+
+// log2lin approximates 2^(x/128)
+func log2lin(x int32) int32 {
+	if x < 0 {
+		return 0
+	}
+	if x >= 3967 {
+		return int32Max
+	}
+	out := int32(1) << uint(x>>7)
+	frac := x & 0x7f
+	if x < 2048 {
+		return out + out*smlawb(frac, smulbb(frac, 128-frac), -174)>>7
+	}
+	return out + (out>>7)*smlawb(frac, smulbb(frac, 128-frac), -174)
+}
+
+// silkRand is the linear congruential generator of SILK
+func silkRand(seed int32) int32 {
+	return 907633515 + seed*196314165
+}
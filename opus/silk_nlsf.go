@@ -0,0 +1,300 @@
+package opus
+
+// the normalized line spectral frequencies of SILK and their conversion to the LPC
+// coefficients, section 4.2.7.5 of RFC 6716
+
+const (
+	silkMaxLPCOrder     = 16
+	silkNLSFQuantMax    = 4 // max amplitude of the residual indices
+	silkNLSFWeightQ     = 2
+	silkNLSFLevelAdj    = 102 // 0.1 in Q10
+	silkMaxLPCStabilize = 16
+	silkMinInvGainQ30   = 107374 // 1/1e4 in Q30
+)
+
+// nlsfUnpack reports the entropy table indices and the predictor of the first stage
+// vector cb1
+func nlsfUnpack(ecIx []int16, predQ8 []uint8, cb *silkNLSFCodebook, cb1 int) {
+	sel := cb.ecSel[cb1*cb.order/2:]
+	for i := 0; i < cb.order; i += 2 {
+		entry := int(sel[i/2])
+		ecIx[i] = int16((entry >> 1 & 7) * (2*silkNLSFQuantMax + 1))
+		predQ8[i] = cb.predQ8[i+(entry&1)*(cb.order-1)]
+		ecIx[i+1] = int16((entry >> 5 & 7) * (2*silkNLSFQuantMax + 1))
+		predQ8[i+1] = cb.predQ8[i+(entry>>4&1)*(cb.order-1)+1]
+	}
+}
+
+// nlsfResidualDequant is the backward predicted dequantization of the second stage
+func nlsfResidualDequant(xQ10 []int16, indices []int8, predQ8 []uint8, stepQ16 int32, order int) {
+	out := int32(0)
+	for i := order - 1; i >= 0; i-- {
+		pred := smulbb(out, int32(predQ8[i])) >> 8
+		out = int32(indices[i]) << 10
+		if out > 0 {
+			out -= silkNLSFLevelAdj
+		} else if out < 0 {
+			out += silkNLSFLevelAdj
+		}
+		out = smlawb(pred, out, stepQ16)
+		xQ10[i] = int16(out)
+	}
+}
+
+// nlsfWeightsLaroia is the low complexity weights of Laroia et al.
+func nlsfWeightsLaroia(w []int16, nlsf []int16, d int) {
+	const one = 1 << (15 + silkNLSFWeightQ)
+	tmp1 := one / max32(int32(nlsf[0]), 1)
+	tmp2 := one / max32(int32(nlsf[1])-int32(nlsf[0]), 1)
+	w[0] = int16(min32(tmp1+tmp2, int16Max))
+	for k := 1; k < d-1; k += 2 {
+		tmp1 = one / max32(int32(nlsf[k+1])-int32(nlsf[k]), 1)
+		w[k] = int16(min32(tmp1+tmp2, int16Max))
+		tmp2 = one / max32(int32(nlsf[k+2])-int32(nlsf[k+1]), 1)
+		w[k+1] = int16(min32(tmp1+tmp2, int16Max))
+	}
+	tmp1 = one / max32(1<<15-int32(nlsf[d-1]), 1)
+	w[d-1] = int16(min32(tmp1+tmp2, int16Max))
+}
+
+// nlsfStabilize moves the NLSFs apart from each other and the borders by at least
+// deltaMin
+func nlsfStabilize(nlsf []int16, deltaMin []int16, l int) {
+	const maxLoops = 20
+	for loops := 0; loops < maxLoops; loops++ {
+		// the smallest distance
+		minDiff := int32(nlsf[0]) - int32(deltaMin[0])
+		idx := 0
+		for i := 1; i <= l-1; i++ {
+			diff := int32(nlsf[i]) - (int32(nlsf[i-1]) + int32(deltaMin[i]))
+			if diff < minDiff {
+				minDiff = diff
+				idx = i
+			}
+		}
+		diff := 1<<15 - (int32(nlsf[l-1]) + int32(deltaMin[l]))
+		if diff < minDiff {
+			minDiff = diff
+			idx = l
+		}
+		if minDiff >= 0 {
+			return
+		}
+		switch idx {
+		case 0:
+			nlsf[0] = deltaMin[0]
+		case l:
+			nlsf[l-1] = int16(1<<15 - int32(deltaMin[l]))
+		default:
+			minCenter := int32(0)
+			for k := 0; k < idx; k++ {
+				minCenter += int32(deltaMin[k])
+			}
+			minCenter += int32(deltaMin[idx]) >> 1
+			maxCenter := int32(1 << 15)
+			for k := l; k > idx; k-- {
+				maxCenter -= int32(deltaMin[k])
+			}
+			maxCenter -= int32(deltaMin[idx]) >> 1
+			center := int16(limit32(rshiftRound(int32(nlsf[idx-1])+int32(nlsf[idx]), 1), minCenter, maxCenter))
+			nlsf[idx-1] = center - deltaMin[idx]>>1
+			nlsf[idx] = nlsf[idx-1] + deltaMin[idx]
+		}
+	}
+
+	// the fall back
+	for i := 1; i < l; i++ {
+		v := nlsf[i]
+		j := i - 1
+		for ; j >= 0 && v < nlsf[j]; j-- {
+			nlsf[j+1] = nlsf[j]
+		}
+		nlsf[j+1] = v
+	}
+	nlsf[0] = int16(max32(int32(nlsf[0]), int32(deltaMin[0])))
+	for i := 1; i < l; i++ {
+		nlsf[i] = int16(max32(int32(nlsf[i]), int32(nlsf[i-1])+int32(deltaMin[i])))
+	}
+	nlsf[l-1] = int16(min32(int32(nlsf[l-1]), 1<<15-int32(deltaMin[l])))
+	for i := l - 2; i >= 0; i-- {
+		nlsf[i] = int16(min32(int32(nlsf[i]), int32(nlsf[i+1])-int32(deltaMin[i+1])))
+	}
+}
+
+// nlsfDecode dequantizes the NLSF vector of the codebook indices
+func nlsfDecode(nlsf []int16, indices []int8, cb *silkNLSFCodebook) {
+	var (
+		predQ8 [silkMaxLPCOrder]uint8
+		ecIx   [silkMaxLPCOrder]int16
+		resQ10 [silkMaxLPCOrder]int16
+		w      [silkMaxLPCOrder]int16
+	)
+	cb1 := int(indices[0])
+	for i := 0; i < cb.order; i++ {
+		nlsf[i] = int16(cb.cb1NLSFQ8[cb1*cb.order+i]) << 7
+	}
+	nlsfUnpack(ecIx[:], predQ8[:], cb, cb1)
+	nlsfResidualDequant(resQ10[:], indices[1:], predQ8[:], cb.quantStepSizeQ16, cb.order)
+	nlsfWeightsLaroia(w[:], nlsf, cb.order)
+	for i := 0; i < cb.order; i++ {
+		wQ9 := sqrtApprox(int32(w[i]) << (18 - silkNLSFWeightQ))
+		v := int32(nlsf[i]) + int32(resQ10[i])<<14/wQ9
+		nlsf[i] = int16(limit32(v, 0, 32767))
+	}
+	nlsfStabilize(nlsf, cb.deltaMinQ15, cb.order)
+}
+
+// nlsf2aFindPoly is the polynomial of the interleaved 2*cos(LSFs), in Q16
+func nlsf2aFindPoly(out []int32, cLSF []int32, dd int) {
+	out[0] = 1 << 16
+	out[1] = -cLSF[0]
+	for k := 1; k < dd; k++ {
+		ftmp := int64(cLSF[2*k])
+		out[k+1] = out[k-1]<<1 - int32(rshiftRound64(ftmp*int64(out[k]), 16))
+		for n := k; n > 1; n-- {
+			out[n] += out[n-2] - int32(rshiftRound64(ftmp*int64(out[n-1]), 16))
+		}
+		out[1] -= int32(ftmp)
+	}
+}
+
+var (
+	nlsf2aOrdering16 = [16]uint8{0, 15, 8, 7, 4, 11, 12, 3, 2, 13, 10, 5, 6, 9, 14, 1}
+	nlsf2aOrdering10 = [10]uint8{0, 9, 6, 3, 4, 5, 8, 1, 2, 7}
+)
+
+// nlsf2a converts the NLSFs to the monic whitening filter in Q12
+func nlsf2a(aQ12 []int16, nlsf []int16, d int) {
+	var (
+		cosLSF [silkMaxLPCOrder]int32
+		p, q   [silkMaxLPCOrder/2 + 1]int32
+		a32    [silkMaxLPCOrder]int32
+	)
+	ordering := nlsf2aOrdering10[:]
+	if d == 16 {
+		ordering = nlsf2aOrdering16[:]
+	}
+	for k := 0; k < d; k++ {
+		fInt := int32(nlsf[k]) >> 8
+		fFrac := int32(nlsf[k]) - fInt<<8
+		cosVal := int32(silkLSFCosTabQ12[fInt])
+		delta := int32(silkLSFCosTabQ12[fInt+1]) - cosVal
+		cosLSF[ordering[k]] = rshiftRound(cosVal<<8+delta*fFrac, 4)
+	}
+	dd := d >> 1
+	nlsf2aFindPoly(p[:], cosLSF[:], dd)
+	nlsf2aFindPoly(q[:], cosLSF[1:], dd)
+	for k := 0; k < dd; k++ {
+		ptmp := p[k+1] + p[k]
+		qtmp := q[k+1] - q[k]
+		a32[k] = -qtmp - ptmp
+		a32[d-k-1] = qtmp - ptmp
+	}
+
+	// limit the coefficients to fit in int16
+	i := 0
+	for ; i < 10; i++ {
+		maxabs, idx := int32(0), 0
+		for k := 0; k < d; k++ {
+			if v := abs32(a32[k]); v > maxabs {
+				maxabs, idx = v, k
+			}
+		}
+		maxabs = rshiftRound(maxabs, 16+1-12)
+		if maxabs <= int16Max {
+			break
+		}
+		maxabs = min32(maxabs, 163838)
+		scQ16 := 65470 - ((maxabs - int16Max) << 14 / (maxabs * int32(idx+1) >> 2))
+		bwexpander32(a32[:d], scQ16)
+	}
+	if i == 10 {
+		for k := 0; k < d; k++ {
+			aQ12[k] = int16(sat16(rshiftRound(a32[k], 16+1-12)))
+			a32[k] = int32(aQ12[k]) << (16 + 1 - 12)
+		}
+	} else {
+		for k := 0; k < d; k++ {
+			aQ12[k] = int16(rshiftRound(a32[k], 16+1-12))
+		}
+	}
+
+	for i := 0; i < silkMaxLPCStabilize; i++ {
+		if lpcInversePredGain(aQ12[:d]) >= silkMinInvGainQ30 {
+			break
+		}
+		bwexpander32(a32[:d], 65536-int32(2)<<uint(i))
+		for k := 0; k < d; k++ {
+			aQ12[k] = int16(rshiftRound(a32[k], 16+1-12))
+		}
+	}
+}
+
+// bwexpander is the chirp of the AR filter in Q12
+func bwexpander(ar []int16, chirpQ16 int32) {
+	chirpMinusOne := chirpQ16 - 65536
+	d := len(ar)
+	for i := 0; i < d-1; i++ {
+		ar[i] = int16(rshiftRound(chirpQ16*int32(ar[i]), 16))
+		chirpQ16 += rshiftRound(chirpQ16*chirpMinusOne, 16)
+	}
+	ar[d-1] = int16(rshiftRound(chirpQ16*int32(ar[d-1]), 16))
+}
+
+// bwexpander32 is bwexpander of 32 bits coefficients
+func bwexpander32(ar []int32, chirpQ16 int32) {
+	chirpMinusOne := chirpQ16 - 65536
+	d := len(ar)
+	for i := 0; i < d-1; i++ {
+		ar[i] = smulww(chirpQ16, ar[i])
+		chirpQ16 += rshiftRound(chirpQ16*chirpMinusOne, 16)
+	}
+	ar[d-1] = smulww(chirpQ16, ar[d-1])
+}
+
+// lpcInversePredGain reports the inverse prediction gain in Q30 of the filter in
+// Q12, 0 if it is unstable
+func lpcInversePredGain(aQ12 []int16) int32 {
+	const (
+		qa     = 24
+		aLimit = 16773022 // 0.99975 in Q24
+	)
+	var a [2][silkMaxLPCOrder]int32
+	order := len(aQ12)
+	anew := a[order&1][:]
+	dc := int32(0)
+	for k := 0; k < order; k++ {
+		dc += int32(aQ12[k])
+		anew[k] = int32(aQ12[k]) << (qa - 12)
+	}
+	if dc >= 4096 {
+		return 0
+	}
+
+	mulFrac := func(a, b int32, q uint) int32 {
+		return int32(rshiftRound64(int64(a)*int64(b), q))
+	}
+	invGain := int32(1 << 30)
+	for k := order - 1; k > 0; k-- {
+		if anew[k] > aLimit || anew[k] < -aLimit {
+			return 0
+		}
+		rc := -(anew[k] << (31 - qa))
+		rcMult1 := 1<<30 - smmul(rc, rc)
+		mult2Q := 32 - clz32(abs32(rcMult1))
+		rcMult2 := inverse32VarQ(rcMult1, int(mult2Q+30))
+		invGain = smmul(invGain, rcMult1) << 2
+		aold := anew
+		anew = a[k&1][:]
+		for n := 0; n < k; n++ {
+			tmp := aold[n] - mulFrac(aold[k-n-1], rc, 31)
+			anew[n] = mulFrac(tmp, rcMult2, uint(mult2Q))
+		}
+	}
+	if anew[0] > aLimit || anew[0] < -aLimit {
+		return 0
+	}
+	rc := -(anew[0] << (31 - qa))
+	rcMult1 := 1<<30 - smmul(rc, rc)
+	return smmul(invGain, rcMult1) << 2
+}
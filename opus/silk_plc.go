@@ -0,0 +1,381 @@
+package opus
+
+// the packet loss concealment and the comfort noise generation of SILK
+
+const (
+	silkPLCBWECoefQ16        = 64881 // 0.99
+	silkVPitchGainStartMin   = 11469 // 0.7 in Q14
+	silkVPitchGainStartMax   = 15565 // 0.95 in Q14
+	silkMaxPitchLagMs        = 18
+	silkRandBufSize          = 128
+	silkRandBufMask          = silkRandBufSize - 1
+	silkLog2InvLPCGainHigh   = 3
+	silkLog2InvLPCGainLow    = 8
+	silkPitchDriftFacQ16     = 655
+	silkCNGBufMaskMax        = 255
+	silkCNGGainSmthQ16       = 4634
+	silkCNGNLSFSmthQ16       = 16348
+	silkCNGRandSeed          = 3176576
+	silkPLCNbAtt             = 2
+	silkPLCFirstRandScaleMin = 3277 // 0.2 in Q14
+)
+
+var (
+	silkHarmAttQ15         = [silkPLCNbAtt]int32{32440, 31130} // 0.99, 0.95
+	silkPLCRandAttenuateV  = [silkPLCNbAtt]int32{31130, 26214} // 0.95, 0.8
+	silkPLCRandAttenuateUV = [silkPLCNbAtt]int32{32440, 29491} // 0.99, 0.9
+)
+
+func (ch *silkChannel) plcReset() {
+	ch.plc.pitchLQ8 = int32(ch.frameLength) << 7
+	ch.plc.prevGainQ16 = [2]int32{1 << 16, 1 << 16}
+	ch.plc.subfrLength = 20
+	ch.plc.nbSubfr = 2
+}
+
+// runPLC updates the concealment state with a decoded frame, or conceals a lost one
+func (ch *silkChannel) runPLC(ctl *silkControl, frame []int16, lost bool) {
+	if ch.fsKHz != ch.plc.fsKHz {
+		ch.plcReset()
+		ch.plc.fsKHz = ch.fsKHz
+	}
+	if lost {
+		ch.plcConceal(ctl, frame)
+		ch.lossCnt++
+	} else {
+		ch.plcUpdate(ctl)
+	}
+}
+
+func (ch *silkChannel) plcUpdate(ctl *silkControl) {
+	p := &ch.plc
+	ch.prevSignalType = int(ch.indices.signalType)
+	ltpGainQ14 := int32(0)
+	if ch.indices.signalType == silkTypeVoiced {
+		// the parameters of the last subframe which contains a pitch pulse
+		for j := 0; j*ch.subfrLength < ctl.pitchL[ch.nbSubfr-1]; j++ {
+			if j == ch.nbSubfr {
+				break
+			}
+			k := ch.nbSubfr - 1 - j
+			g := int32(0)
+			for i := 0; i < silkLTPOrder; i++ {
+				g += int32(ctl.ltpCoefQ14[k*silkLTPOrder+i])
+			}
+			if g > ltpGainQ14 {
+				ltpGainQ14 = g
+				copy(p.ltpCoefQ14[:], ctl.ltpCoefQ14[k*silkLTPOrder:])
+				p.pitchLQ8 = int32(ctl.pitchL[k]) << 8
+			}
+		}
+
+		p.ltpCoefQ14 = [silkLTPOrder]int16{}
+		p.ltpCoefQ14[silkLTPOrder/2] = int16(ltpGainQ14)
+
+		// limit the LT coefficients
+		if ltpGainQ14 < silkVPitchGainStartMin {
+			scaleQ10 := int32(silkVPitchGainStartMin<<10) / max32(ltpGainQ14, 1)
+			for i := range p.ltpCoefQ14 {
+				p.ltpCoefQ14[i] = int16(smulbb(int32(p.ltpCoefQ14[i]), scaleQ10) >> 10)
+			}
+		} else if ltpGainQ14 > silkVPitchGainStartMax {
+			scaleQ14 := int32(silkVPitchGainStartMax<<14) / max32(ltpGainQ14, 1)
+			for i := range p.ltpCoefQ14 {
+				p.ltpCoefQ14[i] = int16(smulbb(int32(p.ltpCoefQ14[i]), scaleQ14) >> 14)
+			}
+		}
+	} else {
+		p.pitchLQ8 = int32(ch.fsKHz*18) << 8
+		p.ltpCoefQ14 = [silkLTPOrder]int16{}
+	}
+
+	copy(p.prevLPCQ12[:ch.lpcOrder], ctl.predCoefQ12[1][:ch.lpcOrder])
+	p.prevLTPScaleQ14 = int16(ctl.ltpScaleQ14)
+	copy(p.prevGainQ16[:], ctl.gainsQ16[ch.nbSubfr-2:ch.nbSubfr])
+	p.subfrLength = ch.subfrLength
+	p.nbSubfr = ch.nbSubfr
+}
+
+// sumSqrShift reports the energy of x shifted right to fit in 30 bits
+func sumSqrShift(x []int16) (energy int32, shift int) {
+	var nrg uint32
+	n := len(x) - 1
+	i := 0
+	for ; i < n; i += 2 {
+		nrg += uint32(int32(x[i]) * int32(x[i]))
+		nrg += uint32(int32(x[i+1]) * int32(x[i+1]))
+		if int32(nrg) < 0 {
+			// scale down
+			nrg >>= 2
+			shift = 2
+			i += 2
+			break
+		}
+	}
+	for ; i < n; i += 2 {
+		tmp := uint32(int32(x[i])*int32(x[i])) + uint32(int32(x[i+1])*int32(x[i+1]))
+		nrg += tmp >> uint(shift)
+		if int32(nrg) < 0 {
+			nrg >>= 2
+			shift += 2
+		}
+	}
+	if i == n {
+		// one sample left
+		nrg += uint32(int32(x[i])*int32(x[i])) >> uint(shift)
+	}
+	// at least two leading zeros
+	if nrg&0xc0000000 != 0 {
+		nrg >>= 2
+		shift += 2
+	}
+	return int32(nrg), shift
+}
+
+func (ch *silkChannel) plcConceal(ctl *silkControl, frame []int16) {
+	var (
+		sLTP    [silkLTPMemLengthMs * silkMaxFsKHz]int16
+		sLTPQ14 [silkLTPMemLengthMs*silkMaxFsKHz + silkMaxFrameLength]int32
+		aQ12    [silkMaxLPCOrder]int16
+		excBuf  [2 * silkMaxSubfrLength]int16
+	)
+	p := &ch.plc
+	prevGainQ10 := [2]int32{p.prevGainQ16[0] >> 6, p.prevGainQ16[1] >> 6}
+
+	if ch.firstFrameAfterReset {
+		p.prevLPCQ12 = [silkMaxLPCOrder]int16{}
+	}
+
+	// the energies of the last two subframes of the excitation
+	n := ch.subfrLength
+	for k := 0; k < 2; k++ {
+		for i := 0; i < n; i++ {
+			e := ch.excQ14[i+(k+ch.nbSubfr-2)*n]
+			excBuf[k*n+i] = int16(sat16(smulww(e, prevGainQ10[k]) >> 8))
+		}
+	}
+	energy1, shift1 := sumSqrShift(excBuf[:n])
+	energy2, shift2 := sumSqrShift(excBuf[n : 2*n])
+
+	// use the subframe of the lowest energy as the random noise
+	var randBuf []int32
+	if energy1>>uint(shift2) < energy2>>uint(shift1) {
+		randBuf = ch.excQ14[maxInt(0, (p.nbSubfr-1)*p.subfrLength-silkRandBufSize):]
+	} else {
+		randBuf = ch.excQ14[maxInt(0, p.nbSubfr*p.subfrLength-silkRandBufSize):]
+	}
+
+	b := &p.ltpCoefQ14
+	randScaleQ14 := p.randScaleQ14
+
+	att := minInt(silkPLCNbAtt-1, ch.lossCnt)
+	harmGainQ15 := silkHarmAttQ15[att]
+	randGainQ15 := silkPLCRandAttenuateUV[att]
+	if ch.prevSignalType == silkTypeVoiced {
+		randGainQ15 = silkPLCRandAttenuateV[att]
+	}
+
+	bwexpander(p.prevLPCQ12[:ch.lpcOrder], silkPLCBWECoefQ16)
+	copy(aQ12[:ch.lpcOrder], p.prevLPCQ12[:ch.lpcOrder])
+
+	// the first lost frame
+	if ch.lossCnt == 0 {
+		randScaleQ14 = 1 << 14
+		if ch.prevSignalType == silkTypeVoiced {
+			// less noise for the voiced frames
+			for i := range b {
+				randScaleQ14 -= b[i]
+			}
+			if randScaleQ14 < silkPLCFirstRandScaleMin {
+				randScaleQ14 = silkPLCFirstRandScaleMin
+			}
+			randScaleQ14 = int16(smulbb(int32(randScaleQ14), int32(p.prevLTPScaleQ14)) >> 14)
+		} else {
+			// less noise for the unvoiced frames of a high LPC gain
+			invGainQ30 := lpcInversePredGain(p.prevLPCQ12[:ch.lpcOrder])
+			downScaleQ30 := min32(1<<30>>silkLog2InvLPCGainHigh, invGainQ30)
+			downScaleQ30 = max32(1<<30>>silkLog2InvLPCGainLow, downScaleQ30)
+			downScaleQ30 <<= silkLog2InvLPCGainHigh
+			randGainQ15 = smulwb(downScaleQ30, randGainQ15) >> 14
+		}
+	}
+
+	randSeed := p.randSeed
+	lag := int(rshiftRound(p.pitchLQ8, 8))
+	ltpBufIdx := ch.ltpMemLength
+
+	// rewhiten the LTP state
+	idx := ch.ltpMemLength - lag - ch.lpcOrder - silkLTPOrder/2
+	lpcAnalysisFilter(sLTP[idx:], ch.outBuf[idx:], aQ12[:], ch.ltpMemLength-idx, ch.lpcOrder)
+	invGainQ30 := min32(inverse32VarQ(p.prevGainQ16[1], 46), int32Max>>1)
+	for i := idx + ch.lpcOrder; i < ch.ltpMemLength; i++ {
+		sLTPQ14[i] = smulwb(invGainQ30, int32(sLTP[i]))
+	}
+
+	// LTP synthesis
+	for k := 0; k < ch.nbSubfr; k++ {
+		pred := ltpBufIdx - lag + silkLTPOrder/2
+		for i := 0; i < ch.subfrLength; i++ {
+			ltpPredQ12 := int32(2)
+			for j := 0; j < silkLTPOrder; j++ {
+				ltpPredQ12 = smlawb(ltpPredQ12, sLTPQ14[pred+i-j], int32(b[j]))
+			}
+			randSeed = silkRand(randSeed)
+			r := randBuf[(randSeed>>25)&silkRandBufMask]
+			sLTPQ14[ltpBufIdx] = smlawb(ltpPredQ12, r, int32(randScaleQ14)) << 2
+			ltpBufIdx++
+		}
+
+		// gradually reduce the LTP gain and the excitation gain
+		for j := range b {
+			b[j] = int16(smulbb(harmGainQ15, int32(b[j])) >> 15)
+		}
+		randScaleQ14 = int16(smulbb(int32(randScaleQ14), randGainQ15) >> 15)
+
+		// slowly increase the pitch lag
+		p.pitchLQ8 = smlawb(p.pitchLQ8, p.pitchLQ8, silkPitchDriftFacQ16)
+		p.pitchLQ8 = min32(p.pitchLQ8, int32(silkMaxPitchLagMs*ch.fsKHz)<<8)
+		lag = int(rshiftRound(p.pitchLQ8, 8))
+	}
+
+	// LPC synthesis
+	sLPC := sLTPQ14[ch.ltpMemLength-silkMaxLPCOrder:]
+	copy(sLPC[:silkMaxLPCOrder], ch.sLPCQ14[:])
+	for i := 0; i < ch.frameLength; i++ {
+		pred := lpcPredQ10(sLPC, i, aQ12[:], ch.lpcOrder)
+		sLPC[silkMaxLPCOrder+i] += pred << 4
+		frame[i] = int16(sat16(rshiftRound(smulww(sLPC[silkMaxLPCOrder+i], prevGainQ10[1]), 8)))
+	}
+	copy(ch.sLPCQ14[:], sLPC[ch.frameLength:ch.frameLength+silkMaxLPCOrder])
+
+	p.randSeed = randSeed
+	p.randScaleQ14 = randScaleQ14
+	for i := range ctl.pitchL {
+		ctl.pitchL[i] = lag
+	}
+}
+
+// plcGlueFrames fades in the energy of the first good frame after a loss
+func (ch *silkChannel) plcGlueFrames(frame []int16, length int) {
+	p := &ch.plc
+	if ch.lossCnt != 0 {
+		// the energy of the concealed residual
+		p.concEnergy, p.concEnergyShift = sumSqrShift(frame[:length])
+		p.lastFrameLost = true
+		return
+	}
+	if p.lastFrameLost {
+		energy, shift := sumSqrShift(frame[:length])
+		if shift > p.concEnergyShift {
+			p.concEnergy >>= uint(shift - p.concEnergyShift)
+		} else if shift < p.concEnergyShift {
+			energy >>= uint(p.concEnergyShift - shift)
+		}
+
+		if energy > p.concEnergy {
+			lz := clz32(p.concEnergy) - 1
+			p.concEnergy <<= uint(lz)
+			energy >>= uint(max32(24-lz, 0))
+			fracQ24 := p.concEnergy / max32(energy, 1)
+
+			gainQ16 := sqrtApprox(fracQ24) << 4
+			// 4x steeper to not miss the onsets after DTX
+			slopeQ16 := ((1<<16 - gainQ16) / int32(length)) << 2
+			for i := 0; i < length; i++ {
+				frame[i] = int16(smulwb(gainQ16, int32(frame[i])))
+				gainQ16 += slopeQ16
+				if gainQ16 > 1<<16 {
+					break
+				}
+			}
+		}
+	}
+	p.lastFrameLost = false
+}
+
+func (ch *silkChannel) cngReset() {
+	step := int32(int16Max) / int32(ch.lpcOrder+1)
+	acc := int32(0)
+	for i := 0; i < ch.lpcOrder; i++ {
+		acc += step
+		ch.cng.smthNLSFQ15[i] = int16(acc)
+	}
+	ch.cng.smthGainQ16 = 0
+	ch.cng.randSeed = silkCNGRandSeed
+}
+
+// cngApply updates the comfort noise estimate and adds it to the lost frames
+func (ch *silkChannel) cngApply(ctl *silkControl, frame []int16, length int) {
+	c := &ch.cng
+	if ch.fsKHz != c.fsKHz {
+		ch.cngReset()
+		c.fsKHz = ch.fsKHz
+	}
+	if ch.lossCnt == 0 && ch.prevSignalType == silkTypeNoVoiceActivity {
+		// smooth the LSFs
+		for i := 0; i < ch.lpcOrder; i++ {
+			c.smthNLSFQ15[i] += int16(smulwb(int32(ch.prevNLSFQ15[i])-int32(c.smthNLSFQ15[i]), silkCNGNLSFSmthQ16))
+		}
+		// the subframe of the highest gain
+		maxGain, subfr := int32(0), 0
+		for i := 0; i < ch.nbSubfr; i++ {
+			if ctl.gainsQ16[i] > maxGain {
+				maxGain = ctl.gainsQ16[i]
+				subfr = i
+			}
+		}
+		n := ch.subfrLength
+		copy(c.excBufQ14[n:ch.nbSubfr*n], c.excBufQ14[:(ch.nbSubfr-1)*n])
+		copy(c.excBufQ14[:n], ch.excQ14[subfr*n:(subfr+1)*n])
+		// smooth the gains
+		for i := 0; i < ch.nbSubfr; i++ {
+			c.smthGainQ16 += smulwb(ctl.gainsQ16[i]-c.smthGainQ16, silkCNGGainSmthQ16)
+		}
+	}
+
+	if ch.lossCnt == 0 {
+		for i := 0; i < ch.lpcOrder; i++ {
+			c.synthState[i] = 0
+		}
+		return
+	}
+
+	// add the comfort noise to the lost frames
+	var (
+		sig  [silkMaxFrameLength + silkMaxLPCOrder]int32
+		aQ12 [silkMaxLPCOrder]int16
+	)
+	gainQ16 := smulww(int32(ch.plc.randScaleQ14), ch.plc.prevGainQ16[1])
+	if gainQ16 >= 1<<21 || c.smthGainQ16 > 1<<23 {
+		gainQ16 = (gainQ16 >> 16) * (gainQ16 >> 16)
+		gainQ16 = (c.smthGainQ16>>16)*(c.smthGainQ16>>16) - gainQ16<<5
+		gainQ16 = sqrtApprox(gainQ16) << 16
+	} else {
+		gainQ16 = smulww(gainQ16, gainQ16)
+		gainQ16 = smulww(c.smthGainQ16, c.smthGainQ16) - gainQ16<<5
+		gainQ16 = sqrtApprox(gainQ16) << 8
+	}
+
+	// excitation
+	mask := int32(silkCNGBufMaskMax)
+	for mask > int32(length) {
+		mask >>= 1
+	}
+	seed := c.randSeed
+	for i := 0; i < length; i++ {
+		seed = silkRand(seed)
+		sig[silkMaxLPCOrder+i] = int32(int16(sat16(smulww(c.excBufQ14[(seed>>24)&mask], gainQ16>>4))))
+	}
+	c.randSeed = seed
+
+	nlsf2a(aQ12[:], c.smthNLSFQ15[:], ch.lpcOrder)
+
+	// synthesis filtering
+	copy(sig[:silkMaxLPCOrder], c.synthState[:])
+	for i := 0; i < length; i++ {
+		sumQ6 := lpcPredQ10(sig[:], i, aQ12[:], ch.lpcOrder)
+		sig[silkMaxLPCOrder+i] += sumQ6 << 4
+		frame[i] = int16(sat16(int32(frame[i]) + rshiftRound(sig[silkMaxLPCOrder+i], 10)))
+	}
+	copy(c.synthState[:], sig[length:length+silkMaxLPCOrder])
+}
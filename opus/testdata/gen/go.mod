@@ -0,0 +1,10 @@
+module gen
+
+go 1.16
+
+require (
+	github.com/toy80/audio v0.0.0
+	layeh.com/gopus v0.0.0-20210501142526-1ee02d434e32
+)
+
+replace github.com/toy80/audio => ../../..
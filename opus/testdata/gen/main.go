@@ -0,0 +1,215 @@
+// Command gen encodes the streams of the opus corpus by libopus, and writes their
+// reference PCM decoded by libopus too, both through layeh.com/gopus.
+//
+// the bitrate and the frame size change every few packets, so the streams have SILK,
+// hybrid and CELT frames of every size, and the transitions between them. some packets
+// are cut to the TOC byte, they are concealed by the decoders.
+//
+//	cd opus/testdata/gen && go run .
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/toy80/audio/ogg"
+	"layeh.com/gopus"
+)
+
+const (
+	sampleRate = 48000
+	preSkip    = 312 // the lookahead of libopus encoder at 48 kHz
+	trim       = 200 // samples trimmed at the end by the granule of last page
+	lossEvery  = 13  // every n-th packet is cut to the TOC byte
+)
+
+// the bitrates and frame sizes of the segments, each segment is 1/8 second
+var (
+	bitrates   = []int{6000, 12000, 20000, 32000, 64000, 16000, 128000, 24000, 10000, 48000}
+	frameSizes = []int{2880, 480, 1920, 120, 240, 960, 960, 1920}
+)
+
+// signal is a voice like tone with a sweep, noise bursts and quiet parts
+type signal struct {
+	channels int
+	seed     uint32
+	n        int
+}
+
+func (s *signal) read(pcm []int16) {
+	for i := 0; i < len(pcm)/s.channels; i++ {
+		t := float64(s.n) / sampleRate
+		for c := 0; c < s.channels; c++ {
+			s.seed = s.seed*1664525 + 1013904223
+			noise := float64(int(s.seed>>8)%2000-1000) / 1000
+			env := 0.5 + 0.5*math.Sin(2*math.Pi*1.3*t+float64(c))
+			f0 := 150 + 100*math.Sin(2*math.Pi*0.7*t)
+			v := 0.15*env*math.Sin(2*math.Pi*f0*t*(1+float64(c)*0.01)) +
+				0.05*math.Sin(2*math.Pi*(440+2000*t)*t)
+			if math.Mod(t, 1) < 0.3 {
+				v += 0.025 * noise
+			}
+			if math.Mod(t, 2) > 1.7 {
+				v *= 0.001
+			}
+			pcm[i*s.channels+c] = int16(math.Round(v * 32767))
+		}
+		s.n++
+	}
+}
+
+func main() {
+	for _, c := range []struct {
+		name     string
+		channels int
+		app      gopus.Application
+		seconds  float64
+	}{
+		{"mono", 1, gopus.Voip, 2},
+		{"stereo", 2, gopus.Audio, 2},
+	} {
+		if err := generate(c.name, c.channels, c.app, c.seconds); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func generate(name string, channels int, app gopus.Application, seconds float64) error {
+	enc, err := gopus.NewEncoder(sampleRate, channels, app)
+	if err != nil {
+		return err
+	}
+	dec, err := gopus.NewDecoder(sampleRate, channels)
+	if err != nil {
+		return err
+	}
+
+	var stream bytes.Buffer
+	w := ogg.NewWriter(&stream, 1)
+	head := []byte("OpusHead\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")[:19]
+	head[9] = byte(channels)
+	binary.LittleEndian.PutUint16(head[10:], preSkip)
+	binary.LittleEndian.PutUint32(head[12:], sampleRate)
+	tags := []byte("OpusTags\x0e\x00\x00\x00libopus 1.1.2\x00\x00\x00\x00\x00")[:30]
+	for _, p := range [][]byte{head, tags} {
+		if err = w.WritePacket(p, 0); err != nil {
+			return err
+		}
+		if err = w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	sig := &signal{channels: channels, seed: 1}
+	total := int(seconds * sampleRate)
+	var ref []int16
+	var packets [][]byte
+	modes := make(map[string]int)
+	for n, k := 0, 0; n < total; k++ {
+		seg := n / (sampleRate / 8)
+		enc.SetBitrate(bitrates[seg%len(bitrates)] * channels)
+		frameSize := frameSizes[seg%len(frameSizes)]
+		pcm := make([]int16, frameSize*channels)
+		sig.read(pcm)
+		p, err := enc.Encode(pcm, frameSize, 4000)
+		if err != nil {
+			return fmt.Errorf("%s: packet %d: %v", name, k, err)
+		}
+		if k%lossEvery == lossEvery-1 && p[0]&3 == 0 {
+			p = p[:1]
+		}
+		out, err := dec.Decode(p, 5760, false)
+		if err != nil {
+			return fmt.Errorf("%s: packet %d: %v", name, k, err)
+		}
+		if len(out) != frameSize*channels {
+			return fmt.Errorf("%s: packet %d decoded %d samples, want %d", name, k, len(out)/channels, frameSize)
+		}
+		ref = append(ref, out...)
+		packets = append(packets, p)
+		modes[describe(p[0], len(p))]++
+		n += frameSize
+	}
+
+	granule := int64(0)
+	for i, p := range packets {
+		granule += int64(frameCount(p) * frameSize(p[0]))
+		if i == len(packets)-1 {
+			granule -= trim
+		}
+		if err = w.WritePacket(p, granule); err != nil {
+			return err
+		}
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	if err = os.WriteFile("../"+name+".opus", stream.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	ref = ref[preSkip*channels : len(ref)-trim*channels]
+	f32 := make([]byte, 4*len(ref))
+	for i, x := range ref {
+		binary.LittleEndian.PutUint32(f32[4*i:], math.Float32bits(float32(x)/32768))
+	}
+	if err = os.WriteFile("../"+name+".f32", f32, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d packets, %d samples\n", name, len(packets), len(ref)/channels)
+	names := make([]string, 0, len(modes))
+	for m := range modes {
+		names = append(names, m)
+	}
+	sort.Strings(names)
+	for _, m := range names {
+		fmt.Printf("  %-24s %d\n", m, modes[m])
+	}
+	return nil
+}
+
+// frameSize reports the samples of a frame of the TOC byte b
+func frameSize(b byte) int {
+	config := int(b >> 3)
+	var size int
+	switch {
+	case config < 12:
+		size = []int{480, 960, 1920, 2880}[config&3]
+	case config < 16:
+		size = []int{480, 960}[config&1]
+	default:
+		size = []int{120, 240, 480, 960}[config&3]
+	}
+	return size
+}
+
+// frameCount reports the frames of packet p
+func frameCount(p []byte) int {
+	switch p[0] & 3 {
+	case 0:
+		return 1
+	case 3:
+		return int(p[1] & 0x3f)
+	}
+	return 2
+}
+
+func describe(b byte, length int) string {
+	config := int(b >> 3)
+	mode := "silk"
+	switch {
+	case config >= 16:
+		mode = "celt"
+	case config >= 12:
+		mode = "hybrid"
+	}
+	if length == 1 {
+		mode += " lost"
+	}
+	return fmt.Sprintf("%s %d code %d", mode, frameSize(b), b&3)
+}
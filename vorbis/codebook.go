@@ -3,6 +3,7 @@ package vorbis
 import (
 	"fmt"
 	"math"
+	"unsafe"
 
 	"github.com/toy80/debug"
 )
@@ -17,6 +18,8 @@ type sCodeBook struct {
 	lookupType  uint8
 	valMin      float32
 	valDelta    float32
+	valMinFix   int64 // valMin in fixed-point, see fixCodebookBits
+	valDeltaFix int64
 	valBits     uint32
 	seqp        bool // seqp
 	numLookVals uint32
@@ -86,11 +89,17 @@ func (cb *sCodeBook) readConfig(vb *Vorbis) bool {
 	var buf [4]uint8
 	vb.pr.ReadBytes(buf[:3])
 	if buf[0] != 0x42 || buf[1] != 0x43 || buf[2] != 0x56 {
-		fmt.Println(fmt.Sprintf("corrupted codebook %d", cb.id))
-		return false
+		return vb.setupError("corrupted codebook %d", cb.id)
 	}
 	cb.codeDims = vb.pr.ReadBits(2 * 8)
 	numCodes := vb.pr.ReadBits(3 * 8)
+	if numCodes > uint32(vb.opts.MaxCodebookEntries) {
+		return vb.limitError("codebook %d has %d entries > %d", cb.id, numCodes, vb.opts.MaxCodebookEntries)
+	}
+	// code lengths, and the huffman tree has 2*numCodes nodes at most
+	if !vb.alloc(int64(numCodes) * (1 + 2*int64(unsafe.Sizeof(huffmanNode{})))) {
+		return false
+	}
 	cb.isOrdered = vb.pr.ReadBits(1) != 0
 	cb.codeLens = make([]uint8, numCodes)
 	if cb.isOrdered {
@@ -99,15 +108,14 @@ func (cb *sCodeBook) readConfig(vb *Vorbis) bool {
 		for curEntry < numCodes {
 			n := ilog(numCodes - curEntry)
 			number := vb.pr.ReadBits(uint32(n))
+			if number > numCodes-curEntry || curLen > 32 {
+				return vb.setupError("corrupted codebook %d: extra entries", cb.id)
+			}
 			for i := curEntry; i < curEntry+number; i++ {
 				cb.codeLens[i] = uint8(curLen)
 			}
 			curEntry += number
 			curLen++
-			if curEntry > numCodes {
-				fmt.Println("extra codebook entry")
-				return false
-			}
 		}
 	} else {
 		sparse := vb.pr.ReadBits(1) != 0
@@ -127,19 +135,33 @@ func (cb *sCodeBook) readConfig(vb *Vorbis) bool {
 	cb.lookupType = uint8(vb.pr.ReadBits(4))
 	if cb.lookupType > 0 {
 		if cb.lookupType > 2 {
-			fmt.Println("unsupported lookup type")
-			return false
+			return vb.setupError("codebook %d: unsupported lookup type %d", cb.id, cb.lookupType)
 		}
-		cb.valMin = float32Unpack(vb.pr.ReadBits(32))
-		cb.valDelta = float32Unpack(vb.pr.ReadBits(32))
+		if cb.codeDims == 0 {
+			return vb.setupError("corrupted codebook %d: zero dimensions", cb.id)
+		}
+		packedMin := vb.pr.ReadBits(32)
+		packedDelta := vb.pr.ReadBits(32)
+		cb.valMin = float32Unpack(packedMin)
+		cb.valDelta = float32Unpack(packedDelta)
+		cb.valMinFix = fixUnpack(packedMin, fixCodebookBits)
+		cb.valDeltaFix = fixUnpack(packedDelta, fixCodebookBits)
 		cb.valBits = vb.pr.ReadBits(4) + 1
 		cb.seqp = vb.pr.ReadBits(1) != 0
+		var numLookVals uint64
 		if cb.lookupType == 1 {
-			cb.numLookVals = lookup1Values(numCodes, cb.codeDims)
+			numLookVals = uint64(lookup1Values(numCodes, cb.codeDims))
 		} else {
 			debug.Assert(cb.lookupType == 2)
-			cb.numLookVals = numCodes * cb.codeDims
+			numLookVals = uint64(numCodes) * uint64(cb.codeDims)
+		}
+		if numLookVals == 0 {
+			return vb.setupError("corrupted codebook %d: empty lookup table", cb.id)
+		}
+		if numLookVals > 1<<32-1 || !vb.alloc(int64(numLookVals)) {
+			return vb.limitError("codebook %d has %d lookup values", cb.id, numLookVals)
 		}
+		cb.numLookVals = uint32(numLookVals)
 		cb.muls = make([]uint8, cb.numLookVals) //(uint8*) malloc(sizeof(uint8) * cb.numLookVals);
 		for i := uint32(0); i < cb.numLookVals; i++ {
 			cb.muls[i] = (uint8)(vb.pr.ReadBits(cb.valBits) & 0xff)
@@ -147,8 +169,7 @@ func (cb *sCodeBook) readConfig(vb *Vorbis) bool {
 	}
 	//fmt.Printf("%+v\n", *cb)
 	if !cb.constructHuffman() {
-		fmt.Println("corrupped huffman book")
-		return false
+		return vb.setupError("corrupted codebook %d: huffman tree", cb.id)
 	}
 
 	return true
@@ -167,6 +188,9 @@ func (cb *sCodeBook) decodeVector(r *Vorbis, _vector []float32) bool {
 	//fmt.Printf("decodeVector: cb.lookupType=%d\n", cb.lookupType)
 	debug.Assert(cb.lookupType == 1 || cb.lookupType == 2)
 	lookOff := cb.decode(r)
+	if lookOff == invalidSymbol {
+		return false
+	}
 	sz := uint32(len(_vector))
 	if cb.codeDims < sz {
 		sz = cb.codeDims
@@ -194,7 +218,6 @@ func (cb *sCodeBook) decodeVector(r *Vorbis, _vector []float32) bool {
 			mulsOff++
 		}
 	default:
-		fmt.Println("unkown vector lookup type")
 		return false
 	}
 	return true
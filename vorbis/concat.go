@@ -0,0 +1,111 @@
+package vorbis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/toy80/audio/ogg"
+)
+
+// Concat joins the ogg vorbis streams of srcs into dst, without decoding and re-encoding.
+// the adjacent streams with the same setup header, channels, frame rate and block sizes
+// are merged into one logical stream with the comments of the first one. the blocks at
+// the joints overlap like the blocks within a stream, so the padding after the end of
+// each stream and before the beginning of next one is decoded, up to a block at each
+// joint. only the leading frames of the first stream and the trailing frames of the last
+// one are trimmed, the granules count the rest, so every decoder plays the same frames. the streams with another
+// setup are chained, each chained stream gets a unique serial number. the sources are
+// read into memory.
+func Concat(dst io.Writer, srcs ...io.Reader) error {
+	if len(srcs) == 0 {
+		return errors.New("vorbis: no stream to concat")
+	}
+	streams := make([]*sStream, len(srcs))
+	for i, r := range srcs {
+		s, err := scanStream(r, true)
+		if err != nil {
+			return err
+		}
+		if len(s.audio) == 0 {
+			return fmt.Errorf("vorbis: stream %d has no audio packets", i)
+		}
+		streams[i] = s
+	}
+	used := make(map[uint32]bool, len(streams))
+	for i := 0; i < len(streams); {
+		j := i + 1
+		for j < len(streams) && canMerge(streams[i], streams[j]) {
+			j++
+		}
+		serial := streams[i].serial
+		for used[serial] {
+			serial++
+		}
+		used[serial] = true
+		if err := concatMerged(dst, serial, streams[i:j]); err != nil {
+			return err
+		}
+		i = j
+	}
+	return nil
+}
+
+// canMerge reports whether s can follow the logical stream begins with first
+func canMerge(first, s *sStream) bool {
+	return bytes.Equal(s.headers[2].Data, first.headers[2].Data) &&
+		s.setup.audioChannels == first.setup.audioChannels &&
+		s.setup.audioFrameRate == first.setup.audioFrameRate &&
+		s.setup.blockSize == first.setup.blockSize
+}
+
+// concatMerged writes the audio packets of streams as one logical stream, the granules
+// continue across the joints
+func concatMerged(dst io.Writer, serial uint32, streams []*sStream) error {
+	first, last := streams[0], streams[len(streams)-1]
+	blockSize := first.setup.blockSize
+	w := ogg.NewWriter(dst, serial)
+	if err := writeHeaders(w, first.headers); err != nil {
+		return err
+	}
+	var pos int64 // frame position after the packet
+	prev := -1    // block flag of previous packet
+	for _, s := range streams {
+		for k, p := range s.audio {
+			flag := int(s.flags[k])
+			if prev < 0 {
+				pos = s.ends[k] // the first stream keeps its leading trim
+			} else {
+				pos += int64(blockSize[prev]/4 + blockSize[flag]/4)
+			}
+			prev = flag
+			if s == last && k == len(s.audio)-1 {
+				break
+			}
+			if err := writeAudioPacket(w, p, pos); err != nil {
+				return err
+			}
+		}
+	}
+	p := last.audio[len(last.audio)-1]
+	if err := w.WritePacket(p.Data, pos-(last.ends[len(last.ends)-1]-last.total)); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// writeAudioPacket writes the packet ends at frame position pos, the page ends after it
+// if the packet ended a page in the source, so the leading trim of the first page is kept
+func writeAudioPacket(w *ogg.Writer, p ogg.Packet, pos int64) error {
+	if pos < 0 {
+		pos = -1 // the pre-roll before the leading trim
+	}
+	if err := w.WritePacket(p.Data, pos); err != nil {
+		return err
+	}
+	if p.Granule >= 0 {
+		return w.Flush()
+	}
+	return nil
+}
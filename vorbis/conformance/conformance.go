@@ -0,0 +1,177 @@
+// Package conformance checks the vorbis decoder against the output of reference decoder.
+//
+// A corpus is a directory of .ogg files, each one has a reference PCM file with same base
+// name and the .f32 extension, i.e. "mono.ogg" and "mono.f32". The reference PCM is raw
+// little-endian float32 samples interleaved by channel, as decoded by a reference decoder
+// such as libvorbis without any conversion or dithering.
+//
+// The corpus should cover the edge cases of the specification, at least 1 channel and
+// 8 channels files, streams switch between short and long blocks, and streams use every
+// residue type (0, 1 and 2). The streams of testdata/gen are such ones, see
+// testdata/README.md.
+package conformance
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/toy80/audio/vorbis"
+	"github.com/toy80/audio/wav"
+)
+
+// DefaultTolerance is the max absolute error accepted by default, 1 LSB of 16 bits PCM
+const DefaultTolerance = 1.0 / 32768
+
+// RefExt is the file extension of reference PCM
+const RefExt = ".f32"
+
+// ErrMissingReference indicates there is no reference PCM for the ogg file
+var ErrMissingReference = errors.New("conformance: missing reference PCM")
+
+// Result of checking a single file
+type Result struct {
+	Name       string  // name of the ogg file
+	Channels   int     // channels of the stream
+	Samples    int64   // samples decoded, of all channels
+	RefSamples int64   // samples of the reference, of all channels
+	MaxError   float64 // max absolute error
+	RMSError   float64 // root mean square error
+	FirstDiff  int64   // index of the first sample exceed the tolerance, -1 if none
+	Err        error   // error occurs during decoding or reading reference
+}
+
+// Pass reports whether the decoded PCM matches the reference
+func (r *Result) Pass() bool {
+	return r.Err == nil && r.FirstDiff < 0
+}
+
+func (r *Result) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%s: %v", r.Name, r.Err)
+	}
+	status := "PASS"
+	diff := "-"
+	if !r.Pass() {
+		status = "FAIL"
+		if r.Channels > 0 {
+			diff = fmt.Sprintf("frame %d ch %d", r.FirstDiff/int64(r.Channels), r.FirstDiff%int64(r.Channels))
+		}
+	}
+	return fmt.Sprintf("%s: %s %dch %d/%d samples, max=%.3g rms=%.3g first diff=%s",
+		r.Name, status, r.Channels, r.Samples, r.RefSamples, r.MaxError, r.RMSError, diff)
+}
+
+// Compare the float32 PCM of decoded and reference, samples differ more than tolerance
+// are considered divergent, the samples beyond the shorter one are divergent too.
+func Compare(decoded, reference io.Reader, channels int, tolerance float64) (r Result) {
+	r.Channels = channels
+	r.FirstDiff = -1
+	rd := bufio.NewReader(decoded)
+	rr := bufio.NewReader(reference)
+	var sum float64
+	var n int64
+	for {
+		x, err1 := readSample(rd)
+		y, err2 := readSample(rr)
+		if err1 == nil {
+			r.Samples++
+		} else if err1 != io.EOF {
+			r.Err = err1
+			return
+		}
+		if err2 == nil {
+			r.RefSamples++
+		} else if err2 != io.EOF {
+			r.Err = err2
+			return
+		}
+		if err1 != nil || err2 != nil {
+			if err1 != err2 && r.FirstDiff < 0 {
+				r.FirstDiff = n // length miss-match
+			}
+			if err1 == nil || err2 == nil {
+				continue // count the remains
+			}
+			break
+		}
+		d := math.Abs(float64(x) - float64(y))
+		if d > r.MaxError {
+			r.MaxError = d
+		}
+		if d > tolerance && r.FirstDiff < 0 {
+			r.FirstDiff = n
+		}
+		sum += d * d
+		n++
+	}
+	if n != 0 {
+		r.RMSError = math.Sqrt(sum / float64(n))
+	}
+	return
+}
+
+func readSample(r io.ByteReader) (float32, error) {
+	var b [4]byte
+	for i := range b {
+		c, err := r.ReadByte()
+		if err != nil {
+			if i != 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		b[i] = c
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(b[:])), nil
+}
+
+// CheckFile decodes the ogg file, compares the output with reference PCM file
+func CheckFile(oggName, refName string, tolerance float64) (r Result) {
+	defer func() {
+		r.Name = oggName
+	}()
+	f, err := os.Open(oggName)
+	if err != nil {
+		r.Err = err
+		return
+	}
+	defer f.Close()
+	ref, err := os.Open(refName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = ErrMissingReference
+		}
+		r.Err = err
+		return
+	}
+	defer ref.Close()
+	vb, err := vorbis.New(f, wav.F32)
+	if err != nil {
+		r.Err = err
+		return
+	}
+	return Compare(vb, ref, vb.NumTracks(), tolerance)
+}
+
+// CheckDir checks all .ogg files in the directory, the results are sorted by name
+func CheckDir(dir string, tolerance float64) ([]Result, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.ogg"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	results := make([]Result, 0, len(names))
+	for _, name := range names {
+		ref := strings.TrimSuffix(name, filepath.Ext(name)) + RefExt
+		results = append(results, CheckFile(name, ref, tolerance))
+	}
+	return results, nil
+}
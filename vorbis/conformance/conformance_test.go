@@ -0,0 +1,69 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+)
+
+func pcm(x ...float32) *bytes.Reader {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, x)
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		a, b      []float32
+		firstDiff int64
+		maxError  float64
+	}{
+		{[]float32{0, 0.5, -0.5, 1}, []float32{0, 0.5, -0.5, 1}, -1, 0},
+		{[]float32{0, 0.5, -0.5, 1}, []float32{0, 0.5, -0.5, 0.75}, 3, 0.25},
+		{[]float32{0, 0.5, -0.5}, []float32{0, 0.5, -0.5, 1}, 3, 0},
+		{[]float32{0, 0.5, -0.5, 1, 0, 0}, []float32{0, 0.5, -0.5, 1}, 4, 0},
+	}
+	for i, c := range cases {
+		r := Compare(pcm(c.a...), pcm(c.b...), 2, DefaultTolerance)
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if r.FirstDiff != c.firstDiff || r.MaxError != c.maxError {
+			t.Fatalf("case %d: first diff %d, max error %g, want %d, %g",
+				i, r.FirstDiff, r.MaxError, c.firstDiff, c.maxError)
+		}
+		if r.Samples != int64(len(c.a)) || r.RefSamples != int64(len(c.b)) {
+			t.Fatalf("case %d: samples %d/%d, want %d/%d", i, r.Samples, r.RefSamples, len(c.a), len(c.b))
+		}
+		if r.Pass() != (c.firstDiff < 0) {
+			t.Fatalf("case %d: pass=%v", i, r.Pass())
+		}
+	}
+}
+
+// corpus is the files of testdata, see testdata/README.md
+var corpus = []string{"test.ogg"}
+
+func TestCorpus(t *testing.T) {
+	results, err := CheckDir("testdata", DefaultTolerance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for _, r := range results {
+		if !r.Pass() {
+			t.Error(r.String())
+		}
+		found[filepath.Base(r.Name)] = true
+	}
+	for _, name := range corpus {
+		if !found[name] {
+			t.Errorf("%s is missing", name)
+		}
+		delete(found, name)
+	}
+	for name := range found {
+		t.Errorf("%s is not in the corpus", name)
+	}
+}
@@ -0,0 +1,7 @@
+module gen
+
+go 1.16
+
+require github.com/toy80/audio v0.0.0
+
+replace github.com/toy80/audio => ../../../..
@@ -0,0 +1,155 @@
+package main
+
+// the states of libvorbis and libogg point to each other, they are allocated by C so that
+// no Go pointer is kept by C
+
+/*
+#cgo pkg-config: vorbisfile vorbisenc
+#include <stdlib.h>
+#include <vorbis/vorbisfile.h>
+#include <vorbis/vorbisenc.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+// channelsOf views the float** of libvorbis as the slices of each channel
+func channelsOf(p **C.float, channels, n int) [][]C.float {
+	ptrs := (*[256]*C.float)(unsafe.Pointer(p))[:channels:channels]
+	chs := make([][]C.float, channels)
+	for ch, x := range ptrs {
+		chs[ch] = (*[1 << 24]C.float)(unsafe.Pointer(x))[:n:n]
+	}
+	return chs
+}
+
+// decode writes the PCM of the ogg vorbis file name into ref, as decoded by vorbisfile
+func decode(name, ref string) error {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	vf := (*C.OggVorbis_File)(C.calloc(1, C.sizeof_OggVorbis_File))
+	defer C.free(unsafe.Pointer(vf))
+	if ret := C.ov_fopen(cname, vf); ret != 0 {
+		return fmt.Errorf("ov_fopen: %d", ret)
+	}
+	defer C.ov_clear(vf)
+
+	var out bytes.Buffer
+	var pcm **C.float
+	var section C.int
+	for {
+		n := int(C.ov_read_float(vf, &pcm, 4096, &section))
+		if n == 0 {
+			break
+		} else if n < 0 {
+			return fmt.Errorf("ov_read_float: %d", n)
+		}
+		channels := int(C.ov_info(vf, section).channels)
+		buf := make([]float32, 0, n*channels)
+		chs := channelsOf(pcm, channels, n)
+		for i := 0; i < n; i++ {
+			for ch := range chs {
+				buf = append(buf, float32(chs[ch][i]))
+			}
+		}
+		binary.Write(&out, binary.LittleEndian, buf)
+	}
+	return os.WriteFile(ref, out.Bytes(), 0666)
+}
+
+// encode writes the pcm interleaved by channel into the ogg vorbis file name, encoded by
+// libvorbis in VBR mode
+func encode(name string, pcm []float32, channels, rate int, quality float32) error {
+	vi := (*C.vorbis_info)(C.calloc(1, C.sizeof_vorbis_info))
+	vc := (*C.vorbis_comment)(C.calloc(1, C.sizeof_vorbis_comment))
+	vd := (*C.vorbis_dsp_state)(C.calloc(1, C.sizeof_vorbis_dsp_state))
+	vb := (*C.vorbis_block)(C.calloc(1, C.sizeof_vorbis_block))
+	ss := (*C.ogg_stream_state)(C.calloc(1, C.sizeof_ogg_stream_state))
+	og := (*C.ogg_page)(C.calloc(1, C.sizeof_ogg_page))
+	op := (*C.ogg_packet)(C.calloc(3, C.sizeof_ogg_packet))
+	for _, p := range []unsafe.Pointer{unsafe.Pointer(vi), unsafe.Pointer(vc), unsafe.Pointer(vd),
+		unsafe.Pointer(vb), unsafe.Pointer(ss), unsafe.Pointer(og), unsafe.Pointer(op)} {
+		defer C.free(p)
+	}
+
+	C.vorbis_info_init(vi)
+	defer C.vorbis_info_clear(vi)
+	if ret := C.vorbis_encode_init_vbr(vi, C.long(channels), C.long(rate), C.float(quality)); ret != 0 {
+		return fmt.Errorf("vorbis_encode_init_vbr: %d", ret)
+	}
+	C.vorbis_comment_init(vc)
+	defer C.vorbis_comment_clear(vc)
+	if ret := C.vorbis_analysis_init(vd, vi); ret != 0 {
+		return fmt.Errorf("vorbis_analysis_init: %d", ret)
+	}
+	defer C.vorbis_dsp_clear(vd)
+	C.vorbis_block_init(vd, vb)
+	defer C.vorbis_block_clear(vb)
+	C.ogg_stream_init(ss, 1)
+	defer C.ogg_stream_clear(ss)
+
+	var out bytes.Buffer
+	pages := func(flush bool) {
+		for {
+			var ret C.int
+			if flush {
+				ret = C.ogg_stream_flush(ss, og)
+			} else {
+				ret = C.ogg_stream_pageout(ss, og)
+			}
+			if ret == 0 {
+				return
+			}
+			out.Write(C.GoBytes(unsafe.Pointer(og.header), C.int(og.header_len)))
+			out.Write(C.GoBytes(unsafe.Pointer(og.body), C.int(og.body_len)))
+		}
+	}
+
+	headers := (*[3]C.ogg_packet)(unsafe.Pointer(op))
+	if ret := C.vorbis_analysis_headerout(vd, vc, &headers[0], &headers[1], &headers[2]); ret != 0 {
+		return fmt.Errorf("vorbis_analysis_headerout: %d", ret)
+	}
+	for i := range headers {
+		C.ogg_stream_packetin(ss, &headers[i])
+	}
+	pages(true) // the audio begins with a fresh page
+
+	const chunk = 1024
+	frames := len(pcm) / channels
+	for off := 0; ; off += chunk {
+		n := frames - off
+		if n > chunk {
+			n = chunk
+		}
+		if n > 0 {
+			chs := channelsOf(C.vorbis_analysis_buffer(vd, C.int(n)), channels, n)
+			for ch := range chs {
+				for i := range chs[ch] {
+					chs[ch][i] = C.float(pcm[(off+i)*channels+ch])
+				}
+			}
+			C.vorbis_analysis_wrote(vd, C.int(n))
+		} else {
+			C.vorbis_analysis_wrote(vd, 0) // end of stream
+		}
+		for C.vorbis_analysis_blockout(vd, vb) == 1 {
+			C.vorbis_analysis(vb, nil)
+			C.vorbis_bitrate_addblock(vb)
+			for C.vorbis_bitrate_flushpacket(vd, op) == 1 {
+				C.ogg_stream_packetin(ss, op)
+				pages(false)
+			}
+		}
+		if n <= 0 {
+			break
+		}
+	}
+	pages(true)
+	return os.WriteFile(name, out.Bytes(), 0666)
+}
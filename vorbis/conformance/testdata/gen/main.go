@@ -0,0 +1,77 @@
+// Command gen generates the streams of the conformance corpus, and the reference PCM of
+// every stream of the corpus, decoded by libvorbis. the streams are encoded by libvorbis
+// from synthetic audio, or synthesized packet by packet, see synth.go.
+//
+// libvorbis and its pkg-config files are required, i.e. the libvorbis-dev package.
+//
+//	cd vorbis/conformance/testdata/gen && go run .
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// encoded is the stream encoded by libvorbis
+type encoded struct {
+	name     string
+	channels int
+	rate     int
+	seconds  float64
+	quality  float32 // of VBR mode, -0.1 to 1
+}
+
+var encodedStreams = []encoded{
+	// coupled channels, the short and long blocks have their own modes and mappings
+	{name: "stereo", channels: 2, rate: 44100, seconds: 3, quality: 0.4},
+	{name: "surround", channels: 6, rate: 48000, seconds: 2, quality: 0.1},
+}
+
+// audio of the channels interleaved, tones of different pitches for each channel, and
+// the clicks force the encoder to switch to short blocks
+func (s *encoded) audio() []float32 {
+	frames := int(s.seconds * float64(s.rate))
+	pcm := make([]float32, frames*s.channels)
+	for i := 0; i < frames; i++ {
+		t := float64(i) / float64(s.rate)
+		for ch := 0; ch < s.channels; ch++ {
+			f := 220 * float64(ch+2)
+			x := 0.3*math.Sin(2*math.Pi*f*t) + 0.1*math.Sin(2*math.Pi*f*(1+t)*t*3)
+			if i%(s.rate/3) < 64 {
+				x += 0.5 * math.Sin(2*math.Pi*3000*t)
+			}
+			pcm[i*s.channels+ch] = float32(x)
+		}
+	}
+	return pcm
+}
+
+func main() {
+	for _, s := range encodedStreams {
+		name := filepath.Join("..", s.name+".ogg")
+		if err := encode(name, s.audio(), s.channels, s.rate, s.quality); err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+	}
+	for i, s := range streams {
+		name := filepath.Join("..", s.name+".ogg")
+		if err := os.WriteFile(name, s.generate(int64(i+1)), 0666); err != nil {
+			log.Fatal(err)
+		}
+	}
+	names, err := filepath.Glob(filepath.Join("..", "*.ogg"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, name := range names {
+		ref := strings.TrimSuffix(name, ".ogg") + ".f32"
+		if err := decode(name, ref); err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		fmt.Println(ref)
+	}
+}
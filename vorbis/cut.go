@@ -0,0 +1,266 @@
+package vorbis
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/toy80/audio/ogg"
+	"github.com/toy80/audio/wav"
+)
+
+// packetList is the packetSource of packets in memory
+type packetList []ogg.Packet
+
+func (l *packetList) Packet() (p ogg.Packet, ok bool) {
+	if len(*l) == 0 {
+		return p, false
+	}
+	p = (*l)[0]
+	*l = (*l)[1:]
+	return p, true
+}
+
+// demuxPackets reads the ogg stream of r, and calls f for each packet in order
+func demuxPackets(r io.Reader, onPage func(offset int64, h *ogg.PageHeader), f func(p ogg.Packet) error) error {
+	var dmx ogg.Demuxer
+	dmx.OnPage(onPage)
+	buf := make([]byte, 64<<10)
+	for !dmx.EndOfStream() {
+		n, err := r.Read(buf)
+		if n != 0 {
+			if _, err := dmx.Write(buf[:n]); err != nil {
+				return err
+			}
+			for {
+				p, ok := dmx.Packet()
+				if !ok {
+					break
+				}
+				if err := f(p); err != nil {
+					return err
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseSetup parses the header packets into a decoder without buffers, for the stream info
+func parseSetup(headers []ogg.Packet) (*Vorbis, error) {
+	list := packetList(headers)
+	pq := &packetQueue{dmx: &list}
+	pq.NextPacket()
+	vb, err := newDecoder(pq, wav.I16, nil)
+	if err != nil {
+		return nil, err
+	}
+	vb.releaseBuffers()
+	vb.pr = nil
+	return vb, nil
+}
+
+// blockFlag reports the block flag of the audio packet, -1 if it is not an audio packet
+func (vb *Vorbis) blockFlag(p []byte) int {
+	if len(p) == 0 || p[0]&1 != 0 {
+		return -1
+	}
+	// numModes <= 64, the mode number is within the first byte
+	mode := uint32(p[0]>>1) & (1<<uint(ilog(vb.numModes-1)) - 1)
+	if mode >= vb.numModes {
+		return -1
+	}
+	return int(vb.modes[mode].blockflag)
+}
+
+// sStream is the audio packets of an ogg vorbis stream, and their frame positions
+type sStream struct {
+	serial  uint32
+	setup   *Vorbis
+	headers []ogg.Packet
+	audio   []ogg.Packet // the audio packets, only if they are kept
+	flags   []int8       // block flag of audio packets
+	ends    []int64      // frame position after each audio packet
+	total   int64        // the granule of the last page
+}
+
+// scanStream reads the ogg vorbis stream, and locates the audio packets. the frame
+// positions are counted from the packets, and synced to the granules which are not on
+// the last page like the decoder does. if keep is true, the audio packets are kept in
+// memory.
+func scanStream(r io.Reader, keep bool) (*sStream, error) {
+	s := &sStream{total: -1}
+	var anchored bool
+	onPage := func(_ int64, h *ogg.PageHeader) {
+		s.serial = h.Stream
+	}
+	err := demuxPackets(r, onPage, func(p ogg.Packet) error {
+		if s.setup == nil {
+			s.headers = append(s.headers, p)
+			if len(s.headers) == numHeaderPackets {
+				var err error
+				s.setup, err = parseSetup(s.headers)
+				return err
+			}
+			return nil
+		}
+		flag := s.setup.blockFlag(p.Data)
+		if flag < 0 {
+			return nil
+		}
+		k := len(s.flags)
+		var n int64
+		if k > 0 {
+			n = int64(s.setup.blockSize[s.flags[k-1]]/4 + s.setup.blockSize[flag]/4)
+			n += s.ends[k-1]
+		}
+		if p.Granule >= 0 && !p.Last {
+			if !anchored {
+				// the positions counted so far are relative to the first granule
+				for j := range s.ends {
+					s.ends[j] += p.Granule - n
+				}
+				anchored = true
+			}
+			n = p.Granule
+		}
+		s.flags = append(s.flags, int8(flag))
+		s.ends = append(s.ends, n)
+		if keep {
+			s.audio = append(s.audio, p)
+		}
+		if p.Granule >= 0 && p.Last {
+			s.total = p.Granule
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.setup == nil {
+		return nil, errors.New("vorbis: missing headers")
+	}
+	if s.total < 0 && len(s.ends) > 0 {
+		s.total = s.ends[len(s.ends)-1]
+	}
+	return s, nil
+}
+
+// writeHeaders writes the header packets, the ident header has its own page, and the
+// audio begins with a fresh page
+func writeHeaders(w *ogg.Writer, headers []ogg.Packet) error {
+	for i, p := range headers {
+		if err := w.WritePacket(p.Data, 0); err != nil {
+			return err
+		}
+		if i == 0 || i == len(headers)-1 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Cut copies the frames [start, end) of the ogg vorbis stream src into dst, without
+// decoding and re-encoding. the whole packets cover the range are copied, the granule
+// positions are rewritten, so the decoders trim the frames beyond the range at both ends.
+// the pages are rebuilt with the same serial number. src is read twice, from the current
+// position and from the beginning.
+func Cut(dst io.Writer, src io.ReadSeeker, start, end int64) error {
+	begin, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	// pass 1, the setup and the frame positions of audio packets
+	s, err := scanStream(src, false)
+	if err != nil {
+		return err
+	}
+	setup, ends, total := s.setup, s.ends, s.total
+	if start < 0 || end > total || start >= end {
+		return fmt.Errorf("vorbis: cut range [%d, %d) out of stream [0, %d)", start, end, total)
+	}
+
+	// packet q contains the start frame, p is the pre-roll, and r contains the end frame
+	q := 1
+	for q < len(ends) && ends[q] <= start {
+		q++
+	}
+	if q >= len(ends) {
+		return fmt.Errorf("vorbis: cut range [%d, %d) out of audio packets", start, end)
+	}
+	p := q - 1
+	r := q
+	for r+1 < len(ends) && ends[r] < end {
+		r++
+	}
+	lead := start - ends[p]
+	if lead > 0 && r == q {
+		return fmt.Errorf("vorbis: cut range [%d, %d) is within a packet", start, end)
+	}
+
+	// pass 2, copy the packets
+	if _, err = src.Seek(begin, io.SeekStart); err != nil {
+		return err
+	}
+	w := ogg.NewWriter(dst, s.serial)
+	numHeaders, k := 0, -1
+	errDone := errors.New("done")
+	err = demuxPackets(src, nil, func(pkt ogg.Packet) error {
+		if numHeaders < numHeaderPackets {
+			numHeaders++
+			if numHeaders == numHeaderPackets {
+				return writeHeaders(w, s.headers)
+			}
+			return nil
+		}
+		if setup.blockFlag(pkt.Data) < 0 {
+			return nil
+		}
+		k++
+		switch {
+		case k < p:
+			return nil
+		case k == p:
+			if lead == 0 {
+				// the granule of the first page is 0, no frames to trim at the beginning
+				if err := w.WritePacket(pkt.Data, 0); err != nil {
+					return err
+				}
+				return w.Flush()
+			}
+			return w.WritePacket(pkt.Data, -1)
+		case k < r:
+			if err := writeAudioPacket(w, pkt, ends[k]-start); err != nil {
+				return err
+			}
+			if k == q && lead > 0 {
+				// the first page ends with packet q, its granule is less than the frames
+				// decoded, so the leading frames are trimmed
+				return w.Flush()
+			}
+			return nil
+		default:
+			if err := w.WritePacket(pkt.Data, end-start); err != nil {
+				return err
+			}
+			if err := w.Close(); err != nil {
+				return err
+			}
+			return errDone
+		}
+	})
+	if err == errDone {
+		return nil
+	} else if err == nil {
+		err = io.ErrUnexpectedEOF // the stream changed between the passes
+	}
+	return err
+}
@@ -13,10 +13,12 @@ type sChannelBuf struct {
 	floor1Y     [9*32 + 2]int
 	sizeFloor1Y int
 	floorUnused bool
-	floor       [4096]float32
-	residue     []float32        // point to sChannelBuf.audio, swap for each packet
-	audio       [2][4096]float32 // two buffers is for overlap
-	pcm         [4096]float32    // final overlapped audio
+	noResidue   bool         // the floor is unused, and so is the coupled channel
+	floor       []float32    // blockSize[1]/2
+	residue     []float32    // point to sChannelBuf.audio, swap for each packet
+	audio       [2][]float32 // two buffers is for overlap, blockSize[1] each
+	pcm         []float32    // final overlapped audio, blockSize[1]/2
+	fix         *sChannelFix // buffers of fixed-point decoding, nil if not used
 }
 
 type sMapping struct {
@@ -33,8 +35,7 @@ func (mp *sMapping) readConfig(vb *Vorbis) bool {
 	typ := vb.pr.ReadBits(16)
 	debug.Println("  read mapping config, type=" + fmt.Sprint(typ))
 	if typ != 0 {
-		fmt.Println("unsupported mapping type")
-		return false
+		return vb.setupError("unsupported mapping type %d", typ)
 	}
 	// i
 	if vb.pr.ReadBits(1) != 0 {
@@ -56,8 +57,7 @@ func (mp *sMapping) readConfig(vb *Vorbis) bool {
 			if mp.magnitude[j] == mp.angle[j] ||
 				mp.magnitude[j] >= uint32(vb.audioChannels) ||
 				mp.angle[j] >= uint32(vb.audioChannels) {
-				fmt.Println("corrupted mapping info")
-				return false
+				return vb.setupError("corrupted mapping: coupling of channels %d and %d", mp.magnitude[j], mp.angle[j])
 			}
 		}
 	} else {
@@ -67,8 +67,7 @@ func (mp *sMapping) readConfig(vb *Vorbis) bool {
 
 	// iii
 	if vb.pr.ReadBits(2) != 0 {
-		fmt.Println("corrupted mapping info")
-		return false
+		return vb.setupError("corrupted mapping: reserved bits")
 	}
 
 	// iv
@@ -78,8 +77,7 @@ func (mp *sMapping) readConfig(vb *Vorbis) bool {
 			mp.mux[j] = uint8(vb.pr.ReadBits(4))
 			// B
 			if mp.mux[j] > mp.submaps-1 {
-				fmt.Println("corrupted mapping info")
-				return false
+				return vb.setupError("corrupted mapping: mux %d of %d submaps", mp.mux[j], mp.submaps)
 			}
 		}
 	}
@@ -91,8 +89,7 @@ func (mp *sMapping) readConfig(vb *Vorbis) bool {
 		mp.submapResidue[j] = uint8(vb.pr.ReadBits(8))
 		if mp.submapFloor[j] >= uint8(vb.numFloors) ||
 			mp.submapResidue[j] >= uint8(vb.numResidues) {
-			fmt.Println("corrupted mapping info")
-			return false
+			return vb.setupError("corrupted mapping: floor %d, residue %d", mp.submapFloor[j], mp.submapResidue[j])
 		}
 	}
 
@@ -119,8 +116,7 @@ func (m *sMode) readConfig(vb *Vorbis) bool {
 	if m.windowtype != 0 ||
 		m.transformtype != 0 ||
 		uint32(m.mapping) >= vb.numMappings {
-		fmt.Println("unsupported modes or corrupted")
-		return false
+		return vb.setupError("unsupported or corrupted mode")
 	}
 	return true
 }
@@ -136,6 +132,7 @@ type sOverlap struct {
 	//       +====+     +=====+  |
 	//       |<------- w1 ------>|
 	s      []float32 // slope
+	si     []int32   // slope in Q30, for fixed-point decoding
 	sw     int       // slope width
 	a0     int
 	w0     int
@@ -162,7 +159,7 @@ func (ov *sOverlap) add(_o []float32, _l []float32, _r []float32) {
 }
 
 func (vb *Vorbis) setOutputFormat(t wav.Type) error {
-	if t != wav.I16 && t != wav.U8 && t != wav.F32 {
+	if t.Bits() == 0 {
 		return fmt.Errorf("vorbis: unsupported target PCM format %s", t)
 	}
 	vb.outTypeSize = t.Bits() / 8
@@ -172,11 +169,11 @@ func (vb *Vorbis) setOutputFormat(t wav.Type) error {
 
 // standard PCM uint8 zero at 128
 func (vb *Vorbis) outputPCMUint8(pcmCount int) {
-	vb.outBuf = vb.outBufRes[:pcmCount*int(vb.audioChannels)]
+	vb.outBuf = vb.outBufRes[:pcmCount*vb.outChannels]
 	k := 0
 	for i := 0; i < pcmCount; i++ {
-		for ch := 0; ch < int(vb.audioChannels); ch++ {
-			vb.outBuf[k] = byte(vb.chnBufs[ch].pcm[i]*127 + 128)
+		for ch := 0; ch < vb.outChannels; ch++ {
+			vb.outBuf[k] = byte(vb.outPCM[ch][i]*127 + 128)
 			k++
 		}
 	}
@@ -184,11 +181,11 @@ func (vb *Vorbis) outputPCMUint8(pcmCount int) {
 
 // standard PCM signed int16
 func (vb *Vorbis) outputPCMInt16(pcmCount int) {
-	vb.outBuf = vb.outBufRes[:2*pcmCount*int(vb.audioChannels)]
+	vb.outBuf = vb.outBufRes[:2*pcmCount*vb.outChannels]
 	k := 0
 	for i := 0; i < pcmCount; i++ {
-		for ch := 0; ch < int(vb.audioChannels); ch++ {
-			x := uint16(int16(vb.chnBufs[ch].pcm[i] * 32767))
+		for ch := 0; ch < vb.outChannels; ch++ {
+			x := uint16(int16(vb.outPCM[ch][i] * 32767))
 			vb.outBuf[k] = byte(x)
 			vb.outBuf[k+1] = byte(x >> 8)
 			k += 2
@@ -198,11 +195,11 @@ func (vb *Vorbis) outputPCMInt16(pcmCount int) {
 
 // standard PCM float32
 func (vb *Vorbis) outputPCMFloat(pcmCount int) {
-	vb.outBuf = vb.outBufRes[:4*pcmCount*int(vb.audioChannels)]
+	vb.outBuf = vb.outBufRes[:4*pcmCount*vb.outChannels]
 	k := 0
 	for i := 0; i < pcmCount; i++ {
-		for ch := 0; ch < int(vb.audioChannels); ch++ {
-			x := math.Float32bits(vb.chnBufs[ch].pcm[i])
+		for ch := 0; ch < vb.outChannels; ch++ {
+			x := math.Float32bits(vb.outPCM[ch][i])
 			vb.outBuf[k] = byte(x)
 			vb.outBuf[k+1] = byte(x >> 8)
 			vb.outBuf[k+2] = byte(x >> 16)
@@ -212,6 +209,63 @@ func (vb *Vorbis) outputPCMFloat(pcmCount int) {
 	}
 }
 
+// standard PCM signed int24, clamped
+func (vb *Vorbis) outputPCMInt24(pcmCount int) {
+	vb.outBuf = vb.outBufRes[:3*pcmCount*vb.outChannels]
+	k := 0
+	for i := 0; i < pcmCount; i++ {
+		for ch := 0; ch < vb.outChannels; ch++ {
+			x := uint32(clampInt(float64(vb.outPCM[ch][i])*(1<<23), 1<<23))
+			vb.outBuf[k] = byte(x)
+			vb.outBuf[k+1] = byte(x >> 8)
+			vb.outBuf[k+2] = byte(x >> 16)
+			k += 3
+		}
+	}
+}
+
+// standard PCM signed int32, clamped
+func (vb *Vorbis) outputPCMInt32(pcmCount int) {
+	vb.outBuf = vb.outBufRes[:4*pcmCount*vb.outChannels]
+	k := 0
+	for i := 0; i < pcmCount; i++ {
+		for ch := 0; ch < vb.outChannels; ch++ {
+			x := uint32(clampInt(float64(vb.outPCM[ch][i])*(1<<31), 1<<31))
+			vb.outBuf[k] = byte(x)
+			vb.outBuf[k+1] = byte(x >> 8)
+			vb.outBuf[k+2] = byte(x >> 16)
+			vb.outBuf[k+3] = byte(x >> 24)
+			k += 4
+		}
+	}
+}
+
+// standard PCM float64
+func (vb *Vorbis) outputPCMFloat64(pcmCount int) {
+	vb.outBuf = vb.outBufRes[:8*pcmCount*vb.outChannels]
+	k := 0
+	for i := 0; i < pcmCount; i++ {
+		for ch := 0; ch < vb.outChannels; ch++ {
+			x := math.Float64bits(float64(vb.outPCM[ch][i]))
+			for j := 0; j < 8; j++ {
+				vb.outBuf[k+j] = byte(x >> (8 * j))
+			}
+			k += 8
+		}
+	}
+}
+
+// clampInt rounds x to integer in range [-limit, limit-1]
+func clampInt(x float64, limit int64) int64 {
+	v := int64(math.Round(x))
+	if v >= limit {
+		return limit - 1
+	} else if v < -limit {
+		return -limit
+	}
+	return v
+}
+
 func (vb *Vorbis) output(buf []byte) (n int, err error) {
 	for len(buf) != 0 {
 
@@ -229,22 +283,71 @@ func (vb *Vorbis) output(buf []byte) (n int, err error) {
 			}
 		}
 
-		if vb.pr.NextPacket() != nil {
-			err = io.EOF
+		if err = vb.decodePacket(); err != nil {
 			break
 		}
+	}
+	return
+}
+
+// propagateNonzero marks the channels without residue, the residues of coupled channels
+// are decoded if either one is used
+func (vb *Vorbis) propagateNonzero(mapping *sMapping) {
+	for ch := uint8(0); ch < vb.audioChannels; ch++ {
+		vb.chnBufs[ch].noResidue = vb.chnBufs[ch].floorUnused
+	}
+	for i := uint32(0); i < mapping.couplingSteps; i++ {
+		mag, ang := &vb.chnBufs[mapping.magnitude[i]], &vb.chnBufs[mapping.angle[i]]
+		if !mag.noResidue || !ang.noResidue {
+			mag.noResidue = false
+			ang.noResidue = false
+		}
+	}
+}
 
+// submapChannels collects the channels of submap into bufs in order, and points their
+// residue vectors to the buffers of current packet. it returns the number of channels.
+func (vb *Vorbis) submapChannels(mapping *sMapping, submap uint8, bufs []*sChannelBuf) (n uint32) {
+	for j := uint8(0); j < vb.audioChannels; j++ {
+		// a)
+		if mapping.mux[j] == submap {
+			// i
+			chnbuf := &vb.chnBufs[j]
+			bufs[n] = chnbuf
+			chnbuf.residue = chnbuf.audio[vb.idxAutoPacket&1][:]
+			if chnbuf.fix != nil {
+				chnbuf.fix.residue = chnbuf.fix.audio[vb.idxAutoPacket&1][:]
+			}
+			// ii
+			n++
+		}
+	}
+	return n
+}
+
+// decodePacket read next audio packet, decode it into outBuf.
+// the outBuf is empty after decoded the first packet.
+func (vb *Vorbis) decodePacket() error {
+	for {
+		if vb.nextPacket() != nil {
+			return io.EOF
+		}
 		// 4.3.1 packet type, mode and window decode
 		// 1
 		packetType := vb.pr.ReadBits(1)
 		if packetType != 0 {
-			fmt.Println("    skip non-audio packet. type = " + fmt.Sprint(packetType))
-			continue
+			continue // not an audio packet
+		}
+		if vb.audioOffset < 0 {
+			vb.markAudioOffset()
 		}
 
 		// 2
 		bits := uint32(ilog(vb.numModes - 1))
 		modeNumber := vb.pr.ReadBits(bits)
+		if modeNumber >= vb.numModes {
+			return &DecodeError{Packet: vb.packetNo, Err: fmt.Errorf("mode number %d >= %d", modeNumber, vb.numModes)}
+		}
 		// 3
 		mode := &vb.modes[modeNumber]
 		curWindowFlag := mode.blockflag
@@ -267,32 +370,14 @@ func (vb *Vorbis) output(buf []byte) (n int, err error) {
 			vb.floors[floorNum].decode(vb, chnbuf, int(halfBlockSize))
 		}
 
-		for i := uint32(0); i < mapping.couplingSteps; i++ {
-			if vb.chnBufs[mapping.magnitude[i]].floorUnused ||
-				vb.chnBufs[mapping.angle[i]].floorUnused {
-				vb.chnBufs[mapping.magnitude[i]].floorUnused = true
-				vb.chnBufs[mapping.angle[i]].floorUnused = true
-			}
-		}
+		// 4.3.3 nonzero vector propagate
+		vb.propagateNonzero(mapping)
 
 		// 4.3.4 residue decode
 		var bufChOrder [maxChannels]*sChannelBuf
 		for i := uint8(0); i < mapping.submaps; i++ {
-			// 1
-			ch := uint32(0)
-			// 2
-			for j := uint8(0); j < vb.audioChannels; j++ {
-				// a)
-				submapNum := mapping.mux[i]
-				if submapNum == i {
-					// i
-					chnbuf := &vb.chnBufs[j]
-					bufChOrder[ch] = chnbuf
-					chnbuf.residue = chnbuf.audio[vb.idxAutoPacket&1][:]
-					// ii
-					ch++
-				}
-			}
+			// 1, 2
+			ch := vb.submapChannels(mapping, i, bufChOrder[:])
 			// 3
 			residueNum := mapping.submapResidue[i]
 			// 4
@@ -305,10 +390,19 @@ func (vb *Vorbis) output(buf []byte) (n int, err error) {
 			}
 		}
 
+		bitsRead := -1
+		if bc, ok := vb.pr.(bitCounter); ok && vb.opts.Tracer != nil {
+			bitsRead = bc.BitsRead()
+		}
+
 		// 4.3.5 inverse coupling
 		for i := int(mapping.couplingSteps) - 1; i >= 0; i-- {
 			magVecIdx := mapping.magnitude[i]
 			angVecIdx := mapping.angle[i]
+			if vb.opts.FixedPoint {
+				inverseCouplingFixed(vb.chnBufs[magVecIdx].fix.residue, vb.chnBufs[angVecIdx].fix.residue, halfBlockSize)
+				continue
+			}
 			mag := vb.chnBufs[magVecIdx].residue[:]
 			ang := vb.chnBufs[angVecIdx].residue[:]
 			inverseCoupling(mag, ang, halfBlockSize)
@@ -326,6 +420,15 @@ func (vb *Vorbis) output(buf []byte) (n int, err error) {
 		for ch := uint8(0); ch < vb.audioChannels; ch++ {
 			// 4.3.6 dot product
 			chnbuf := &vb.chnBufs[ch]
+			if fix := chnbuf.fix; fix != nil {
+				dotProductFixed(fix.residue, fix.floor[:], halfBlockSize)
+				vb.mdctFix[curWindowFlag].inverse(fix.residue)
+				if !isFirstFrame {
+					prevHalfAudio := fix.audio[1&^vb.idxAutoPacket][vb.prevBlockSize/2:]
+					ov.addFixed(fix.pcm[:], prevHalfAudio, fix.residue)
+				}
+				continue
+			}
 			dotProduct(chnbuf.residue[:], chnbuf.floor[:], halfBlockSize)
 			vb.mdct[curWindowFlag].inverse(chnbuf.residue[:])
 			if !isFirstFrame {
@@ -333,7 +436,16 @@ func (vb *Vorbis) output(buf []byte) (n int, err error) {
 				ov.add(chnbuf.pcm[:], prevHalfAudio, chnbuf.residue[:])
 			}
 		}
-		if !isFirstFrame {
+		if !isFirstFrame && vb.mix != nil {
+			if vb.opts.FixedPoint {
+				vb.downmixFixed(pcmCount)
+			} else {
+				vb.downmix(pcmCount)
+			}
+		}
+		if !isFirstFrame && vb.opts.FixedPoint {
+			vb.outputFixed(pcmCount)
+		} else if !isFirstFrame {
 			switch vb.outType {
 			case wav.F32:
 				vb.outputPCMFloat(pcmCount)
@@ -341,15 +453,26 @@ func (vb *Vorbis) output(buf []byte) (n int, err error) {
 				vb.outputPCMInt16(pcmCount)
 			case wav.U8:
 				vb.outputPCMUint8(pcmCount)
+			case wav.I24:
+				vb.outputPCMInt24(pcmCount)
+			case wav.I32:
+				vb.outputPCMInt32(pcmCount)
+			case wav.F64:
+				vb.outputPCMFloat64(pcmCount)
 			default:
-				panic(nil)
+				return &DecodeError{Packet: vb.packetNo, Err: fmt.Errorf("unsupported output format %s", vb.outType)}
 			}
 		}
 		vb.prevWindowFlag = int(curWindowFlag)
 		vb.idxAutoPacket++
 		vb.prevBlockSize = blockSize
+		vb.updateStat(curWindowFlag, pcmCount)
+		vb.updatePosition(pcmCount)
+		if vb.opts.Tracer != nil {
+			vb.tracePacket(modeNumber, bitsRead, int(halfBlockSize))
+		}
+		return nil
 	}
-	return
 }
 
 func dotProduct(_a []float32, _b []float32, _len uint32) {
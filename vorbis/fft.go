@@ -0,0 +1,96 @@
+package vorbis
+
+import (
+	"math"
+)
+
+// fft is a precomputed in-place forward complex FFT, the length must be power of 2.
+//
+// the input is permuted into bit-reversed order, then a radix-2 stage is applied
+// if the log2 of length is odd, the remain stages are fused into radix-4 butterflies.
+type fft struct {
+	n     int
+	swaps []int32   // index pairs for bit-reversal permutation
+	tw    []float32 // twiddles of radix-4 stages, (w^k, w^2k, w^3k) for each k, interleaved
+}
+
+func (f *fft) init(n int) {
+	f.n = n
+	ldn := uint32(ilog(uint32(n)) - 1)
+	f.swaps = f.swaps[:0]
+	for i := 0; i < n; i++ {
+		j := int(reverseBits(uint32(i), ldn))
+		if i < j {
+			f.swaps = append(f.swaps, int32(i), int32(j))
+		}
+	}
+
+	f.tw = f.tw[:0]
+	h := 1
+	if ldn&1 != 0 {
+		h = 2
+	}
+	for ; 4*h <= n; h *= 4 {
+		for k := 0; k < h; k++ {
+			for m := 1; m <= 3; m++ {
+				s, c := math.Sincos(-2 * math.Pi * float64(m*k) / float64(4*h))
+				f.tw = append(f.tw, float32(c), float32(s))
+			}
+		}
+	}
+}
+
+// transform the n complex numbers in x, which is interleaved real and imaginary parts
+func (f *fft) transform(x []float32) {
+	n := f.n
+	x = x[:2*n]
+	for i := 0; i < len(f.swaps); i += 2 {
+		a, b := 2*f.swaps[i], 2*f.swaps[i+1]
+		x[a], x[b] = x[b], x[a]
+		x[a+1], x[b+1] = x[b+1], x[a+1]
+	}
+
+	h := 1
+	if ilog(uint32(n))&1 == 0 {
+		// odd log2(n), a radix-2 stage without twiddle
+		for i := 0; i < 2*n; i += 4 {
+			ar, ai, br, bi := x[i], x[i+1], x[i+2], x[i+3]
+			x[i], x[i+1] = ar+br, ai+bi
+			x[i+2], x[i+3] = ar-br, ai-bi
+		}
+		h = 2
+	}
+
+	tw := f.tw
+	for ; 4*h <= n; h *= 4 {
+		for b := 0; b < n; b += 4 * h {
+			for k := 0; k < h; k++ {
+				w := tw[6*k : 6*k+6]
+				i0 := 2 * (b + k)
+				i1 := i0 + 2*h
+				i2 := i1 + 2*h
+				i3 := i2 + 2*h
+
+				// in bit-reversed order, x1 takes w^2k, x2 takes w^k
+				t0r, t0i := x[i0], x[i0+1]
+				t1r := x[i1]*w[2] - x[i1+1]*w[3]
+				t1i := x[i1]*w[3] + x[i1+1]*w[2]
+				t2r := x[i2]*w[0] - x[i2+1]*w[1]
+				t2i := x[i2]*w[1] + x[i2+1]*w[0]
+				t3r := x[i3]*w[4] - x[i3+1]*w[5]
+				t3i := x[i3]*w[5] + x[i3+1]*w[4]
+
+				ar, ai := t0r+t1r, t0i+t1i
+				br, bi := t0r-t1r, t0i-t1i
+				cr, ci := t2r+t3r, t2i+t3i
+				dr, di := t2r-t3r, t2i-t3i
+
+				x[i0], x[i0+1] = ar+cr, ai+ci
+				x[i2], x[i2+1] = ar-cr, ai-ci
+				x[i1], x[i1+1] = br+di, bi-dr // b - i*d
+				x[i3], x[i3+1] = br-di, bi+dr // b + i*d
+			}
+		}
+		tw = tw[6*h:]
+	}
+}
@@ -0,0 +1,544 @@
+package vorbis
+
+import (
+	"math"
+	"math/bits"
+
+	"github.com/toy80/audio/wav"
+)
+
+// fixed-point decoding, like the Tremor decoder of Xiph.org, uses integer arithmetic
+// only, so the output is bit-identical on every platform. the tables are also computed
+// with integers, neither the math package nor the FPU is involved.
+//
+// the binary points:
+//
+//	residue vector                Q12
+//	floor curve                   Q30
+//	spectrum, PCM                 Q24, 7 bits headroom
+//	twiddles and window slope     Q30
+const (
+	fixResidueBits  = 12
+	fixFloorBits    = 30
+	fixPCMBits      = 24
+	fixTwiddleBits  = 30
+	fixCodebookBits = 20 // for dequantization of codebook, before rounded into Q12
+)
+
+// 2*pi in Q60
+const twoPiQ60 = 7244019458077122842
+
+// mulQ60 multiplies unsigned Q60 numbers
+func mulQ60(a, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	return hi<<4 | lo>>60
+}
+
+// sin and cos of x in Q60 radians, 0 <= x <= pi/4, results are Q60
+func sincosQ60(x uint64) (s, c uint64) {
+	const one = 1 << 60
+	x2 := mulQ60(x, x)
+	// Taylor series, the terms are decreasing fast enough for x <= pi/4
+	var st, ct uint64 = x, one
+	s, c = x, one
+	for k := uint64(1); k <= 12; k++ {
+		st = mulQ60(st, x2) / ((2 * k) * (2*k + 1))
+		ct = mulQ60(ct, x2) / ((2*k - 1) * (2 * k))
+		if k&1 != 0 {
+			s -= st
+			c -= ct
+		} else {
+			s += st
+			c += ct
+		}
+	}
+	return s, c
+}
+
+// isin reports sin(2*pi*t/2^32) in Q30
+func isin(t uint32) int32 {
+	quadrant := t >> 30
+	r := t & (1<<30 - 1) // angle within the quadrant
+	if quadrant&1 != 0 {
+		r = 1<<30 - r // sin(pi-x) = sin(x)
+	}
+	var v uint64
+	if r <= 1<<29 {
+		hi, lo := bits.Mul64(uint64(r), twoPiQ60)
+		s, _ := sincosQ60(hi<<32 | lo>>32)
+		v = s
+	} else {
+		hi, lo := bits.Mul64(uint64(1<<30-r), twoPiQ60)
+		_, c := sincosQ60(hi<<32 | lo>>32)
+		v = c
+	}
+	y := int32((v + 1<<29) >> 30)
+	if quadrant >= 2 {
+		y = -y
+	}
+	return y
+}
+
+// icos reports cos(2*pi*t/2^32) in Q30
+func icos(t uint32) int32 {
+	return isin(t + 1<<30)
+}
+
+// fixFloat32 converts the float32 into fixed-point with q fraction bits, rounded,
+// using the IEEE 754 bits of x. it is used to convert the float32 constant tables.
+func fixFloat32(x float32, q int) int64 {
+	b := math.Float32bits(x)
+	e := int((b >> 23) & 0xff)
+	if e == 0 {
+		return 0 // zero or subnormal, too small anyway
+	}
+	m := int64(b&0x7fffff | 0x800000)
+	v := shiftRound(m, e-150+q)
+	if b&0x80000000 != 0 {
+		v = -v
+	}
+	return v
+}
+
+// fixUnpack converts the packed float of codebook into fixed-point with q fraction bits,
+// the magnitude saturates at 2^43.
+func fixUnpack(x uint32, q int) int64 {
+	m := int64(x & 0x1fffff)
+	e := int((x & 0x7fe00000) >> 21)
+	v := shiftRound(m, e-788+q)
+	if x&0x80000000 != 0 {
+		v = -v
+	}
+	return v
+}
+
+// shiftRound reports m*2^n, rounded, m is non-negative. it saturates at 2^43
+func shiftRound(m int64, n int) int64 {
+	const limit = 1 << 43
+	switch {
+	case m == 0:
+		return 0
+	case n >= 0:
+		if n >= 43 || m > limit>>uint(n) {
+			return limit
+		}
+		return m << uint(n)
+	case n > -63:
+		return (m + 1<<uint(-n-1)) >> uint(-n)
+	default:
+		return 0
+	}
+}
+
+func clampInt32(x int64) int32 {
+	if x > math.MaxInt32 {
+		return math.MaxInt32
+	} else if x < math.MinInt32 {
+		return math.MinInt32
+	}
+	return int32(x)
+}
+
+// multiplies a by the Q30 number w, rounded
+func mulQ30(a, w int32) int32 {
+	return int32((int64(a)*int64(w) + 1<<29) >> 30)
+}
+
+// floor1InverseDB in Q30, converted from the float32 table
+var floor1InverseDBFix [256]int32
+
+func init() {
+	for i, x := range floor1InverseDB {
+		floor1InverseDBFix[i] = int32(fixFloat32(x, fixFloorBits))
+	}
+}
+
+// buffers of fixed-point decoding, see sChannelBuf
+type sChannelFix struct {
+	floor   []int32
+	residue []int32
+	audio   [2][]int32
+	pcm     []int32
+}
+
+// initFixed computes the tables of fixed-point decoding
+func (vb *Vorbis) initFixed() {
+	for i := 0; i < 2; i++ {
+		vb.mdctFix[i].init(int(vb.blockSize[i]))
+
+		// see initOverlap
+		w := vb.blockSize[i]
+		hw := w >> 1
+		s := make([]int32, hw)
+		vb.slopeFix[i] = s
+		for n := uint32(0); n < hw; n++ {
+			a := int64(isin((2*n + 1) * uint32(1<<32/(4*uint64(w))))) // sin((n+0.5)/w*pi) in Q30
+			s[n] = isin(uint32((a * a) >> 30))                        // sin(pi/2*a*a)
+		}
+	}
+	for i := range vb.overlap {
+		for j := range vb.overlap[i] {
+			ov := &vb.overlap[i][j]
+			ov.si = vb.slopeFix[0]
+			if ov.sw == int(vb.blockSize[1]>>1) {
+				ov.si = vb.slopeFix[1]
+			}
+		}
+	}
+}
+
+// decodeVectorFixed is the fixed-point version of decodeVector, the values are Q12
+func (cb *sCodeBook) decodeVectorFixed(r *Vorbis, _vector []int32) bool {
+	lookOff := cb.decode(r)
+	if lookOff == invalidSymbol {
+		return false
+	}
+	sz := uint32(len(_vector))
+	if cb.codeDims < sz {
+		sz = cb.codeDims
+	}
+	const shift = fixCodebookBits - fixResidueBits
+	var last int64
+	switch cb.lookupType {
+	case 1:
+		idxDiv := uint32(1)
+		for i := uint32(0); i < sz; i++ {
+			mulsOff := (lookOff / idxDiv) % cb.numLookVals
+			v := int64(cb.muls[mulsOff])*cb.valDeltaFix + cb.valMinFix + last
+			_vector[i] = clampInt32((v + 1<<(shift-1)) >> shift)
+			if cb.seqp {
+				last = v
+			}
+			idxDiv *= cb.numLookVals
+		}
+	case 2:
+		mulsOff := lookOff * cb.codeDims
+		for i := uint32(0); i < sz; i++ {
+			v := int64(cb.muls[mulsOff])*cb.valDeltaFix + cb.valMinFix + last
+			_vector[i] = clampInt32((v + 1<<(shift-1)) >> shift)
+			if cb.seqp {
+				last = v
+			}
+			mulsOff++
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func inverseCouplingFixed(mag []int32, ang []int32, halfBlockSize uint32) {
+	for i := uint32(0); i < halfBlockSize; i++ {
+		m := mag[i]
+		a := ang[i]
+		if m > 0 {
+			if a > 0 {
+				ang[i] = m - a
+			} else {
+				ang[i] = m
+				mag[i] = m + a
+			}
+		} else {
+			if a > 0 {
+				ang[i] = m + a
+			} else {
+				ang[i] = m
+				mag[i] = m - a
+			}
+		}
+	}
+}
+
+// residue in Q12 multiplies floor in Q30, gives the spectrum in Q24
+func dotProductFixed(_a []int32, _b []int32, _len uint32) {
+	const shift = fixResidueBits + fixFloorBits - fixPCMBits
+	for i := uint32(0); i < _len; i++ {
+		_a[i] = clampInt32((int64(_a[i])*int64(_b[i]) + 1<<(shift-1)) >> shift)
+	}
+}
+
+func (ov *sOverlap) addFixed(_o []int32, _l []int32, _r []int32) {
+	copy(_o[:ov.a0], _l)
+	_o = _o[ov.a0:]
+	_l = _l[ov.a0:]
+	_r = _r[ov.a1:]
+	x0, x1 := ov.sw-1, 0
+	for i := 0; i < ov.sw; i++ {
+		_o[i] = int32((int64(_l[i])*int64(ov.si[x0]) + int64(_r[i])*int64(ov.si[x1]) + 1<<29) >> 30)
+		x0--
+		x1++
+	}
+	_o = _o[ov.sw:]
+	_r = _r[ov.sw:]
+	k := ov.w1 - (ov.a1 + ov.sw)
+	copy(_o[:k], _r)
+}
+
+func (vb *Vorbis) outputFixed(pcmCount int) {
+	channels := vb.outChannels
+	k := 0
+	switch vb.outType {
+	case wav.U8:
+		vb.outBuf = vb.outBufRes[:pcmCount*channels]
+		for i := 0; i < pcmCount; i++ {
+			for ch := 0; ch < channels; ch++ {
+				x := (vb.outPCMFix[ch][i] >> (fixPCMBits - 7)) + 128
+				if x < 0 {
+					x = 0
+				} else if x > 255 {
+					x = 255
+				}
+				vb.outBuf[k] = byte(x)
+				k++
+			}
+		}
+	case wav.I16:
+		vb.outBuf = vb.outBufRes[:2*pcmCount*channels]
+		for i := 0; i < pcmCount; i++ {
+			for ch := 0; ch < channels; ch++ {
+				x := (vb.outPCMFix[ch][i] + 1<<(fixPCMBits-16)) >> (fixPCMBits - 15)
+				if x > math.MaxInt16 {
+					x = math.MaxInt16
+				} else if x < math.MinInt16 {
+					x = math.MinInt16
+				}
+				vb.outBuf[k] = byte(x)
+				vb.outBuf[k+1] = byte(x >> 8)
+				k += 2
+			}
+		}
+	case wav.F32:
+		// int32 to float32 and scale by power of 2 are exact or correctly rounded
+		vb.outBuf = vb.outBufRes[:4*pcmCount*channels]
+		for i := 0; i < pcmCount; i++ {
+			for ch := 0; ch < channels; ch++ {
+				x := math.Float32bits(float32(vb.outPCMFix[ch][i]) * (1.0 / (1 << fixPCMBits)))
+				vb.outBuf[k] = byte(x)
+				vb.outBuf[k+1] = byte(x >> 8)
+				vb.outBuf[k+2] = byte(x >> 16)
+				vb.outBuf[k+3] = byte(x >> 24)
+				k += 4
+			}
+		}
+	case wav.I24, wav.I32:
+		// scale from fixPCMBits fraction bits to 23 or 31
+		bits := vb.outType.Bits()
+		limit := int64(1) << (bits - 1)
+		vb.outBuf = vb.outBufRes[:bits/8*pcmCount*channels]
+		for i := 0; i < pcmCount; i++ {
+			for ch := 0; ch < channels; ch++ {
+				x := int64(vb.outPCMFix[ch][i])
+				if bits-1 >= fixPCMBits {
+					x <<= uint(bits - 1 - fixPCMBits)
+				} else {
+					x = (x + 1<<(fixPCMBits-bits)) >> uint(fixPCMBits-bits+1)
+				}
+				if x >= limit {
+					x = limit - 1
+				} else if x < -limit {
+					x = -limit
+				}
+				for j := 0; j < bits/8; j++ {
+					vb.outBuf[k+j] = byte(x >> (8 * j))
+				}
+				k += bits / 8
+			}
+		}
+	case wav.F64:
+		vb.outBuf = vb.outBufRes[:8*pcmCount*channels]
+		for i := 0; i < pcmCount; i++ {
+			for ch := 0; ch < channels; ch++ {
+				x := math.Float64bits(float64(vb.outPCMFix[ch][i]) * (1.0 / (1 << fixPCMBits)))
+				for j := 0; j < 8; j++ {
+					vb.outBuf[k+j] = byte(x >> (8 * j))
+				}
+				k += 8
+			}
+		}
+	}
+}
+
+// sMDCTFix is the fixed-point version of MDCT, see MDCT.inverse for the algorithm
+type sMDCTFix struct {
+	N       int
+	N2      int
+	N4      int
+	fft     sFFTFix
+	twiddle []int32 // Q30
+	buf     []int32
+}
+
+func (m *sMDCTFix) init(n int) {
+	m.N = n
+	m.N2 = n / 2
+	m.N4 = n / 4
+	m.fft.init(m.N4)
+	// exp(-i*pi*(8k+1)/(4N)), the angle is (8k+1)/(8N) turns
+	step := uint32(1 << 32 / (8 * uint64(n)))
+	m.twiddle = make([]int32, m.N2)
+	for k := 0; k < m.N4; k++ {
+		t := uint32(8*k+1) * step
+		m.twiddle[2*k], m.twiddle[2*k+1] = icos(t), -isin(t)
+	}
+	m.buf = make([]int32, m.N2)
+}
+
+// inverse MDCT, the N/2 coefficients in x are transformed into N samples in place
+func (m *sMDCTFix) inverse(x []int32) {
+	n2, n4 := m.N2, m.N4
+	z, w := m.buf, m.twiddle
+
+	for k, k2 := 0, 0; k < n4; k, k2 = k+1, k2+2 {
+		re, im := int64(x[k2]), int64(x[n2-1-k2])
+		c, s := int64(w[k2]), int64(w[k2+1])
+		z[k2] = int32((re*c - im*s + 1<<29) >> 30)
+		z[k2+1] = int32((re*s + im*c + 1<<29) >> 30)
+	}
+
+	m.fft.transform(z)
+
+	n8 := n4 / 2
+	n34 := n2 + n4
+	for k, k2 := 0, 0; k < n4; k, k2 = k+1, k2+2 {
+		re, im := int64(z[k2]), int64(z[k2+1])
+		c, s := int64(w[k2]), int64(w[k2+1])
+		u0 := int32((re*c - im*s + 1<<29) >> 30)
+		u1 := -int32((re*s + im*c + 1<<29) >> 30)
+		j0, j1 := k2, n2-1-k2
+		x[n34-1-j0] = -u0
+		x[n34-1-j1] = -u1
+		if k < n8 {
+			x[j0+n34] = -u0
+			x[j1-n4] = u1
+		} else {
+			x[j0-n4] = u0
+			x[j1+n34] = -u1
+		}
+	}
+}
+
+// sFFTFix is the fixed-point version of fft
+type sFFTFix struct {
+	n     int
+	swaps []int32
+	tw    []int32 // Q30
+}
+
+func (f *sFFTFix) init(n int) {
+	f.n = n
+	ldn := uint32(ilog(uint32(n)) - 1)
+	f.swaps = f.swaps[:0]
+	for i := 0; i < n; i++ {
+		j := int(reverseBits(uint32(i), ldn))
+		if i < j {
+			f.swaps = append(f.swaps, int32(i), int32(j))
+		}
+	}
+
+	f.tw = f.tw[:0]
+	h := 1
+	if ldn&1 != 0 {
+		h = 2
+	}
+	for ; 4*h <= n; h *= 4 {
+		step := uint32(1 << 32 / (4 * uint64(h)))
+		for k := 0; k < h; k++ {
+			for m := 1; m <= 3; m++ {
+				t := uint32(m*k) * step // exp(-2*pi*i*m*k/(4h))
+				f.tw = append(f.tw, icos(t), -isin(t))
+			}
+		}
+	}
+}
+
+func (f *sFFTFix) transform(x []int32) {
+	n := f.n
+	x = x[:2*n]
+	for i := 0; i < len(f.swaps); i += 2 {
+		a, b := 2*f.swaps[i], 2*f.swaps[i+1]
+		x[a], x[b] = x[b], x[a]
+		x[a+1], x[b+1] = x[b+1], x[a+1]
+	}
+
+	h := 1
+	if ilog(uint32(n))&1 == 0 {
+		for i := 0; i < 2*n; i += 4 {
+			ar, ai, br, bi := x[i], x[i+1], x[i+2], x[i+3]
+			x[i], x[i+1] = ar+br, ai+bi
+			x[i+2], x[i+3] = ar-br, ai-bi
+		}
+		h = 2
+	}
+
+	// complex multiply by the Q30 twiddle, rounded
+	cmul := func(re, im int32, c, s int32) (int32, int32) {
+		r, i, c1, s1 := int64(re), int64(im), int64(c), int64(s)
+		return int32((r*c1 - i*s1 + 1<<29) >> 30), int32((r*s1 + i*c1 + 1<<29) >> 30)
+	}
+
+	tw := f.tw
+	for ; 4*h <= n; h *= 4 {
+		for b := 0; b < n; b += 4 * h {
+			for k := 0; k < h; k++ {
+				w := tw[6*k : 6*k+6]
+				i0 := 2 * (b + k)
+				i1 := i0 + 2*h
+				i2 := i1 + 2*h
+				i3 := i2 + 2*h
+
+				t0r, t0i := x[i0], x[i0+1]
+				t1r, t1i := cmul(x[i1], x[i1+1], w[2], w[3])
+				t2r, t2i := cmul(x[i2], x[i2+1], w[0], w[1])
+				t3r, t3i := cmul(x[i3], x[i3+1], w[4], w[5])
+
+				ar, ai := t0r+t1r, t0i+t1i
+				br, bi := t0r-t1r, t0i-t1i
+				cr, ci := t2r+t3r, t2i+t3i
+				dr, di := t2r-t3r, t2i-t3i
+
+				x[i0], x[i0+1] = ar+cr, ai+ci
+				x[i2], x[i2+1] = ar-cr, ai-ci
+				x[i1], x[i1+1] = br+di, bi-dr
+				x[i3], x[i3+1] = br-di, bi+dr
+			}
+		}
+		tw = tw[6*h:]
+	}
+}
+
+// decodePartiFixed is the fixed-point version of decodePartiFormat0 and decodePartiFormat1
+func (rs *sResidue) decodePartiFixed(vb *Vorbis, _vqbook *sCodeBook, v []int32, offset uint32, n uint32) bool {
+	if rs.typ == 0 {
+		return rs.decodePartiFormat0Fixed(vb, _vqbook, v, offset, n)
+	}
+	return rs.decodePartiFormat1Fixed(vb, _vqbook, v, offset, n)
+}
+
+func (rs *sResidue) decodePartiFormat0Fixed(vb *Vorbis, _vqbook *sCodeBook, v []int32, offset uint32, n uint32) bool {
+	var entTemp [64]int32
+	step := n / _vqbook.codeDims
+	for i := uint32(0); i < step; i++ {
+		if !_vqbook.decodeVectorFixed(vb, entTemp[:_vqbook.codeDims]) {
+			return false
+		}
+		for j := uint32(0); j < _vqbook.codeDims; j++ {
+			v[offset+i+j*step] += entTemp[j]
+		}
+	}
+	return true
+}
+
+func (rs *sResidue) decodePartiFormat1Fixed(vb *Vorbis, _vqbook *sCodeBook, v []int32, offset uint32, n uint32) bool {
+	var entTemp [64]int32
+	var i uint32
+	for i < n {
+		if !_vqbook.decodeVectorFixed(vb, entTemp[:_vqbook.codeDims]) {
+			return false
+		}
+		for j := uint32(0); j < _vqbook.codeDims && i < n; j++ {
+			v[offset+i] += entTemp[j]
+			i++
+		}
+	}
+	return true
+}
@@ -1,12 +1,6 @@
 package vorbis
 
-import (
-	"fmt"
-	"sort"
-	"unsafe"
-
-	"github.com/toy80/debug"
-)
+import "github.com/toy80/debug"
 
 var floor1InverseDB = [256]float32{
 	1.0649863e-07, 1.1341951e-07, 1.2079015e-07, 1.2863978e-07,
@@ -92,23 +86,28 @@ type sFloor struct {
 	subBooks      [17][8]int
 	multiplier    int
 	rangebits     uint8
-	sizeXList     uint32
-	xList         [34]int
+	xList         [maxFloor1Values]int
 	values        int
 }
 
-type f1asSlice []sF1AS
+// the spec limits floor1_values to 65
+const maxFloor1Values = 65
 
-func (p f1asSlice) Len() int           { return len(p) }
-func (p f1asSlice) Less(i, j int) bool { return p[i].x < p[j].x }
-func (p f1asSlice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+// sortF1AS sorts by x, insertion sort is fine for no more than 65 values, and
+// it doesn't allocate like sort.Sort does
+func sortF1AS(p []sF1AS) {
+	for i := 1; i < len(p); i++ {
+		for j := i; j > 0 && p[j].x < p[j-1].x; j-- {
+			p[j], p[j-1] = p[j-1], p[j]
+		}
+	}
+}
 
 func (fl *sFloor) readConfig(vb *Vorbis) bool {
 	debug.Println("  read floor config.")
 	fl.typ = vb.pr.ReadBits(16)
 	if fl.typ != 1 {
-		fmt.Println(fmt.Sprintf("unsupported floor type: %d", fl.typ))
-		return false
+		return vb.setupError("unsupported floor type %d", fl.typ)
 	}
 	fl.partitions = vb.pr.ReadBits(5)
 	fl.numClass = 0
@@ -125,8 +124,7 @@ func (fl *sFloor) readConfig(vb *Vorbis) bool {
 		if fl.classSubs[i] != 0 {
 			fl.classMasters[i] = uint8(vb.pr.ReadBits(8))
 			if uint32(fl.classMasters[i]) >= vb.numCodebooks {
-				fmt.Println("corrupted floor config: master books")
-				return false
+				return vb.setupError("corrupted floor: master book %d", fl.classMasters[i])
 			}
 		}
 		jj := 0x00000001 << fl.classSubs[i]
@@ -134,29 +132,34 @@ func (fl *sFloor) readConfig(vb *Vorbis) bool {
 		for j := 0; j < jj; j++ {
 			fl.subBooks[i][j] = int(vb.pr.ReadBits(8)) - 1
 			if fl.subBooks[i][j] >= int(vb.numCodebooks) {
-				fmt.Println("corrupted floor config: subclass books", fl.subBooks[i][j], vb.numCodebooks)
-				return false
+				return vb.setupError("corrupted floor: subclass book %d", fl.subBooks[i][j])
 			}
 		}
 	}
 
 	fl.multiplier = int(vb.pr.ReadBits(2) + 1)
 	fl.rangebits = uint8(vb.pr.ReadBits(4))
-	fl.sizeXList = fl.partitions + 2
 	fl.xList[0] = 0
 	fl.xList[1] = 0x0000001 << fl.rangebits
 	fl.values = 2
 	for i := uint32(0); i < fl.partitions; i++ {
 		numCurClass := fl.listPartClass[i]
 		for j := uint8(0); j < fl.classDims[numCurClass]; j++ {
-			if fl.values >= int(fl.sizeXList) {
-				fl.sizeXList += fl.partitions
-				debug.Assert(fl.values < (int)(unsafe.Sizeof(fl.xList)))
+			if fl.values >= maxFloor1Values {
+				return vb.setupError("corrupted floor: more than %d values", maxFloor1Values)
 			}
 			fl.xList[fl.values] = int(vb.pr.ReadBits(uint32(fl.rangebits)))
 			fl.values++
 		}
 	}
+	// the X list must be unique, or the curve computation divides by zero
+	for i := 1; i < fl.values; i++ {
+		for j := 0; j < i; j++ {
+			if fl.xList[i] == fl.xList[j] {
+				return vb.setupError("corrupted floor: duplicated X %d", fl.xList[i])
+			}
+		}
+	}
 	return true
 }
 
@@ -235,9 +238,16 @@ func renderLine(_x0 int, _y0 int, _x1 int, _y1 int, _v []int) {
 		abase = base
 	}
 	ady = ady - abase*adx
+	if x >= len(_v) {
+		return // the line is beyond the block, truncated
+	}
 	_v[x] = y
 
-	for x = _x0 + 1; x <= _x1-1; x++ {
+	end := _x1
+	if end > len(_v) {
+		end = len(_v)
+	}
+	for x = _x0 + 1; x < end; x++ {
 		err = err + ady
 		if err >= adx {
 			err = err - adx
@@ -254,10 +264,7 @@ func (fl *sFloor) decode(vb *Vorbis, _buf *sChannelBuf, halfBlockSize int) bool
 	debug.Assert(fl.typ == 1)
 	_buf.floorUnused = vb.pr.ReadBits(1) == 0
 	if _buf.floorUnused {
-		f := _buf.floor[:halfBlockSize]
-		for i := range f {
-			f[i] = 0
-		}
+		_buf.zeroFloor(halfBlockSize)
 		return true
 	}
 
@@ -279,7 +286,11 @@ func (fl *sFloor) decode(vb *Vorbis, _buf *sChannelBuf, halfBlockSize int) bool
 		// 11
 		if cbits != 0 {
 			cb := &vb.codebooks[fl.classMasters[cls]]
-			cval = int(cb.decode(vb))
+			sym := cb.decode(vb)
+			if sym == invalidSymbol {
+				return fl.decodeFailed(_buf, halfBlockSize)
+			}
+			cval = int(sym)
 		}
 
 		// 13
@@ -291,7 +302,11 @@ func (fl *sFloor) decode(vb *Vorbis, _buf *sChannelBuf, halfBlockSize int) bool
 			// 16
 			if book >= 0 {
 				// 17
-				_buf.floor1Y[j+offset] = int(vb.codebooks[book].decode(vb))
+				sym := vb.codebooks[book].decode(vb)
+				if sym == invalidSymbol {
+					return fl.decodeFailed(_buf, halfBlockSize)
+				}
+				_buf.floor1Y[j+offset] = int(sym)
 			} else {
 				// 18
 				_buf.floor1Y[j+offset] = 0
@@ -304,7 +319,7 @@ func (fl *sFloor) decode(vb *Vorbis, _buf *sChannelBuf, halfBlockSize int) bool
 
 	// 7.2.2 curve computation
 	// step 1: amplitude value synthesis
-	var f1as [34]sF1AS
+	var f1as [maxFloor1Values]sF1AS
 	f1as[0].flag = true
 	f1as[1].flag = true
 	f1as[0].y = _buf.floor1Y[0]
@@ -365,9 +380,9 @@ func (fl *sFloor) decode(vb *Vorbis, _buf *sChannelBuf, halfBlockSize int) bool
 	//29
 
 	// step 2, curve synthesis
-	var floorBuf [8192 + 1024]int // ?
+	floorBuf := vb.floorBuf
 
-	sort.Sort(f1asSlice(f1as[:fl.values]))
+	sortF1AS(f1as[:fl.values])
 	hx := 0
 	hy := 0
 	lx := 0
@@ -381,7 +396,7 @@ func (fl *sFloor) decode(vb *Vorbis, _buf *sChannelBuf, halfBlockSize int) bool
 			// 7
 			hx = f1as[i].x
 			// 8
-			renderLine(lx, ly, hx, hy, floorBuf[:])
+			renderLine(lx, ly, hx, hy, floorBuf[:halfBlockSize])
 			// 9
 			lx = hx
 			// 10
@@ -389,13 +404,52 @@ func (fl *sFloor) decode(vb *Vorbis, _buf *sChannelBuf, halfBlockSize int) bool
 		}
 	}
 	if hx < halfBlockSize {
-		renderLine(hx, hy, halfBlockSize, hy, floorBuf[:])
+		renderLine(hx, hy, halfBlockSize, hy, floorBuf[:halfBlockSize])
 	} // else if hx > halfBlockSize {
 	// 	// truncate
 	// }
+	if _buf.fix != nil {
+		for i := 0; i < halfBlockSize; i++ {
+			y := floorBuf[i]
+			if y < 0 {
+				y = 0
+			} else if y > 255 {
+				y = 255
+			}
+			_buf.fix.floor[i] = floor1InverseDBFix[y]
+		}
+		return true
+	}
 	for i := 0; i < halfBlockSize; i++ {
-		debug.Assert(floorBuf[i] >= 0 && floorBuf[i] < 256)
-		_buf.floor[i] = floor1InverseDB[floorBuf[i]]
+		// the Y values are in range for a sane stream, but never trust the input
+		y := floorBuf[i]
+		if y < 0 {
+			y = 0
+		} else if y > 255 {
+			y = 255
+		}
+		_buf.floor[i] = floor1InverseDB[y]
 	}
 	return true
 }
+
+// the packet is ended or corrupted while decoding floor, treat the channel unused
+func (fl *sFloor) decodeFailed(_buf *sChannelBuf, halfBlockSize int) bool {
+	_buf.floorUnused = true
+	_buf.zeroFloor(halfBlockSize)
+	return false
+}
+
+func (_buf *sChannelBuf) zeroFloor(halfBlockSize int) {
+	if _buf.fix != nil {
+		f := _buf.fix.floor[:halfBlockSize]
+		for i := range f {
+			f[i] = 0
+		}
+		return
+	}
+	f := _buf.floor[:halfBlockSize]
+	for i := range f {
+		f[i] = 0
+	}
+}
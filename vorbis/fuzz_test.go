@@ -0,0 +1,147 @@
+package vorbis
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/toy80/audio/ogg"
+	"github.com/toy80/audio/wav"
+)
+
+// sliceReader is a PacketReader of raw packets, without ogg framing
+type sliceReader struct {
+	packets [][]byte
+	cur     []byte
+	pos     int // bit position in cur
+	eop     bool
+}
+
+func newSliceReader(packets ...[]byte) *sliceReader {
+	r := &sliceReader{packets: packets}
+	r.NextPacket()
+	return r
+}
+
+func (r *sliceReader) NextPacket() error {
+	if len(r.packets) == 0 {
+		r.cur, r.eop = nil, true
+		return io.EOF
+	}
+	r.cur, r.packets = r.packets[0], r.packets[1:]
+	r.pos, r.eop = 0, false
+	return nil
+}
+
+func (r *sliceReader) ReadBits(bits uint32) (v uint32) {
+	for i := uint32(0); i < bits; i++ {
+		if r.pos >= 8*len(r.cur) {
+			r.eop = true
+			return v
+		}
+		v |= uint32(r.cur[r.pos>>3]>>uint(r.pos&7)&1) << i
+		r.pos++
+	}
+	return v
+}
+
+func (r *sliceReader) ReadBytes(p []byte) {
+	for i := range p {
+		p[i] = byte(r.ReadBits(8))
+	}
+}
+
+func (r *sliceReader) ReadString() string {
+	n := r.ReadBits(32)
+	var buf []byte
+	for i := uint32(0); i < n && !r.eop; i++ {
+		buf = append(buf, byte(r.ReadBits(8)))
+	}
+	return string(buf)
+}
+
+func (r *sliceReader) EndOfPacket() bool { return r.eop }
+
+// splitPackets extracts at most n packets from the ogg stream
+func splitPackets(tb testing.TB, data []byte, n int) (packets [][]byte) {
+	pr := new(ogg.Reader)
+	if err := pr.Init(bytes.NewReader(data)); err != nil {
+		tb.Fatal(err)
+	}
+	for len(packets) < n {
+		var p []byte
+		for {
+			c := byte(pr.ReadBits(8))
+			if pr.EndOfPacket() {
+				break
+			}
+			p = append(p, c)
+		}
+		packets = append(packets, p)
+		if pr.NextPacket() != nil {
+			break
+		}
+	}
+	return
+}
+
+func FuzzOggReader(f *testing.F) {
+	f.Add(oggfile1)
+	f.Add(oggfile1[:len(oggfile1)/2])
+	f.Add(oggfile1[:4096])
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pr := new(ogg.Reader)
+		if pr.Init(bytes.NewReader(data)) != nil {
+			return
+		}
+		var buf [64]byte
+		for i := 0; i < 1<<16; i++ {
+			pr.ReadBits(32)
+			pr.ReadBytes(buf[:])
+			pr.ReadString()
+			if pr.NextPacket() != nil {
+				break
+			}
+		}
+	})
+}
+
+func FuzzHeaders(f *testing.F) {
+	h := splitPackets(f, oggfile1, numHeaderPackets)
+	f.Add(h[0], h[1], h[2])
+	f.Add(h[0], h[1], h[2][:len(h[2])/2])
+	f.Fuzz(func(t *testing.T, ident, comments, setup []byte) {
+		vb := new(Vorbis)
+		vb.opts.normalize()
+		vb.pr = newSliceReader(ident, comments, setup)
+		if vb.parseVorbisHeaders() {
+			// the setup must be usable to decode
+			vb.setOutputFormat(wav.F32)
+			vb.audioOffset = -1
+			vb.mdct[0].init(int(vb.blockSize[0]))
+			vb.mdct[1].init(int(vb.blockSize[1]))
+			vb.initOverlap()
+			vb.initLayout()
+			vb.allocBuffers()
+			vb.releaseBuffers()
+		}
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	setup, err := New(bytes.NewReader(oggfile1), wav.F32)
+	if err != nil {
+		f.Fatal(err)
+	}
+	packets := splitPackets(f, oggfile1, 64)
+	for i := numHeaderPackets; i+1 < len(packets); i += 8 {
+		f.Add(packets[i], packets[i+1])
+	}
+	f.Fuzz(func(t *testing.T, p0, p1 []byte) {
+		vb := setup.fork()
+		vb.pr = newSliceReader(nil, p0, p1)
+		for vb.decodePacket() == nil {
+		}
+		vb.releaseBuffers()
+	})
+}
@@ -1,8 +1,8 @@
 package vorbis
 
 import (
-	"fmt"
 	"strings"
+	"unsafe"
 
 	"github.com/toy80/debug"
 )
@@ -18,24 +18,24 @@ func (vb *Vorbis) parseIdentHeader() bool {
 	vb.pr.ReadBytes(buf[:])
 
 	if buf[0] != 1 || !isVorbis(buf[1:]) {
-		fmt.Println(string(buf[:]))
-		fmt.Println("corrupted identification header")
-		return false
+		return vb.setupError("corrupted identification header")
 	}
 	vb.vorbisVersion = vb.pr.ReadBits(32)
 	if vb.vorbisVersion != 0 {
-		fmt.Println("unsupported vorbis version")
-		return false
+		return vb.setupError("unsupported vorbis version %d", vb.vorbisVersion)
 	}
 	vb.audioChannels = uint8(vb.pr.ReadBits(8))
-	if vb.audioChannels > maxChannels {
-		fmt.Printf("too many channels: %d\n", vb.audioChannels)
-		return false
+	if vb.audioChannels == 0 {
+		return vb.setupError("no audio channels")
+	}
+	if int(vb.audioChannels) > vb.opts.MaxChannels {
+		return vb.limitError("%d channels > %d", vb.audioChannels, vb.opts.MaxChannels)
 	}
-	vb.outBufRes = make([]byte, int(vb.audioChannels)*4096*vb.outTypeSize)
-
 	//vb.blockAlign = int(vb.audioChannels * 4)
 	vb.audioFrameRate = vb.pr.ReadBits(32)
+	if vb.audioFrameRate == 0 {
+		return vb.setupError("zero sample rate")
+	}
 	vb.maxBitrate = vb.pr.ReadBits(32)
 	vb.nomBitrate = vb.pr.ReadBits(32)
 	vb.minBitrate = vb.pr.ReadBits(32)
@@ -48,37 +48,44 @@ func (vb *Vorbis) parseIdentHeader() bool {
 	//debug.Assert(frame_width[frame_type[0]] == blockSize[0]);
 	//debug.Assert(frame_width[frame_type[1]] == blockSize[1]);
 	if vb.blockSize[0] > vb.blockSize[1] || vb.blockSize[0] < 64 || vb.blockSize[1] > 8192 {
-		fmt.Println(fmt.Sprintf("unsupported blocksize pair %d,%d", vb.blockSize[0], vb.blockSize[1]))
-		return false
+		return vb.setupError("unsupported block sizes %d, %d", vb.blockSize[0], vb.blockSize[1])
 	}
 
-	vb.pr.ReadBits(1) //framing_flag
-
-	vb.mdct[0].init(int(vb.blockSize[0]))
-	vb.mdct[1].init(int(vb.blockSize[1]))
+	if vb.pr.ReadBits(1) == 0 { //framing_flag
+		return vb.setupError("corrupted identification header: framing flag")
+	}
 	return true
 }
 
 func (vb *Vorbis) parseCommentsHeader() bool {
 	var err error
-	if err = vb.pr.NextPacket(); err != nil {
-		fmt.Println("missing comments header:", err)
-		return false
+	if err = vb.nextPacket(); err != nil {
+		return vb.setupError("missing comments header: %v", err)
 	}
 
 	var buf [32]uint8
 	vb.pr.ReadBytes(buf[:7])
 	if buf[0] != 3 || !isVorbis(buf[1:7]) {
-		fmt.Println(string(buf[1:7]))
-		fmt.Println("corrupted comments header")
-		return false
+		return vb.setupError("corrupted comments header")
 	}
 
-	vb.vendor = vb.pr.ReadString()
+	// comments are counted into setup memory, drop the previous ones if any
+	vb.setupMem -= vb.commentMem
+	vb.commentMem = 0
+	var ok bool
+	if vb.vendor, ok = vb.readString(); !ok {
+		return false
+	}
 	listCount := vb.pr.ReadBits(32)
 	vb.comments = make(map[string]string)
 	for j := uint32(0); j < listCount; j++ {
-		s := vb.pr.ReadString()
+		if vb.endOfPacket() {
+			return vb.setupError("corrupted comments header: %d comments", listCount)
+		}
+		s, ok := vb.readString()
+		if !ok {
+			return false
+		}
 		if pos := strings.IndexByte(s, '='); pos != -1 {
 			k, v := s[:pos], s[pos+1:]
 			if v0, ok := vb.comments[k]; ok {
@@ -98,28 +105,105 @@ func (vb *Vorbis) parseCommentsHeader() bool {
 	return true
 }
 
+// readString read a string with 32 bits length prefix, the bytes are counted into setup memory
+func (vb *Vorbis) readString() (string, bool) {
+	n := vb.pr.ReadBits(32)
+	if n == 0 {
+		return "", true
+	}
+	if !vb.alloc(int64(n)) {
+		return "", false
+	}
+	vb.commentMem += int64(n)
+	buf := make([]byte, n)
+	vb.pr.ReadBytes(buf)
+	if vb.endOfPacket() {
+		return "", vb.setupError("corrupted comments header: string of %d bytes", n)
+	}
+	return string(buf), true
+}
+
+// endOfPacket reports whether reading beyond the current packet, if the PacketReader can tell
+func (vb *Vorbis) endOfPacket() bool {
+	if x, ok := vb.pr.(interface{ EndOfPacket() bool }); ok {
+		return x.EndOfPacket()
+	}
+	return false
+}
+
+// bitRecord keeps the bits read from a packet, packed as they are in the packet
+type bitRecord struct {
+	data []byte
+	n    int
+}
+
+func (b *bitRecord) put(v uint32, bits uint32) {
+	for i := uint32(0); i < bits; i++ {
+		if b.n&7 == 0 {
+			b.data = append(b.data, 0)
+		}
+		b.data[b.n>>3] |= byte(v>>i&1) << uint(b.n&7)
+		b.n++
+	}
+}
+
+// recordReader is the PacketReader that records the bits read into rec
+type recordReader struct {
+	PacketReader
+	rec *bitRecord
+}
+
+func (r recordReader) ReadBits(bits uint32) uint32 {
+	v := r.PacketReader.ReadBits(bits)
+	r.rec.put(v, bits)
+	return v
+}
+
+func (r recordReader) ReadBytes(p []byte) {
+	r.PacketReader.ReadBytes(p)
+	for _, c := range p {
+		r.rec.put(uint32(c), 8)
+	}
+}
+
+func (r recordReader) ReadString() string {
+	s := r.PacketReader.ReadString()
+	r.rec.put(uint32(len(s)), 32)
+	for i := 0; i < len(s); i++ {
+		r.rec.put(uint32(s[i]), 8)
+	}
+	return s
+}
+
 func (vb *Vorbis) parseSetupHeader() bool {
-	if vb.pr.NextPacket() != nil {
-		fmt.Println("missing setup header")
-		return false
+	if vb.nextPacket() != nil {
+		return vb.setupError("missing setup header")
 	}
+	// the bits are kept for Reset, to compare with the setup header of next stream
+	vb.setupBits = bitRecord{}
+	rr := recordReader{PacketReader: vb.pr, rec: &vb.setupBits}
+	vb.pr = rr
+	defer func() { vb.pr = rr.PacketReader }()
 
 	var buf [32]uint8
 	// setup header packet
 	vb.pr.ReadBytes(buf[:7])
 
 	if buf[0] != 5 || !isVorbis(buf[1:7]) {
-		fmt.Println(string(buf[1:7]))
-		fmt.Println("corrupted setup header")
-		return false
+		return vb.setupError("corrupted setup header")
 	}
 
 	// codebooks
 	vb.numCodebooks = vb.pr.ReadBits(8) + 1
+	if !vb.alloc(int64(vb.numCodebooks) * int64(unsafe.Sizeof(sCodeBook{}))) {
+		return false
+	}
 	vb.codebooks = make([]sCodeBook, vb.numCodebooks)
 	for i := 0; i < int(vb.numCodebooks); i++ {
 		vb.codebooks[i].id = i
-		vb.codebooks[i].readConfig(vb)
+		if !vb.codebooks[i].readConfig(vb) {
+			return false
+		}
 	}
 
 	//  time domain transforms (unused)
@@ -127,35 +211,47 @@ func (vb *Vorbis) parseSetupHeader() bool {
 	for tdtCount > 0 {
 		tdtCount--
 		if vb.pr.ReadBits(16) != 0 {
-			fmt.Println("unsupported time domain transforms")
-			return false
+			return vb.setupError("unsupported time domain transforms")
 		}
 	}
 
 	// floors
 	debug.Assert(vb.floors == nil)
 	vb.numFloors = vb.pr.ReadBits(6) + 1
-	if vb.numFloors != 0 {
-		vb.floors = make([]sFloor, vb.numFloors)
-		for i := uint32(0); i < vb.numFloors; i++ {
-			vb.floors[i].readConfig(vb)
+	if !vb.alloc(int64(vb.numFloors) * int64(unsafe.Sizeof(sFloor{}))) {
+		return false
+	}
+	vb.floors = make([]sFloor, vb.numFloors)
+	for i := uint32(0); i < vb.numFloors; i++ {
+		if !vb.floors[i].readConfig(vb) {
+			return false
 		}
 	}
 
 	// residues
 	vb.numResidues = vb.pr.ReadBits(6) + 1
 	debug.Assert(vb.residues == nil)
+	if !vb.alloc(int64(vb.numResidues) * int64(unsafe.Sizeof(sResidue{}))) {
+		return false
+	}
 	vb.residues = make([]sResidue, vb.numResidues)
 	for i := uint32(0); i < vb.numResidues; i++ {
-		vb.residues[i].readConfig(vb)
+		if !vb.residues[i].readConfig(vb) {
+			return false
+		}
 	}
 
 	// mapping
 	vb.numMappings = vb.pr.ReadBits(6) + 1
 	debug.Assert(vb.mappings == nil)
+	if !vb.alloc(int64(vb.numMappings) * int64(unsafe.Sizeof(sMapping{}))) {
+		return false
+	}
 	vb.mappings = make([]sMapping, vb.numMappings)
 	for i := uint32(0); i < vb.numMappings; i++ {
-		vb.mappings[i].readConfig(vb)
+		if !vb.mappings[i].readConfig(vb) {
+			return false
+		}
 	}
 
 	// modes
@@ -163,13 +259,14 @@ func (vb *Vorbis) parseSetupHeader() bool {
 	debug.Assert(vb.modes == nil)
 	vb.modes = make([]sMode, vb.numModes)
 	for i := uint32(0); i < vb.numModes; i++ {
-		vb.modes[i].readConfig(vb)
+		if !vb.modes[i].readConfig(vb) {
+			return false
+		}
 	}
 
 	framingFlag := vb.pr.ReadBits(1)
 	if framingFlag == 0 {
-		fmt.Println("corrupted setup header")
-		return false
+		return vb.setupError("corrupted setup header: framing flag")
 	}
 
 	return true
@@ -4,6 +4,9 @@ import (
 	"fmt"
 )
 
+// invalidSymbol is decoded from a unused codeword
+const invalidSymbol = 0xFFFFFFFF
+
 // simple binary tree implementation
 type huffmanNode struct {
 	sub [2]*huffmanNode
@@ -23,6 +26,9 @@ func (d *huffmanDecoder) decodeHuffman(in interface {
 	p := &d.huffmanNode
 	for {
 		p = p.sub[in.ReadBits(1)]
+		if p == nil {
+			return invalidSymbol // underspecified tree, or corrupted data
+		}
 		if p.sym != 0xFFFFFFFF {
 			return p.sym
 		}
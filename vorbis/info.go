@@ -0,0 +1,65 @@
+package vorbis
+
+// Info of vorbis stream, and the statistics during decode
+type Info struct {
+	Version    int
+	Channels   int
+	SampleRate int
+	MaxBitrate int    // bits per second, 0 if not set
+	NomBitrate int    // bits per second, 0 if not set
+	MinBitrate int    // bits per second, 0 if not set
+	BlockSize  [2]int // short and long block size
+	Vendor     string
+
+	Bitrate     int   // instantaneous bitrate of the last decoded packet
+	AvgBitrate  int   // average bitrate of the packets decoded so far
+	ShortBlocks int64 // count of decoded short blocks
+	LongBlocks  int64 // count of decoded long blocks
+}
+
+type packetSizer interface {
+	PacketSize() int
+}
+
+// the bitrate fields of identification header are signed, only positive values make sense
+func headerBitrate(x uint32) int {
+	if int32(x) <= 0 {
+		return 0
+	}
+	return int(x)
+}
+
+// Info reports the stream info and the statistics during decode
+func (vb *Vorbis) Info() Info {
+	x := Info{
+		Version:     int(vb.vorbisVersion),
+		Channels:    int(vb.audioChannels),
+		SampleRate:  int(vb.audioFrameRate),
+		MaxBitrate:  headerBitrate(vb.maxBitrate),
+		NomBitrate:  headerBitrate(vb.nomBitrate),
+		MinBitrate:  headerBitrate(vb.minBitrate),
+		BlockSize:   [2]int{int(vb.blockSize[0]), int(vb.blockSize[1])},
+		Vendor:      vb.vendor,
+		Bitrate:     vb.statBitrate,
+		ShortBlocks: vb.statBlocks[0],
+		LongBlocks:  vb.statBlocks[1],
+	}
+	if vb.statFrames != 0 {
+		x.AvgBitrate = int(vb.statBytes * 8 * int64(vb.audioFrameRate) / vb.statFrames)
+	}
+	return x
+}
+
+// update statistics after decoded a packet, which yields frames of pcm, i.e. a quarter
+// of previous block plus a quarter of current block, and none for the first packet
+func (vb *Vorbis) updateStat(blockflag uint8, frames int) {
+	vb.statBlocks[blockflag]++
+	if ps, ok := vb.pr.(packetSizer); ok {
+		n := int64(ps.PacketSize())
+		vb.statBytes += n
+		vb.statFrames += int64(frames)
+		if frames > 0 {
+			vb.statBitrate = int(n * 8 * int64(vb.audioFrameRate) / int64(frames))
+		}
+	}
+}
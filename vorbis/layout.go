@@ -0,0 +1,197 @@
+package vorbis
+
+import (
+	"math"
+	"sort"
+
+	"github.com/toy80/audio/wav"
+)
+
+// ChannelOrder is the order of channels in the decoded PCM
+type ChannelOrder int
+
+// Channel orders
+const (
+	// VorbisOrder is the order of stream, defined by the Vorbis I spec for 1 to 8
+	// channels, i.e. L, C, R, Ls, Rs, LFE for 5.1
+	VorbisOrder ChannelOrder = iota
+
+	// WaveOrder is the order of WAVE_FORMAT_EXTENSIBLE, i.e. L, R, C, LFE, Ls, Rs for 5.1.
+	// the channels without defined position are kept in the stream order.
+	WaveOrder
+)
+
+// the speakers of 1 to 8 channels, section 4.3.9 of the spec
+var vorbisLayouts = [...][]wav.Speaker{
+	1: {wav.SpeakerFrontCenter},
+	2: {wav.SpeakerFrontLeft, wav.SpeakerFrontRight},
+	3: {wav.SpeakerFrontLeft, wav.SpeakerFrontCenter, wav.SpeakerFrontRight},
+	4: {wav.SpeakerFrontLeft, wav.SpeakerFrontRight, wav.SpeakerBackLeft, wav.SpeakerBackRight},
+	5: {wav.SpeakerFrontLeft, wav.SpeakerFrontCenter, wav.SpeakerFrontRight,
+		wav.SpeakerBackLeft, wav.SpeakerBackRight},
+	6: {wav.SpeakerFrontLeft, wav.SpeakerFrontCenter, wav.SpeakerFrontRight,
+		wav.SpeakerBackLeft, wav.SpeakerBackRight, wav.SpeakerLowFrequency},
+	7: {wav.SpeakerFrontLeft, wav.SpeakerFrontCenter, wav.SpeakerFrontRight,
+		wav.SpeakerSideLeft, wav.SpeakerSideRight, wav.SpeakerBackCenter, wav.SpeakerLowFrequency},
+	8: {wav.SpeakerFrontLeft, wav.SpeakerFrontCenter, wav.SpeakerFrontRight,
+		wav.SpeakerSideLeft, wav.SpeakerSideRight, wav.SpeakerBackLeft, wav.SpeakerBackRight,
+		wav.SpeakerLowFrequency},
+}
+
+// StreamLayout reports the speakers of stream channels in stream order, the positions of
+// more than 8 channels are defined by application, they are wav.SpeakerUnknown.
+func (vb *Vorbis) StreamLayout() []wav.Speaker {
+	layout := make([]wav.Speaker, vb.audioChannels)
+	if int(vb.audioChannels) < len(vorbisLayouts) {
+		copy(layout, vorbisLayouts[vb.audioChannels])
+	}
+	return layout
+}
+
+// Layout reports the speakers of tracks returned by Read, after reordering or downmix
+func (vb *Vorbis) Layout() []wav.Speaker {
+	return append([]wav.Speaker(nil), vb.layout...)
+}
+
+// initLayout decides the output tracks from the options, called after the headers are parsed
+func (vb *Vorbis) initLayout() {
+	stream := vb.StreamLayout()
+	n := len(stream)
+	vb.outSrc, vb.mix, vb.mixFix = nil, nil, nil
+	if vb.opts.Downmix != 0 && vb.opts.Downmix != n {
+		vb.initDownmix(stream)
+		return
+	}
+
+	vb.outChannels = n
+	vb.outSrc = make([]int, n)
+	for i := range vb.outSrc {
+		vb.outSrc[i] = i
+	}
+	if vb.opts.ChannelOrder == WaveOrder {
+		// the unknown speakers are 0, they are sorted before the known ones, so keep
+		// the stream order if any is unknown
+		known := true
+		for _, s := range stream {
+			known = known && s != wav.SpeakerUnknown
+		}
+		if known {
+			sort.SliceStable(vb.outSrc, func(i, j int) bool {
+				return stream[vb.outSrc[i]] < stream[vb.outSrc[j]]
+			})
+		}
+	}
+	vb.layout = make([]wav.Speaker, n)
+	for i, src := range vb.outSrc {
+		vb.layout[i] = stream[src]
+	}
+}
+
+// gains of the speaker for stereo downmix, ITU-R BS.775, LFE is dropped
+func stereoGains(s wav.Speaker) (l, r float64) {
+	const g = math.Sqrt2 / 2 // -3dB
+	switch s {
+	case wav.SpeakerFrontLeft, wav.SpeakerFrontLeftOfCenter:
+		return 1, 0
+	case wav.SpeakerFrontRight, wav.SpeakerFrontRightOfCenter:
+		return 0, 1
+	case wav.SpeakerFrontCenter:
+		return g, g
+	case wav.SpeakerBackLeft, wav.SpeakerSideLeft:
+		return g, 0
+	case wav.SpeakerBackRight, wav.SpeakerSideRight:
+		return 0, g
+	case wav.SpeakerBackCenter:
+		return 0.5, 0.5
+	case wav.SpeakerLowFrequency:
+		return 0, 0
+	default:
+		return 1, 1 // unknown position, to both sides
+	}
+}
+
+// initDownmix builds the mix matrix of vb.opts.Downmix tracks, the gains are normalized
+// so the tracks never clip.
+func (vb *Vorbis) initDownmix(stream []wav.Speaker) {
+	n := len(stream)
+	gl := make([]float64, n)
+	gr := make([]float64, n)
+	var sumL, sumR float64
+	for i, s := range stream {
+		if n == 1 {
+			gl[i], gr[i] = 1, 1 // mono to stereo
+		} else {
+			gl[i], gr[i] = stereoGains(s)
+		}
+		sumL += gl[i]
+		sumR += gr[i]
+	}
+	for i := range stream {
+		if sumL > 1 {
+			gl[i] /= sumL
+		}
+		if sumR > 1 {
+			gr[i] /= sumR
+		}
+	}
+
+	var rows [][]float64
+	if vb.opts.Downmix == 1 {
+		m := make([]float64, n)
+		for i := range m {
+			m[i] = (gl[i] + gr[i]) / 2
+		}
+		rows = [][]float64{m}
+		vb.layout = []wav.Speaker{wav.SpeakerFrontCenter}
+	} else {
+		rows = [][]float64{gl, gr}
+		vb.layout = []wav.Speaker{wav.SpeakerFrontLeft, wav.SpeakerFrontRight}
+	}
+	vb.outChannels = len(rows)
+	vb.mix = make([]float32, 0, len(rows)*n)
+	vb.mixFix = make([]int32, 0, len(rows)*n)
+	for _, row := range rows {
+		for _, g := range row {
+			vb.mix = append(vb.mix, float32(g))
+			// the gains are computed without fused operations, so they are deterministic
+			vb.mixFix = append(vb.mixFix, int32(math.Round(g*(1<<fixTwiddleBits))))
+		}
+	}
+}
+
+// downmix mixes the PCM of channels into the output tracks
+func (vb *Vorbis) downmix(pcmCount int) {
+	n := int(vb.audioChannels)
+	for o := 0; o < vb.outChannels; o++ {
+		gains := vb.mix[o*n : o*n+n]
+		out := vb.outPCM[o][:pcmCount]
+		for i := range out {
+			out[i] = 0
+		}
+		for ch, g := range gains {
+			if g == 0 {
+				continue
+			}
+			pcm := vb.chnBufs[ch].pcm[:pcmCount]
+			for i, x := range pcm {
+				out[i] += g * x
+			}
+		}
+	}
+}
+
+// downmixFixed is the fixed-point version of downmix
+func (vb *Vorbis) downmixFixed(pcmCount int) {
+	n := int(vb.audioChannels)
+	for o := 0; o < vb.outChannels; o++ {
+		gains := vb.mixFix[o*n : o*n+n]
+		out := vb.outPCMFix[o][:pcmCount]
+		for i := range out {
+			var acc int64
+			for ch, g := range gains {
+				acc += int64(vb.chnBufs[ch].fix.pcm[i]) * int64(g)
+			}
+			out[i] = clampInt32((acc + 1<<(fixTwiddleBits-1)) >> fixTwiddleBits)
+		}
+	}
+}
@@ -9,26 +9,14 @@ const pi = math.Pi
 
 // MDCT calculator.
 type MDCT struct {
-	N   int // 1/1
-	N2  int // 1/2
-	N4  int // 1/4
-	N8  int // 1/8
-	N43 int // 3/4
-	LDN int // ld(n)
-	A   []float32
-	B   []float32
-	C   []float32
-	buf []float32
+	N       int       // 1/1
+	N2      int       // 1/2
+	N4      int       // 1/4
+	fft     fft       // N/4 points complex FFT
+	twiddle []float32 // exp(-i*pi*(8k+1)/(4N)), pre and post twiddle share it
+	buf     []float32 // N/4 complex numbers, interleaved
 }
 
-// func cos(x float32) float32 {
-// 	return float32(math.Cos(float64(x)))
-// }
-
-// func sin(x float32) float32 {
-// 	return float32(math.Sin(float64(x)))
-// }
-
 func ilog(x uint32) (y int) {
 	for x != 0 {
 		y++
@@ -72,100 +60,20 @@ func (m *MDCT) init(n int) {
 	}
 
 	m.N = n
-	m.buf = make([]float32, m.N)
-
 	if n < 16 {
+		m.buf = make([]float32, m.N)
 		return // 小于16特殊处理, 不优化
 	}
 	m.N2 = n / 2
 	m.N4 = n / 4
-	m.N8 = n / 8
-	m.N43 = 3 * m.N4
-	m.LDN = ilog(uint32(n)) - 1
+	m.fft.init(m.N4)
 
-	m.A = make([]float32, m.N2)
-	m.B = make([]float32, m.N2)
+	m.twiddle = make([]float32, m.N2)
 	for k := 0; k < m.N4; k++ {
-		sa, ca := math.Sincos(4 * float64(k) * pi / float64(m.N))
-		sb, cb := math.Sincos((2*float64(k) + 1) * pi / float64(m.N) / 2)
-		m.A[2*k], m.A[2*k+1] = float32(ca), -float32(sa)
-		m.B[2*k], m.B[2*k+1] = float32(cb), float32(sb)
-	}
-
-	m.C = make([]float32, m.N4)
-	for k := 0; k < m.N8; k++ {
-		sc, cc := math.Sincos(2 * (2*float64(k) + 1) * pi / float64(m.N))
-		m.C[2*k], m.C[2*k+1] = float32(cc), -float32(sc)
-	}
-
-}
-
-func (m *MDCT) inv1(u, v []float32) {
-	for k2 := 0; k2 < m.N2; k2 += 2 {
-		k4 := k2 << 1
-		v[m.N-k4-1] = (u[k4]-u[m.N-k4-1])*m.A[k2] - (u[k4+2]-u[m.N-k4-3])*m.A[k2+1]
-		v[m.N-k4-3] = (u[k4]-u[m.N-k4-1])*m.A[k2+1] + (u[k4+2]-u[m.N-k4-3])*m.A[k2]
-	}
-}
-
-func (m *MDCT) inv2(v, w []float32) {
-	for k4 := 0; k4 < m.N2; k4 += 4 {
-		w[m.N2+3+k4] = v[m.N2+3+k4] + v[k4+3]
-		w[m.N2+1+k4] = v[m.N2+1+k4] + v[k4+1]
-		w[k4+3] = (v[m.N2+3+k4]-v[k4+3])*m.A[m.N2-4-k4] - (v[m.N2+1+k4]-v[k4+1])*m.A[m.N2-3-k4]
-		w[k4+1] = (v[m.N2+1+k4]-v[k4+1])*m.A[m.N2-4-k4] + (v[m.N2+3+k4]-v[k4+3])*m.A[m.N2-3-k4]
-	}
-}
-
-func (m *MDCT) inv4(u, v []float32) {
-	for i := uint32(0); i < (uint32)(m.N8); i++ {
-		// 这里不是瓶颈, 改为查表后实测没有提升
-		j := reverseBits(i, uint32(m.LDN-3))
-		if i == j {
-			i8 := i << 3
-			v[i8+1] = u[i8+1]
-			v[i8+3] = u[i8+3]
-			v[i8+5] = u[i8+5]
-			v[i8+7] = u[i8+7]
-		} else if i < j {
-			i8 := i << 3
-			j8 := j << 3
-			v[j8+1] = u[i8+1]
-			v[i8+1] = u[j8+1]
-			v[j8+3] = u[i8+3]
-			v[i8+3] = u[j8+3]
-			v[j8+5] = u[i8+5]
-			v[i8+5] = u[j8+5]
-			v[j8+7] = u[i8+7]
-			v[i8+7] = u[j8+7]
-		}
-	}
-}
-
-func (m *MDCT) inv3(w, u []float32) {
-	for l := 0; l < m.LDN-3; l++ {
-		k0 := m.N >> uint(l+2)
-		k1 := 0x00000001 << uint(l+3)
-
-		rn := m.N >> uint(l+4)
-		s2n := 0x00000001 << uint(l+2)
-		for r := 0; r < rn; r++ {
-			for s2 := 0; s2 < s2n; s2 += 2 {
-				n1s0 := m.N - 1 - k0*s2 - 4*r
-				n3s0 := m.N - 3 - k0*s2 - 4*r
-				n1s1 := n1s0 - k0
-				n3s1 := n3s0 - k0
-				u[n1s0] = w[n1s0] + w[n1s1]
-				u[n3s0] = w[n3s0] + w[n3s1]
-				u[n1s1] = (w[n1s0]-w[n1s1])*m.A[r*k1] - (w[n3s0]-w[n3s1])*m.A[r*k1+1]
-				u[n3s1] = (w[n3s0]-w[n3s1])*m.A[r*k1] + (w[n1s0]-w[n1s1])*m.A[r*k1+1]
-			}
-		}
-
-		if l+1 < m.LDN-3 {
-			copy(w[:m.N], u[:m.N])
-		}
+		s, c := math.Sincos((8*float64(k) + 1) * pi / float64(4*m.N))
+		m.twiddle[2*k], m.twiddle[2*k+1] = float32(c), -float32(s)
 	}
+	m.buf = make([]float32, m.N2)
 }
 
 func inverseSlow(in []float32, out []float32, n int) {
@@ -179,88 +87,50 @@ func inverseSlow(in []float32, out []float32, n int) {
 	}
 }
 
-// inverse MDCT algorithm from the paper
-// "The use of multirate filter banks for coding of high quality digital audio" 1992
-// TODO: inverse MDCT is bottle neck, need optimizatiion
+// inverse MDCT, the N/2 coefficients in x are transformed into N samples in place.
+//
+// the IMDCT is unfolded from a DCT-IV of length N/2, and the DCT-IV is computed with
+// a N/4 points complex FFT between the pre-twiddle and post-twiddle.
 func (m *MDCT) inverse(x []float32) {
 	if m.N < 16 {
 		copy(m.buf, x)
 		inverseSlow(m.buf, x, m.N)
 		return
 	}
-	Y := x
-	y := x
-
-	var u, v, w, X []float32
-
-	// init: Y => u
-	u = m.buf
-	for k := 0; k < m.N2; k++ {
-		u[k] = Y[k]
-	}
-
-	for k := m.N2; k < m.N; k++ {
-		u[k] = -Y[m.N-k-1]
-	}
-
-	// step1
-	v = x
-	m.inv1(u, v)
-
-	// step2
-	w = m.buf
-	m.inv2(v, w)
-
-	// step3
-	u = x
-	m.inv3(w, u)
-
-	// step4
-	v = m.buf
-	m.inv4(u, v)
-
-	// step5
-	w = x
-	for k := 0; k < m.N2; k++ {
-		w[k] = v[k*2+1]
-	}
-
-	// step6
-	u = m.buf
-	for k := 0; k < m.N8; k++ {
-		u[m.N-1-2*k] = w[4*k]
-		u[m.N-2-2*k] = w[4*k+1]
-		u[m.N43-1-2*k] = w[4*k+2]
-		u[m.N43-2-2*k] = w[4*k+3]
-	}
-
-	// step7
-	v = x
-
-	for k, k2 := 0, 0; k < m.N8; k, k2 = k+1, k2+2 {
-		v[m.N2+k2] = (u[m.N2+k2] + u[m.N-2-k2] + m.C[k2+1]*(u[m.N2+k2]-u[m.N-2-k2]) + m.C[k2]*(u[m.N2+k2+1]+u[m.N-2-k2+1])) / 2
-		v[m.N-2-k2] = (u[m.N2+k2] + u[m.N-2-k2] - m.C[k2+1]*(u[m.N2+k2]-u[m.N-2-k2]) - m.C[k2]*(u[m.N2+k2+1]+u[m.N-2-k2+1])) / 2
-		v[m.N2+1+k2] = (u[m.N2+1+k2] - u[m.N-1-k2] + m.C[k2+1]*(u[m.N2+1+k2]+u[m.N-1-k2]) - m.C[k2]*(u[m.N2+k2]-u[m.N-2-k2])) / 2
-		v[m.N-1-k2] = (-u[m.N2+1+k2] + u[m.N-1-k2] + m.C[k2+1]*(u[m.N2+1+k2]+u[m.N-1-k2]) - m.C[k2]*(u[m.N2+k2]-u[m.N-2-k2])) / 2
-	}
-
-	// step8
-	X = m.buf
-	for k, k2 := 0, 0; k < m.N4; k, k2 = k+1, k2+2 {
-		X[k] = v[k2+m.N2]*m.B[k2] + v[k2+1+m.N2]*m.B[k2+1]
-		X[m.N2-1-k] = v[k2+m.N2]*m.B[k2+1] - v[k2+1+m.N2]*m.B[k2]
-	}
-
-	// final X --> y
-	for k := 0; k < m.N4; k++ {
-		y[k] = X[k+m.N4] * 0.5
-	}
-
-	for k := m.N4; k < m.N43; k++ {
-		y[k] = -X[m.N43-k-1] * 0.5
-	}
-
-	for k := m.N43; k < m.N; k++ {
-		y[k] = -X[k-m.N43] * 0.5
+	n2, n4 := m.N2, m.N4
+	z, w := m.buf, m.twiddle
+
+	// pre-twiddle: z[k] = (x[2k] + i*x[N/2-1-2k]) * w[k]
+	for k, k2 := 0, 0; k < n4; k, k2 = k+1, k2+2 {
+		re, im := x[k2], x[n2-1-k2]
+		c, s := w[k2], w[k2+1]
+		z[k2] = re*c - im*s
+		z[k2+1] = re*s + im*c
+	}
+
+	m.fft.transform(z)
+
+	// post-twiddle gives the DCT-IV u: u[2k] = Re(z[k]*w[k]), u[N/2-1-2k] = -Im(z[k]*w[k]),
+	// then unfold into the IMDCT output y:
+	//    y[n] =  u[n+N/4]        0     <= n < N/4
+	//    y[n] = -u[3N/4-1-n]     N/4   <= n < 3N/4
+	//    y[n] = -u[n-3N/4]       3N/4  <= n < N
+	n8 := n4 / 2
+	n34 := n2 + n4
+	for k, k2 := 0, 0; k < n4; k, k2 = k+1, k2+2 {
+		re, im := z[k2], z[k2+1]
+		c, s := w[k2], w[k2+1]
+		u0 := re*c - im*s    // u[2k]
+		u1 := -(re*s + im*c) // u[N/2-1-2k]
+		j0, j1 := k2, n2-1-k2
+		x[n34-1-j0] = -u0
+		x[n34-1-j1] = -u1
+		if k < n8 {
+			x[j0+n34] = -u0
+			x[j1-n4] = u1
+		} else {
+			x[j0-n4] = u0
+			x[j1+n34] = -u1
+		}
 	}
 }
@@ -0,0 +1,80 @@
+package vorbis
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded indicates the stream requires more resources than the Options allowed
+var ErrLimitExceeded = errors.New("vorbis: resource limit exceeded")
+
+// Options of decoder, the limits protect the decoder from hostile streams.
+// zero value of a field means the default value.
+type Options struct {
+	MaxChannels        int   // max audio channels, no more than 255
+	MaxCodebookEntries int   // max entries of a single codebook, no more than 2^24
+	MaxSetupMemory     int64 // max bytes allocated by the headers, includes comments
+
+	// FixedPoint decodes with integer arithmetic only, the output is bit-identical on
+	// every platform, and it is faster on the CPUs without FPU. the quality is a little
+	// lower than the floating-point decoding, the difference is within a few LSB of I16.
+	FixedPoint bool
+
+	// ChannelOrder of the decoded PCM, the default is VorbisOrder
+	ChannelOrder ChannelOrder
+
+	// Downmix the channels into 1 or 2 tracks, 0 keeps the channels of stream. the LFE
+	// channel is dropped, and the gains are normalized, so the tracks never clip.
+	Downmix int
+
+	// Tracer receives the details of pages and packets, nil to disable
+	Tracer Tracer
+}
+
+// DefaultOptions is used when the Options or its fields are not specified
+var DefaultOptions = Options{
+	MaxChannels:        maxChannels,
+	MaxCodebookEntries: 1 << 20,
+	MaxSetupMemory:     64 << 20,
+}
+
+func (opts *Options) normalize() {
+	if opts.MaxChannels <= 0 || opts.MaxChannels > maxChannels {
+		opts.MaxChannels = maxChannels
+	}
+	if opts.MaxCodebookEntries <= 0 || opts.MaxCodebookEntries > 1<<24 {
+		opts.MaxCodebookEntries = DefaultOptions.MaxCodebookEntries
+	}
+	if opts.Downmix < 0 || opts.Downmix > 2 {
+		opts.Downmix = 0
+	}
+	if opts.MaxSetupMemory <= 0 {
+		opts.MaxSetupMemory = DefaultOptions.MaxSetupMemory
+	}
+}
+
+// limitError records the first error of resource limit, and always returns false
+func (vb *Vorbis) limitError(format string, v ...interface{}) bool {
+	if vb.setupErr == nil {
+		vb.setupErr = fmt.Errorf("%w: "+format, append([]interface{}{ErrLimitExceeded}, v...)...)
+	}
+	return false
+}
+
+// setupError records the first error of corrupted or unsupported headers, and always
+// returns false
+func (vb *Vorbis) setupError(format string, v ...interface{}) bool {
+	if vb.setupErr == nil {
+		vb.setupErr = fmt.Errorf(format, v...)
+	}
+	return false
+}
+
+// alloc reserves n bytes of setup memory, reports false if out of limit
+func (vb *Vorbis) alloc(n int64) bool {
+	if n < 0 || vb.setupMem+n > vb.opts.MaxSetupMemory {
+		return vb.limitError("setup memory %d + %d bytes > %d", vb.setupMem, n, vb.opts.MaxSetupMemory)
+	}
+	vb.setupMem += n
+	return true
+}
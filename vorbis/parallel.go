@@ -0,0 +1,162 @@
+package vorbis
+
+import (
+	"errors"
+	"io"
+	"runtime"
+
+	"github.com/toy80/audio/ogg"
+	"github.com/toy80/audio/wav"
+)
+
+// number of header packets before the first audio packet
+const numHeaderPackets = 3
+
+type sPageIndex struct {
+	offset int64 // position of page
+	first  int   // index of the first packet begin at or after this page
+}
+
+// chunk of stream, decodes packets [from, end), the first one is pre-roll
+type sParallelChunk struct {
+	page int // page where packet "from" begins
+	from int
+	end  int
+	out  []byte
+	err  error
+}
+
+// DecodeParallel decodes the whole ogg vorbis stream in r into wav.I16 with several workers.
+// the stream is split at page boundaries, each chunk decodes one more packet ahead as
+// pre-roll, so the result is identical to sequential decoding.
+// if workers <= 0, runtime.NumCPU() workers are used.
+func DecodeParallel(r io.ReaderAt, size int64, workers int) (*wav.Block, error) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	setup, err := New(io.NewSectionReader(r, 0, size), wav.I16)
+	if err != nil {
+		return nil, err
+	}
+
+	// scan the pages, stop at end of stream like ogg.Reader does
+	var pages []sPageIndex
+	var h ogg.PageHeader
+	var numPackets int
+	for off := int64(0); off < size; off += int64(h.Size()) {
+		if err = ogg.ReadPageHeader(r, off, &h); err != nil {
+			return nil, err
+		}
+		pages = append(pages, sPageIndex{offset: off, first: numPackets})
+		numPackets += h.NumPacketStarts()
+		if h.Last() {
+			break
+		}
+	}
+	audio := pageOfPacket(pages, numPackets, numHeaderPackets)
+	if audio < 0 {
+		return wav.NewBlock(nil, uint8(setup.outChannels), wav.I16, setup.Frequency()), nil
+	}
+
+	// split the audio pages into chunks with similar bytes
+	var chunks []*sParallelChunk
+	total := size - pages[audio].offset
+	begin := audio
+	for p := audio + 1; p <= len(pages); p++ {
+		if p < len(pages) && (pages[p].offset-pages[begin].offset)*int64(workers) < total {
+			continue
+		}
+		c := new(sParallelChunk)
+		c.from = pages[begin].first - 1
+		if begin == audio {
+			c.from = numHeaderPackets
+		}
+		c.end = numPackets
+		if p < len(pages) {
+			c.end = pages[p].first
+		}
+		if c.from < c.end {
+			c.page = pageOfPacket(pages, numPackets, c.from)
+			chunks = append(chunks, c)
+		}
+		begin = p
+	}
+
+	setup.releaseBuffers() // the chunks are decoded by the forks
+	done := make(chan *sParallelChunk, len(chunks))
+	for _, c := range chunks {
+		go func(c *sParallelChunk) {
+			c.out, c.err = decodeChunk(setup, r, size, pages, c)
+			done <- c
+		}(c)
+	}
+	n := 0
+	for range chunks {
+		c := <-done
+		if c.err != nil && err == nil {
+			err = c.err
+		}
+		n += len(c.out)
+	}
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, n)
+	for _, c := range chunks {
+		buf = append(buf, c.out...)
+	}
+	return wav.NewBlock(buf, uint8(setup.outChannels), wav.I16, setup.Frequency()), nil
+}
+
+// pageOfPacket reports the index of page where packet j begins, -1 if not found
+func pageOfPacket(pages []sPageIndex, numPackets int, j int) int {
+	if j >= numPackets {
+		return -1
+	}
+	for p := len(pages) - 1; p >= 0; p-- {
+		if pages[p].first <= j {
+			return p
+		}
+	}
+	return -1
+}
+
+func decodeChunk(setup *Vorbis, r io.ReaderAt, size int64, pages []sPageIndex, c *sParallelChunk) (out []byte, err error) {
+	vb := setup.fork()
+	defer vb.releaseBuffers()
+	if c.from == numHeaderPackets {
+		// the chunk begins at the first audio packet, so the position is known and the
+		// leading frames are trimmed by the granule like sequential decoding, see Cut
+		vb.anchored = true
+	}
+	defer func() {
+		if x := recover(); x != nil {
+			out, err = nil, vb.panicError(x)
+		}
+	}()
+	pr := new(ogg.Reader)
+	if err := pr.Init(io.NewSectionReader(r, 0, size)); err != nil {
+		return nil, err
+	}
+	vb.pr = pr
+	if err := pr.SeekPage(pages[c.page].offset); err != nil {
+		return nil, err
+	}
+	// the first NextPacket after SeekPage turns to the packet pages[c.page].first
+	vb.packetNo = int64(pages[c.page].first) - 1
+	for j := pages[c.page].first; j < c.from; j++ {
+		if err := vb.nextPacket(); err != nil {
+			return nil, errors.New("vorbis: failed to locate the packet")
+		}
+	}
+	for j := c.from; j < c.end; j++ {
+		if err := vb.decodePacket(); err != nil {
+			if err != io.EOF {
+				return nil, err
+			}
+			break // end of stream, same as sequential decoding
+		}
+		out = append(out, vb.outBuf...)
+	}
+	return out, nil
+}
@@ -0,0 +1,157 @@
+package vorbis
+
+import (
+	"sync"
+)
+
+// sDecodeMem is the memory of decoding buffers. it is sized from the block sizes and
+// channels of stream, and pooled by the long block size, so the decoders of similar
+// streams reuse it. after New, the decoding never allocates.
+type sDecodeMem struct {
+	chn []sChannelBuf
+	fix []sChannelFix
+	f   []float32 // floor, audio and pcm of channels, then the format 2 residue
+	i   []int32   // the same as f, for fixed-point decoding
+	y   []int     // floor curve
+	cls []int     // residue classifications
+	out []byte
+	pcm [][]float32
+	pci [][]int32
+}
+
+// indexed by ilog(blockSize[1]), the block size is 64 to 8192
+var memPools [15]sync.Pool
+
+// allocBuffers takes the decoding buffers from pool, and carves them for channels
+func (vb *Vorbis) allocBuffers() {
+	long := int(vb.blockSize[1])
+	half := long / 2
+	channels := int(vb.audioChannels)
+	pool := &memPools[ilog(uint32(long))]
+	mem, _ := pool.Get().(*sDecodeMem)
+	if mem == nil {
+		mem = new(sDecodeMem)
+	}
+
+	// each channel has floor [half], audio [2][long], pcm [half], that is 3*long,
+	// then the format 2 residue of all channels, and the downmix tracks
+	mixOff := channels*3*long + channels*half
+	size := mixOff
+	if vb.mix != nil {
+		size += vb.outChannels * half
+	}
+	mem.chn = resizeChannels(mem.chn, channels)
+	mem.out = resizeBytes(mem.out, vb.outChannels*half*vb.outTypeSize)
+	mem.y = resizeInts(mem.y, half)
+	mem.cls = resizeInts(mem.cls, channels*(maxPartitions+64))
+	if vb.opts.FixedPoint {
+		if cap(mem.fix) < channels {
+			mem.fix = make([]sChannelFix, channels)
+		}
+		mem.fix = mem.fix[:channels]
+		if cap(mem.i) < size {
+			mem.i = make([]int32, size)
+		}
+		mem.i = mem.i[:size]
+	} else {
+		if cap(mem.f) < size {
+			mem.f = make([]float32, size)
+		}
+		mem.f = mem.f[:size]
+	}
+
+	for ch := range mem.chn {
+		b := &mem.chn[ch]
+		*b = sChannelBuf{}
+		off := ch * 3 * long
+		if vb.opts.FixedPoint {
+			s := mem.i[off : off+3*long]
+			x := &mem.fix[ch]
+			*x = sChannelFix{}
+			x.floor, s = s[:half:half], s[half:]
+			x.audio[0], s = s[:long:long], s[long:]
+			x.audio[1], s = s[:long:long], s[long:]
+			x.pcm = s[:half:half]
+			b.fix = x
+			continue
+		}
+		s := mem.f[off : off+3*long]
+		b.floor, s = s[:half:half], s[half:]
+		b.audio[0], s = s[:long:long], s[long:]
+		b.audio[1], s = s[:long:long], s[long:]
+		b.pcm = s[:half:half]
+	}
+
+	vb.mem = mem
+	vb.chnBufs = mem.chn
+	vb.outBufRes = mem.out
+	vb.floorBuf = mem.y
+	vb.classBuf = mem.cls
+	vb.tempBuf, vb.tempBufFix = nil, nil
+	vb.outPCM, vb.outPCMFix = nil, nil
+	if vb.opts.FixedPoint {
+		vb.tempBufFix = mem.i[channels*3*long : mixOff]
+		if cap(mem.pci) < vb.outChannels {
+			mem.pci = make([][]int32, vb.outChannels)
+		}
+		vb.outPCMFix = mem.pci[:vb.outChannels]
+		for o := range vb.outPCMFix {
+			if vb.mix != nil {
+				vb.outPCMFix[o] = mem.i[mixOff+o*half : mixOff+o*half+half]
+			} else {
+				vb.outPCMFix[o] = mem.chn[vb.outSrc[o]].fix.pcm
+			}
+		}
+	} else {
+		vb.tempBuf = mem.f[channels*3*long : mixOff]
+		if cap(mem.pcm) < vb.outChannels {
+			mem.pcm = make([][]float32, vb.outChannels)
+		}
+		vb.outPCM = mem.pcm[:vb.outChannels]
+		for o := range vb.outPCM {
+			if vb.mix != nil {
+				vb.outPCM[o] = mem.f[mixOff+o*half : mixOff+o*half+half]
+			} else {
+				vb.outPCM[o] = mem.chn[vb.outSrc[o]].pcm
+			}
+		}
+	}
+	vb.outBuf = nil
+}
+
+// releaseBuffers puts the decoding buffers back to pool, the decoder can't decode anymore
+func (vb *Vorbis) releaseBuffers() {
+	if vb.mem == nil {
+		return
+	}
+	memPools[ilog(vb.blockSize[1])].Put(vb.mem)
+	vb.mem = nil
+	vb.chnBufs = nil
+	vb.outBufRes = nil
+	vb.outBuf = nil
+	vb.floorBuf = nil
+	vb.classBuf = nil
+	vb.tempBuf, vb.tempBufFix = nil, nil
+	vb.outPCM, vb.outPCMFix = nil, nil
+}
+
+func resizeChannels(s []sChannelBuf, n int) []sChannelBuf {
+	if cap(s) < n {
+		return make([]sChannelBuf, n)
+	}
+	return s[:n]
+}
+
+func resizeBytes(s []byte, n int) []byte {
+	if cap(s) < n {
+		return make([]byte, n)
+	}
+	return s[:n]
+}
+
+func resizeInts(s []int, n int) []int {
+	if cap(s) < n {
+		return make([]int, n)
+	}
+	return s[:n]
+}
@@ -0,0 +1,90 @@
+package vorbis
+
+// granuler is the optional interface of PacketReader to report page granules, see ogg.Reader
+type granuler interface {
+	Granule() int64
+	LastPage() bool
+}
+
+// Block is the PCM frame range decoded from a packet
+type Block struct {
+	Packet int64 // index of the packet in logical stream
+	Begin  int64 // frame position of the first frame
+	Frames int   // frames count, the first packet after rewind produces nothing
+}
+
+// End reports the frame position after the last frame
+func (b Block) End() int64 {
+	return b.Begin + int64(b.Frames)
+}
+
+// Position reports the frame position of the next frame returned by Read.
+// the position is counted from the decoded packets, and corrected by the page granules.
+func (vb *Vorbis) Position() int64 {
+	return vb.block.End() - int64(vb.pendingFrames())
+}
+
+// frames decoded but not read yet
+func (vb *Vorbis) pendingFrames() int {
+	return len(vb.outBuf) / (vb.outTypeSize * vb.outChannels)
+}
+
+// ReadBlock reads the PCM of next packet, the data is valid until next call of Read or
+// ReadBlock. if the PCM of current packet is partially read, the remains are returned.
+// the first packet after New or Rewind produces an empty block.
+func (vb *Vorbis) ReadBlock() (b Block, data []byte, err error) {
+	if vb.fault != nil {
+		return b, nil, vb.fault
+	}
+	defer func() {
+		if x := recover(); x != nil {
+			vb.fault = vb.panicError(x)
+			b, data, err = Block{}, nil, vb.fault
+		}
+	}()
+	if len(vb.outBuf) == 0 {
+		if err = vb.decodePacket(); err != nil {
+			return
+		}
+	}
+	b = vb.block
+	b.Frames = vb.pendingFrames()
+	b.Begin = vb.block.End() - int64(b.Frames)
+	data = vb.outBuf
+	vb.outBuf = nil
+	return
+}
+
+// updatePosition locates the frames of packet just decoded, which produced n frames.
+// the granule of page is the position after the last packet ends within that page,
+// and on the last page it truncates the padding of the final packet. at the beginning
+// of stream, a granule less than the frames decoded truncates the leading frames.
+func (vb *Vorbis) updatePosition(n int) {
+	begin := vb.block.End()
+	g := int64(-1)
+	last := false
+	if x, ok := vb.pr.(granuler); ok {
+		g = x.Granule()
+		last = x.LastPage()
+	}
+	if g >= 0 {
+		if last && vb.anchored && g >= begin && g < begin+int64(n) {
+			n = int(g - begin)
+			vb.outBuf = vb.outBuf[:n*vb.outTypeSize*vb.outChannels]
+		}
+		begin = g - int64(n)
+		if begin < 0 && vb.anchored {
+			// the granule is less than the frames decoded from the beginning of stream,
+			// the leading frames are trimmed, see Cut
+			skip := int(-begin)
+			if skip > n {
+				skip = n
+			}
+			vb.outBuf = vb.outBuf[skip*vb.outTypeSize*vb.outChannels:]
+			n -= skip
+			begin = g - int64(n)
+		}
+		vb.anchored = true
+	}
+	vb.block = Block{Packet: vb.packetNo, Begin: begin, Frames: n}
+}
@@ -0,0 +1,232 @@
+package vorbis
+
+import (
+	"io"
+	"time"
+
+	"github.com/toy80/audio/ogg"
+	"github.com/toy80/audio/wav"
+)
+
+// packetSource provides the complete packets, see ogg.Demuxer
+type packetSource interface {
+	Packet() (p ogg.Packet, ok bool)
+}
+
+// packetQueue is the PacketReader of packets assembled by ogg.Demuxer
+type packetQueue struct {
+	dmx packetSource
+	cur ogg.Packet
+	pos int // bit position in cur.Data
+	eop bool
+}
+
+func (q *packetQueue) NextPacket() error {
+	p, ok := q.dmx.Packet()
+	if !ok {
+		q.cur, q.pos, q.eop = ogg.Packet{}, 0, true
+		return io.EOF
+	}
+	q.cur, q.pos, q.eop = p, 0, false
+	return nil
+}
+
+func (q *packetQueue) ReadBits(bits uint32) uint32 {
+	if bits == 0 || q.eop {
+		return 0
+	}
+	data := q.cur.Data
+	i, shift := q.pos>>3, uint(q.pos&7)
+	var v uint64
+	var n uint32
+	for n < bits {
+		if i >= len(data) {
+			// not enough data, it is the end-of-packet condition
+			q.eop = true
+			q.pos = 8 * len(data)
+			return uint32(v & (1<<n - 1))
+		}
+		v |= uint64(data[i]>>shift) << n
+		n += 8 - uint32(shift)
+		shift = 0
+		i++
+	}
+	q.pos += int(bits)
+	return uint32(v & (1<<bits - 1))
+}
+
+func (q *packetQueue) ReadBytes(p []byte) {
+	for i := range p {
+		p[i] = byte(q.ReadBits(8))
+	}
+}
+
+func (q *packetQueue) ReadString() string {
+	n := q.ReadBits(32)
+	var buf []byte
+	for i := uint32(0); i < n; i++ {
+		c := byte(q.ReadBits(8))
+		if q.eop {
+			break
+		}
+		buf = append(buf, c)
+	}
+	return string(buf)
+}
+
+func (q *packetQueue) EndOfPacket() bool { return q.eop }
+
+func (q *packetQueue) PacketSize() int { return len(q.cur.Data) }
+
+func (q *packetQueue) Granule() int64 { return q.cur.Granule }
+
+func (q *packetQueue) LastPage() bool { return q.cur.Last }
+
+func (q *packetQueue) BitsRead() int { return q.pos }
+
+// PushDecoder is the non-blocking vorbis decoder. the compressed ogg stream is written into
+// it piece by piece, and the PCM is available as soon as the packets are complete.
+type PushDecoder struct {
+	t    wav.Type
+	opts *Options
+	dmx  ogg.Demuxer
+	pq   packetQueue
+	vb   *Vorbis // nil until the headers are decoded
+	err  error
+	eof  bool // no more input
+}
+
+// NewPushDecoder creates push mode decoder, opts can be nil
+func NewPushDecoder(t wav.Type, opts *Options) (*PushDecoder, error) {
+	d := &PushDecoder{t: t, opts: opts}
+	if err := new(Vorbis).setOutputFormat(t); err != nil {
+		return nil, err
+	}
+	d.pq.dmx = &d.dmx
+	if opts != nil && opts.Tracer != nil {
+		d.dmx.OnPage(opts.Tracer.TracePage)
+	}
+	return d, nil
+}
+
+// Write feeds the compressed data, the headers are decoded once they are complete.
+// the error of corrupted stream or headers is sticky.
+func (d *PushDecoder) Write(p []byte) (n int, err error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	if n, err = d.dmx.Write(p); err != nil {
+		d.err = err
+		return
+	}
+	if d.vb == nil && d.dmx.Buffered() >= numHeaderPackets {
+		d.pq.NextPacket()
+		if d.vb, err = newDecoder(&d.pq, d.t, d.opts); err != nil {
+			d.err = err
+			return
+		}
+	}
+	return
+}
+
+// CloseWrite tells the decoder there is no more input, the remaining PCM can still be read
+func (d *PushDecoder) CloseWrite() error {
+	d.eof = true
+	return nil
+}
+
+// Ready reports whether the headers are decoded
+func (d *PushDecoder) Ready() bool {
+	return d.vb != nil
+}
+
+// Decoder reports the underlying decoder for stream info, nil if not Ready.
+// don't Read from it directly.
+func (d *PushDecoder) Decoder() *Vorbis {
+	return d.vb
+}
+
+// ReadAvailable reads the PCM can be decoded from the written data, it never blocks.
+// n is 0 if more input is required. io.EOF is returned after the end of stream is
+// reached, by the last page or CloseWrite, and all the PCM has been read. a corrupted
+// audio packet is dropped and reported by DecodeError, the next call goes on with the
+// following packets. a fault of the decoder is sticky.
+func (d *PushDecoder) ReadAvailable(p []byte) (n int, err error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+	vb := d.vb
+	if vb == nil {
+		if d.eof || d.dmx.EndOfStream() {
+			return 0, io.ErrUnexpectedEOF // ended before headers
+		}
+		return 0, nil
+	}
+	if vb.fault != nil {
+		return 0, vb.fault
+	}
+	defer func() {
+		if x := recover(); x != nil {
+			vb.fault = vb.panicError(x)
+			err = vb.fault
+		}
+	}()
+	for len(p) != 0 {
+		if len(vb.outBuf) == 0 {
+			if d.dmx.Buffered() == 0 {
+				if d.eof || d.dmx.EndOfStream() {
+					if n == 0 {
+						err = io.EOF
+					}
+				}
+				return
+			}
+			if err = vb.decodePacket(); err == io.EOF {
+				// the written packets are not audio
+				err = nil
+				continue
+			} else if err != nil {
+				return // the packet is dropped, the next one can be decoded as well
+			}
+		}
+		n1 := copy(p, vb.outBuf)
+		vb.outBuf = vb.outBuf[n1:]
+		p = p[n1:]
+		n += n1
+	}
+	return
+}
+
+// Position reports the frame position of the next frame returned by ReadAvailable
+func (d *PushDecoder) Position() int64 {
+	if d.vb == nil {
+		return 0
+	}
+	return d.vb.Position()
+}
+
+// SampleType reporst sample's data type
+func (d *PushDecoder) SampleType() wav.Type {
+	return d.t
+}
+
+// Frequency reports the sample frequency, 0 if not Ready
+func (d *PushDecoder) Frequency() int {
+	if d.vb == nil {
+		return 0
+	}
+	return d.vb.Frequency()
+}
+
+// NumTracks reports track count, 0 if not Ready
+func (d *PushDecoder) NumTracks() int {
+	if d.vb == nil {
+		return 0
+	}
+	return d.vb.NumTracks()
+}
+
+// Duration of the audio, it is unknown for push mode
+func (d *PushDecoder) Duration() time.Duration {
+	return 0
+}
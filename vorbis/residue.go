@@ -22,15 +22,20 @@ type sResidue struct {
 func (rs *sResidue) readConfig(vb *Vorbis) bool {
 	rs.typ = vb.pr.ReadBits(16)
 	debug.Println("  read residue config, rs.typ=" + fmt.Sprint(rs.typ))
-	if rs.typ > 3 {
-		fmt.Println("unsupported residues type")
-		return false
+	if rs.typ > 2 {
+		return vb.setupError("unsupported residue type %d", rs.typ)
 	}
 	rs.begin = vb.pr.ReadBits(24)
 	rs.end = vb.pr.ReadBits(24)
 	rs.partiSize = vb.pr.ReadBits(24) + 1
 	rs.classify = vb.pr.ReadBits(6) + 1
 	rs.classbook = vb.pr.ReadBits(8)
+	if rs.classbook >= vb.numCodebooks || vb.codebooks[rs.classbook].codeDims == 0 {
+		return vb.setupError("corrupted residue: classbook %d", rs.classbook)
+	}
+	if rs.begin > rs.end {
+		return vb.setupError("corrupted residue: begin %d > end %d", rs.begin, rs.end)
+	}
 	//rs.cascade = (uint8*) malloc(classifications);
 	for i := uint32(0); i < rs.classify; i++ {
 		var hibits uint32
@@ -46,7 +51,12 @@ func (rs *sResidue) readConfig(vb *Vorbis) bool {
 		rb := &rs.books[i]
 		for j := uint8(0); j < 8; j++ {
 			if (rs.cascade[i]>>j)&0x01 != 0 {
-				rb[j] = int(vb.pr.ReadBits(8)) // TODO: check for overflow?
+				rb[j] = int(vb.pr.ReadBits(8))
+				if uint32(rb[j]) >= vb.numCodebooks ||
+					vb.codebooks[rb[j]].lookupType == 0 ||
+					vb.codebooks[rb[j]].codeDims > 64 {
+					return vb.setupError("corrupted residue: book %d", rb[j])
+				}
 			} else {
 				rb[j] = -1
 			}
@@ -109,7 +119,7 @@ func (rs *sResidue) decodePartiFormat1(vb *Vorbis, _vqbook *sCodeBook, v []float
 		if !_vqbook.decodeVector(vb, entTemp[:_vqbook.codeDims]) {
 			return false
 		}
-		for j := uint32(0); j < _vqbook.codeDims; j++ {
+		for j := uint32(0); j < _vqbook.codeDims && i < n; j++ {
 			v[offset+i] += entTemp[j]
 			i++
 		}
@@ -122,6 +132,13 @@ func (rs *sResidue) decodeFormat01(vb *Vorbis, bufChOrder []*sChannelBuf, chCoun
 	debug.Assert(rs.typ != 2)
 
 	for i := uint32(0); i < chCount; i++ {
+		if fix := bufChOrder[i].fix; fix != nil {
+			v := fix.residue[:_sz]
+			for j := range v {
+				v[j] = 0
+			}
+			continue
+		}
 		v := bufChOrder[i].residue[:_sz]
 		// zero out the memory, write as std for-range, maybe compiler can optimize it
 		for j := range v {
@@ -139,10 +156,10 @@ func (rs *sResidue) decodeFormat01(vb *Vorbis, bufChOrder []*sChannelBuf, chCoun
 	if actualSize < rs.end {
 		limitResidueEnd = actualSize
 	}
-	bytesToRead := limitResidueEnd - limitResidueBegin
-	if bytesToRead == 0 {
-		return true // ???
+	if limitResidueEnd <= limitResidueBegin {
+		return true // nothing to decode
 	}
+	bytesToRead := limitResidueEnd - limitResidueBegin
 	partitionCount := bytesToRead / rs.partiSize
 
 	// 1
@@ -150,12 +167,12 @@ func (rs *sResidue) decodeFormat01(vb *Vorbis, bufChOrder []*sChannelBuf, chCoun
 	classwordsPerCodeword := classbook.codeDims
 	// 2
 	// 3
-	if _sz > 4096 || classwordsPerCodeword > 64 || partitionCount > maxPartitions || chCount > 64 {
-		fmt.Println("internal errors")
+	if _sz > 4096 || classwordsPerCodeword > 64 || partitionCount > maxPartitions {
 		return false
 	}
 
-	var classifications [64][maxPartitions + 64]int
+	const stride = maxPartitions + 64
+	classifications := vb.classBuf[:chCount*stride]
 	for pass := 0; pass < 8; pass++ {
 		var idPart uint32
 		for idPart < partitionCount {
@@ -163,13 +180,16 @@ func (rs *sResidue) decodeFormat01(vb *Vorbis, bufChOrder []*sChannelBuf, chCoun
 			if pass == 0 {
 				for ch := uint32(0); ch < chCount; ch++ {
 					// 8
-					if !bufChOrder[ch].floorUnused {
+					if !bufChOrder[ch].noResidue {
 						// 9
 						temp := classbook.decode(vb)
+						if temp == invalidSymbol {
+							return false // end of packet or corrupted, the remains are zero
+						}
 						// 10
 						for i := int(classwordsPerCodeword) - 1; i >= 0; i-- {
 							cls := temp % rs.classify
-							classifications[ch][i+int(idPart)] = int(cls)
+							classifications[ch*stride+uint32(i)+idPart] = int(cls)
 							temp = temp / rs.classify
 						}
 					}
@@ -180,9 +200,9 @@ func (rs *sResidue) decodeFormat01(vb *Vorbis, bufChOrder []*sChannelBuf, chCoun
 				// 14
 				for ch := uint32(0); ch < chCount; ch++ {
 					buf := bufChOrder[ch]
-					if !buf.floorUnused {
+					if !buf.noResidue {
 						// 16
-						vqclass := classifications[ch][idPart]
+						vqclass := classifications[ch*stride+idPart]
 						// 17
 						vqbookid := rs.books[vqclass][pass]
 						// 18
@@ -192,20 +212,24 @@ func (rs *sResidue) decodeFormat01(vb *Vorbis, bufChOrder []*sChannelBuf, chCoun
 							n := rs.partiSize
 							v := buf.residue[:]
 							offset := limitResidueBegin + idPart*rs.partiSize
-							switch rs.typ {
-							case 0:
+							switch {
+							case buf.fix != nil:
+								if !rs.decodePartiFixed(vb, vqbook, buf.fix.residue, offset, n) {
+									return false
+								}
+							case rs.typ == 0:
 								if !rs.decodePartiFormat0(vb, vqbook, v, offset, n) {
 									return false
 								}
-							case 1:
+							case rs.typ == 1:
 								if !rs.decodePartiFormat1(vb, vqbook, v, offset, n) {
 									return false
 								}
 							}
 						}
 					}
-					idPart++
 				}
+				idPart++
 			}
 		}
 	}
@@ -218,19 +242,30 @@ func (rs *sResidue) decodeFormat2(vb *Vorbis, bufChOrder []*sChannelBuf, chCount
 	debug.Assert(rs.typ == 2)
 	needDecode := false
 	for ch := uint32(0); ch < chCount; ch++ {
-		if !bufChOrder[ch].floorUnused {
+		if !bufChOrder[ch].noResidue {
 			needDecode = true
 		}
 	}
 
 	// 8.6.2
 	actualSize := _sz * chCount
-	vb.requireTempBufSize(actualSize, true)
-
-	v := vb.tempBuf[:actualSize]
-	// zero out the memory, write as std for-range, hope the compiler can optimize it
-	for i := range v {
-		v[i] = 0
+	var v []float32
+	var vfix []int32
+	if vb.opts.FixedPoint {
+		if uint32(len(vb.tempBufFix)) < actualSize {
+			vb.tempBufFix = make([]int32, actualSize)
+		}
+		vfix = vb.tempBufFix[:actualSize]
+		for i := range vfix {
+			vfix[i] = 0
+		}
+	} else {
+		vb.requireTempBufSize(actualSize, true)
+		v = vb.tempBuf[:actualSize]
+		// zero out the memory, write as std for-range, hope the compiler can optimize it
+		for i := range v {
+			v[i] = 0
+		}
 	}
 
 	if needDecode {
@@ -239,18 +274,17 @@ func (rs *sResidue) decodeFormat2(vb *Vorbis, bufChOrder []*sChannelBuf, chCount
 		if actualSize < rs.end {
 			limitResidueEnd = actualSize
 		}
-		bytesToRead := limitResidueEnd - limitResidueBegin
-		if bytesToRead == 0 {
+		if limitResidueEnd <= limitResidueBegin {
 			return true
 		}
+		bytesToRead := limitResidueEnd - limitResidueBegin
 		partitionCount := bytesToRead / rs.partiSize
 
 		// 1
 		classbook := vb.codebooks[rs.classbook]
 		classwordsPerCodeword := classbook.codeDims
 		// 2
-		if _sz > 4096 || classwordsPerCodeword > 64 || partitionCount > maxPartitions || chCount > 64 {
-			fmt.Println("internal errors")
+		if _sz > 4096 || classwordsPerCodeword > 64 || partitionCount > maxPartitions {
 			return false
 		}
 
@@ -265,6 +299,9 @@ func (rs *sResidue) decodeFormat2(vb *Vorbis, bufChOrder []*sChannelBuf, chCount
 				if pass == 0 {
 					// 9
 					temp := classbook.decode(vb)
+					if temp == invalidSymbol {
+						return false
+					}
 					// 10
 					for i := int(classwordsPerCodeword) - 1; i >= 0; i-- {
 						cls := temp % rs.classify
@@ -284,7 +321,11 @@ func (rs *sResidue) decodeFormat2(vb *Vorbis, bufChOrder []*sChannelBuf, chCount
 						vqbook := &vb.codebooks[vqbookid]
 						n := rs.partiSize
 						offset := limitResidueBegin + idPart*n
-						if !rs.decodePartiFormat1(vb, vqbook, v, offset, n) {
+						if vfix != nil {
+							if !rs.decodePartiFormat1Fixed(vb, vqbook, vfix, offset, n) {
+								return false
+							}
+						} else if !rs.decodePartiFormat1(vb, vqbook, v, offset, n) {
 							return false
 						}
 					}
@@ -295,6 +336,15 @@ func (rs *sResidue) decodeFormat2(vb *Vorbis, bufChOrder []*sChannelBuf, chCount
 	}
 
 	// post decode, de-interlacing into origin channel
+	if vfix != nil {
+		for ch := uint32(0); ch < chCount; ch++ {
+			p := vfix[ch:]
+			for i, j := uint32(0), uint32(0); j < actualSize; i, j = i+1, j+chCount {
+				bufChOrder[ch].fix.residue[i] = p[j]
+			}
+		}
+		return true
+	}
 	for ch := uint32(0); ch < chCount; ch++ {
 		p := v[ch:]
 		for i, j := uint32(0), uint32(0); j < actualSize; i, j = i+1, j+chCount {
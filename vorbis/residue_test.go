@@ -0,0 +1,135 @@
+package vorbis
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// testBook creates the codebook of dims 1, the lookup values are muls+1 if not nil
+func testBook(t *testing.T, lens []uint8, muls []uint8) sCodeBook {
+	cb := sCodeBook{codeDims: 1, codeLens: lens}
+	if muls != nil {
+		cb.lookupType = 1
+		cb.valMin, cb.valDelta = 1, 1
+		cb.muls, cb.numLookVals = muls, uint32(len(muls))
+	}
+	if !cb.constructHuffman() {
+		t.Fatal("bad huffman tree")
+	}
+	return cb
+}
+
+// putCode appends the codeword of entry, in a book whose codewords are of the same
+// length, the most significant bit first
+func putCode(bits []byte, entry, length int) []byte {
+	for i := length - 1; i >= 0; i-- {
+		bits = append(bits, byte(entry>>uint(i)&1))
+	}
+	return bits
+}
+
+func TestResidueFormat01(t *testing.T) {
+	const (
+		partitions = 4
+		partiSize  = 2
+		n          = partitions * partiSize
+	)
+	rnd := rand.New(rand.NewSource(1))
+	for _, typ := range []uint32{0, 1} {
+		vb := new(Vorbis)
+		vb.codebooks = []sCodeBook{
+			testBook(t, []uint8{1, 1}, nil),                       // class 0 or 1
+			testBook(t, []uint8{2, 2, 2, 2}, []uint8{0, 1, 2, 3}), // values 1 to 4
+		}
+		vb.numCodebooks = 2
+		vb.classBuf = make([]int, 3*(maxPartitions+64))
+		rs := &sResidue{typ: typ, end: n, partiSize: partiSize, classify: 2}
+		for i := range rs.books {
+			for j := range rs.books[i] {
+				rs.books[i][j] = -1
+			}
+		}
+		rs.books[0][0] = 1 // only class 0 has values, in the first pass
+
+		// 3 channels, the second one has no residue. the classwords of all channels are
+		// followed by the values of all channels, partition by partition
+		var chs [3]sChannelBuf
+		var want [3][n]float32
+		bufs := []*sChannelBuf{&chs[0], &chs[1], &chs[2]}
+		chs[1].noResidue = true
+		var bits []byte
+		for p := 0; p < partitions; p++ {
+			var class [3]int
+			for ch := range chs {
+				if ch != 1 {
+					class[ch] = rnd.Intn(2)
+					bits = putCode(bits, class[ch], 1)
+				}
+			}
+			for ch := range chs {
+				if ch == 1 || class[ch] != 0 {
+					continue
+				}
+				for i := 0; i < partiSize; i++ {
+					v := rnd.Intn(4)
+					bits = putCode(bits, v, 2)
+					want[ch][p*partiSize+i] = float32(v + 1)
+				}
+			}
+		}
+		for ch := range chs {
+			chs[ch].residue = make([]float32, n)
+			chs[ch].residue[0] = -1 // it is cleared
+		}
+		vb.pr = newSliceReader(packBits(bits))
+		if !rs.decodeFormat01(vb, bufs, 3, n) {
+			t.Fatalf("type %d: decode failed", typ)
+		}
+		for ch := range chs {
+			for i, v := range chs[ch].residue {
+				if v != want[ch][i] {
+					t.Fatalf("type %d: channel %d residue %v, want %v", typ, ch, chs[ch].residue, want[ch])
+				}
+			}
+		}
+	}
+}
+
+func TestPropagateNonzero(t *testing.T) {
+	vb := &Vorbis{audioChannels: 5, chnBufs: make([]sChannelBuf, 5)}
+	mp := &sMapping{couplingSteps: 2}
+	mp.magnitude[0], mp.angle[0] = 0, 1
+	mp.magnitude[1], mp.angle[1] = 2, 3
+	unused := []bool{true, false, true, true, true}
+	want := []bool{false, false, true, true, true}
+	for ch := range unused {
+		vb.chnBufs[ch].floorUnused = unused[ch]
+	}
+	vb.propagateNonzero(mp)
+	for ch := range want {
+		if vb.chnBufs[ch].noResidue != want[ch] {
+			t.Fatalf("channel %d: noResidue %v, want %v", ch, vb.chnBufs[ch].noResidue, want[ch])
+		}
+		if vb.chnBufs[ch].floorUnused != unused[ch] {
+			t.Fatalf("channel %d: the floor is changed", ch)
+		}
+	}
+}
+
+func TestSubmapChannels(t *testing.T) {
+	vb := &Vorbis{audioChannels: 5, chnBufs: make([]sChannelBuf, 5)}
+	mp := &sMapping{submaps: 3}
+	copy(mp.mux[:], []uint8{2, 0, 2, 1, 0})
+	for submap, want := range [][]int{{1, 4}, {3}, {0, 2}} {
+		bufs := make([]*sChannelBuf, 5)
+		n := vb.submapChannels(mp, uint8(submap), bufs)
+		if int(n) != len(want) {
+			t.Fatalf("submap %d: %d channels, want %d", submap, n, len(want))
+		}
+		for i, ch := range want {
+			if bufs[i] != &vb.chnBufs[ch] {
+				t.Fatalf("submap %d: channel %d is not %d", submap, i, ch)
+			}
+		}
+	}
+}
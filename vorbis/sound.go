@@ -0,0 +1,131 @@
+package vorbis
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/toy80/audio/ogg"
+	"github.com/toy80/audio/wav"
+)
+
+// Sound is an ogg vorbis stream in memory. the headers are decoded only once, and the
+// setup is shared by the cursors, each cursor is an independent decoder of the stream,
+// so a sound can be played by several players at once without decoding it to wav.Block.
+type Sound struct {
+	data   []byte
+	setup  *Vorbis // read-only after NewSound
+	frames int64
+}
+
+// NewSound creates sound from the ogg vorbis stream, the data must not be modified afterwards
+func NewSound(data []byte, t wav.Type) (s *Sound, err error) {
+	setup, err := New(bytes.NewReader(data), t)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if x := recover(); x != nil {
+			s, err = nil, setup.panicError(x)
+		}
+	}()
+	// locate the first audio page, the cursors will start from there
+	if err := setup.decodePacket(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	setup.pr = nil
+	setup.fault = nil
+	setup.resetDecoder()
+	setup.releaseBuffers() // the cursors have their own
+	return &Sound{data: data, setup: setup, frames: lastGranule(data)}, nil
+}
+
+// LoadSound reads the whole ogg vorbis file into memory
+func LoadSound(filename string, t wav.Type) (*Sound, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return NewSound(data, t)
+}
+
+// lastGranule reports the granule of the last page, it is the frames of stream
+func lastGranule(data []byte) (g int64) {
+	var h ogg.PageHeader
+	r := bytes.NewReader(data)
+	for off := int64(0); off < int64(len(data)); off += int64(h.Size()) {
+		if ogg.ReadPageHeader(r, off, &h) != nil {
+			break
+		}
+		if int64(h.Granule) >= 0 {
+			g = int64(h.Granule)
+		}
+		if h.Last() {
+			break
+		}
+	}
+	return g
+}
+
+// NewCursor creates a decoder at the beginning of sound, it has its own decoding buffers
+// but shares the setup and the compressed data. the cursors can be used concurrently.
+// if the cursor can't be placed at the audio, the error is reported by its Read.
+func (s *Sound) NewCursor() *Vorbis {
+	vb := s.setup.fork()
+	pr := new(ogg.Reader)
+	err := pr.Init(bytes.NewReader(s.data))
+	if err == nil {
+		if vb.audioOffset >= 0 {
+			err = pr.SeekPage(vb.audioOffset)
+		} else {
+			// no audio packets, skip the headers
+			for i := 1; i < numHeaderPackets && err == nil; i++ {
+				err = pr.NextPacket()
+			}
+		}
+	}
+	if err != nil && err != io.EOF {
+		vb.fault = fmt.Errorf("vorbis: cursor: %w", err)
+	}
+	vb.pr = pr
+	vb.packetNo = numHeaderPackets - 1
+	vb.anchored = true
+	return vb
+}
+
+// SampleType reporst sample's data type
+func (s *Sound) SampleType() wav.Type {
+	return s.setup.SampleType()
+}
+
+// Frequency reports the sample frequency. i.e. 441000
+func (s *Sound) Frequency() int {
+	return s.setup.Frequency()
+}
+
+// NumTracks reports track count
+func (s *Sound) NumTracks() int {
+	return s.setup.NumTracks()
+}
+
+// NumFrames reports total frames count, from the granule of the last page
+func (s *Sound) NumFrames() int64 {
+	return s.frames
+}
+
+// Duration of the sound
+func (s *Sound) Duration() time.Duration {
+	return time.Second * time.Duration(s.frames) / time.Duration(s.setup.audioFrameRate)
+}
+
+// Comment reports of the name, i.e.  s.Comment("TITLE")
+func (s *Sound) Comment(name string) string {
+	return s.setup.Comment(name)
+}
+
+// Size reports bytes of the compressed data
+func (s *Sound) Size() int {
+	return len(s.data)
+}
@@ -0,0 +1,92 @@
+package vorbis
+
+import (
+	"io"
+
+	"github.com/toy80/audio/ogg"
+)
+
+// Tracer receives the decoding events, it is for debugging and analysis of streams,
+// i.e. rendering the timeline of block sizes, without a debug.ON build. see Options.Tracer
+type Tracer interface {
+	// TracePage is called after each ogg page header is read, offset is the position of
+	// page in stream. h is valid only during the call.
+	TracePage(offset int64, h *ogg.PageHeader)
+
+	// TracePacket is called after each audio packet is decoded. p and its slices are
+	// reused, they are valid only during the call.
+	TracePacket(p *PacketTrace)
+}
+
+// PacketTrace is the decoding details of an audio packet
+type PacketTrace struct {
+	Packet      int64       // index of the packet in logical stream
+	Mode        int         // mode number
+	BlockFlag   int         // 0 for short block, 1 for long block
+	BlockSize   int         // samples of the block, before overlapping
+	Bits        int         // bits consumed by decoding, -1 if unknown
+	Size        int         // bytes of the packet, -1 if unknown
+	FloorUnused []bool      // of each channel, the channel is silent in this packet if true
+	Floor       [][]float32 // floor curve of each channel, BlockSize/2 values
+	Block       Block       // the PCM frames produced
+}
+
+// bitCounter is the optional interface of PacketReader, see ogg.Reader
+type bitCounter interface {
+	BitsRead() int
+}
+
+// newOggReader creates ogg.Reader of r, with the page events sent to t if not nil
+func newOggReader(r io.Reader, t Tracer) (*ogg.Reader, error) {
+	pr := new(ogg.Reader)
+	if t != nil {
+		pr.OnPage(t.TracePage)
+	}
+	if err := pr.Init(r); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// tracePacket collects the details of the audio packet just decoded, bits is the bits
+// consumed, and sends them to the tracer
+func (vb *Vorbis) tracePacket(modeNumber uint32, bits int, half int) {
+	p := &vb.trace
+	mode := &vb.modes[modeNumber]
+	p.Packet = vb.packetNo
+	p.Mode = int(modeNumber)
+	p.BlockFlag = int(mode.blockflag)
+	p.BlockSize = int(vb.blockSize[mode.blockflag])
+	p.Bits = bits
+	p.Size = -1
+	if x, ok := vb.pr.(interface{ PacketSize() int }); ok {
+		p.Size = x.PacketSize()
+	}
+	channels := int(vb.audioChannels)
+	if cap(p.FloorUnused) < channels {
+		p.FloorUnused = make([]bool, channels)
+		p.Floor = make([][]float32, channels)
+	}
+	p.FloorUnused = p.FloorUnused[:channels]
+	p.Floor = p.Floor[:channels]
+	for ch := range vb.chnBufs {
+		b := &vb.chnBufs[ch]
+		p.FloorUnused[ch] = b.floorUnused
+		if b.fix == nil {
+			p.Floor[ch] = b.floor[:half]
+			continue
+		}
+		// convert the fixed-point floor, the buffer is kept for next packet
+		f := p.Floor[ch]
+		if cap(f) < len(b.fix.floor) {
+			f = make([]float32, len(b.fix.floor))
+		}
+		f = f[:half]
+		for i, x := range b.fix.floor[:half] {
+			f[i] = float32(x) * (1.0 / (1 << fixFloorBits))
+		}
+		p.Floor[ch] = f
+	}
+	p.Block = vb.block
+	vb.opts.Tracer.TracePacket(p)
+}
@@ -3,6 +3,7 @@
 package vorbis
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -10,15 +11,33 @@ import (
 	"os"
 	"time"
 
-	"github.com/toy80/audio/ogg"
 	"github.com/toy80/audio/wav"
 	"github.com/toy80/debug"
 )
 
 const (
-	maxChannels = 20
+	maxChannels = 255
 )
 
+var (
+	// ErrRandomAccess indicates the underlying reader is not seekable
+	ErrRandomAccess = errors.New("vorbis: not random accessible")
+)
+
+// DecodeError is returned when the stream can not be decoded, usually it is corrupted
+type DecodeError struct {
+	Packet int64 // index of the packet in logical stream, the headers are packet 0, 1 and 2
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("vorbis: packet %d: %v", e.Packet, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
 type PacketReader interface {
 	NextPacket() (err error)
 	ReadBits(bits uint32) uint32
@@ -26,11 +45,29 @@ type PacketReader interface {
 	ReadString() string
 }
 
+// pageSeeker is the optional interface of PacketReader to locate pages, see ogg.Reader
+type pageSeeker interface {
+	Seekable() bool
+	PageOffset() int64
+	SeekPage(offset int64) error
+}
+
 // Vorbis decoder
 type Vorbis struct {
 	pr PacketReader
 
+	opts        Options
+	setupMem    int64     // bytes allocated by headers
+	commentMem  int64     // bytes allocated by comments header, part of setupMem
+	setupErr    error     // the first error found in headers
+	setupBits   bitRecord // the setup header read by the parser, Reset compares it
 	headerReady bool
+	audioOffset int64 // offset of the first audio page, -1 if not located yet
+	packetNo    int64 // index of current packet
+	fault       error // the decoder is broken by the error, it is sticky
+	block       Block // the packet decoded last
+	anchored    bool  // the position is known, counted from stream begin or a granule
+	trace       PacketTrace
 
 	vorbisVersion  uint32
 	audioChannels  uint8
@@ -51,10 +88,22 @@ type Vorbis struct {
 
 	mdct [2]MDCT
 
+	// fixed-point decoding, see Options.FixedPoint
+	mdctFix    [2]sMDCTFix
+	slopeFix   [2][]int32
+	tempBufFix []int32
+
+	mem            *sDecodeMem // the pooled memory of buffers
 	chnBufs        []sChannelBuf
+	floorBuf       []int // floor curve
+	classBuf       []int // residue classifications
 	prevWindowFlag int
 	prevBlockSize  uint32
 	idxAutoPacket  uint32    // non-audio packet is excluded
+	statBlocks     [2]int64  // count of short and long blocks
+	statBytes      int64     // bytes of audio packets
+	statFrames     int64     // frames covered by audio packets
+	statBitrate    int       // bitrate of the last packet
 	tempBuf        []float32 // use in decode format 2 residue
 
 	// output format and position
@@ -63,6 +112,15 @@ type Vorbis struct {
 	outBuf      []byte   // current output buffer
 	outBufRes   []byte   // memory reserve for outBuf
 
+	// output tracks, see initLayout
+	outChannels int
+	layout      []wav.Speaker // of output tracks
+	outSrc      []int         // the stream channel of each track, nil if downmix
+	mix         []float32     // downmix gains, outChannels x audioChannels
+	mixFix      []int32       // mix in Q30
+	outPCM      [][]float32   // PCM of each track
+	outPCMFix   [][]int32
+
 	numCodebooks uint32
 	codebooks    []sCodeBook
 	floors       []sFloor
@@ -131,6 +189,15 @@ func (vb *Vorbis) String() string {
 }
 
 func (vb *Vorbis) Read(buf []byte) (n int, err error) {
+	if vb.fault != nil {
+		return 0, vb.fault
+	}
+	defer func() {
+		if x := recover(); x != nil {
+			vb.fault = vb.panicError(x)
+			err = vb.fault
+		}
+	}()
 	return vb.output(buf)
 }
 
@@ -154,9 +221,10 @@ func (vb *Vorbis) Frequency() int {
 	return int(vb.audioFrameRate)
 }
 
-// NumTracks reports track count
+// NumTracks reports track count of the PCM returned by Read, it differs from the
+// stream channels if Options.Downmix is set
 func (vb *Vorbis) NumTracks() int {
-	return int(vb.audioChannels)
+	return vb.outChannels
 }
 
 // Duration of the audio
@@ -189,8 +257,8 @@ func (vb *Vorbis) Comment(name string) string {
 }
 
 func (vb *Vorbis) Init(r io.Reader) (err error) {
-	pr := new(ogg.Reader)
-	if err = pr.Init(r); err != nil {
+	pr, err := newOggReader(r, vb.opts.Tracer)
+	if err != nil {
 		return
 	}
 	vb.pr = pr
@@ -199,6 +267,24 @@ func (vb *Vorbis) Init(r io.Reader) (err error) {
 
 // New vorbis decoder
 func New(r io.Reader, t wav.Type) (vb *Vorbis, err error) {
+	return NewWithOptions(r, t, nil)
+}
+
+// NewWithOptions create vorbis decoder with options, nil means the DefaultOptions
+func NewWithOptions(r io.Reader, t wav.Type, opts *Options) (*Vorbis, error) {
+	var tracer Tracer
+	if opts != nil {
+		tracer = opts.Tracer
+	}
+	pr, err := newOggReader(r, tracer)
+	if err != nil {
+		return nil, err
+	}
+	return newDecoder(pr, t, opts)
+}
+
+// newDecoder parses the headers from pr, which is at the first packet of stream
+func newDecoder(pr PacketReader, t wav.Type, opts *Options) (vb *Vorbis, err error) {
 	defer func() {
 		if err != nil {
 			vb = nil
@@ -206,25 +292,173 @@ func New(r io.Reader, t wav.Type) (vb *Vorbis, err error) {
 	}()
 
 	vb = new(Vorbis)
-	if err = vb.setOutputFormat(t); err != nil {
-		return
+	defer func() {
+		if x := recover(); x != nil {
+			err = vb.panicError(x)
+		}
+	}()
+	if opts != nil {
+		vb.opts = *opts
 	}
-
-	if err = vb.Init(r); err != nil {
+	vb.opts.normalize()
+	if err = vb.setOutputFormat(t); err != nil {
 		return
 	}
+	vb.pr = pr
 
 	if !vb.parseVorbisHeaders() {
-		err = errors.New("failed to read vorbis headers")
+		err = &DecodeError{Packet: vb.packetNo, Err: vb.headerError()}
 		return
 	}
+	vb.audioOffset = -1
+	vb.anchored = true
 
-	vb.chnBufs = make([]sChannelBuf, vb.audioChannels)
+	vb.mdct[0].init(int(vb.blockSize[0]))
+	vb.mdct[1].init(int(vb.blockSize[1]))
 	vb.initOverlap()
-	vb.requireTempBufSize(vb.blockSize[1], true)
+	vb.initLayout()
+	if vb.opts.FixedPoint {
+		vb.initFixed()
+	}
+	vb.allocBuffers()
 	return
 }
 
+// fork a new decoder that shares the read-only setup with vb, but has its own
+// decoding buffers. the packet reader is not set.
+func (vb *Vorbis) fork() *Vorbis {
+	x := new(Vorbis)
+	*x = *vb
+	x.pr = nil
+	x.mem = nil
+	x.trace = PacketTrace{}
+	for i := range x.mdct {
+		x.mdct[i].buf = make([]float32, x.mdct[i].N)
+		if x.opts.FixedPoint {
+			x.mdctFix[i].buf = make([]int32, len(x.mdctFix[i].buf))
+		}
+	}
+	x.allocBuffers()
+	x.resetDecoder()
+	x.anchored = false // the position is unknown until a granule is met
+	return x
+}
+
+// panicError converts the recovered value of a panic into DecodeError
+func (vb *Vorbis) panicError(x interface{}) error {
+	err, ok := x.(error)
+	if !ok {
+		err = fmt.Errorf("%v", x)
+	}
+	return &DecodeError{Packet: vb.packetNo, Err: fmt.Errorf("internal fault: %w", err)}
+}
+
+// nextPacket turns to the next packet, and counts the packet index
+func (vb *Vorbis) nextPacket() error {
+	err := vb.pr.NextPacket()
+	if err == nil {
+		vb.packetNo++
+	}
+	return err
+}
+
+func (vb *Vorbis) headerError() error {
+	if vb.setupErr != nil {
+		return vb.setupErr
+	}
+	return errors.New("failed to read vorbis headers")
+}
+
+// the first audio packet always begin with a fresh page, called when it is read
+func (vb *Vorbis) markAudioOffset() {
+	vb.audioOffset = 0
+	if ps, ok := vb.pr.(pageSeeker); ok {
+		vb.audioOffset = ps.PageOffset()
+	}
+}
+
+// reset the decoding state, so the next packet is decoded as the first one
+func (vb *Vorbis) resetDecoder() {
+	vb.outBuf = nil
+	vb.prevWindowFlag = 0
+	vb.prevBlockSize = 0
+	vb.idxAutoPacket = 0
+	vb.statBlocks = [2]int64{}
+	vb.statBytes = 0
+	vb.statFrames = 0
+	vb.statBitrate = 0
+	vb.block = Block{}
+	vb.anchored = true
+}
+
+// CanRewind reports whether the decoder can rewind
+func (vb *Vorbis) CanRewind() bool {
+	ps, ok := vb.pr.(pageSeeker)
+	return ok && ps.Seekable()
+}
+
+// Rewind to the first audio packet, the headers are not parsed again
+func (vb *Vorbis) Rewind() error {
+	ps, ok := vb.pr.(pageSeeker)
+	if !ok || !ps.Seekable() {
+		return ErrRandomAccess
+	}
+	if vb.audioOffset < 0 {
+		return nil // not started yet
+	}
+	if err := ps.SeekPage(vb.audioOffset); err != nil {
+		return err
+	}
+	vb.resetDecoder()
+	vb.packetNo = numHeaderPackets - 1
+	vb.fault = nil
+	return nil
+}
+
+// Reset discards current stream and continue to decode r, the buffers are reused.
+// r must share the same setup header with current stream, i.e. encoded with same
+// encoder and settings, otherwise an error is returned and the decoder is unchanged.
+// The previous underlying reader is not closed.
+func (vb *Vorbis) Reset(r io.Reader) (err error) {
+	// the headers are parsed into h, the decoder is updated after they are verified
+	h := &Vorbis{opts: vb.opts, setupMem: vb.setupMem - vb.commentMem}
+	if err = h.Init(r); err != nil {
+		return
+	}
+	if !h.parseIdentHeader() || !h.parseCommentsHeader() {
+		return &DecodeError{Packet: h.packetNo, Err: h.headerError()}
+	}
+	if h.audioChannels != vb.audioChannels || h.audioFrameRate != vb.audioFrameRate || h.blockSize != vb.blockSize {
+		return errors.New("vorbis: reset with different stream setup")
+	}
+	if err = h.nextPacket(); err != nil {
+		return errors.New("vorbis: missing setup header")
+	}
+	var setup bitRecord
+	for setup.n < vb.setupBits.n {
+		bits := uint32(vb.setupBits.n - setup.n)
+		if bits > 32 {
+			bits = 32
+		}
+		setup.put(h.pr.ReadBits(bits), bits)
+	}
+	if !bytes.Equal(setup.data, vb.setupBits.data) {
+		return errors.New("vorbis: reset with different setup header")
+	}
+
+	vb.pr = h.pr
+	vb.vorbisVersion = h.vorbisVersion
+	vb.maxBitrate, vb.nomBitrate, vb.minBitrate = h.maxBitrate, h.nomBitrate, h.minBitrate
+	vb.vendor, vb.comments = h.vendor, h.comments
+	vb.setupMem, vb.commentMem = h.setupMem, h.commentMem
+	vb.resetDecoder()
+	vb.setupErr = nil
+	vb.packetNo = h.packetNo
+	vb.fault = nil
+	vb.audioOffset = -1
+	return nil
+}
+
 // Open vorbis file
 func Open(filename string) (*Vorbis, error) {
 	f, err := os.Open(filename)
@@ -239,7 +473,10 @@ func Open(filename string) (*Vorbis, error) {
 	return vb, nil
 }
 
+// Close closes the underlying reader if it is an io.Closer, and puts the decoding buffers
+// back to pool. the decoder must not be used after Close.
 func (vb *Vorbis) Close() error {
+	vb.releaseBuffers()
 	if c, ok := vb.pr.(interface{ Close() error }); ok {
 		return c.Close()
 	}
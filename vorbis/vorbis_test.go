@@ -2,8 +2,17 @@ package vorbis
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"log"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/toy80/audio/ogg"
@@ -59,3 +68,937 @@ func BenchmarkVorbis(b *testing.B) {
 	b.SetBytes(sz / int64(b.N))
 	b.ReportAllocs()
 }
+
+func TestRewind(t *testing.T) {
+	vb, err := New(bytes.NewReader(oggfile1), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !vb.CanRewind() {
+		t.Fatal("bytes.Reader should be rewindable")
+	}
+	data1, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = vb.Rewind(); err != nil {
+		t.Fatal(err)
+	}
+	data2, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data1, data2) {
+		t.Fatalf("rewind output miss-match, %d != %d bytes", len(data2), len(data1))
+	}
+}
+
+func TestReset(t *testing.T) {
+	vb, err := New(bytes.NewReader(oggfile1), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf [12345]byte
+	if _, err = vb.Read(buf[:]); err != nil {
+		t.Fatal(err)
+	}
+	if err = vb.Reset(bytes.NewReader(oggfile1)); err != nil {
+		t.Fatal(err)
+	}
+	data1, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vb, err = New(bytes.NewReader(oggfile1), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data2, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data1, data2) {
+		t.Fatalf("reset output miss-match, %d != %d bytes", len(data1), len(data2))
+	}
+
+	// the streams of other setup are refused, and the current one goes on
+	rate := append([]byte(nil), oggfile1...)
+	rate[28+12] ^= 1 // the sample rate in identification header
+	vb, err = New(bytes.NewReader(oggfile1), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.ReadFull(vb, buf[:]); err != nil {
+		t.Fatal(err)
+	}
+	for i, data := range [][]byte{rate, threeModes(t, oggfile1, -1), oggfile1[:100]} {
+		if err = vb.Reset(bytes.NewReader(data)); err == nil {
+			t.Fatalf("stream %d: expect error", i)
+		}
+	}
+	data1, err = io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(append(buf[:], data1...), data2) {
+		t.Fatalf("output miss-match after failed reset, %d != %d bytes", len(buf)+len(data1), len(data2))
+	}
+}
+
+func TestDecodeParallel(t *testing.T) {
+	// the cut stream has a leading trim and an end trim
+	var cut bytes.Buffer
+	if err := Cut(&cut, bytes.NewReader(oggfile1), 12345, 678901); err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{oggfile1, cut.Bytes()} {
+		vb, err := New(bytes.NewReader(data), wav.I16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := io.ReadAll(vb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, workers := range []int{1, 2, 3, 4, 8, 100} {
+			block, err := DecodeParallel(bytes.NewReader(data), int64(len(data)), workers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(block)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%d workers: output miss-match, %d != %d bytes", workers, len(got), len(want))
+			}
+		}
+	}
+}
+
+func TestInfo(t *testing.T) {
+	vb, err := New(bytes.NewReader(oggfile1), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := vb.Info()
+	if info.Channels != 2 || info.SampleRate != 44100 || info.NomBitrate != 112000 ||
+		info.BlockSize != [2]int{256, 2048} || info.Vendor != vb.Vendor() {
+		t.Fatalf("bad stream info: %+v", info)
+	}
+	data, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info = vb.Info()
+	if info.ShortBlocks == 0 || info.LongBlocks == 0 || info.Bitrate == 0 {
+		t.Fatalf("bad statistics: %+v", info)
+	}
+	// the file bitrate, include the headers and ogg pages
+	frames := int64(len(data) / 4)
+	// the frames of packets, the output differs by the trimmed frames only
+	if vb.statFrames < frames || vb.statFrames-frames >= int64(info.BlockSize[1]) {
+		t.Fatalf("statistics count %d frames, decoded %d frames", vb.statFrames, frames)
+	}
+	bitrate := int(int64(len(oggfile1)) * 8 * 44100 / frames)
+	if info.AvgBitrate > bitrate || info.AvgBitrate < bitrate*9/10 {
+		t.Fatalf("average bitrate %d, file bitrate %d", info.AvgBitrate, bitrate)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	for _, opts := range []Options{
+		{MaxChannels: 1},
+		{MaxSetupMemory: 1024},
+		{MaxCodebookEntries: 2},
+	} {
+		_, err := NewWithOptions(bytes.NewReader(oggfile1), wav.I16, &opts)
+		if !errors.Is(err, ErrLimitExceeded) {
+			t.Fatalf("%+v: expect ErrLimitExceeded, got %v", opts, err)
+		}
+	}
+	if _, err := NewWithOptions(bytes.NewReader(oggfile1), wav.I16, &DefaultOptions); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCorrupted(t *testing.T) {
+	// truncated and bit-flipped copies must not crash the decoder
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 32; i++ {
+		data := append([]byte(nil), oggfile1...)
+		if i&1 == 0 {
+			data = data[:rnd.Intn(len(data))]
+		}
+		for j := 0; j < 1+i%8; j++ {
+			data[rnd.Intn(len(data))] ^= byte(1 << uint(rnd.Intn(8)))
+		}
+		if s, err := NewSound(data, wav.F32); err == nil {
+			io.Copy(io.Discard, s.NewCursor())
+		}
+		vb, err := New(bytes.NewReader(data), wav.F32)
+		if err != nil {
+			continue
+		}
+		io.Copy(io.Discard, vb)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	opts := Options{MaxChannels: 1}
+	_, err := NewWithOptions(bytes.NewReader(oggfile1), wav.I16, &opts)
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Packet != 0 {
+		t.Fatalf("expect DecodeError at packet 0, got %v", err)
+	}
+}
+
+func TestSetupError(t *testing.T) {
+	badBook := append([]byte(nil), oggfile1...)
+	badBook[bytes.Index(badBook, []byte("BCV"))] = 0
+	for _, c := range []struct {
+		data   []byte
+		opts   Options
+		reason string
+	}{
+		{badBook, DefaultOptions, "corrupted codebook 0"},
+		{oggfile1, Options{MaxChannels: 1}, "2 channels > 1"},
+	} {
+		// the reason is in the error, nothing is printed
+		stdout := os.Stdout
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Stdout = w
+		_, err = NewWithOptions(bytes.NewReader(c.data), wav.I16, &c.opts)
+		os.Stdout = stdout
+		w.Close()
+		printed, _ := io.ReadAll(r)
+		r.Close()
+		if err == nil || !strings.Contains(err.Error(), c.reason) || len(printed) != 0 {
+			t.Fatalf("expect %q, got %v, printed %q", c.reason, err, printed)
+		}
+	}
+}
+
+// dropReader drops the packet of index drop, to simulate a lost packet
+type dropReader struct {
+	*ogg.Reader
+	n    int
+	drop int
+}
+
+func (r *dropReader) NextPacket() error {
+	r.n++
+	if r.n == r.drop {
+		if err := r.Reader.NextPacket(); err != nil {
+			return err
+		}
+	}
+	return r.Reader.NextPacket()
+}
+
+func TestPosition(t *testing.T) {
+	const lastGranule = 1251039 // granule of the last page of oggfile1
+	vb, err := New(bytes.NewReader(oggfile1), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf [1000]byte
+	n, _ := vb.Read(buf[:])
+	if vb.Position() != int64(n/4) {
+		t.Fatalf("position %d after read %d frames", vb.Position(), n/4)
+	}
+	// the rest of current block, then the followings are contiguous
+	frames := int64(n / 4)
+	for {
+		b, data, err := vb.ReadBlock()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if b.Begin != frames || b.Frames != len(data)/4 {
+			t.Fatalf("block %+v, expect begin at %d with %d frames", b, frames, len(data)/4)
+		}
+		frames += int64(b.Frames)
+	}
+	if frames != lastGranule || vb.Position() != lastGranule {
+		t.Fatalf("decoded %d frames, position %d, expect %d", frames, vb.Position(), lastGranule)
+	}
+
+	if err = vb.Rewind(); err != nil {
+		t.Fatal(err)
+	}
+	if vb.Position() != 0 {
+		t.Fatalf("position %d after rewind", vb.Position())
+	}
+
+	// the position is corrected by the granule after the dropped packet
+	pr := new(ogg.Reader)
+	if err = pr.Init(bytes.NewReader(oggfile1)); err != nil {
+		t.Fatal(err)
+	}
+	vb.pr = &dropReader{Reader: pr, drop: 10}
+	if !vb.parseVorbisHeaders() {
+		t.Fatal("failed to parse headers")
+	}
+	vb.resetDecoder()
+	if _, err = io.ReadAll(vb); err != nil {
+		t.Fatal(err)
+	}
+	if vb.Position() != lastGranule {
+		t.Fatalf("position %d after dropped packet, expect %d", vb.Position(), lastGranule)
+	}
+}
+
+func TestSound(t *testing.T) {
+	vb, err := New(bytes.NewReader(oggfile1), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSound(oggfile1, wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.NumFrames() != int64(len(want)/4) {
+		t.Fatalf("sound has %d frames, decoded %d", s.NumFrames(), len(want)/4)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(c *Vorbis) {
+			defer wg.Done()
+			got, err := io.ReadAll(c)
+			if err == nil && !bytes.Equal(got, want) {
+				err = fmt.Errorf("cursor output miss-match, %d != %d bytes", len(got), len(want))
+			}
+			errs <- err
+		}(s.NewCursor())
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCursorError(t *testing.T) {
+	s, err := NewSound(oggfile1, wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first audio page is cut, the cursor can't be placed there
+	cut := *s
+	cut.data = s.data[:s.setup.audioOffset+10]
+	c := cut.NewCursor()
+	for i := 0; i < 2; i++ {
+		if n, err := c.Read(make([]byte, 64)); n != 0 || err == nil || err == io.EOF {
+			t.Fatalf("read %d bytes, %v", n, err)
+		}
+	}
+}
+
+func TestPushDecoder(t *testing.T) {
+	vb, err := New(bytes.NewReader(oggfile1), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []int{1000, 4096, 77777} {
+		d, err := NewPushDecoder(wav.I16, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []byte
+		var buf [3000]byte
+		for off := 0; ; {
+			n, err := d.ReadAvailable(buf[:])
+			got = append(got, buf[:n]...)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if n == 0 {
+				if off == len(oggfile1) {
+					t.Fatal("expect io.EOF at the end of stream")
+				}
+				end := off + step
+				if end > len(oggfile1) {
+					end = len(oggfile1)
+				}
+				if _, err = d.Write(oggfile1[off:end]); err != nil {
+					t.Fatal(err)
+				}
+				off = end
+			}
+		}
+		if !d.Ready() || d.Frequency() != 44100 || d.Position() != int64(len(want)/4) {
+			t.Fatalf("step %d: bad stream info or position %d", step, d.Position())
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("step %d: output miss-match, %d != %d bytes", step, len(got), len(want))
+		}
+	}
+}
+
+// unpackBits splits the packet into bits, least significant first as vorbis does
+func unpackBits(p []byte) []byte {
+	bits := make([]byte, 8*len(p))
+	for i := range bits {
+		bits[i] = p[i>>3] >> uint(i&7) & 1
+	}
+	return bits
+}
+
+func packBits(bits []byte) []byte {
+	p := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		p[i>>3] |= b << uint(i&7)
+	}
+	return p
+}
+
+// threeModes rewrites the stream of two modes with a third mode, the mode numbers of
+// the audio packets are widened to 2 bits. the audio packet bad has the mode number 3,
+// that is out of range.
+func threeModes(t *testing.T, data []byte, bad int) []byte {
+	var out bytes.Buffer
+	w := ogg.NewWriter(&out, 1)
+	err := demuxPackets(bytes.NewReader(data), nil, func(p ogg.Packet) error {
+		bits := unpackBits(p.Data)
+		switch {
+		case p.Index == numHeaderPackets-1:
+			// the modes of 41 bits and the framing bit end the setup header
+			end := len(bits) - 1
+			for bits[end] == 0 {
+				end--
+			}
+			modes := end - 2*41
+			if n := packBits(bits[modes-6 : modes])[0]; n != 1 {
+				t.Fatalf("%d modes", n+1)
+			}
+			copy(bits[modes-6:], []byte{0, 1, 0, 0, 0, 0})
+			bits = append(bits[:end:end], bits[end-41:end]...)
+			bits = append(bits, 1)
+		case p.Index >= numHeaderPackets:
+			mode := bits[1]
+			if p.Index == bad {
+				mode = 3
+			}
+			bits = append([]byte{bits[0], mode & 1, mode >> 1}, bits[2:]...)
+		}
+		return w.WritePacket(packBits(bits), p.Granule)
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestPushDecodeError(t *testing.T) {
+	vb, err := New(bytes.NewReader(oggfile1), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const bad = numHeaderPackets + 20
+	for _, corrupt := range []bool{false, true} {
+		data := threeModes(t, oggfile1, -1)
+		if corrupt {
+			data = threeModes(t, oggfile1, bad)
+		}
+		d, err := NewPushDecoder(wav.I16, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = d.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		var got []byte
+		var errs []error
+		buf := make([]byte, 3000)
+		for {
+			n, err := d.ReadAvailable(buf)
+			got = append(got, buf[:n]...)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				errs = append(errs, err)
+			} else if n == 0 {
+				t.Fatal("expect io.EOF at the end of stream")
+			}
+		}
+		if !corrupt {
+			if len(errs) != 0 || !bytes.Equal(got, want) {
+				t.Fatalf("output miss-match, %d != %d bytes, %v", len(got), len(want), errs)
+			}
+			continue
+		}
+		var de *DecodeError
+		if len(errs) != 1 || !errors.As(errs[0], &de) || de.Packet != bad {
+			t.Fatalf("expect DecodeError at packet %d, got %v", bad, errs)
+		}
+		// the packets after the dropped one are decoded
+		if len(got) == 0 || d.Position() != int64(len(want)/4) {
+			t.Fatalf("%d bytes, position %d", len(got), d.Position())
+		}
+	}
+}
+
+// crc32 of the fixed-point I16 output of oggfile1
+const fixedPointCRC uint32 = 0x7a75468b
+
+func TestFixedPoint(t *testing.T) {
+	vb, err := New(bytes.NewReader(oggfile1), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vb, err = NewWithOptions(bytes.NewReader(oggfile1), wav.I16, &Options{FixedPoint: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("length miss-match, %d != %d bytes", len(got), len(want))
+	}
+	maxDiff := 0
+	for i := 0; i < len(got); i += 2 {
+		d := int(int16(uint16(got[i])|uint16(got[i+1])<<8)) - int(int16(uint16(want[i])|uint16(want[i+1])<<8))
+		if d < 0 {
+			d = -d
+		}
+		if d > maxDiff {
+			maxDiff = d
+		}
+	}
+	if maxDiff > 2 {
+		t.Fatalf("fixed-point output differs too much, %d LSB", maxDiff)
+	}
+	// the output is bit-identical on every platform, pinned by the checksum
+	if sum := crc32.ChecksumIEEE(got); sum != fixedPointCRC {
+		t.Fatalf("fixed-point output checksum %#08x, want %#08x", sum, fixedPointCRC)
+	}
+}
+
+func TestReadAllocs(t *testing.T) {
+	for _, opts := range []Options{{}, {FixedPoint: true}} {
+		vb, err := NewWithOptions(bytes.NewReader(oggfile1), wav.I16, &opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf [1024]byte
+		allocs := testing.AllocsPerRun(2, func() {
+			if err := vb.Rewind(); err != nil {
+				t.Fatal(err)
+			}
+			for {
+				_, err := vb.Read(buf[:])
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+		if allocs != 0 {
+			t.Fatalf("fixed-point %v: %v allocations per decoding", opts.FixedPoint, allocs)
+		}
+		vb.Close()
+	}
+}
+
+type testTracer struct {
+	pages   []int64
+	packets int
+	frames  int64
+	err     error
+}
+
+func (tr *testTracer) TracePage(offset int64, h *ogg.PageHeader) {
+	tr.pages = append(tr.pages, offset)
+}
+
+func (tr *testTracer) TracePacket(p *PacketTrace) {
+	tr.packets++
+	tr.frames += int64(p.Block.Frames)
+	switch {
+	case tr.err != nil:
+	case p.BlockSize != 256<<(3*p.BlockFlag):
+		tr.err = fmt.Errorf("packet %d: block size %d", p.Packet, p.BlockSize)
+	case p.Bits <= 0 || p.Bits > 8*p.Size:
+		tr.err = fmt.Errorf("packet %d: %d bits consumed of %d bytes", p.Packet, p.Bits, p.Size)
+	case len(p.Floor) != 2 || len(p.FloorUnused) != 2 || len(p.Floor[0]) != p.BlockSize/2:
+		tr.err = fmt.Errorf("packet %d: bad floor curves", p.Packet)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	var pages []int64
+	var h ogg.PageHeader
+	for off := int64(0); off < int64(len(oggfile1)); off += int64(h.Size()) {
+		if err := ogg.ReadPageHeader(bytes.NewReader(oggfile1), off, &h); err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, off)
+	}
+	for _, fixed := range []bool{false, true} {
+		tr := new(testTracer)
+		vb, err := NewWithOptions(bytes.NewReader(oggfile1), wav.I16, &Options{Tracer: tr, FixedPoint: fixed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(vb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tr.err != nil {
+			t.Fatal(tr.err)
+		}
+		if fmt.Sprint(tr.pages) != fmt.Sprint(pages) {
+			t.Fatalf("traced %d pages, want %d", len(tr.pages), len(pages))
+		}
+		if tr.frames != int64(len(data)/4) || int64(tr.packets) != vb.block.Packet-numHeaderPackets+1 {
+			t.Fatalf("traced %d packets %d frames", tr.packets, tr.frames)
+		}
+	}
+}
+
+func TestLayout(t *testing.T) {
+	vb := new(Vorbis)
+	vb.audioChannels = 6
+	vb.opts.ChannelOrder = WaveOrder
+	vb.initLayout()
+	if fmt.Sprint(vb.outSrc) != "[0 2 1 5 3 4]" || fmt.Sprint(vb.layout) != "[FL FR FC LFE BL BR]" {
+		t.Fatalf("5.1 in wave order: %v %v", vb.outSrc, vb.layout)
+	}
+	vb.audioChannels = 9
+	vb.initLayout()
+	if fmt.Sprint(vb.outSrc) != "[0 1 2 3 4 5 6 7 8]" {
+		t.Fatalf("unknown layout is reordered: %v", vb.outSrc)
+	}
+
+	vb.audioChannels = 6
+	vb.opts.Downmix = 2
+	vb.initLayout()
+	const g = 0.7071068 / (1 + 2*0.7071068)
+	want := []float32{1 / (1 + 2*0.7071068), g, 0, g, 0, 0, 0, g, 1 / (1 + 2*0.7071068), 0, g, 0}
+	for i, x := range vb.mix {
+		if d := x - want[i]; d > 1e-6 || d < -1e-6 || vb.outChannels != 2 {
+			t.Fatalf("5.1 stereo downmix gains: %v", vb.mix)
+		}
+	}
+
+	// downmix of the stereo stream to mono
+	vb, err := New(bytes.NewReader(oggfile1), wav.F32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stereo, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fixed := range []bool{false, true} {
+		vb, err = NewWithOptions(bytes.NewReader(oggfile1), wav.F32, &Options{Downmix: 1, FixedPoint: fixed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		mono, err := io.ReadAll(vb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vb.NumTracks() != 1 || len(mono)*2 != len(stereo) {
+			t.Fatalf("downmix to %d tracks, %d bytes", vb.NumTracks(), len(mono))
+		}
+		for i := 0; i < len(mono); i += 4 {
+			l := math.Float32frombits(binary.LittleEndian.Uint32(stereo[2*i:]))
+			r := math.Float32frombits(binary.LittleEndian.Uint32(stereo[2*i+4:]))
+			m := math.Float32frombits(binary.LittleEndian.Uint32(mono[i:]))
+			if d := m - (l+r)/2; d > 1e-4 || d < -1e-4 {
+				t.Fatalf("frame %d: downmix %v of %v and %v", i/4, m, l, r)
+			}
+		}
+	}
+}
+
+// checkPages verifies the checksums of ogg pages
+func checkPages(t *testing.T, data []byte) {
+	var h ogg.PageHeader
+	for off := int64(0); off < int64(len(data)); off += int64(h.Size()) {
+		if err := ogg.ReadPageHeader(bytes.NewReader(data), off, &h); err != nil {
+			t.Fatal(err)
+		}
+		if sum := ogg.PageChecksum(data[off : off+int64(h.Size())]); sum != h.Checksum {
+			t.Fatalf("page at %d: checksum %#x, want %#x", off, sum, h.Checksum)
+		}
+	}
+}
+
+func TestCut(t *testing.T) {
+	checkPages(t, oggfile1)
+	vb, err := New(bytes.NewReader(oggfile1), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := int64(len(full) / 4)
+	for _, r := range [][2]int64{{0, total}, {0, 1000}, {12345, 678901}, {1000000, total}, {128, 1152}, {500000, 500100}} {
+		var out bytes.Buffer
+		err := Cut(&out, bytes.NewReader(oggfile1), r[0], r[1])
+		if r[1]-r[0] == 100 {
+			if err == nil {
+				t.Fatalf("cut %v: expect error of range within a packet", r)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("cut %v: %v", r, err)
+		}
+		checkPages(t, out.Bytes())
+		vb, err := New(bytes.NewReader(out.Bytes()), wav.I16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(vb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, full[4*r[0]:4*r[1]]) {
+			t.Fatalf("cut %v: %d frames decoded, miss-match", r, len(got)/4)
+		}
+		if s, err := NewSound(out.Bytes(), wav.I16); err != nil || s.NumFrames() != r[1]-r[0] {
+			t.Fatalf("cut %v: sound of %d frames, %v", r, s.NumFrames(), err)
+		}
+	}
+	if err := Cut(io.Discard, bytes.NewReader(oggfile1), 0, total+1); err == nil {
+		t.Fatal("expect error of range out of stream")
+	}
+}
+
+func TestConcat(t *testing.T) {
+	vb, err := New(bytes.NewReader(oggfile1), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same setup, merged into one stream. the padding at the joints is decoded, only
+	// the end of the last stream is trimmed, so the output matches the decoder which
+	// follows nothing but the last granule. the middle one has padding at the end.
+	var cut, out bytes.Buffer
+	if err = Cut(&cut, bytes.NewReader(oggfile1), 0, 50000); err != nil {
+		t.Fatal(err)
+	}
+	vb, err = New(bytes.NewReader(cut.Bytes()), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	middle, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Concat(&out, bytes.NewReader(oggfile1), bytes.NewReader(cut.Bytes()), bytes.NewReader(oggfile1)); err != nil {
+		t.Fatal(err)
+	}
+	checkPages(t, out.Bytes())
+	if streams, _ := splitStreams(t, out.Bytes()); len(streams) != 1 {
+		t.Fatalf("merged: %d logical streams", len(streams))
+	}
+	want := endTrimDecode(t, out.Bytes())
+	if !bytes.HasPrefix(want, full) || !bytes.HasSuffix(want, full) ||
+		!bytes.Contains(want[len(full):len(want)-len(full)], middle) {
+		t.Fatalf("merged: %d frames decoded, miss the sources", len(want)/4)
+	}
+	if pad := len(want) - 2*len(full) - len(middle); pad <= 0 || pad > 4*4096 {
+		t.Fatalf("merged: %d frames of padding at the joints", pad/4)
+	}
+	vb, err = New(bytes.NewReader(out.Bytes()), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("merged: %d frames decoded, want %d", len(got)/4, len(want)/4)
+	}
+	for _, workers := range []int{3, 8, 100} {
+		block, err := DecodeParallel(bytes.NewReader(out.Bytes()), int64(out.Len()), workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err = io.ReadAll(block); err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("merged: %d workers: output miss-match, %d != %d bytes", workers, len(got), len(want))
+		}
+	}
+	// cut the merged stream across the joints
+	cut.Reset()
+	from, to := int64(len(full)/4-1000), int64(len(full)/4+50000+1000)
+	if err = Cut(&cut, bytes.NewReader(out.Bytes()), from, to); err != nil {
+		t.Fatal(err)
+	}
+	vb, err = New(bytes.NewReader(cut.Bytes()), wav.I16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err = io.ReadAll(vb); err != nil || !bytes.Equal(got, want[from*4:to*4]) {
+		t.Fatalf("merged: cut %d frames, want %d, %v", len(got)/4, to-from, err)
+	}
+
+	// another frame rate, chained with unique serial numbers
+	other := append([]byte(nil), oggfile1...)
+	other[27+1+12]++ // the frame rate of ident header, the first page has one segment
+	binary.LittleEndian.PutUint32(other[22:], ogg.PageChecksum(other[:27+1+30]))
+	out.Reset()
+	if err = Concat(&out, bytes.NewReader(oggfile1), bytes.NewReader(other), bytes.NewReader(oggfile1)); err != nil {
+		t.Fatal(err)
+	}
+	checkPages(t, out.Bytes())
+	streams, serials := splitStreams(t, out.Bytes())
+	if len(streams) != 3 || serials[0] == serials[1] || serials[1] == serials[2] || serials[0] == serials[2] {
+		t.Fatalf("chained: serial numbers %v", serials)
+	}
+	for i, data := range streams {
+		vb, err = New(bytes.NewReader(data), wav.I16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err = io.ReadAll(vb); err != nil || !bytes.Equal(got, full) {
+			t.Fatalf("chained stream %d: %d frames decoded, want %d, %v", i, len(got)/4, len(full)/4, err)
+		}
+	}
+}
+
+// endTrimReader hides the page granules but the last one, from the decoder
+type endTrimReader struct {
+	*ogg.Reader
+}
+
+func (r endTrimReader) Granule() int64 {
+	if !r.LastPage() {
+		return -1
+	}
+	return r.Reader.Granule()
+}
+
+// endTrimDecode decodes the stream by the granule of the last page only, i.e. every frame
+// decoded is played but the padding at the end of stream
+func endTrimDecode(t *testing.T, data []byte) []byte {
+	pr, err := newOggReader(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vb, err := newDecoder(endTrimReader{pr}, wav.I16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcm, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pcm
+}
+
+// splitStreams splits the chained ogg streams, and reports their serial numbers
+func splitStreams(t *testing.T, data []byte) (streams [][]byte, serials []uint32) {
+	var h ogg.PageHeader
+	var begin int64
+	for off := int64(0); off < int64(len(data)); off += int64(h.Size()) {
+		if err := ogg.ReadPageHeader(bytes.NewReader(data), off, &h); err != nil {
+			t.Fatal(err)
+		}
+		if h.First() {
+			serials = append(serials, h.Stream)
+		}
+		if h.Last() {
+			streams = append(streams, data[begin:off+int64(h.Size())])
+			begin = off + int64(h.Size())
+		}
+	}
+	return
+}
+
+// sampleAt decodes the sample i of pcm of type typ, scaled to [-1, 1)
+func sampleAt(pcm []byte, typ wav.Type, i int) float64 {
+	switch typ {
+	case wav.I24:
+		b := pcm[3*i:]
+		return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)) / (1 << 31)
+	case wav.I32:
+		return float64(int32(binary.LittleEndian.Uint32(pcm[4*i:]))) / (1 << 31)
+	case wav.F32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(pcm[4*i:])))
+	case wav.F64:
+		return math.Float64frombits(binary.LittleEndian.Uint64(pcm[8*i:]))
+	}
+	panic(typ)
+}
+
+func TestWideOutput(t *testing.T) {
+	vb, err := New(bytes.NewReader(oggfile1), wav.F32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := io.ReadAll(vb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(want) / 4
+	for _, fixed := range []bool{false, true} {
+		for _, typ := range []wav.Type{wav.I24, wav.I32, wav.F64} {
+			vb, err = NewWithOptions(bytes.NewReader(oggfile1), typ, &Options{FixedPoint: fixed})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(vb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != n*typ.Bits()/8 {
+				t.Fatalf("%s: %d bytes, want %d", typ, len(got), n*typ.Bits()/8)
+			}
+			tolerance := 1e-6
+			if fixed {
+				tolerance = 1e-4
+			}
+			for i := 0; i < n; i++ {
+				x, y := sampleAt(got, typ, i), sampleAt(want, wav.F32, i)
+				if y > -1 && y < 1 && math.Abs(x-y) > tolerance {
+					t.Fatalf("%s fixed-point %v: sample %d is %v, want %v", typ, fixed, i, x, y)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package wav
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// format tags of the compressed formats, they are decoded to I16
+const (
+	formatMSADPCM  = 2
+	formatALaw     = 6
+	formatMuLaw    = 7
+	formatIMAADPCM = 0x11
+)
+
+// the G.711 frames decoded in a block, the block of file is a single frame
+const g711Frames = 1024
+
+var alawTable, mulawTable [256]int16
+
+func init() {
+	for i := range alawTable {
+		alawTable[i] = alaw(byte(i))
+		mulawTable[i] = mulaw(byte(i))
+	}
+}
+
+func alaw(a byte) int16 {
+	a ^= 0x55
+	t := int16(a&0x0f) << 4
+	seg := (a & 0x70) >> 4
+	switch seg {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t += 0x108
+		t <<= seg - 1
+	}
+	if a&0x80 != 0 {
+		return t
+	}
+	return -t
+}
+
+func mulaw(u byte) int16 {
+	u = ^u
+	t := (int16(u&0x0f) << 3) + 0x84
+	t <<= (u & 0x70) >> 4
+	if u&0x80 != 0 {
+		return 0x84 - t
+	}
+	return t - 0x84
+}
+
+var imaIndexTable = [8]int{-1, -1, -1, -1, 2, 4, 6, 8}
+
+var imaStepTable = [89]int{
+	7, 8, 9, 10, 11, 12, 13, 14, 16, 17, 19, 21, 23, 25, 28, 31, 34, 37, 41, 45,
+	50, 55, 60, 66, 73, 80, 88, 97, 107, 118, 130, 143, 157, 173, 190, 209, 230, 253, 279, 307,
+	337, 371, 408, 449, 494, 544, 598, 658, 724, 796, 876, 963, 1060, 1166, 1282, 1411, 1552,
+	1707, 1878, 2066, 2272, 2499, 2749, 3024, 3327, 3660, 4026, 4428, 4871, 5358, 5894, 6484,
+	7132, 7845, 8630, 9493, 10442, 11487, 12635, 13899, 15289, 16818, 18500, 20350, 22385,
+	24623, 27086, 29794, 32767,
+}
+
+var msAdaptTable = [16]int{230, 230, 230, 230, 307, 409, 512, 614, 768, 614, 512, 409, 307, 230, 230, 230}
+
+// the coefficients of MS ADPCM if the fmt chunk has none
+var msDefaultCoefs = [][2]int{{256, 0}, {512, -256}, {0, 0}, {192, 64}, {240, 0}, {460, -208}, {392, -232}}
+
+func clamp16(x int) int {
+	if x > 32767 {
+		return 32767
+	} else if x < -32768 {
+		return -32768
+	}
+	return x
+}
+
+// codec decodes the blocks of a compressed format
+type codec struct {
+	format         uint16
+	channels       int
+	blockAlign     int
+	framesPerBlock int
+	coefs          [][2]int // of MS ADPCM
+}
+
+// newCodec parses the extra bytes of fmt chunk
+func newCodec(format, channels, blockAlign, bitsPerSample uint16, extra []byte) (*codec, error) {
+	c := &codec{format: format, channels: int(channels), blockAlign: int(blockAlign)}
+	switch format {
+	case formatALaw, formatMuLaw:
+		if bitsPerSample != 8 || blockAlign != channels {
+			return nil, ErrFormat
+		}
+		c.framesPerBlock = g711Frames
+		c.blockAlign *= g711Frames
+	case formatIMAADPCM:
+		if bitsPerSample != 4 || c.blockAlign < 4*c.channels {
+			return nil, ErrFormat
+		}
+		c.framesPerBlock = (c.blockAlign-4*c.channels)*2/c.channels + 1
+	case formatMSADPCM:
+		if bitsPerSample != 4 || c.blockAlign < 7*c.channels {
+			return nil, ErrFormat
+		}
+		c.framesPerBlock = (c.blockAlign-7*c.channels)*2/c.channels + 2
+		c.coefs = msDefaultCoefs
+		if len(extra) >= 6 {
+			n := int(u16(extra[4:]))
+			if len(extra) >= 6+4*n && n > 0 {
+				c.coefs = make([][2]int, n)
+				for i := range c.coefs {
+					c.coefs[i][0] = int(int16(u16(extra[6+4*i:])))
+					c.coefs[i][1] = int(int16(u16(extra[8+4*i:])))
+				}
+			}
+		}
+	default:
+		return nil, ErrFormat
+	}
+	return c, nil
+}
+
+// blockFrames reports the frames of block of size bytes, the last block may be short
+func (c *codec) blockFrames(size int) int {
+	ch := c.channels
+	switch c.format {
+	case formatALaw, formatMuLaw:
+		return size / ch
+	case formatIMAADPCM:
+		if size < 4*ch {
+			return 0
+		}
+		return 1 + (size-4*ch)/(4*ch)*8
+	default:
+		if size < 7*ch {
+			return 0
+		}
+		return 2 + (size-7*ch)*2/ch
+	}
+}
+
+// decode the block into interleaved samples of out, reports the frames decoded. the block
+// may be short at the end of data.
+func (c *codec) decode(block []byte, out []int16) int {
+	switch c.format {
+	case formatALaw, formatMuLaw:
+		table := &alawTable
+		if c.format == formatMuLaw {
+			table = &mulawTable
+		}
+		n := len(block) / c.channels * c.channels
+		for i, x := range block[:n] {
+			out[i] = table[x]
+		}
+		return n / c.channels
+	case formatIMAADPCM:
+		return c.decodeIMA(block, out)
+	default:
+		return c.decodeMS(block, out)
+	}
+}
+
+// decodeIMA decodes the block of IMA ADPCM. the header of each channel is the first
+// sample and the step index, then each channel takes 4 bytes of 8 samples in turn,
+// the low nibble first.
+func (c *codec) decodeIMA(block []byte, out []int16) int {
+	ch := c.channels
+	if len(block) < 4*ch {
+		return 0
+	}
+	var pred, index [8]int
+	if ch > len(pred) {
+		return 0
+	}
+	for i := 0; i < ch; i++ {
+		pred[i] = int(int16(u16(block[4*i:])))
+		index[i] = int(block[4*i+2])
+		if index[i] > 88 {
+			index[i] = 88
+		}
+		out[i] = int16(pred[i])
+	}
+	data := block[4*ch:]
+	groups := len(data) / (4 * ch)
+	for g := 0; g < groups; g++ {
+		for i := 0; i < ch; i++ {
+			word := data[(g*ch+i)*4:]
+			for j := 0; j < 8; j++ {
+				nibble := int(word[j/2] >> (4 * uint(j&1)) & 0x0f)
+				step := imaStepTable[index[i]]
+				diff := step >> 3
+				if nibble&1 != 0 {
+					diff += step >> 2
+				}
+				if nibble&2 != 0 {
+					diff += step >> 1
+				}
+				if nibble&4 != 0 {
+					diff += step
+				}
+				if nibble&8 != 0 {
+					pred[i] = clamp16(pred[i] - diff)
+				} else {
+					pred[i] = clamp16(pred[i] + diff)
+				}
+				index[i] += imaIndexTable[nibble&7]
+				if index[i] < 0 {
+					index[i] = 0
+				} else if index[i] > 88 {
+					index[i] = 88
+				}
+				out[(1+g*8+j)*ch+i] = int16(pred[i])
+			}
+		}
+	}
+	return 1 + groups*8
+}
+
+// decodeMS decodes the block of Microsoft ADPCM. the header is the predictors, the
+// deltas, the second samples and the first samples of channels, then the nibbles of
+// interleaved samples, the high nibble first.
+func (c *codec) decodeMS(block []byte, out []int16) int {
+	ch := c.channels
+	if len(block) < 7*ch || ch > 8 {
+		return 0
+	}
+	var c1, c2, delta, s1, s2 [8]int
+	for i := 0; i < ch; i++ {
+		p := int(block[i])
+		if p >= len(c.coefs) {
+			p = 0
+		}
+		c1[i], c2[i] = c.coefs[p][0], c.coefs[p][1]
+		delta[i] = int(int16(u16(block[ch+2*i:])))
+		s1[i] = int(int16(u16(block[3*ch+2*i:])))
+		s2[i] = int(int16(u16(block[5*ch+2*i:])))
+		out[i] = int16(s2[i])
+		out[ch+i] = int16(s1[i])
+	}
+	data := block[7*ch:]
+	frames := 2 + len(data)*2/ch
+	for k := 0; k < (frames-2)*ch; k++ {
+		nibble := int(data[k/2] >> (4 * uint(1-k&1)) & 0x0f)
+		i := k % ch
+		pred := (s1[i]*c1[i] + s2[i]*c2[i]) >> 8
+		signed := nibble
+		if signed >= 8 {
+			signed -= 16
+		}
+		pred = clamp16(pred + signed*delta[i])
+		s2[i], s1[i] = s1[i], pred
+		delta[i] = msAdaptTable[nibble] * delta[i] >> 8
+		if delta[i] < 16 {
+			delta[i] = 16
+		}
+		out[2*ch+k] = int16(pred)
+	}
+	return frames
+}
+
+// codecReader decodes the data chunk to I16
+type codecReader struct {
+	*codec
+	r     io.Reader
+	left  int64 // frames not decoded, -1 if unknown
+	skip  int   // frames to drop from the next block, for seeking
+	block []byte
+	pcm   []int16
+	buf   []byte
+	out   []byte // decoded but not read
+	fault error
+}
+
+func newCodecReader(c *codec, r io.Reader, frames int64) *codecReader {
+	return &codecReader{
+		codec: c,
+		r:     r,
+		left:  frames,
+		block: make([]byte, c.blockAlign),
+		pcm:   make([]int16, c.framesPerBlock*c.channels),
+		buf:   make([]byte, 2*c.framesPerBlock*c.channels),
+	}
+}
+
+func (d *codecReader) Read(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		if len(d.out) == 0 {
+			if d.fault != nil {
+				break
+			}
+			d.fault = d.next()
+			continue
+		}
+		k := copy(p, d.out)
+		d.out = d.out[k:]
+		p = p[k:]
+		n += k
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return 0, d.fault
+}
+
+// next decodes the next block into out
+func (d *codecReader) next() error {
+	if d.left == 0 {
+		return io.EOF
+	}
+	m, err := io.ReadFull(d.r, d.block)
+	if err == io.ErrUnexpectedEOF {
+		err = nil // the last block is short
+	} else if err != nil {
+		return err
+	}
+	frames := d.decode(d.block[:m], d.pcm)
+	if frames == 0 {
+		return io.EOF
+	}
+	if d.left >= 0 && int64(frames) > d.left {
+		frames = int(d.left)
+	}
+	if d.left >= 0 {
+		d.left -= int64(frames)
+	}
+	begin := d.skip
+	if begin > frames {
+		begin = frames
+	}
+	d.skip -= begin
+	samples := d.pcm[begin*d.channels : frames*d.channels]
+	d.out = d.buf[:2*len(samples)]
+	for i, x := range samples {
+		binary.LittleEndian.PutUint16(d.out[2*i:], uint16(x))
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+// makeWave makes the wav file of the format, fact is omitted if frames < 0
+func makeWave(format, channels, blockAlign, bits int, extra []byte, frames int, data []byte) []byte {
+	fmtData := make([]byte, 16, 18+len(extra))
+	le := binary.LittleEndian
+	le.PutUint16(fmtData, uint16(format))
+	le.PutUint16(fmtData[2:], uint16(channels))
+	le.PutUint32(fmtData[4:], 8000)
+	le.PutUint32(fmtData[8:], uint32(8000*blockAlign))
+	le.PutUint16(fmtData[12:], uint16(blockAlign))
+	le.PutUint16(fmtData[14:], uint16(bits))
+	if format != formatALaw && format != formatMuLaw {
+		fmtData = append(fmtData, byte(len(extra)), byte(len(extra)>>8))
+		fmtData = append(fmtData, extra...)
+	}
+	var body []byte
+	chunk := func(id string, b []byte) {
+		body = append(body, id...)
+		body = append(body, 0, 0, 0, 0)
+		le.PutUint32(body[len(body)-4:], uint32(len(b)))
+		body = append(body, b...)
+		if len(b)&1 != 0 {
+			body = append(body, 0)
+		}
+	}
+	chunk("fmt ", fmtData)
+	if frames >= 0 {
+		chunk("fact", []byte{byte(frames), byte(frames >> 8), byte(frames >> 16), byte(frames >> 24)})
+	}
+	chunk("data", data)
+	file := append([]byte("RIFF\x00\x00\x00\x00WAVE"), body...)
+	le.PutUint32(file[4:], uint32(len(file)-8))
+	return file
+}
+
+func testSignal(n, channels int) []int16 {
+	x := make([]int16, n*channels)
+	for i := range x {
+		ch := i % channels
+		x[i] = int16(20000 * math.Sin(float64(i/channels)*0.05*float64(ch+1)))
+	}
+	return x
+}
+
+// encodeIMA encodes samples of IMA ADPCM, reports the blocks and the samples decoded
+func encodeIMA(x []int16, channels, blockAlign int) (data []byte, decoded []int16) {
+	spb := (blockAlign-4*channels)*2/channels + 1
+	frames := len(x) / channels
+	var index [8]int // the step index is kept between blocks
+	for b := 0; b < frames; b += spb {
+		block := make([]byte, blockAlign)
+		var pred [8]int
+		for ch := 0; ch < channels; ch++ {
+			pred[ch] = int(x[b*channels+ch])
+			binary.LittleEndian.PutUint16(block[4*ch:], uint16(pred[ch]))
+			block[4*ch+2] = byte(index[ch])
+			decoded = append(decoded, int16(pred[ch]))
+		}
+		out := make([]int16, (spb-1)*channels)
+		for g := 0; g < (spb-1)/8; g++ {
+			for ch := 0; ch < channels; ch++ {
+				for j := 0; j < 8; j++ {
+					f := b + 1 + g*8 + j
+					var v int
+					if f < frames {
+						v = int(x[f*channels+ch])
+					}
+					step := imaStepTable[index[ch]]
+					d := v - pred[ch]
+					nibble := 0
+					if d < 0 {
+						nibble, d = 8, -d
+					}
+					diff := step >> 3
+					if d >= step {
+						nibble |= 4
+						d -= step
+						diff += step
+					}
+					if d >= step>>1 {
+						nibble |= 2
+						d -= step >> 1
+						diff += step >> 1
+					}
+					if d >= step>>2 {
+						nibble |= 1
+						diff += step >> 2
+					}
+					if nibble&8 != 0 {
+						pred[ch] = clamp16(pred[ch] - diff)
+					} else {
+						pred[ch] = clamp16(pred[ch] + diff)
+					}
+					index[ch] += imaIndexTable[nibble&7]
+					if index[ch] < 0 {
+						index[ch] = 0
+					} else if index[ch] > 88 {
+						index[ch] = 88
+					}
+					block[4*channels+(g*channels+ch)*4+j/2] |= byte(nibble << (4 * uint(j&1)))
+					out[(g*8+j)*channels+ch] = int16(pred[ch])
+				}
+			}
+		}
+		decoded = append(decoded, out...)
+		data = append(data, block...)
+	}
+	return data, decoded[:len(x)]
+}
+
+// encodeMS encodes samples of MS ADPCM with the predictor 1, reports the blocks and the
+// samples decoded
+func encodeMS(x []int16, channels, blockAlign int) (data []byte, decoded []int16) {
+	spb := (blockAlign-7*channels)*2/channels + 2
+	frames := len(x) / channels
+	sample := func(f, ch int) int {
+		if f < frames {
+			return int(x[f*channels+ch])
+		}
+		return 0
+	}
+	for b := 0; b < frames; b += spb {
+		block := make([]byte, blockAlign)
+		var delta, s1, s2 [8]int
+		for ch := 0; ch < channels; ch++ {
+			block[ch] = 1
+			delta[ch] = 16
+			s2[ch], s1[ch] = sample(b, ch), sample(b+1, ch)
+			binary.LittleEndian.PutUint16(block[channels+2*ch:], uint16(delta[ch]))
+			binary.LittleEndian.PutUint16(block[3*channels+2*ch:], uint16(s1[ch]))
+			binary.LittleEndian.PutUint16(block[5*channels+2*ch:], uint16(s2[ch]))
+		}
+		for ch := 0; ch < channels; ch++ {
+			decoded = append(decoded, int16(s2[ch]))
+		}
+		for ch := 0; ch < channels; ch++ {
+			decoded = append(decoded, int16(s1[ch]))
+		}
+		for k := 0; k < (spb-2)*channels; k++ {
+			ch := k % channels
+			pred := (s1[ch]*512 - s2[ch]*256) >> 8
+			n := int(math.Round(float64(sample(b+2+k/channels, ch)-pred) / float64(delta[ch])))
+			if n > 7 {
+				n = 7
+			} else if n < -8 {
+				n = -8
+			}
+			pred = clamp16(pred + n*delta[ch])
+			s2[ch], s1[ch] = s1[ch], pred
+			delta[ch] = msAdaptTable[n&15] * delta[ch] >> 8
+			if delta[ch] < 16 {
+				delta[ch] = 16
+			}
+			block[7*channels+k/2] |= byte(n&15) << (4 * uint(1-k&1))
+			decoded = append(decoded, int16(pred))
+		}
+		data = append(data, block...)
+	}
+	return data, decoded[:len(x)]
+}
+
+func readInt16(t *testing.T, x io.Reader) []int16 {
+	b, err := io.ReadAll(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]int16, len(b)/2)
+	for i := range out {
+		out[i] = int16(binary.LittleEndian.Uint16(b[2*i:]))
+	}
+	return out
+}
+
+func equalInt16(a, b []int16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestG711(t *testing.T) {
+	for _, c := range []struct {
+		format int
+		data   []byte
+		want   []int16
+	}{
+		{formatMuLaw, []byte{0xff, 0x00, 0x80, 0x7f}, []int16{0, -32124, 32124, 0}},
+		{formatALaw, []byte{0xd5, 0x55, 0xaa, 0x2a}, []int16{8, -8, 32256, -32256}},
+	} {
+		x, err := NewReader(bytes.NewReader(makeWave(c.format, 2, 2, 8, nil, -1, c.data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x.SampleType() != I16 || x.(*wavReader).NumFrames() != 2 {
+			t.Fatalf("%s, %d frames", x.SampleType(), x.(*wavReader).NumFrames())
+		}
+		if got := readInt16(t, x); !equalInt16(got, c.want) {
+			t.Fatalf("format %d: %v, want %v", c.format, got, c.want)
+		}
+	}
+}
+
+func TestADPCM(t *testing.T) {
+	for _, c := range []struct {
+		format, channels, blockAlign int
+	}{
+		{formatIMAADPCM, 1, 256}, {formatIMAADPCM, 2, 512}, {formatMSADPCM, 1, 256}, {formatMSADPCM, 2, 512},
+	} {
+		const frames = 2000
+		x := testSignal(frames, c.channels)
+		var data []byte
+		var want []int16
+		var extra []byte
+		if c.format == formatIMAADPCM {
+			data, want = encodeIMA(x, c.channels, c.blockAlign)
+			spb := (c.blockAlign-4*c.channels)*2/c.channels + 1
+			extra = []byte{byte(spb), byte(spb >> 8)}
+		} else {
+			data, want = encodeMS(x, c.channels, c.blockAlign)
+			spb := (c.blockAlign-7*c.channels)*2/c.channels + 2
+			extra = []byte{byte(spb), byte(spb >> 8), 7, 0}
+			for _, coef := range msDefaultCoefs {
+				extra = append(extra, byte(coef[0]), byte(coef[0]>>8), byte(coef[1]), byte(coef[1]>>8))
+			}
+		}
+		file := makeWave(c.format, c.channels, c.blockAlign, 4, extra, frames, data)
+		r, err := NewReader(bytes.NewReader(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := r.(*wavReader)
+		if w.NumFrames() != frames || w.Duration() != frames*time.Second/8000 {
+			t.Fatalf("%+v: %d frames, duration %v", c, w.NumFrames(), w.Duration())
+		}
+		got := readInt16(t, r)
+		if !equalInt16(got, want) {
+			t.Fatalf("%+v: decoded samples miss-match", c)
+		}
+		// the error of encoding is small, after the step adapts
+		for i := 100 * c.channels; i < len(x); i++ {
+			if d := int(x[i]) - int(got[i]); d > 2000 || d < -2000 {
+				t.Fatalf("%+v: sample %d is %d, want %d", c, i, got[i], x[i])
+			}
+		}
+
+		for _, frame := range []int64{0, 777, 1999, 2000} {
+			if err = w.SeekFrame(frame); err != nil {
+				t.Fatal(err)
+			}
+			if tail := readInt16(t, r); !equalInt16(tail, want[int(frame)*c.channels:]) {
+				t.Fatalf("%+v: seek to %d, %d samples", c, frame, len(tail))
+			}
+		}
+	}
+}
+
+func TestSeekPCM(t *testing.T) {
+	data := make([]byte, 4*100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	file := bytes.NewBuffer(nil)
+	if err := Write(file, NewBlock(data, 2, I16, 8000)); err != nil {
+		t.Fatal(err)
+	}
+	x, err := NewReader(bytes.NewReader(file.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := x.(*wavReader)
+	if err = w.SeekFrame(60); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(x)
+	if err != nil || !bytes.Equal(b, data[240:]) {
+		t.Fatalf("%d bytes, %v", len(b), err)
+	}
+	x, _ = NewReader(io.MultiReader(bytes.NewReader(file.Bytes())))
+	if err = x.(*wavReader).SeekFrame(60); err != ErrRandomAccess {
+		t.Fatalf("expect ErrRandomAccess, got %v", err)
+	}
+}
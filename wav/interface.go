@@ -1,5 +1,6 @@
 // Package wav privides interface to PCM wave data.
-// 8bits unsigned, 16bits signed and 32bits float are supported.
+// 8bits unsigned, 16, 24 and 32bits signed, 32 and 64bits float are supported.
+// the wave files of A-law, µ-law, IMA ADPCM and Microsoft ADPCM are decoded to 16bits signed.
 // samples must tight interlacing.
 package wav
 
@@ -16,6 +17,9 @@ const (
 	U8 Type = iota
 	I16
 	F32
+	I24
+	I32
+	F64
 )
 
 func (x Type) String() string {
@@ -26,11 +30,22 @@ func (x Type) String() string {
 		return "16bits signed"
 	case F32:
 		return "32bits float"
+	case I24:
+		return "24bits signed"
+	case I32:
+		return "32bits signed"
+	case F64:
+		return "64bits float"
 	default:
 		return fmt.Sprintf("unkown pcm wave type %d", int8(x))
 	}
 }
 
+// IsFloat reports whether the samples are IEEE float
+func (x Type) IsFloat() bool {
+	return x == F32 || x == F64
+}
+
 // Bits per sample
 func (x Type) Bits() int {
 	switch x {
@@ -38,8 +53,12 @@ func (x Type) Bits() int {
 		return 8
 	case I16:
 		return 16
-	case F32:
+	case I24:
+		return 24
+	case F32, I32:
 		return 32
+	case F64:
+		return 64
 	default:
 		return 0
 	}
@@ -64,3 +83,11 @@ type ReadCloser interface {
 	Reader
 	Close() error
 }
+
+// LayoutReader is a Reader knows the speakers of tracks
+type LayoutReader interface {
+	Reader
+
+	// Layout reports the speakers of tracks, SpeakerUnknown if not defined
+	Layout() []Speaker
+}
@@ -0,0 +1,77 @@
+package wav
+
+import (
+	"encoding/binary"
+
+	"golang.org/x/image/riff"
+)
+
+// RF64 (EBU Tech 3306) and BW64 (ITU-R BS.2088) are RIFF with 64 bits sizes. the sizes of
+// header are 0xffffffff, the real sizes are in the ds64 chunk, which is the first.
+var (
+	rf64Magic = riff.FourCC{'R', 'F', '6', '4'}
+	bw64Magic = riff.FourCC{'B', 'W', '6', '4'}
+	ds64Chunk = riff.FourCC{'d', 's', '6', '4'}
+	junkChunk = riff.FourCC{'J', 'U', 'N', 'K'}
+)
+
+// the chunks other than data are read into memory, they are no larger than it
+const maxChunkSize = 1 << 20
+
+// the bytes of ds64 chunk without the table, the JUNK placeholder is as large
+const ds64Size = 28
+
+type ds64 struct {
+	riffSize    int64
+	dataSize    int64
+	sampleCount int64
+	table       map[riff.FourCC]int64 // sizes of the other large chunks
+}
+
+func parseDS64(b []byte) (*ds64, error) {
+	if len(b) < ds64Size {
+		return nil, ErrCorrupted
+	}
+	le := binary.LittleEndian
+	ds := &ds64{
+		riffSize:    int64(le.Uint64(b)),
+		dataSize:    int64(le.Uint64(b[8:])),
+		sampleCount: int64(le.Uint64(b[16:])),
+	}
+	n := int(le.Uint32(b[24:]))
+	b = b[ds64Size:]
+	for i := 0; i < n && len(b) >= 12; i++ {
+		if ds.table == nil {
+			ds.table = make(map[riff.FourCC]int64)
+		}
+		ds.table[riff.FourCC{b[0], b[1], b[2], b[3]}] = int64(le.Uint64(b[4:]))
+		b = b[12:]
+	}
+	if ds.dataSize < 0 || ds.riffSize < 0 {
+		return nil, ErrCorrupted
+	}
+	return ds, nil
+}
+
+// size reports the size of chunk id, its size in the chunk header is 0xffffffff
+func (ds *ds64) size(id riff.FourCC) int64 {
+	if id == wavChunkData {
+		return ds.dataSize
+	}
+	if n, ok := ds.table[id]; ok && n >= 0 {
+		return n
+	}
+	return 0xffffffff
+}
+
+// appendDS64 appends the ds64 chunk of the sizes, it is as large as the JUNK placeholder
+func appendDS64(b []byte, riffSize, dataSize, sampleCount int64) []byte {
+	b = append(b, ds64Chunk[:]...)
+	b = append(b, ds64Size, 0, 0, 0)
+	var x [ds64Size]byte
+	le := binary.LittleEndian
+	le.PutUint64(x[0:], uint64(riffSize))
+	le.PutUint64(x[8:], uint64(dataSize))
+	le.PutUint64(x[16:], uint64(sampleCount))
+	return append(b, x[:]...)
+}
@@ -0,0 +1,108 @@
+package wav
+
+import (
+	"strings"
+)
+
+// Speaker is the position of a channel. the values are the bits of channel mask of
+// WAVE_FORMAT_EXTENSIBLE, so the speakers of channels can be or'ed into the mask.
+type Speaker uint32
+
+// Speaker positions, in the order of WAVE_FORMAT_EXTENSIBLE
+const (
+	SpeakerFrontLeft Speaker = 1 << iota
+	SpeakerFrontRight
+	SpeakerFrontCenter
+	SpeakerLowFrequency
+	SpeakerBackLeft
+	SpeakerBackRight
+	SpeakerFrontLeftOfCenter
+	SpeakerFrontRightOfCenter
+	SpeakerBackCenter
+	SpeakerSideLeft
+	SpeakerSideRight
+	SpeakerTopCenter
+	SpeakerTopFrontLeft
+	SpeakerTopFrontCenter
+	SpeakerTopFrontRight
+	SpeakerTopBackLeft
+	SpeakerTopBackCenter
+	SpeakerTopBackRight
+)
+
+// SpeakerUnknown is the position of a channel not defined by the format
+const SpeakerUnknown Speaker = 0
+
+var speakerNames = [...]string{"FL", "FR", "FC", "LFE", "BL", "BR", "FLC", "FRC", "BC",
+	"SL", "SR", "TC", "TFL", "TFC", "TFR", "TBL", "TBC", "TBR"}
+
+func (s Speaker) String() string {
+	var names []string
+	for i, name := range speakerNames {
+		if s&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "?"
+	}
+	return strings.Join(names, "|")
+}
+
+// ChannelMask ors the speakers into the channel mask of WAVE_FORMAT_EXTENSIBLE
+func ChannelMask(speakers []Speaker) uint32 {
+	var mask uint32
+	for _, s := range speakers {
+		mask |= uint32(s)
+	}
+	return mask
+}
+
+// Speakers reports the speakers of n channels of the channel mask, the channels take the
+// bits of mask in order. the channels more than the bits are SpeakerUnknown.
+func Speakers(mask uint32, n int) []Speaker {
+	speakers := make([]Speaker, n)
+	k := 0
+	for bit := uint32(1); bit != 0 && k < n; bit <<= 1 {
+		if mask&bit != 0 {
+			speakers[k] = Speaker(bit)
+			k++
+		}
+	}
+	return speakers
+}
+
+// the channel masks of 1 to 8 channels, if the layout is not known
+var defaultMasks = [...]uint32{0, 0x4, 0x3, 0x7, 0x33, 0x37, 0x3f, 0x70f, 0x63f}
+
+// DefaultLayout reports the usual speakers of n channels, i.e. FL, FR, FC, LFE, BL, BR
+// for 6 channels. the channels more than 8 are SpeakerUnknown.
+func DefaultLayout(n int) []Speaker {
+	if n < len(defaultMasks) {
+		return Speakers(defaultMasks[n], n)
+	}
+	return make([]Speaker, n)
+}
+
+// layoutMask reports the channel mask of the layout, it is 0 if the speakers are not in
+// the order of mask bits. the unknown speakers are allowed at the end.
+func layoutMask(layout []Speaker) uint32 {
+	var mask uint32
+	var last Speaker
+	for i, s := range layout {
+		if s == SpeakerUnknown {
+			for _, s := range layout[i:] {
+				if s != SpeakerUnknown {
+					return 0
+				}
+			}
+			break
+		}
+		if s <= last || s&(s-1) != 0 {
+			return 0
+		}
+		mask |= uint32(s)
+		last = s
+	}
+	return mask
+}
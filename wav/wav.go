@@ -16,26 +16,43 @@ var (
 	waveMagic    = riff.FourCC{'W', 'A', 'V', 'E'}
 	wavChunkFmt  = riff.FourCC{'f', 'm', 't', ' '}
 	wavChunkData = riff.FourCC{'d', 'a', 't', 'a'}
+	wavChunkFact = riff.FourCC{'f', 'a', 'c', 't'}
+
+	// the sub-format GUID of WAVE_FORMAT_EXTENSIBLE, the format tag is in the first 2 bytes
+	subFormatGUID = [16]byte{0, 0, 0, 0, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71}
 
 	ErrFormat       = errors.New("wav: bad or unsupported format")
 	ErrRandomAccess = errors.New("wav: not random accessible")
 	ErrCorrupted    = errors.New("wav: corrupted data")
 )
 
+// format tags
+const (
+	formatPCM        = 1
+	formatFloat      = 3
+	formatExtensible = 0xfffe
+)
+
 type wavReader struct {
-	rr *riff.Reader
-	u  io.Reader // underlying reader
-	b  int64     // begin of file positon
+	u io.Reader // underlying reader
+	b int64     // begin of file positon
 
 	format         uint16
 	channels       uint16
 	frameRate      uint32
 	bytesPerSecond uint32
 	blockAlign     uint16
-	bitsPerSample  uint16 // 8, 16, 32
+	bitsPerSample  uint16 // 8, 16, 24, 32, 64
+	validBits      uint16 // of WAVE_FORMAT_EXTENSIBLE, or bitsPerSample
+	channelMask    uint32 // of WAVE_FORMAT_EXTENSIBLE, 0 if not defined
+	extensible     bool
+	codec          *codec // of the compressed formats, nil for PCM
+	factFrames     int64  // frames of fact chunk, -1 if none
 
-	nd uint32    // data length
-	dr io.Reader // data reader
+	nd      int64             // data length
+	data    *io.LimitedReader // data chunk
+	dataPos int64             // position of data chunk, -1 if not seekable
+	dr      io.Reader         // data reader, the decoder of compressed formats
 }
 
 func (f *wavReader) String() string {
@@ -54,16 +71,24 @@ func (f *wavReader) Close() error {
 }
 
 func (f *wavReader) SampleType() Type {
-	if f.format == 3 {
-		return F32
+	if f.codec != nil {
+		return I16
 	}
-	if f.format == 1 {
-		if f.bitsPerSample == 8 {
-			return U8
-		}
-		if f.bitsPerSample == 16 {
-			return I16
+	if f.format == formatFloat {
+		if f.bitsPerSample == 64 {
+			return F64
 		}
+		return F32
+	}
+	switch f.bitsPerSample {
+	case 8:
+		return U8
+	case 16:
+		return I16
+	case 24:
+		return I24
+	case 32:
+		return I32
 	}
 	return 0
 }
@@ -89,7 +114,80 @@ func (f *wavReader) Rewind() error {
 	return f.reset(true)
 }
 
+// Layout reports the speakers of tracks, from the channel mask of WAVE_FORMAT_EXTENSIBLE,
+// or DefaultLayout if there is no channel mask
+func (f *wavReader) Layout() []Speaker {
+	if f.channelMask == 0 {
+		if f.extensible {
+			return make([]Speaker, f.channels)
+		}
+		return DefaultLayout(int(f.channels))
+	}
+	return Speakers(f.channelMask, int(f.channels))
+}
+
+// ValidBits reports the valid bits of samples, which may be less than the container
+func (f *wavReader) ValidBits() int {
+	return int(f.validBits)
+}
+
+// NumFrames reports the frames of data chunk. for the compressed formats, it is from the
+// fact chunk, or the frames of blocks if there is no fact chunk.
+func (f *wavReader) NumFrames() int64 {
+	if f.codec == nil {
+		return f.nd / int64(f.blockAlign)
+	}
+	if f.factFrames >= 0 {
+		return f.factFrames
+	}
+	c := f.codec
+	n := f.nd / int64(c.blockAlign) * int64(c.framesPerBlock)
+	return n + int64(c.blockFrames(int(f.nd%int64(c.blockAlign))))
+}
+
+// SeekFrame seeks to the frame of data, the underlying reader must be an io.ReadSeeker.
+// the compressed formats are seeked to the block, then the frames before are decoded
+// and dropped.
+func (f *wavReader) SeekFrame(frame int64) error {
+	rs, ok := f.u.(io.ReadSeeker)
+	if !ok || f.dataPos < 0 {
+		return ErrRandomAccess
+	}
+	if frame < 0 {
+		frame = 0
+	} else if n := f.NumFrames(); frame > n {
+		frame = n
+	}
+	var off int64
+	if f.codec == nil {
+		off = frame * int64(f.blockAlign)
+	} else {
+		c := f.codec
+		off = frame / int64(c.framesPerBlock) * int64(c.blockAlign)
+	}
+	if off > f.nd {
+		off = f.nd
+	}
+	if _, err := rs.Seek(f.dataPos+off, io.SeekStart); err != nil {
+		return err
+	}
+	f.data.N = f.nd - off
+	if f.codec != nil {
+		c := f.codec
+		d := newCodecReader(c, f.data, -1)
+		if f.factFrames >= 0 {
+			d.left = f.factFrames - frame/int64(c.framesPerBlock)*int64(c.framesPerBlock)
+		}
+		d.skip = int(frame % int64(c.framesPerBlock))
+		f.dr = d
+	}
+	return nil
+}
+
 func (f *wavReader) Duration() time.Duration {
+	if f.codec != nil {
+		return time.Second * time.Duration(f.NumFrames()) / time.Duration(f.frameRate)
+	}
 	if f.bytesPerSecond != 0 {
 		return time.Second * time.Duration(f.nd) / time.Duration(f.bytesPerSecond)
 	}
@@ -105,6 +203,66 @@ func u16(b []byte) uint16 {
 	return uint16(b[0]) | uint16(b[1])<<8
 }
 
+// parseFormat parses the fmt chunk, the format tag of WAVE_FORMAT_EXTENSIBLE is replaced
+// by that of the sub-format
+func (f *wavReader) parseFormat(b []byte) error {
+	if len(b) < 16 {
+		return ErrCorrupted
+	}
+	f.format = u16(b)
+	f.channels = u16(b[2:])
+	f.frameRate = u32(b[4:])
+	f.bytesPerSecond = u32(b[8:])
+	f.blockAlign = u16(b[12:])
+	f.bitsPerSample = u16(b[14:])
+	f.validBits = f.bitsPerSample
+	f.channelMask = 0
+	f.extensible = f.format == formatExtensible
+	if f.extensible {
+		if len(b) < 40 || u16(b[16:]) < 22 {
+			return ErrCorrupted
+		}
+		if v := u16(b[18:]); v != 0 {
+			f.validBits = v
+		}
+		f.channelMask = u32(b[20:])
+		guid := b[24:40]
+		if string(guid[2:]) != string(subFormatGUID[2:]) {
+			return ErrFormat
+		}
+		f.format = u16(guid)
+	}
+	f.codec = nil
+	if f.channels == 0 || f.blockAlign == 0 {
+		return ErrCorrupted
+	}
+	switch f.format {
+	case formatMSADPCM, formatALaw, formatMuLaw, formatIMAADPCM:
+		var extra []byte
+		if len(b) > 18 {
+			extra = b[18:]
+		}
+		var err error
+		f.codec, err = newCodec(f.format, f.channels, f.blockAlign, f.bitsPerSample, extra)
+		return err
+	}
+
+	if f.format != formatPCM && f.format != formatFloat {
+		return ErrFormat // only PCM and float is supported
+	}
+	if f.format == formatFloat {
+		if f.bitsPerSample != 32 && f.bitsPerSample != 64 {
+			return fmt.Errorf("wav: unsupported float bits width %d", f.bitsPerSample)
+		}
+	} else if f.bitsPerSample != 8 && f.bitsPerSample != 16 && f.bitsPerSample != 24 && f.bitsPerSample != 32 {
+		return fmt.Errorf("wav: unsupported integer bits width %d", f.bitsPerSample)
+	}
+	if f.validBits > f.bitsPerSample || f.channels == 0 || f.blockAlign == 0 {
+		return ErrCorrupted
+	}
+	return nil
+}
+
 func (f *wavReader) reset(rewind bool) error {
 	// reset file positon
 	if rs, ok := f.u.(io.ReadSeeker); ok {
@@ -114,58 +272,102 @@ func (f *wavReader) reset(rewind bool) error {
 	} else if rewind {
 		return ErrRandomAccess
 	}
-	// parse the file, must be RIFF + WAVE
-	t, rr, err := riff.NewReader(f.u)
-	if err != nil {
-		return err
+	// parse the file, must be RIFF, RF64 or BW64 + WAVE
+	var head [12]byte
+	if _, err := io.ReadFull(f.u, head[:]); err != nil {
+		return ErrFormat
 	}
-	if t[0] != 'W' || t[1] != 'A' || t[2] != 'V' || t[3] != 'E' {
+	magic := riff.FourCC{head[0], head[1], head[2], head[3]}
+	rf64 := magic == rf64Magic || magic == bw64Magic
+	if magic != riffMagic && !rf64 || string(head[8:]) != string(waveMagic[:]) {
 		return ErrFormat
 	}
 	f.dr = nil
+	f.factFrames = -1
+	var ds *ds64
 	var fmtLoaded bool
-	for {
-		chunkID, chunkLen, chunkData, err := rr.Next()
-		if err == io.EOF {
+	for i := 0; ; i++ {
+		var ch [8]byte
+		if _, err := io.ReadFull(f.u, ch[:]); err != nil {
 			return ErrCorrupted
 		}
-		if err != nil {
-			return err
+		chunkID := riff.FourCC{ch[0], ch[1], ch[2], ch[3]}
+		chunkLen := int64(u32(ch[4:]))
+		if ds != nil && chunkLen == 0xffffffff {
+			chunkLen = ds.size(chunkID)
 		}
-		if chunkID == wavChunkFmt {
-			var b []byte
-			if b, err = io.ReadAll(chunkData); err != nil {
+		var err error
+		switch {
+		case rf64 && i == 0:
+			// the ds64 chunk must be the first
+			if chunkID != ds64Chunk {
+				return ErrCorrupted
+			}
+			b, err := readChunk(f.u, chunkLen)
+			if err != nil {
 				return err
 			}
-
-			f.format = u16(b)
-			f.channels = u16(b[2:])
-			f.frameRate = u32(b[4:])
-			f.bytesPerSecond = u32(b[8:])
-			f.blockAlign = u16(b[12:])
-			f.bitsPerSample = u16(b[14:])
-
-			if f.format != 1 && f.format != 3 {
-				return ErrFormat // only PCM and float is supported
+			if ds, err = parseDS64(b); err != nil {
+				return err
 			}
-			if f.format == 3 && f.bitsPerSample != 32 {
-				return fmt.Errorf("wav: unsupported float bits width %d", f.bitsPerSample)
-			} else if f.bitsPerSample != 8 && f.bitsPerSample != 16 {
-				return fmt.Errorf("wav: unsupported integer bits width %d", f.bitsPerSample)
+		case chunkID == wavChunkFmt:
+			b, err := readChunk(f.u, chunkLen)
+			if err != nil {
+				return err
+			}
+			if err = f.parseFormat(b); err != nil {
+				return err
 			}
 			fmtLoaded = true
-		} else if chunkID == wavChunkData {
+		case chunkID == wavChunkFact:
+			b, err := readChunk(f.u, chunkLen)
+			if err != nil {
+				return err
+			}
+			if len(b) < 4 {
+				return ErrCorrupted
+			}
+			f.factFrames = int64(u32(b))
+			if f.factFrames == 0xffffffff && ds != nil {
+				f.factFrames = ds.sampleCount
+			}
+		case chunkID == wavChunkData:
 			if !fmtLoaded {
 				return ErrCorrupted // data before fmt, ill form
 			}
 			f.nd = chunkLen
-			f.dr = chunkData
-			f.rr = rr
+			f.data = &io.LimitedReader{R: f.u, N: chunkLen}
+			f.dataPos = -1
+			if rs, ok := f.u.(io.ReadSeeker); ok {
+				if f.dataPos, err = rs.Seek(0, io.SeekCurrent); err != nil {
+					f.dataPos = -1
+				}
+			}
+			f.dr = f.data
+			if f.codec != nil {
+				f.dr = newCodecReader(f.codec, f.data, f.factFrames)
+			}
 			return nil
+		default:
+			if _, err = io.CopyN(io.Discard, f.u, chunkLen+chunkLen&1); err != nil {
+				return ErrCorrupted
+			}
 		}
 	}
 }
 
+// readChunk reads the chunk of n bytes, the padding byte is skipped
+func readChunk(r io.Reader, n int64) ([]byte, error) {
+	if n > maxChunkSize {
+		return nil, ErrCorrupted
+	}
+	b := make([]byte, n+n&1)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, ErrCorrupted
+	}
+	return b[:n], nil
+}
+
 func NewReader(r io.Reader) (Reader, error) {
 	w := &wavReader{u: r}
 	if rs, ok := r.(io.ReadSeeker); ok {
@@ -190,105 +392,74 @@ func Open(filename string) (ReadCloser, error) {
 	return x.(ReadCloser), err
 }
 
-// Write PCM wave to writer w
-func Write(w io.Writer, wave Reader) (err error) {
-	var format uint16
-	var channels uint16
-	var frameRate uint32
-	var bytesPerSecond uint32
-	var blockAlign uint16
-	var bitsPerSample uint16 // 8, 16, 32
-	//var cbSize uint32
-	var subChunkSize1 uint32
-	var subChunkSize2 uint32
-	var chunkSize uint32
-
-	isFloat := wave.SampleType() == F32
-	if isFloat {
-		format = 3
-	} else {
-		format = 1
-	}
-	channels = uint16(wave.NumTracks())
-	frameRate = uint32(wave.Frequency())
-	bitsPerSample = uint16(wave.SampleType().Bits())
-	if bitsPerSample%8 != 0 {
-		return fmt.Errorf("wav: unsupported bits width %d", bitsPerSample)
-	}
-	blockAlign = channels * bitsPerSample / 8
-	bytesPerSecond = uint32(blockAlign) * frameRate
-
-	buf, err := io.ReadAll(wave)
-	if err != nil {
-		return err
-	}
-
-	subChunkSize1 = 16
-	//cbSize = 0
-
-	subChunkSize2 = uint32(len(buf))
-	//subChunkSize2 = subChunkSize2 / uint32(blockAlign) * uint32(blockAlign)
-	chunkSize = 4 + 4 + 8 + subChunkSize1 + 8 + subChunkSize2
-	padded := chunkSize&0x1 != 0
-	if padded {
-		chunkSize++
-	}
-	if _, err = w.Write(riffMagic[:]); err != nil {
-		return err
-	}
-	if err = binary.Write(w, binary.LittleEndian, chunkSize); err != nil {
-		return err
-	}
-	if _, err = w.Write(waveMagic[:]); err != nil {
-		return err
-	}
+// formatChunk makes the fmt chunk, the header included. it is WAVE_FORMAT_EXTENSIBLE for
+// more than 2 channels or more than 16 bits, the channel mask is from layout, or the
+// default layout of channels if layout is nil.
+func formatChunk(t Type, channels, frameRate int, layout []Speaker) []byte {
+	format := uint16(formatPCM)
+	if t.IsFloat() {
+		format = formatFloat
+	}
+	bitsPerSample := t.Bits()
+	blockAlign := channels * bitsPerSample / 8
+	extensible := channels > 2 || bitsPerSample > 16
 
-	if _, err = w.Write(wavChunkFmt[:]); err != nil {
-		return err
-	}
+	size := 16
+	if extensible {
+		size = 40
+	}
+	b := make([]byte, 8+size)
+	copy(b, wavChunkFmt[:])
+	le := binary.LittleEndian
+	le.PutUint32(b[4:], uint32(size))
+	le.PutUint16(b[8:], format)
+	le.PutUint16(b[10:], uint16(channels))
+	le.PutUint32(b[12:], uint32(frameRate))
+	le.PutUint32(b[16:], uint32(blockAlign*frameRate))
+	le.PutUint16(b[20:], uint16(blockAlign))
+	le.PutUint16(b[22:], uint16(bitsPerSample))
+	if extensible {
+		if layout == nil {
+			layout = DefaultLayout(channels)
+		}
+		le.PutUint16(b[8:], formatExtensible)
+		le.PutUint16(b[24:], 22)
+		le.PutUint16(b[26:], uint16(bitsPerSample))
+		le.PutUint32(b[28:], layoutMask(layout))
+		copy(b[32:], subFormatGUID[:])
+		le.PutUint16(b[32:], format)
+	}
+	return b
+}
 
-	if err = binary.Write(w, binary.LittleEndian, subChunkSize1); err != nil {
-		return err
-	}
-	if err = binary.Write(w, binary.LittleEndian, format); err != nil {
-		return err
-	}
-	if err = binary.Write(w, binary.LittleEndian, channels); err != nil {
-		return err
-	}
-	if err = binary.Write(w, binary.LittleEndian, frameRate); err != nil {
-		return err
-	}
-	if err = binary.Write(w, binary.LittleEndian, bytesPerSecond); err != nil {
-		return err
-	}
-	if err = binary.Write(w, binary.LittleEndian, blockAlign); err != nil {
-		return err
-	}
-	if err = binary.Write(w, binary.LittleEndian, bitsPerSample); err != nil {
+// Write PCM wave to writer w. the header is WAVE_FORMAT_EXTENSIBLE for more than 2 tracks
+// or more than 16 bits, the channel mask is from the layout if wave is a LayoutReader.
+// the samples are streamed by Writer. if w is not an io.WriteSeeker, the sizes of header
+// are exact only if wave is a Block, otherwise they are the largest, as the frames
+// reported by decoders may be estimated. the data larger than 4GiB is written as RF64.
+func Write(w io.Writer, wave Reader) (err error) {
+	x, err := NewWriter(w, wave.SampleType(), wave.NumTracks(), wave.Frequency())
+	if err != nil {
 		return err
 	}
-	// 	if err = binary.Write(w, binary.LittleEndian, cbSize); err != nil {
-	// 		return err
-	// 	}
+	return x.writeWave(wave)
+}
 
-	if _, err = w.Write(wavChunkData[:]); err != nil {
-		return err
-	}
-	if err = binary.Write(w, binary.LittleEndian, subChunkSize2); err != nil {
-		return err
+// writeWave streams wave of the format of x, and closes x
+func (x *Writer) writeWave(wave Reader) (err error) {
+	if lr, ok := wave.(LayoutReader); ok {
+		x.SetLayout(lr.Layout())
+	}
+	if b, ok := wave.(*Block); ok && x.ws == nil {
+		x.size = 0
+		if n := int64(len(b.buf)) - b.i; n > 0 {
+			x.size = n
+		}
 	}
-	if _, err = w.Write(buf); err != nil {
+	if _, err = io.Copy(x, wave); err != nil {
 		return err
 	}
-	if padded {
-		var zero [1]byte
-		if _, err = w.Write(zero[:]); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return x.Close()
 }
 
 // WriteFile write PCM wave to file
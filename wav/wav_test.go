@@ -2,9 +2,11 @@ package wav
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"math/rand"
 	"testing"
+	"time"
 )
 
 func TestReadWrite(t *testing.T) {
@@ -42,3 +44,106 @@ func TestReadWrite(t *testing.T) {
 		}
 	}
 }
+
+func TestSpeaker(t *testing.T) {
+	layout := []Speaker{SpeakerFrontLeft, SpeakerFrontRight, SpeakerFrontCenter,
+		SpeakerLowFrequency, SpeakerBackLeft, SpeakerBackRight}
+	if mask := ChannelMask(layout); mask != 0x3f {
+		t.Fatalf("5.1 channel mask %#x", mask)
+	}
+	if s := (SpeakerSideLeft | SpeakerSideRight).String(); s != "SL|SR" {
+		t.Fatal(s)
+	}
+}
+
+func TestSampleTypes(t *testing.T) {
+	for _, typ := range []Type{U8, I16, I24, I32, F32, F64} {
+		size := typ.Bits() / 8
+		data := make([]byte, 2*size*100)
+		for i := range data {
+			data[i] = byte(rand.Intn(256))
+		}
+		file := bytes.NewBuffer(nil)
+		if err := Write(file, NewBlock(data, 2, typ, 8000)); err != nil {
+			t.Fatal(err)
+		}
+		x, err := NewReader(bytes.NewReader(file.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", typ, err)
+		}
+		if x.SampleType() != typ || x.Duration() != 100*time.Second/8000 {
+			t.Fatalf("%s: read as %s, duration %v", typ, x.SampleType(), x.Duration())
+		}
+		data2, err := io.ReadAll(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, data2) {
+			t.Fatalf("%s: data miss-match", typ)
+		}
+	}
+}
+
+type layoutBlock struct {
+	*Block
+	layout []Speaker
+}
+
+func (b layoutBlock) Layout() []Speaker {
+	return b.layout
+}
+
+func TestExtensible(t *testing.T) {
+	// 5.1 of 24 bits, the default layout
+	data := make([]byte, 6*3*10)
+	file := bytes.NewBuffer(nil)
+	if err := Write(file, NewBlock(data, 6, I24, 48000)); err != nil {
+		t.Fatal(err)
+	}
+	b := file.Bytes()
+	if u16(b[20:]) != formatExtensible || u32(b[40:]) != 0x3f {
+		t.Fatalf("format %#x, channel mask %#x", u16(b[20:]), u32(b[40:]))
+	}
+	x, err := NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(x.(LayoutReader).Layout()); s != "[FL FR FC LFE BL BR]" || x.SampleType() != I24 {
+		t.Fatalf("layout %s, %s", s, x.SampleType())
+	}
+
+	// the layout of source, 20 valid bits
+	layout := []Speaker{SpeakerFrontLeft, SpeakerFrontRight, SpeakerLowFrequency}
+	file.Reset()
+	if err = Write(file, layoutBlock{NewBlock(data[:3*3*10], 3, I24, 48000), layout}); err != nil {
+		t.Fatal(err)
+	}
+	b = file.Bytes()
+	b[38] = 20
+	x, err = NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(x.(LayoutReader).Layout()); s != "[FL FR LFE]" || x.(interface{ ValidBits() int }).ValidBits() != 20 {
+		t.Fatalf("layout %s", s)
+	}
+	pcm, err := io.ReadAll(x)
+	if err != nil || len(pcm) != 3*3*10 {
+		t.Fatalf("%d bytes, %v", len(pcm), err)
+	}
+
+	// the sub-format is not PCM
+	b[44] = 2
+	if _, err = NewReader(bytes.NewReader(b)); err != ErrFormat {
+		t.Fatalf("expect ErrFormat, got %v", err)
+	}
+
+	// stereo 16 bits is not extensible
+	file.Reset()
+	if err = Write(file, NewBlock(data[:40], 2, I16, 48000)); err != nil {
+		t.Fatal(err)
+	}
+	if u16(file.Bytes()[20:]) != formatPCM {
+		t.Fatal("stereo 16 bits is written as extensible")
+	}
+}
@@ -0,0 +1,223 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// the largest data of RIFF, the sizes are uint32. it is even, so the padded data with
+// the largest fmt chunk and the JUNK placeholder still fits
+const maxDataSize = 0xffffffff - 4 - (8 + ds64Size) - (8 + 40) - 8 - 1
+
+// ErrTooLarge is reported when the data written to a pipe outgrows 4GiB, the header can
+// not be upgraded to RF64
+var ErrTooLarge = errors.New("wav: data is too large")
+
+// Writer writes the wave file as the samples come. the header is written before the
+// first samples, the sizes in it are patched by Close if the underlying writer is an
+// io.WriteSeeker. otherwise the sizes are the largest, the readers take the data until
+// the end of file.
+//
+// for io.WriteSeeker, a JUNK chunk is reserved before the fmt chunk. if the data outgrows
+// 4GiB, Close turns the file into RF64, the JUNK chunk becomes the ds64 chunk.
+type Writer struct {
+	w      io.Writer
+	ws     io.WriteSeeker // nil if not seekable
+	begin  int64          // position of the header
+	t      Type
+	tracks int
+	freq   int
+	layout []Speaker
+	limit  int64 // the data larger than it is written as RF64, maxDataSize except in tests
+
+	size    int64 // the data size of header, -1 if not known
+	dataPos int64 // position of the data size, from the header
+	junkPos int64 // position of the JUNK placeholder, from the header, 0 if none
+	header  bool  // the header is written
+	n       int64 // bytes of data written
+	err     error
+}
+
+// NewWriter writes the wave of sample type t to w, see Write for the header
+func NewWriter(w io.Writer, t Type, tracks, freq int) (*Writer, error) {
+	if t.Bits() == 0 {
+		return nil, fmt.Errorf("wav: unsupported sample type %s", t)
+	}
+	if tracks <= 0 || tracks > 0xffff || freq <= 0 {
+		return nil, fmt.Errorf("wav: bad format, %d tracks %dHz", tracks, freq)
+	}
+	x := &Writer{w: w, t: t, tracks: tracks, freq: freq, limit: maxDataSize, size: -1}
+	if ws, ok := w.(io.WriteSeeker); ok {
+		if pos, err := ws.Seek(0, io.SeekCurrent); err == nil {
+			x.ws, x.begin = ws, pos
+		}
+	}
+	return x, nil
+}
+
+// SetLayout sets the speakers of tracks for the channel mask, it must be called before
+// the first Write
+func (x *Writer) SetLayout(layout []Speaker) {
+	x.layout = layout
+}
+
+// SampleType reports sample's data type
+func (x *Writer) SampleType() Type {
+	return x.t
+}
+
+// Frequency reports the sample frequency
+func (x *Writer) Frequency() int {
+	return x.freq
+}
+
+// NumTracks reports track count
+func (x *Writer) NumTracks() int {
+	return x.tracks
+}
+
+// Frames reports the frames written
+func (x *Writer) Frames() int64 {
+	return x.n / int64(x.tracks*x.t.Bits()/8)
+}
+
+func (x *Writer) writeHeader() error {
+	x.header = true
+	fmtChunk := formatChunk(x.t, x.tracks, x.freq, x.layout)
+	size := x.size
+	if size < 0 {
+		size = maxDataSize
+	}
+	le := binary.LittleEndian
+	b := make([]byte, 0, 12+8+ds64Size+len(fmtChunk)+8)
+	b = append(b, riffMagic[:]...)
+	b = append(b, 0, 0, 0, 0)
+	b = append(b, waveMagic[:]...)
+	rf64 := x.size > x.limit
+	switch {
+	case rf64:
+		// the size is known, so the ds64 chunk is written at once
+		riffSize := 4 + 8 + ds64Size + int64(len(fmtChunk)) + 8 + size + size&1
+		copy(b, rf64Magic[:])
+		b = appendDS64(b, riffSize, size, size/int64(x.tracks*x.t.Bits()/8))
+	case x.size < 0 && x.ws != nil:
+		x.junkPos = int64(len(b))
+		b = append(b, junkChunk[:]...)
+		b = append(b, ds64Size, 0, 0, 0)
+		b = append(b, make([]byte, ds64Size)...)
+	}
+	b = append(b, fmtChunk...)
+	b = append(b, wavChunkData[:]...)
+	x.dataPos = int64(len(b))
+	b = append(b, 0, 0, 0, 0)
+	if rf64 {
+		le.PutUint32(b[4:], 0xffffffff)
+		le.PutUint32(b[x.dataPos:], 0xffffffff)
+	} else {
+		le.PutUint32(b[4:], uint32(len(b)-8)+uint32(size+size&1))
+		le.PutUint32(b[x.dataPos:], uint32(size))
+	}
+	_, err := x.w.Write(b)
+	return err
+}
+
+// Write the samples, they are interleaved tracks of the sample type
+func (x *Writer) Write(p []byte) (n int, err error) {
+	if x.err != nil {
+		return 0, x.err
+	}
+	if !x.header {
+		if x.err = x.writeHeader(); x.err != nil {
+			return 0, x.err
+		}
+	}
+	if x.size < 0 && x.ws == nil && x.n+int64(len(p)) > x.limit {
+		x.err = ErrTooLarge // the header of pipe can not be upgraded
+		return 0, x.err
+	}
+	if x.size >= 0 && x.n+int64(len(p)) > x.size {
+		// the part fits the header is written, so the file is still valid
+		n, x.err = x.w.Write(p[:x.size-x.n])
+		x.n += int64(n)
+		if x.err == nil {
+			x.err = fmt.Errorf("wav: data is larger than %d bytes in header", x.size)
+		}
+		return n, x.err
+	}
+	n, x.err = x.w.Write(p)
+	x.n += int64(n)
+	return n, x.err
+}
+
+// Close finishes the file, the sizes of header are patched if the underlying writer is
+// an io.WriteSeeker. it does not close the underlying writer.
+func (x *Writer) Close() error {
+	if x.err != nil {
+		return x.err
+	}
+	if !x.header {
+		if x.err = x.writeHeader(); x.err != nil {
+			return x.err
+		}
+	}
+	x.err = errors.New("wav: writer is closed")
+	if x.size < 0 && x.ws == nil {
+		return nil // the data takes the rest of file, no padding
+	}
+	if x.n&1 != 0 {
+		if _, err := x.w.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+	if x.size >= 0 {
+		if x.n != x.size {
+			return fmt.Errorf("wav: %d bytes of data written, %d in header", x.n, x.size)
+		}
+		return nil
+	}
+	end, err := x.ws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if x.n > x.limit {
+		err = x.upgrade(end - x.begin - 8)
+	} else {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], uint32(end-x.begin-8))
+		if err = x.patch(4, b[:]); err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint32(b[:], uint32(x.n))
+		err = x.patch(x.dataPos, b[:])
+	}
+	if err != nil {
+		return err
+	}
+	_, err = x.ws.Seek(end, io.SeekStart)
+	return err
+}
+
+// upgrade turns the file into RF64, the JUNK placeholder is replaced by the ds64 chunk
+func (x *Writer) upgrade(riffSize int64) error {
+	var b []byte
+	b = append(b, rf64Magic[:]...)
+	b = append(b, 0xff, 0xff, 0xff, 0xff)
+	if err := x.patch(0, b); err != nil {
+		return err
+	}
+	if err := x.patch(x.junkPos, appendDS64(nil, riffSize, x.n, x.Frames())); err != nil {
+		return err
+	}
+	return x.patch(x.dataPos, b[4:])
+}
+
+// patch writes b at off from the header
+func (x *Writer) patch(off int64, b []byte) error {
+	if _, err := x.ws.Seek(x.begin+off, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := x.ws.Write(b)
+	return err
+}
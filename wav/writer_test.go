@@ -0,0 +1,233 @@
+package wav
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	data := make([]byte, 3*2*1001)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	name := filepath.Join(t.TempDir(), "test.wav")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.Write([]byte("junk")); err != nil {
+		t.Fatal(err)
+	}
+	x, err := NewWriter(f, I24, 2, 48000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(data); i += 1000 {
+		end := i + 1000
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, err = x.Write(data[i:end]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if x.Frames() != 1001 {
+		t.Fatalf("%d frames", x.Frames())
+	}
+	if err = x.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = b[4:]
+	if int(u32(b[4:]))+8 != len(b) {
+		t.Fatalf("riff size %d, file size %d", u32(b[4:]), len(b))
+	}
+	r, err := NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("%d bytes, %v", len(got), err)
+	}
+}
+
+func TestWriterPipe(t *testing.T) {
+	data := []byte{1, 2, 3, 4, 5, 6}
+	var buf bytes.Buffer
+	x, err := NewWriter(&buf, I16, 1, 8000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = x.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err = x.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = x.Write(data); err == nil {
+		t.Fatal("expect error after Close")
+	}
+	// the sizes are the largest, the data takes the rest of file
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("%x, %v", got, err)
+	}
+}
+
+// framesReader reports the frames, which may be an estimate, streamReader does not
+type (
+	framesReader struct {
+		*Block
+		frames int64
+	}
+	streamReader struct {
+		*Block
+	}
+)
+
+func (r framesReader) NumFrames() int64 {
+	return r.frames
+}
+
+func TestWriteStream(t *testing.T) {
+	data := make([]byte, 2*2*301)
+	for i := range data {
+		data[i] = byte(i * 5)
+	}
+	for _, c := range []struct {
+		wave Reader
+		size uint32
+	}{
+		{NewBlock(data, 2, I16, 8000), uint32(len(data))},
+		{framesReader{NewBlock(data, 2, I16, 8000), 301}, maxDataSize}, // may be estimated
+		{framesReader{NewBlock(data, 2, I16, 8000), 302}, maxDataSize},
+		{streamReader{NewBlock(data, 2, I16, 8000)}, maxDataSize},
+	} {
+		var buf bytes.Buffer
+		if err := Write(&buf, c.wave); err != nil {
+			t.Fatal(err)
+		}
+		b := buf.Bytes()
+		if size := u32(b[len(b)-len(data)-4:]); size != c.size {
+			t.Fatalf("%T: data size %d, want %d", c.wave, size, c.size)
+		}
+		r, err := NewReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("%T: %d bytes, %v", c.wave, len(got), err)
+		}
+	}
+
+	// the data can not overrun the size of header
+	var buf bytes.Buffer
+	x, err := NewWriter(&buf, I16, 2, 8000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x.size = 6
+	if n, err := x.Write(data[:4]); n != 4 || err != nil {
+		t.Fatalf("%d, %v", n, err)
+	}
+	if n, err := x.Write(data[4:8]); n != 2 || err == nil {
+		t.Fatalf("%d, %v", n, err)
+	}
+	if b := buf.Bytes(); u32(b[len(b)-6-4:]) != 6 {
+		t.Fatalf("data size %d", u32(b[len(b)-6-4:]))
+	}
+}
+
+func TestRF64(t *testing.T) {
+	const limit = 100 // the data larger than it is RF64
+	data := make([]byte, 4*101)
+	for i := range data {
+		data[i] = byte(i * 3)
+	}
+	check := func(b []byte) {
+		t.Helper()
+		if string(b[:4]) != "RF64" || u32(b[4:]) != 0xffffffff || string(b[12:16]) != "ds64" {
+			t.Fatalf("header %q", b[:16])
+		}
+		ds, err := parseDS64(b[20:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ds.riffSize != int64(len(b)-8) || ds.dataSize != int64(len(data)) || ds.sampleCount != 101 {
+			t.Fatalf("ds64 %+v, file size %d", ds, len(b))
+		}
+		for _, magic := range []string{"RF64", "BW64"} {
+			copy(b, magic)
+			r, err := NewReader(bytes.NewReader(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := r.(*wavReader).NumFrames(); n != 101 {
+				t.Fatalf("%d frames", n)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil || !bytes.Equal(got, data) {
+				t.Fatalf("%d bytes, %v", len(got), err)
+			}
+		}
+	}
+
+	// upgraded from RIFF by Close
+	name := filepath.Join(t.TempDir(), "test.wav")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	x, err := NewWriter(f, I16, 2, 8000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x.limit = limit
+	if _, err = x.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err = x.Close(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(b)
+
+	// the size is known
+	var buf bytes.Buffer
+	if x, err = NewWriter(&buf, I16, 2, 8000); err != nil {
+		t.Fatal(err)
+	}
+	x.limit = limit
+	if err = x.writeWave(NewBlock(data, 2, I16, 8000)); err != nil {
+		t.Fatal(err)
+	}
+	check(buf.Bytes())
+
+	// the pipe can not be upgraded
+	buf.Reset()
+	if x, err = NewWriter(&buf, I16, 2, 8000); err != nil {
+		t.Fatal(err)
+	}
+	x.limit = limit
+	if _, err = x.Write(data); err != ErrTooLarge {
+		t.Fatalf("expect ErrTooLarge, got %v", err)
+	}
+}